@@ -14,3 +14,8 @@ const (
 type PacketDispatcher interface {
 	DispatchToOutbound(meta *proxy.InboundHandlerMeta, session *proxy.SessionInfo) ray.InboundRay
 }
+
+// BindDispatcher dispatches a BIND request to the outbound handler of its destination.
+type BindDispatcher interface {
+	DispatchBind(meta *proxy.InboundHandlerMeta, session *proxy.SessionInfo) (ray.InboundRay, *proxy.BindSession, error)
+}
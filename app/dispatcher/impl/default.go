@@ -2,6 +2,7 @@ package impl
 
 import (
 	"v2ray.com/core/app"
+	"v2ray.com/core/app/dns"
 	"v2ray.com/core/app/proxyman"
 	"v2ray.com/core/app/router"
 	"v2ray.com/core/common/alloc"
@@ -12,8 +13,9 @@ import (
 )
 
 type DefaultDispatcher struct {
-	ohm    proxyman.OutboundHandlerManager
-	router router.Router
+	ohm       proxyman.OutboundHandlerManager
+	router    router.Router
+	dnsServer dns.Server
 }
 
 func NewDefaultDispatcher(space app.Space) *DefaultDispatcher {
@@ -36,6 +38,10 @@ func (this *DefaultDispatcher) Initialize(space app.Space) error {
 		this.router = space.GetApp(router.APP_ID).(router.Router)
 	}
 
+	if space.HasApp(dns.APP_ID) {
+		this.dnsServer = space.GetApp(dns.APP_ID).(dns.Server)
+	}
+
 	return nil
 }
 
@@ -45,9 +51,40 @@ func (this *DefaultDispatcher) Release() {
 
 func (this *DefaultDispatcher) DispatchToOutbound(meta *proxy.InboundHandlerMeta, session *proxy.SessionInfo) ray.InboundRay {
 	direct := ray.NewRay()
-	dispatcher := this.ohm.GetDefaultHandler()
-	destination := session.Destination
+	destination := this.translateFakeIP(session.Destination)
+	dispatcher := this.pickHandler(destination)
+
+	if meta.AllowPassiveConnection {
+		go dispatcher.Dispatch(destination, alloc.NewLocalBuffer(32).Clear(), direct)
+	} else {
+		go this.FilterPacketAndDispatch(destination, direct, dispatcher)
+	}
 
+	return direct
+}
+
+// DispatchBind implements dispatcher.BindDispatcher. The request is refused if the outbound handler chosen for the
+// destination doesn't support BIND.
+func (this *DefaultDispatcher) DispatchBind(meta *proxy.InboundHandlerMeta, session *proxy.SessionInfo) (ray.InboundRay, *proxy.BindSession, error) {
+	destination := this.translateFakeIP(session.Destination)
+	binder, ok := this.pickHandler(destination).(proxy.Binder)
+	if !ok {
+		log.Info("DefaultDispatcher: BIND is not supported by the outbound handler for ", destination)
+		return nil, nil, proxy.ErrBindNotSupported
+	}
+	direct := ray.NewRay()
+	bindSession, err := binder.Bind(destination, direct)
+	if err != nil {
+		direct.OutboundInput().Release()
+		direct.OutboundOutput().Release()
+		return nil, nil, err
+	}
+	return direct, bindSession, nil
+}
+
+// pickHandler returns the outbound handler for the destination, as decided by the router.
+func (this *DefaultDispatcher) pickHandler(destination v2net.Destination) proxy.OutboundHandler {
+	dispatcher := this.ohm.GetDefaultHandler()
 	if this.router != nil {
 		if tag, err := this.router.TakeDetour(destination); err == nil {
 			if handler := this.ohm.GetHandler(tag); handler != nil {
@@ -60,14 +97,21 @@ func (this *DefaultDispatcher) DispatchToOutbound(meta *proxy.InboundHandlerMeta
 			log.Info("DefaultDispatcher: Default route for ", destination)
 		}
 	}
+	return dispatcher
+}
 
-	if meta.AllowPassiveConnection {
-		go dispatcher.Dispatch(destination, alloc.NewLocalBuffer(32).Clear(), direct)
-	} else {
-		go this.FilterPacketAndDispatch(destination, direct, dispatcher)
+// translateFakeIP replaces a fake IP handed out by the DNS server with its original domain.
+func (this *DefaultDispatcher) translateFakeIP(destination v2net.Destination) v2net.Destination {
+	if this.dnsServer == nil || destination.Address.Family().IsDomain() {
+		return destination
 	}
-
-	return direct
+	domain, found := this.dnsServer.LookupFakeIP(destination.Address.IP())
+	if !found {
+		return destination
+	}
+	log.Info("DefaultDispatcher: Translating fake IP ", destination.Address, " to ", domain)
+	destination.Address = v2net.DomainAddress(domain)
+	return destination
 }
 
 // Private: Visible for testing.
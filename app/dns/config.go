@@ -1,20 +1,71 @@
 package dns
 
 import (
-	"net"
+	"crypto/tls"
 
 	"v2ray.com/core/common/log"
 )
 
-func (this *Config) GetInternalHosts() map[string]net.IP {
-	hosts := make(map[string]net.IP)
+// BuildStaticHosts collects static hosts from Hosts, StaticHosts and HostsFiles.
+func (this *Config) BuildStaticHosts() (*StaticHosts, error) {
+	hosts := NewStaticHosts()
 	for domain, addressPB := range this.GetHosts() {
 		address := addressPB.AsAddress()
 		if address.Family().IsDomain() {
-			log.Warning("DNS: Ignoring domain address in static hosts: ", address.Domain())
+			hosts.SetAlias(domain, address.Domain())
 			continue
 		}
-		hosts[domain] = address.IP()
+		hosts.AddIPs(domain, address.IP())
+	}
+	for _, mapping := range this.GetStaticHosts() {
+		if len(mapping.Alias) > 0 {
+			hosts.SetAlias(mapping.Domain, mapping.Alias)
+			continue
+		}
+		for _, addressPB := range mapping.Ip {
+			address := addressPB.AsAddress()
+			if address.Family().IsDomain() {
+				log.Warning("DNS: Ignoring domain address in static hosts: ", address.Domain())
+				continue
+			}
+			hosts.AddIPs(mapping.Domain, address.IP())
+		}
+	}
+	for _, file := range this.HostsFiles {
+		if err := hosts.LoadFile(file); err != nil {
+			log.Error("DNS: Failed to import hosts file ", file, ": ", err)
+			return hosts, err
+		}
+	}
+	return hosts, nil
+}
+
+// GetNameServerConfigs returns all name servers in this config, including the plain ones in NameServers.
+func (this *Config) GetNameServerConfigs() []*NameServerConfig {
+	configs := make([]*NameServerConfig, 0, len(this.GetNameServers())+len(this.GetServers()))
+	for _, destPB := range this.GetNameServers() {
+		configs = append(configs, &NameServerConfig{
+			Address: destPB,
+		})
+	}
+	return append(configs, this.GetServers()...)
+}
+
+// TLSServerName returns the server name for certificate verification.
+func (this *NameServerConfig) TLSServerName() string {
+	if len(this.ServerName) > 0 {
+		return this.ServerName
+	}
+	address := this.Address.AsDestination().Address
+	if address.Family().IsDomain() {
+		return address.Domain()
+	}
+	return address.IP().String()
+}
+
+func (this *NameServerConfig) tlsConfig() *tls.Config {
+	return &tls.Config{
+		ServerName:         this.TLSServerName(),
+		InsecureSkipVerify: this.AllowInsecure,
 	}
-	return hosts
 }
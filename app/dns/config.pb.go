@@ -9,6 +9,8 @@ It is generated from these files:
 	v2ray.com/core/app/dns/config.proto
 
 It has these top-level messages:
+	NameServerConfig
+	HostMapping
 	Config
 */
 package dns
@@ -30,15 +32,145 @@ var _ = math.Inf
 // proto package needs to be updated.
 const _ = proto.ProtoPackageIsVersion2 // please upgrade the proto package
 
+type NameServerConfig_Protocol int32
+
+const (
+	// Plain DNS over UDP or TCP, as per the network of the address.
+	NameServerConfig_Plain NameServerConfig_Protocol = 0
+	// DNS over TLS.
+	NameServerConfig_TLS NameServerConfig_Protocol = 1
+	// DNS over HTTPS.
+	NameServerConfig_HTTPS NameServerConfig_Protocol = 2
+)
+
+var NameServerConfig_Protocol_name = map[int32]string{
+	0: "Plain",
+	1: "TLS",
+	2: "HTTPS",
+}
+var NameServerConfig_Protocol_value = map[string]int32{
+	"Plain": 0,
+	"TLS":   1,
+	"HTTPS": 2,
+}
+
+func (x NameServerConfig_Protocol) String() string {
+	return proto.EnumName(NameServerConfig_Protocol_name, int32(x))
+}
+func (NameServerConfig_Protocol) EnumDescriptor() ([]byte, []int) { return fileDescriptor0, []int{0, 0} }
+
+type Config_QueryStrategy int32
+
+const (
+	// Name servers are queried one after another, until one of them answers.
+	Config_Sequential Config_QueryStrategy = 0
+	// All name servers are queried at the same time, and the first answer is taken.
+	Config_Parallel Config_QueryStrategy = 1
+	// The first name server is queried first. Others are queried as well if it doesn't answer in head_start.
+	Config_PreferFirst Config_QueryStrategy = 2
+)
+
+var Config_QueryStrategy_name = map[int32]string{
+	0: "Sequential",
+	1: "Parallel",
+	2: "PreferFirst",
+}
+var Config_QueryStrategy_value = map[string]int32{
+	"Sequential":  0,
+	"Parallel":    1,
+	"PreferFirst": 2,
+}
+
+func (x Config_QueryStrategy) String() string {
+	return proto.EnumName(Config_QueryStrategy_name, int32(x))
+}
+func (Config_QueryStrategy) EnumDescriptor() ([]byte, []int) { return fileDescriptor0, []int{2, 0} }
+
+type NameServerConfig struct {
+	Address  *v2ray_core_common_net2.DestinationPB `protobuf:"bytes,1,opt,name=address" json:"address,omitempty"`
+	Protocol NameServerConfig_Protocol             `protobuf:"varint,2,opt,name=protocol,enum=v2ray.core.app.dns.NameServerConfig_Protocol" json:"protocol,omitempty"`
+	// URL of the DNS over HTTPS endpoint.
+	Url string `protobuf:"bytes,3,opt,name=url" json:"url,omitempty"`
+	// Server name for certificate verification. Defaults to the host of the address.
+	ServerName    string `protobuf:"bytes,4,opt,name=server_name,json=serverName" json:"server_name,omitempty"`
+	AllowInsecure bool   `protobuf:"varint,5,opt,name=allow_insecure,json=allowInsecure" json:"allow_insecure,omitempty"`
+	// Domains served by this name server, in the same syntax as domain rules of the router. If not empty, the name
+	// server is only queried for matching domains, before any other name server.
+	Domains []string `protobuf:"bytes,6,rep,name=domains" json:"domains,omitempty"`
+	// Timeout of queries in milliseconds. Defaults to 8 seconds.
+	Timeout uint32 `protobuf:"varint,7,opt,name=timeout" json:"timeout,omitempty"`
+	// EDNS Client Subnet of queries to this name server. It overrides client_subnet of Config.
+	ClientSubnet string `protobuf:"bytes,8,opt,name=client_subnet,json=clientSubnet" json:"client_subnet,omitempty"`
+}
+
+func (m *NameServerConfig) Reset()                    { *m = NameServerConfig{} }
+func (m *NameServerConfig) String() string            { return proto.CompactTextString(m) }
+func (*NameServerConfig) ProtoMessage()               {}
+func (*NameServerConfig) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{0} }
+
+func (m *NameServerConfig) GetAddress() *v2ray_core_common_net2.DestinationPB {
+	if m != nil {
+		return m.Address
+	}
+	return nil
+}
+
+type HostMapping struct {
+	// Domain pattern. It is a domain for exact match, a domain prefixed with "." which matches the domain and all its
+	// subdomains, or a pattern where "*" matches any characters, such as "*.example.com".
+	Domain string                             `protobuf:"bytes,1,opt,name=domain" json:"domain,omitempty"`
+	Ip     []*v2ray_core_common_net.AddressPB `protobuf:"bytes,2,rep,name=ip" json:"ip,omitempty"`
+	// Domain that the matching domains are aliases of. If set, ip is ignored.
+	Alias string `protobuf:"bytes,3,opt,name=alias" json:"alias,omitempty"`
+}
+
+func (m *HostMapping) Reset()                    { *m = HostMapping{} }
+func (m *HostMapping) String() string            { return proto.CompactTextString(m) }
+func (*HostMapping) ProtoMessage()               {}
+func (*HostMapping) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{1} }
+
+func (m *HostMapping) GetIp() []*v2ray_core_common_net.AddressPB {
+	if m != nil {
+		return m.Ip
+	}
+	return nil
+}
+
 type Config struct {
-	NameServers []*v2ray_core_common_net2.DestinationPB     `protobuf:"bytes,1,rep,name=NameServers,json=nameServers" json:"NameServers,omitempty"`
-	Hosts       map[string]*v2ray_core_common_net.AddressPB `protobuf:"bytes,2,rep,name=Hosts,json=hosts" json:"Hosts,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	NameServers []*v2ray_core_common_net2.DestinationPB `protobuf:"bytes,1,rep,name=NameServers,json=nameServers" json:"NameServers,omitempty"`
+	// Static hosts from domain patterns to IPs. A domain address makes the domain an alias of it.
+	Hosts map[string]*v2ray_core_common_net.AddressPB `protobuf:"bytes,2,rep,name=Hosts,json=hosts" json:"Hosts,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	// Name servers with extended settings. They are used after NameServers.
+	Servers       []*NameServerConfig  `protobuf:"bytes,3,rep,name=Servers,json=servers" json:"Servers,omitempty"`
+	QueryStrategy Config_QueryStrategy `protobuf:"varint,4,opt,name=query_strategy,json=queryStrategy,enum=v2ray.core.app.dns.Config_QueryStrategy" json:"query_strategy,omitempty"`
+	// Head start in milliseconds of the first name server in PreferFirst strategy. Defaults to 200 milliseconds.
+	HeadStart uint32 `protobuf:"varint,5,opt,name=head_start,json=headStart" json:"head_start,omitempty"`
+	// CIDR of fake IPs, such as "198.18.0.0/15". If set, DNS clients served by V2Ray get fake IPs for domains, which
+	// are translated back to the domains before routing. IPs are handed out in turn, and once the range is used up, the
+	// oldest IP is handed out again for another domain, even if a client still uses it. The range should be large enough
+	// to outlast the connections and DNS caches of the clients.
+	FakeIpRange string `protobuf:"bytes,6,opt,name=fake_ip_range,json=fakeIpRange" json:"fake_ip_range,omitempty"`
+	// TTL in seconds of negative records, i.e. failed or empty answers. Defaults to 60 seconds.
+	NegativeTtl uint32 `protobuf:"varint,7,opt,name=negative_ttl,json=negativeTtl" json:"negative_ttl,omitempty"`
+	// How long in seconds an expired record may still be served while it is being refreshed. 0 disables serving
+	// stale records. Negative records are never served stale.
+	StaleTtl uint32 `protobuf:"varint,8,opt,name=stale_ttl,json=staleTtl" json:"stale_ttl,omitempty"`
+	// Whether to refresh frequently used records before they expire.
+	Prefetch bool `protobuf:"varint,9,opt,name=prefetch" json:"prefetch,omitempty"`
+	// File to save the cache to, so that it survives restarts.
+	CacheFile string `protobuf:"bytes,10,opt,name=cache_file,json=cacheFile" json:"cache_file,omitempty"`
+	// Static hosts in addition to Hosts.
+	StaticHosts []*HostMapping `protobuf:"bytes,11,rep,name=static_hosts,json=staticHosts" json:"static_hosts,omitempty"`
+	// Files in the format of /etc/hosts to import static hosts from.
+	HostsFiles []string `protobuf:"bytes,12,rep,name=hosts_files,json=hostsFiles" json:"hosts_files,omitempty"`
+	// EDNS Client Subnet of queries, either a subnet such as "1.2.3.0/24", or "auto" for the subnet of DNS clients.
+	ClientSubnet string `protobuf:"bytes,13,opt,name=client_subnet,json=clientSubnet" json:"client_subnet,omitempty"`
 }
 
 func (m *Config) Reset()                    { *m = Config{} }
 func (m *Config) String() string            { return proto.CompactTextString(m) }
 func (*Config) ProtoMessage()               {}
-func (*Config) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{0} }
+func (*Config) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{2} }
 
 func (m *Config) GetNameServers() []*v2ray_core_common_net2.DestinationPB {
 	if m != nil {
@@ -54,29 +186,76 @@ func (m *Config) GetHosts() map[string]*v2ray_core_common_net.AddressPB {
 	return nil
 }
 
+func (m *Config) GetServers() []*NameServerConfig {
+	if m != nil {
+		return m.Servers
+	}
+	return nil
+}
+
+func (m *Config) GetStaticHosts() []*HostMapping {
+	if m != nil {
+		return m.StaticHosts
+	}
+	return nil
+}
+
 func init() {
+	proto.RegisterType((*NameServerConfig)(nil), "v2ray.core.app.dns.NameServerConfig")
+	proto.RegisterType((*HostMapping)(nil), "v2ray.core.app.dns.HostMapping")
 	proto.RegisterType((*Config)(nil), "v2ray.core.app.dns.Config")
+	proto.RegisterEnum("v2ray.core.app.dns.NameServerConfig_Protocol", NameServerConfig_Protocol_name, NameServerConfig_Protocol_value)
+	proto.RegisterEnum("v2ray.core.app.dns.Config_QueryStrategy", Config_QueryStrategy_name, Config_QueryStrategy_value)
 }
 
 func init() { proto.RegisterFile("v2ray.com/core/app/dns/config.proto", fileDescriptor0) }
 
 var fileDescriptor0 = []byte{
-	// 270 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x09, 0x6e, 0x88, 0x02, 0xff, 0x74, 0x90, 0xcb, 0x4a, 0xc4, 0x30,
-	0x14, 0x86, 0x49, 0x4b, 0x07, 0x4c, 0x37, 0x92, 0x85, 0x94, 0xae, 0x8a, 0x17, 0x2c, 0x0a, 0x09,
-	0x54, 0x10, 0xd1, 0x95, 0xf5, 0x82, 0x2b, 0x29, 0x75, 0x37, 0xbb, 0xd8, 0x1c, 0xb5, 0x68, 0x4f,
-	0x4a, 0x12, 0x0b, 0x7d, 0x64, 0xdf, 0x42, 0xda, 0x20, 0x15, 0x9d, 0xd9, 0x1d, 0xc2, 0xf7, 0x5f,
-	0xf2, 0xd3, 0x83, 0xa1, 0x30, 0x72, 0xe4, 0x8d, 0xee, 0x44, 0xa3, 0x0d, 0x08, 0xd9, 0xf7, 0x42,
-	0xa1, 0x15, 0x8d, 0xc6, 0x97, 0xf6, 0x95, 0xf7, 0x46, 0x3b, 0xcd, 0xd8, 0x0f, 0x64, 0x80, 0xcb,
-	0xbe, 0xe7, 0x0a, 0x6d, 0x7a, 0xfc, 0x47, 0xd8, 0xe8, 0xae, 0xd3, 0x28, 0x10, 0x9c, 0x90, 0x4a,
-	0x19, 0xb0, 0xd6, 0x8b, 0xd3, 0xd3, 0xed, 0xa0, 0x02, 0xeb, 0x5a, 0x94, 0xae, 0xd5, 0xe8, 0xe1,
-	0xfd, 0x2f, 0x42, 0x57, 0x37, 0x73, 0x34, 0xbb, 0xa7, 0xf1, 0xa3, 0xec, 0xe0, 0x09, 0xcc, 0x00,
-	0xc6, 0x26, 0x24, 0x0b, 0xf3, 0xb8, 0x38, 0xe4, 0xbf, 0xaa, 0x78, 0x27, 0x8e, 0xe0, 0xf8, 0xed,
-	0xe2, 0x54, 0x95, 0x75, 0x8c, 0x8b, 0x90, 0x5d, 0xd1, 0xe8, 0x41, 0x5b, 0x67, 0x93, 0x60, 0x76,
-	0x38, 0xe2, 0xff, 0x3f, 0xc3, 0x7d, 0x24, 0x9f, 0xb9, 0x3b, 0x74, 0x66, 0xac, 0xa3, 0xb7, 0xe9,
-	0x4e, 0xd7, 0x94, 0x2e, 0x8f, 0x6c, 0x97, 0x86, 0xef, 0x30, 0x26, 0x24, 0x23, 0xf9, 0x4e, 0x3d,
-	0x9d, 0xec, 0x9c, 0x46, 0x83, 0xfc, 0xf8, 0x84, 0x24, 0xc8, 0x48, 0x1e, 0x17, 0xd9, 0x96, 0x7a,
-	0xd7, 0x7e, 0x91, 0xaa, 0xac, 0x3d, 0x7e, 0x19, 0x5c, 0x90, 0xf2, 0x84, 0xee, 0x35, 0xba, 0xdb,
-	0x50, 0xa7, 0x8c, 0x7d, 0x9f, 0x6a, 0x9a, 0x64, 0x1d, 0x2a, 0xb4, 0xcf, 0xab, 0x79, 0x9e, 0xb3,
-	0xef, 0x00, 0x00, 0x00, 0xff, 0xff, 0x17, 0x9a, 0x1d, 0xee, 0xaf, 0x01, 0x00, 0x00,
+	// 733 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x09, 0x6e, 0x88, 0x02, 0xff, 0x8c, 0x54, 0xdf, 0x8f, 0xdb, 0x44,
+	0x10, 0xae, 0x6d, 0xf2, 0xc3, 0xe3, 0x38, 0x58, 0x2b, 0x54, 0x59, 0x87, 0x50, 0x4d, 0x4a, 0x85,
+	0x01, 0xe1, 0xa0, 0x20, 0x21, 0x04, 0x52, 0x25, 0x02, 0x9c, 0x7a, 0x12, 0x3f, 0x82, 0x73, 0x4f,
+	0x7d, 0xb1, 0xb6, 0xf6, 0x5c, 0xb2, 0xea, 0x7a, 0xed, 0xdb, 0xdd, 0x04, 0xe5, 0x95, 0x7f, 0x9b,
+	0x17, 0xb4, 0x6b, 0xa7, 0x39, 0x9a, 0xbb, 0xaa, 0x6f, 0x9e, 0x6f, 0xe7, 0xdb, 0x99, 0xfd, 0xe6,
+	0x1b, 0xc3, 0xd3, 0xfd, 0x42, 0xd2, 0x43, 0x56, 0x36, 0xf5, 0xbc, 0x6c, 0x24, 0xce, 0x69, 0xdb,
+	0xce, 0x2b, 0xa1, 0xe6, 0x65, 0x23, 0x6e, 0xd8, 0x26, 0x6b, 0x65, 0xa3, 0x1b, 0x42, 0x8e, 0x49,
+	0x12, 0x33, 0xda, 0xb6, 0x59, 0x25, 0xd4, 0xc5, 0xe7, 0x6f, 0x11, 0xcb, 0xa6, 0xae, 0x1b, 0x31,
+	0x17, 0xa8, 0xe7, 0xb4, 0xaa, 0x24, 0x2a, 0xd5, 0x91, 0x2f, 0xbe, 0x7a, 0x38, 0xb1, 0x42, 0xa5,
+	0x99, 0xa0, 0x9a, 0x35, 0xa2, 0x4b, 0x9e, 0xfd, 0xeb, 0x42, 0xf4, 0x07, 0xad, 0x71, 0x8d, 0x72,
+	0x8f, 0xf2, 0x67, 0xdb, 0x04, 0x79, 0x0e, 0xa3, 0xfe, 0xca, 0xd8, 0x49, 0x9c, 0x34, 0x58, 0x7c,
+	0x96, 0xdd, 0x69, 0xa8, 0xbb, 0x2f, 0x13, 0xa8, 0xb3, 0x5f, 0x4e, 0xf7, 0xad, 0x96, 0xf9, 0x91,
+	0x44, 0xae, 0x60, 0x6c, 0x6f, 0x2f, 0x1b, 0x1e, 0xbb, 0x89, 0x93, 0x4e, 0x17, 0x5f, 0x67, 0xe7,
+	0x2f, 0xca, 0xde, 0xae, 0x9b, 0xad, 0x7a, 0x52, 0xfe, 0x86, 0x4e, 0x22, 0xf0, 0x76, 0x92, 0xc7,
+	0x5e, 0xe2, 0xa4, 0x7e, 0x6e, 0x3e, 0xc9, 0x13, 0x08, 0x94, 0x25, 0x15, 0x82, 0xd6, 0x18, 0x7f,
+	0x60, 0x4f, 0xa0, 0x83, 0xcc, 0x8d, 0xe4, 0x19, 0x4c, 0x29, 0xe7, 0xcd, 0xdf, 0x05, 0x13, 0x0a,
+	0xcb, 0x9d, 0xc4, 0x78, 0x90, 0x38, 0xe9, 0x38, 0x0f, 0x2d, 0x7a, 0xd5, 0x83, 0x24, 0x86, 0x51,
+	0xd5, 0xd4, 0x94, 0x09, 0x15, 0x0f, 0x13, 0x2f, 0xf5, 0xf3, 0x63, 0x68, 0x4e, 0x34, 0xab, 0xb1,
+	0xd9, 0xe9, 0x78, 0x94, 0x38, 0x69, 0x98, 0x1f, 0x43, 0xf2, 0x14, 0xc2, 0x92, 0x33, 0x14, 0xba,
+	0x50, 0xbb, 0x57, 0x02, 0x75, 0x3c, 0xb6, 0xd5, 0x27, 0x1d, 0xb8, 0xb6, 0xd8, 0xec, 0x0b, 0x18,
+	0x1f, 0x1f, 0x42, 0x7c, 0x18, 0xac, 0x38, 0x65, 0x22, 0x7a, 0x44, 0x46, 0xe0, 0x5d, 0xff, 0xb6,
+	0x8e, 0x1c, 0x83, 0xbd, 0xb8, 0xbe, 0x5e, 0xad, 0x23, 0x77, 0x56, 0x43, 0xf0, 0xa2, 0x51, 0xfa,
+	0x77, 0xda, 0xb6, 0x4c, 0x6c, 0xc8, 0x63, 0x18, 0x76, 0x3d, 0x58, 0xd9, 0xfd, 0xbc, 0x8f, 0xc8,
+	0x37, 0xe0, 0xb2, 0x36, 0x76, 0x13, 0x2f, 0x0d, 0x16, 0xc9, 0x03, 0xa3, 0xf8, 0xa9, 0xd3, 0x7e,
+	0xb5, 0xcc, 0x5d, 0xd6, 0x92, 0x8f, 0x60, 0x40, 0x39, 0xa3, 0xaa, 0x17, 0xae, 0x0b, 0x66, 0xff,
+	0x0c, 0x61, 0xd8, 0x8f, 0xf8, 0x12, 0x82, 0x93, 0xfc, 0x66, 0xcc, 0xde, 0x7b, 0x8f, 0x39, 0x10,
+	0x27, 0x22, 0xf9, 0x11, 0x06, 0xe6, 0x05, 0xaa, 0xef, 0xee, 0xd9, 0x7d, 0x73, 0xee, 0xa7, 0x6b,
+	0xf3, 0x7e, 0x15, 0x5a, 0x1e, 0xf2, 0xc1, 0xd6, 0x7c, 0x1b, 0x9f, 0x1d, 0x1b, 0xf0, 0xce, 0x1b,
+	0x78, 0xc8, 0x26, 0xf9, 0x48, 0xf5, 0xc5, 0xff, 0x84, 0xe9, 0xed, 0x0e, 0xe5, 0xa1, 0x50, 0x5a,
+	0x52, 0x8d, 0x9b, 0x83, 0x75, 0xc3, 0x74, 0x91, 0xbe, 0xa3, 0x8b, 0xbf, 0x0c, 0x61, 0xdd, 0xe7,
+	0xe7, 0xe1, 0xed, 0xdd, 0x90, 0x7c, 0x02, 0xb0, 0x45, 0x5a, 0x15, 0x4a, 0x53, 0xa9, 0xad, 0x6d,
+	0xc2, 0xdc, 0x37, 0xc8, 0xda, 0x00, 0x64, 0x06, 0xe1, 0x0d, 0x7d, 0x8d, 0x05, 0x6b, 0x0b, 0x49,
+	0xc5, 0x06, 0xe3, 0xa1, 0x55, 0x37, 0x30, 0xe0, 0x55, 0x9b, 0x1b, 0x88, 0x7c, 0x0a, 0x13, 0x81,
+	0x1b, 0xaa, 0xd9, 0x1e, 0x0b, 0xad, 0x79, 0xef, 0xa0, 0xe0, 0x88, 0x5d, 0x6b, 0x4e, 0x3e, 0x06,
+	0x5f, 0x69, 0xca, 0xbb, 0xf3, 0xb1, 0x3d, 0x1f, 0x5b, 0xc0, 0x1c, 0x5e, 0x98, 0xdd, 0xc1, 0x1b,
+	0xd4, 0xe5, 0x36, 0xf6, 0xad, 0x6f, 0xdf, 0xc4, 0xa6, 0xbd, 0x92, 0x96, 0x5b, 0x2c, 0x6e, 0x18,
+	0xc7, 0x18, 0x6c, 0x71, 0xdf, 0x22, 0x97, 0x8c, 0x23, 0x59, 0xc2, 0x44, 0x69, 0xaa, 0x59, 0x59,
+	0x58, 0x79, 0xe3, 0xc0, 0x6a, 0xfa, 0xe4, 0x3e, 0x31, 0xee, 0xb8, 0x2e, 0x0f, 0x3a, 0x92, 0x1d,
+	0x8f, 0xd9, 0x2e, 0x4b, 0xb6, 0x25, 0x54, 0x3c, 0xb1, 0x9b, 0x01, 0x16, 0x32, 0x35, 0xd4, 0xf9,
+	0x0a, 0x84, 0xe7, 0x2b, 0x70, 0xf1, 0x12, 0xe0, 0x34, 0x6d, 0xb3, 0xc3, 0xaf, 0xf1, 0xd0, 0x7b,
+	0xda, 0x7c, 0x92, 0xef, 0x60, 0xb0, 0xa7, 0x7c, 0x87, 0xf6, 0xef, 0xf0, 0x3e, 0x9e, 0xee, 0xd2,
+	0x7f, 0x70, 0xbf, 0x77, 0x66, 0xcf, 0x21, 0xfc, 0xdf, 0x0c, 0xc9, 0x14, 0x60, 0x8d, 0xb7, 0x3b,
+	0x14, 0x9a, 0x51, 0x1e, 0x3d, 0x22, 0x13, 0x18, 0xaf, 0xa8, 0xa4, 0x9c, 0x23, 0x8f, 0x1c, 0xf2,
+	0x21, 0x04, 0x2b, 0xa3, 0x9f, 0xbc, 0x64, 0x52, 0xe9, 0xc8, 0x5d, 0x7e, 0x09, 0x8f, 0xcb, 0xa6,
+	0xbe, 0x47, 0x94, 0x65, 0xd0, 0x59, 0xc4, 0x2e, 0xef, 0x4b, 0xaf, 0x12, 0xea, 0xd5, 0xd0, 0xfe,
+	0x87, 0xbe, 0xfd, 0x6f, 0x00, 0x95, 0x03, 0xed, 0xff, 0xb5, 0x05, 0x00, 0x00,
 }
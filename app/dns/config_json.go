@@ -4,31 +4,224 @@ package dns
 
 import (
 	"encoding/json"
+	"errors"
+	"net"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
 
 	v2net "v2ray.com/core/common/net"
 )
 
+// parseNameServer parses a name server in the form of "[tcp://|udp://]address[:port]".
+func parseNameServer(server string) (*v2net.DestinationPB, error) {
+	network := v2net.Network_UDP
+	if strings.HasPrefix(server, "tcp://") {
+		network = v2net.Network_TCP
+		server = server[len("tcp://"):]
+	} else if strings.HasPrefix(server, "udp://") {
+		server = server[len("udp://"):]
+	}
+	return parseHostPort(server, network, 53)
+}
+
+func parseHostPort(server string, network v2net.Network, defaultPort uint32) (*v2net.DestinationPB, error) {
+	host := server
+	port := defaultPort
+	if h, p, err := net.SplitHostPort(server); err == nil {
+		portNum, err := strconv.ParseUint(p, 10, 16)
+		if err != nil {
+			return nil, errors.New("DNS: Invalid port of name server: " + server)
+		}
+		host = h
+		port = uint32(portNum)
+	}
+
+	return &v2net.DestinationPB{
+		Network: network,
+		Address: v2net.NewAddressPB(v2net.ParseAddress(host)),
+		Port:    port,
+	}, nil
+}
+
+// parseNameServerAddress parses a name server in the form of "tls://address[:port]", "https://address[:port]/path"
+// or any form accepted by parseNameServer.
+func parseNameServerAddress(server string) (*NameServerConfig, error) {
+	if strings.HasPrefix(server, "tls://") {
+		dest, err := parseHostPort(server[len("tls://"):], v2net.Network_TCP, 853)
+		if err != nil {
+			return nil, err
+		}
+		return &NameServerConfig{
+			Address:  dest,
+			Protocol: NameServerConfig_TLS,
+		}, nil
+	}
+
+	if strings.HasPrefix(server, "https://") {
+		serverURL, err := url.Parse(server)
+		if err != nil {
+			return nil, errors.New("DNS: Invalid URL of name server: " + server)
+		}
+		dest, err := parseHostPort(serverURL.Host, v2net.Network_TCP, 443)
+		if err != nil {
+			return nil, err
+		}
+		return &NameServerConfig{
+			Address:  dest,
+			Protocol: NameServerConfig_HTTPS,
+			Url:      server,
+		}, nil
+	}
+
+	dest, err := parseNameServer(server)
+	if err != nil {
+		return nil, err
+	}
+	return &NameServerConfig{
+		Address: dest,
+	}, nil
+}
+
+func (this *NameServerConfig) UnmarshalJSON(data []byte) error {
+	var address string
+	if err := json.Unmarshal(data, &address); err == nil {
+		config, err := parseNameServerAddress(address)
+		if err != nil {
+			return err
+		}
+		*this = *config
+		return nil
+	}
+
+	type JsonNameServer struct {
+		Address       string   `json:"address"`
+		ServerName    string   `json:"serverName"`
+		AllowInsecure bool     `json:"allowInsecure"`
+		Domains       []string `json:"domains"`
+		Timeout       uint32   `json:"timeout"`
+		ClientSubnet  string   `json:"clientSubnet"`
+	}
+	jsonConfig := new(JsonNameServer)
+	if err := json.Unmarshal(data, jsonConfig); err != nil {
+		return err
+	}
+	config, err := parseNameServerAddress(jsonConfig.Address)
+	if err != nil {
+		return err
+	}
+	config.ServerName = jsonConfig.ServerName
+	config.AllowInsecure = jsonConfig.AllowInsecure
+	config.Domains = jsonConfig.Domains
+	config.Timeout = jsonConfig.Timeout
+	if _, err := ParseClientSubnet(jsonConfig.ClientSubnet); err != nil {
+		return errors.New("DNS: Invalid client subnet: " + jsonConfig.ClientSubnet)
+	}
+	config.ClientSubnet = jsonConfig.ClientSubnet
+	*this = *config
+	return nil
+}
+
+// isPlain returns true if the name server can be represented by its destination alone.
+func (this *NameServerConfig) isPlain() bool {
+	return this.Protocol == NameServerConfig_Plain && len(this.ServerName) == 0 && !this.AllowInsecure &&
+		len(this.Domains) == 0 && this.Timeout == 0 && len(this.ClientSubnet) == 0
+}
+
 func (this *Config) UnmarshalJSON(data []byte) error {
 	type JsonConfig struct {
-		Servers []*v2net.AddressPB          `json:"servers"`
-		Hosts   map[string]*v2net.AddressPB `json:"hosts"`
+		Servers       []*NameServerConfig        `json:"servers"`
+		Hosts         map[string]json.RawMessage `json:"hosts"`
+		HostsFiles    []string                   `json:"hostsFiles"`
+		QueryStrategy string                     `json:"queryStrategy"`
+		HeadStart     uint32                     `json:"headStart"`
+		FakeIPRange   string                     `json:"fakeIpRange"`
+		NegativeTTL   uint32                     `json:"negativeTtl"`
+		StaleTTL      uint32                     `json:"staleTtl"`
+		Prefetch      bool                       `json:"prefetch"`
+		CacheFile     string                     `json:"cacheFile"`
+		ClientSubnet  string                     `json:"clientSubnet"`
 	}
 	jsonConfig := new(JsonConfig)
 	if err := json.Unmarshal(data, jsonConfig); err != nil {
 		return err
 	}
-	this.NameServers = make([]*v2net.DestinationPB, len(jsonConfig.Servers))
-	for idx, server := range jsonConfig.Servers {
-		this.NameServers[idx] = &v2net.DestinationPB{
-			Network: v2net.Network_UDP,
-			Address: server,
-			Port:    53,
+
+	switch strings.ToLower(jsonConfig.QueryStrategy) {
+	case "", "sequential":
+		this.QueryStrategy = Config_Sequential
+	case "parallel":
+		this.QueryStrategy = Config_Parallel
+	case "preferfirst":
+		this.QueryStrategy = Config_PreferFirst
+	default:
+		return errors.New("DNS: Unknown query strategy: " + jsonConfig.QueryStrategy)
+	}
+	this.HeadStart = jsonConfig.HeadStart
+	this.FakeIpRange = jsonConfig.FakeIPRange
+	this.NegativeTtl = jsonConfig.NegativeTTL
+	this.StaleTtl = jsonConfig.StaleTTL
+	this.Prefetch = jsonConfig.Prefetch
+	this.CacheFile = jsonConfig.CacheFile
+	if _, err := ParseClientSubnet(jsonConfig.ClientSubnet); err != nil {
+		return errors.New("DNS: Invalid client subnet: " + jsonConfig.ClientSubnet)
+	}
+	this.ClientSubnet = jsonConfig.ClientSubnet
+
+	allPlain := true
+	for _, server := range jsonConfig.Servers {
+		if server == nil {
+			return errors.New("DNS: Empty name server.")
+		}
+		if !server.isPlain() {
+			allPlain = false
+		}
+	}
+	// Servers are kept in NameServers when possible, for compatibility. Otherwise they all go to Servers
+	// to preserve their order.
+	if allPlain {
+		this.NameServers = make([]*v2net.DestinationPB, len(jsonConfig.Servers))
+		for idx, server := range jsonConfig.Servers {
+			this.NameServers[idx] = server.Address
 		}
+	} else {
+		this.Servers = jsonConfig.Servers
 	}
 
 	if jsonConfig.Hosts != nil {
-		this.Hosts = jsonConfig.Hosts
+		if err := this.parseHosts(jsonConfig.Hosts); err != nil {
+			return err
+		}
+	}
+	this.HostsFiles = jsonConfig.HostsFiles
+
+	return nil
+}
+
+// parseHosts parses static hosts. Each host is mapped to either an address, or a list of IPs.
+func (this *Config) parseHosts(hosts map[string]json.RawMessage) error {
+	domains := make([]string, 0, len(hosts))
+	for domain := range hosts {
+		domains = append(domains, domain)
 	}
+	sort.Strings(domains)
 
+	this.Hosts = make(map[string]*v2net.AddressPB)
+	for _, domain := range domains {
+		address := new(v2net.AddressPB)
+		if err := json.Unmarshal(hosts[domain], address); err == nil {
+			this.Hosts[domain] = address
+			continue
+		}
+		var ips []*v2net.AddressPB
+		if err := json.Unmarshal(hosts[domain], &ips); err != nil {
+			return errors.New("DNS: Invalid static host " + domain + ": " + err.Error())
+		}
+		this.StaticHosts = append(this.StaticHosts, &HostMapping{
+			Domain: domain,
+			Ip:     ips,
+		})
+	}
 	return nil
 }
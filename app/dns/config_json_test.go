@@ -27,3 +27,184 @@ func TestConfigParsing(t *testing.T) {
 	assert.Address(dest.Address).Equals(v2net.IPAddress([]byte{8, 8, 8, 8}))
 	assert.Port(dest.Port).Equals(v2net.Port(53))
 }
+
+func TestTCPNameServerParsing(t *testing.T) {
+	assert := assert.On(t)
+
+	rawJson := `{
+    "servers": ["tcp://8.8.4.4", "1.1.1.1:5353", "localhost"]
+  }`
+
+	config := new(Config)
+	err := json.Unmarshal([]byte(rawJson), config)
+	assert.Error(err).IsNil()
+	assert.Int(len(config.NameServers)).Equals(3)
+
+	dest := config.NameServers[0].AsDestination()
+	assert.Destination(dest).IsTCP()
+	assert.Address(dest.Address).Equals(v2net.IPAddress([]byte{8, 8, 4, 4}))
+	assert.Port(dest.Port).Equals(v2net.Port(53))
+
+	dest = config.NameServers[1].AsDestination()
+	assert.Destination(dest).IsUDP()
+	assert.Port(dest.Port).Equals(v2net.Port(5353))
+
+	dest = config.NameServers[2].AsDestination()
+	assert.Address(dest.Address).Equals(v2net.DomainAddress("localhost"))
+}
+
+func TestEncryptedNameServerParsing(t *testing.T) {
+	assert := assert.On(t)
+
+	rawJson := `{
+    "servers": [
+      "8.8.8.8",
+      "tls://1.1.1.1",
+      {"address": "tls://dns.example.com:8853", "serverName": "example.com"},
+      {"address": "https://dns.example.com/dns-query", "allowInsecure": true}
+    ]
+  }`
+
+	config := new(Config)
+	err := json.Unmarshal([]byte(rawJson), config)
+	assert.Error(err).IsNil()
+	assert.Int(len(config.NameServers)).Equals(0)
+	assert.Int(len(config.Servers)).Equals(4)
+
+	server := config.Servers[0]
+	assert.Bool(server.Protocol == NameServerConfig_Plain).IsTrue()
+	assert.Destination(server.Address.AsDestination()).IsUDP()
+
+	server = config.Servers[1]
+	assert.Bool(server.Protocol == NameServerConfig_TLS).IsTrue()
+	assert.Destination(server.Address.AsDestination()).IsTCP()
+	assert.Port(server.Address.AsDestination().Port).Equals(v2net.Port(853))
+	assert.String(server.TLSServerName()).Equals("1.1.1.1")
+
+	server = config.Servers[2]
+	assert.Port(server.Address.AsDestination().Port).Equals(v2net.Port(8853))
+	assert.String(server.TLSServerName()).Equals("example.com")
+
+	server = config.Servers[3]
+	assert.Bool(server.Protocol == NameServerConfig_HTTPS).IsTrue()
+	assert.Address(server.Address.AsDestination().Address).Equals(v2net.DomainAddress("dns.example.com"))
+	assert.Port(server.Address.AsDestination().Port).Equals(v2net.Port(443))
+	assert.String(server.Url).Equals("https://dns.example.com/dns-query")
+	assert.Bool(server.AllowInsecure).IsTrue()
+	assert.Int(len(config.GetNameServerConfigs())).Equals(4)
+}
+
+func TestDomainScopedNameServerParsing(t *testing.T) {
+	assert := assert.On(t)
+
+	rawJson := `{
+    "servers": [
+      "8.8.8.8",
+      {"address": "tcp://10.0.0.53", "domains": ["corp", "regexp:\\.internal$"]}
+    ]
+  }`
+
+	config := new(Config)
+	err := json.Unmarshal([]byte(rawJson), config)
+	assert.Error(err).IsNil()
+	assert.Int(len(config.Servers)).Equals(2)
+	assert.Int(len(config.Servers[0].Domains)).Equals(0)
+
+	server := config.Servers[1]
+	assert.Destination(server.Address.AsDestination()).IsTCP()
+	assert.Int(len(server.Domains)).Equals(2)
+	assert.String(server.Domains[1]).Equals("regexp:\\.internal$")
+}
+
+func TestQueryStrategyParsing(t *testing.T) {
+	assert := assert.On(t)
+
+	rawJson := `{
+    "servers": ["8.8.8.8", {"address": "1.1.1.1", "timeout": 2000}],
+    "queryStrategy": "preferFirst",
+    "headStart": 100,
+    "fakeIpRange": "198.18.0.0/15"
+  }`
+
+	config := new(Config)
+	err := json.Unmarshal([]byte(rawJson), config)
+	assert.Error(err).IsNil()
+	assert.Bool(config.QueryStrategy == Config_PreferFirst).IsTrue()
+	assert.Uint32(config.HeadStart).Equals(100)
+	assert.String(config.FakeIpRange).Equals("198.18.0.0/15")
+	assert.Int(len(config.Servers)).Equals(2)
+	assert.Uint32(config.Servers[1].Timeout).Equals(2000)
+
+	err = json.Unmarshal([]byte(`{"queryStrategy": "random"}`), new(Config))
+	assert.Error(err).IsNotNil()
+}
+
+func TestCacheOptionsParsing(t *testing.T) {
+	assert := assert.On(t)
+
+	rawJson := `{
+    "servers": ["8.8.8.8"],
+    "negativeTtl": 30,
+    "staleTtl": 600,
+    "prefetch": true,
+    "cacheFile": "/var/cache/v2ray/dns.json"
+  }`
+
+	config := new(Config)
+	err := json.Unmarshal([]byte(rawJson), config)
+	assert.Error(err).IsNil()
+	assert.Uint32(config.NegativeTtl).Equals(30)
+	assert.Uint32(config.StaleTtl).Equals(600)
+	assert.Bool(config.Prefetch).IsTrue()
+	assert.String(config.CacheFile).Equals("/var/cache/v2ray/dns.json")
+}
+
+func TestClientSubnetParsing(t *testing.T) {
+	assert := assert.On(t)
+
+	rawJson := `{
+    "servers": [
+      "8.8.8.8",
+      {"address": "1.1.1.1", "clientSubnet": "auto"}
+    ],
+    "clientSubnet": "1.2.3.0/24"
+  }`
+
+	config := new(Config)
+	err := json.Unmarshal([]byte(rawJson), config)
+	assert.Error(err).IsNil()
+	assert.String(config.ClientSubnet).Equals("1.2.3.0/24")
+	assert.Int(len(config.Servers)).Equals(2)
+	assert.String(config.Servers[0].ClientSubnet).Equals("")
+	assert.String(config.Servers[1].ClientSubnet).Equals("auto")
+
+	err = json.Unmarshal([]byte(`{"clientSubnet": "1.2.3.0/33"}`), new(Config))
+	assert.Error(err).IsNotNil()
+}
+
+func TestStaticHostsParsing(t *testing.T) {
+	assert := assert.On(t)
+
+	rawJson := `{
+    "hosts": {
+      "v2ray.com": "10.0.0.1",
+      "www.v2ray.com": "v2ray.com",
+      "*.corp": ["10.0.0.2", "fd00::2"]
+    },
+    "hostsFiles": ["/etc/hosts"]
+  }`
+
+	config := new(Config)
+	err := json.Unmarshal([]byte(rawJson), config)
+	assert.Error(err).IsNil()
+	assert.Int(len(config.Hosts)).Equals(2)
+	assert.Address(config.Hosts["v2ray.com"].AsAddress()).Equals(v2net.IPAddress([]byte{10, 0, 0, 1}))
+	assert.Address(config.Hosts["www.v2ray.com"].AsAddress()).Equals(v2net.DomainAddress("v2ray.com"))
+	assert.Int(len(config.StaticHosts)).Equals(1)
+	assert.String(config.StaticHosts[0].Domain).Equals("*.corp")
+	assert.Int(len(config.StaticHosts[0].Ip)).Equals(2)
+	assert.Int(len(config.HostsFiles)).Equals(1)
+
+	err = json.Unmarshal([]byte(`{"hosts": {"v2ray.com": 1}}`), new(Config))
+	assert.Error(err).IsNotNil()
+}
@@ -0,0 +1,86 @@
+package dns
+
+import (
+	"io"
+	"net"
+	"time"
+
+	"v2ray.com/core/common/alloc"
+	v2io "v2ray.com/core/common/io"
+	v2net "v2ray.com/core/common/net"
+	"v2ray.com/core/transport/ray"
+)
+
+// rayConnection is a net.Conn on top of an InboundRay, so that DNS queries can be sent by the dispatcher
+// over any outbound, with standard libraries for TLS and HTTP.
+type rayConnection struct {
+	ray    ray.InboundRay
+	reader *v2io.ChanReader
+	dest   v2net.Destination
+}
+
+func newRayConnection(inboundRay ray.InboundRay, dest v2net.Destination) *rayConnection {
+	return &rayConnection{
+		ray:  inboundRay,
+		dest: dest,
+	}
+}
+
+func (this *rayConnection) Read(b []byte) (int, error) {
+	if this.reader == nil {
+		this.reader = v2io.NewChanReader(this.ray.InboundOutput())
+	}
+	return this.reader.Read(b)
+}
+
+func (this *rayConnection) Write(b []byte) (int, error) {
+	for written := 0; written < len(b); {
+		buffer := alloc.NewBuffer().Clear()
+		n := len(b) - written
+		if n > alloc.BufferSize {
+			n = alloc.BufferSize
+		}
+		buffer.Append(b[written : written+n])
+		if err := this.ray.InboundInput().Write(buffer); err != nil {
+			buffer.Release()
+			return written, io.ErrClosedPipe
+		}
+		written += n
+	}
+	return len(b), nil
+}
+
+func (this *rayConnection) Close() error {
+	this.ray.InboundInput().Close()
+	this.ray.InboundOutput().Release()
+	return nil
+}
+
+func (this *rayConnection) LocalAddr() net.Addr {
+	return &net.TCPAddr{
+		IP:   []byte{127, 0, 0, 1},
+		Port: int(pseudoDestination.Port),
+	}
+}
+
+func (this *rayConnection) RemoteAddr() net.Addr {
+	addr := &net.TCPAddr{
+		Port: int(this.dest.Port),
+	}
+	if !this.dest.Address.Family().IsDomain() {
+		addr.IP = this.dest.Address.IP()
+	}
+	return addr
+}
+
+func (this *rayConnection) SetDeadline(t time.Time) error {
+	return nil
+}
+
+func (this *rayConnection) SetReadDeadline(t time.Time) error {
+	return nil
+}
+
+func (this *rayConnection) SetWriteDeadline(t time.Time) error {
+	return nil
+}
@@ -2,6 +2,7 @@ package dns
 
 import (
 	"net"
+	"time"
 
 	"v2ray.com/core/app"
 )
@@ -10,7 +11,83 @@ const (
 	APP_ID = app.ID(2)
 )
 
+// IPOption specifies which IP families are returned for a domain, and in which order.
+type IPOption int
+
+const (
+	// IPv4AndIPv6 returns all IPv4 addresses followed by all IPv6 addresses.
+	IPv4AndIPv6 = IPOption(0)
+	// IPv4Only returns IPv4 addresses only.
+	IPv4Only = IPOption(1)
+	// IPv6Only returns IPv6 addresses only.
+	IPv6Only = IPOption(2)
+	// PreferIPv4 returns IPv4 addresses if there are any, otherwise IPv6 addresses.
+	PreferIPv4 = IPOption(3)
+	// PreferIPv6 returns IPv6 addresses if there are any, otherwise IPv4 addresses.
+	PreferIPv6 = IPOption(4)
+)
+
+func (this IPOption) WantIPv4() bool {
+	return this != IPv6Only
+}
+
+func (this IPOption) WantIPv6() bool {
+	return this != IPv4Only
+}
+
+// Apply picks the IPs from the given IPv4 and IPv6 lists according to this option.
+func (this IPOption) Apply(ipv4 []net.IP, ipv6 []net.IP) []net.IP {
+	switch this {
+	case IPv4Only:
+		return ipv4
+	case IPv6Only:
+		return ipv6
+	case PreferIPv4:
+		if len(ipv4) > 0 {
+			return ipv4
+		}
+		return ipv6
+	case PreferIPv6:
+		if len(ipv6) > 0 {
+			return ipv6
+		}
+		return ipv4
+	default:
+		ips := make([]net.IP, 0, len(ipv4)+len(ipv6))
+		ips = append(ips, ipv4...)
+		return append(ips, ipv6...)
+	}
+}
+
+// An Answer is the result of resolving a domain on behalf of a DNS client.
+type Answer struct {
+	IPs []net.IP
+	// TTL is the remaining time that the answer may be cached, or 0 if it doesn't come from name servers, e.g. from
+	// static hosts.
+	TTL time.Duration
+	// Rcode is the DNS response code, e.g. dns.RcodeNameError if the domain doesn't exist, or
+	// dns.RcodeServerFailure if name servers failed to answer.
+	Rcode int
+}
+
 // A DnsCache is an internal cache of DNS resolutions.
 type Server interface {
-	Get(domain string) []net.IP
+	// Resolve returns the addresses of the domain as specified by the option, with the TTL and response code of the
+	// answer. The client is the DNS client that the query is made on behalf of, or nil if there is none.
+	Resolve(domain string, option IPOption, client net.IP) *Answer
+
+	// Watch registers a watcher which is notified when the resolved IPs of a domain change.
+	Watch(watcher RecordWatcher)
+
+	// FakeIP returns a fake IP for the domain, or nil if fake IPs are disabled.
+	FakeIP(domain string) net.IP
+
+	// LookupFakeIP returns the domain that the fake IP was handed out for.
+	LookupFakeIP(ip net.IP) (string, bool)
+
+	// LookupDomain returns the domain that was recently resolved to the IP, while its answer is still valid.
+	LookupDomain(ip net.IP) (string, bool)
 }
+
+// A RecordWatcher is called with the domain whose resolved IPs have changed.
+type RecordWatcher func(domain string)
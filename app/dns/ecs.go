@@ -0,0 +1,106 @@
+package dns
+
+import (
+	"errors"
+	"net"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+const (
+	// ClientSubnetAuto derives the client subnet from the source address of DNS clients.
+	ClientSubnetAuto = "auto"
+
+	// Prefix lengths of client subnets derived from source addresses, as recommended by RFC 7871.
+	DefaultIPv4SubnetPrefix = 24
+	DefaultIPv6SubnetPrefix = 56
+)
+
+var (
+	ErrInvalidClientSubnet = errors.New("DNS: Invalid client subnet.")
+)
+
+// ClientSubnet decides the EDNS Client Subnet (RFC 7871) of queries, which is either a fixed subnet, or derived from
+// the address of the DNS client.
+type ClientSubnet struct {
+	subnet *net.IPNet
+}
+
+// ParseClientSubnet parses a client subnet in CIDR notation, or "auto". It returns nil if the string is empty.
+func ParseClientSubnet(s string) (*ClientSubnet, error) {
+	if len(s) == 0 {
+		return nil, nil
+	}
+	if strings.ToLower(s) == ClientSubnetAuto {
+		return &ClientSubnet{}, nil
+	}
+	if !strings.Contains(s, "/") {
+		ip := net.ParseIP(s)
+		if ip == nil {
+			return nil, ErrInvalidClientSubnet
+		}
+		return &ClientSubnet{
+			subnet: subnetOf(ip),
+		}, nil
+	}
+	_, subnet, err := net.ParseCIDR(s)
+	if err != nil {
+		return nil, ErrInvalidClientSubnet
+	}
+	return &ClientSubnet{
+		subnet: subnet,
+	}, nil
+}
+
+// subnetOf returns the subnet of the IP with the default prefix length.
+func subnetOf(ip net.IP) *net.IPNet {
+	if ipv4 := ip.To4(); ipv4 != nil {
+		mask := net.CIDRMask(DefaultIPv4SubnetPrefix, 8*net.IPv4len)
+		return &net.IPNet{IP: ipv4.Mask(mask), Mask: mask}
+	}
+	mask := net.CIDRMask(DefaultIPv6SubnetPrefix, 8*net.IPv6len)
+	return &net.IPNet{IP: ip.Mask(mask), Mask: mask}
+}
+
+// For returns the subnet to attach to queries on behalf of the client, or nil if there is none. client may be nil if
+// the query is not made for a DNS client.
+func (this *ClientSubnet) For(client net.IP) *net.IPNet {
+	if this == nil {
+		return nil
+	}
+	if this.subnet != nil {
+		return this.subnet
+	}
+	if client == nil || client.IsLoopback() {
+		return nil
+	}
+	return subnetOf(client)
+}
+
+// IsAuto returns true if the subnet is derived from the client.
+func (this *ClientSubnet) IsAuto() bool {
+	return this != nil && this.subnet == nil
+}
+
+// setClientSubnet attaches the subnet to the query as an EDNS0 option.
+func setClientSubnet(msg *dns.Msg, subnet *net.IPNet) {
+	option := &dns.EDNS0_SUBNET{
+		Code:        dns.EDNS0SUBNET,
+		Address:     subnet.IP,
+		Family:      1,
+		SourceScope: 0,
+	}
+	ones, _ := subnet.Mask.Size()
+	option.SourceNetmask = uint8(ones)
+	if subnet.IP.To4() == nil {
+		option.Family = 2
+	}
+
+	opt := msg.IsEdns0()
+	if opt == nil {
+		msg.SetEdns0(dns.DefaultMsgSize, false)
+		opt = msg.IsEdns0()
+	}
+	opt.Option = append(opt.Option, option)
+}
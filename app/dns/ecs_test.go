@@ -0,0 +1,37 @@
+package dns_test
+
+import (
+	"net"
+	"testing"
+
+	. "v2ray.com/core/app/dns"
+	"v2ray.com/core/testing/assert"
+)
+
+func TestClientSubnet(t *testing.T) {
+	assert := assert.On(t)
+
+	subnet, err := ParseClientSubnet("")
+	assert.Error(err).IsNil()
+	assert.Bool(subnet.For(net.ParseIP("1.2.3.4")) == nil).IsTrue()
+
+	subnet, err = ParseClientSubnet("1.2.3.4")
+	assert.Error(err).IsNil()
+	assert.String(subnet.For(nil).String()).Equals("1.2.3.0/24")
+
+	subnet, err = ParseClientSubnet("2001:db8::/32")
+	assert.Error(err).IsNil()
+	assert.String(subnet.For(net.ParseIP("1.2.3.4")).String()).Equals("2001:db8::/32")
+
+	subnet, err = ParseClientSubnet("auto")
+	assert.Error(err).IsNil()
+	assert.String(subnet.For(net.ParseIP("5.6.7.8")).String()).Equals("5.6.7.0/24")
+	assert.String(subnet.For(net.ParseIP("2001:db8:1:2:3::1")).String()).Equals("2001:db8:1::/56")
+	assert.Bool(subnet.For(net.ParseIP("127.0.0.1")) == nil).IsTrue()
+	assert.Bool(subnet.For(nil) == nil).IsTrue()
+
+	_, err = ParseClientSubnet("1.2.3.4/33")
+	assert.Error(err).IsNotNil()
+	_, err = ParseClientSubnet("v2ray.com")
+	assert.Error(err).IsNotNil()
+}
@@ -0,0 +1,119 @@
+package dns
+
+import (
+	"encoding/binary"
+	"errors"
+	"net"
+	"sync"
+)
+
+const (
+	// MaxFakeIPPoolSize is the maximum number of fake IPs handed out at the same time.
+	MaxFakeIPPoolSize = 1 << 20
+)
+
+var (
+	ErrFakeIPRangeTooSmall = errors.New("DNS: Fake IP range is too small.")
+)
+
+// FakeIPPool hands out fake IPs for domains from a range in turn, and keeps the reverse mapping. When the range is
+// exhausted, it wraps around and reuses the oldest IP, regardless of whether the IP is still in use.
+type FakeIPPool struct {
+	sync.Mutex
+	base       net.IP
+	size       uint32
+	next       uint32
+	domainToIP map[string]uint32
+	ipToDomain map[uint32]string
+}
+
+func NewFakeIPPool(cidr string) (*FakeIPPool, error) {
+	_, ipNet, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return nil, err
+	}
+	base := ipNet.IP
+	if ipv4 := base.To4(); ipv4 != nil {
+		base = ipv4
+	}
+	ones, bits := ipNet.Mask.Size()
+	size := uint32(MaxFakeIPPoolSize)
+	if bits-ones < 21 {
+		size = uint32(1) << uint(bits-ones)
+	}
+	// The first and last addresses of the range are not handed out.
+	if size < 4 {
+		return nil, ErrFakeIPRangeTooSmall
+	}
+	return &FakeIPPool{
+		base:       base,
+		size:       size - 2,
+		domainToIP: make(map[string]uint32),
+		ipToDomain: make(map[uint32]string),
+	}, nil
+}
+
+func (this *FakeIPPool) ip(offset uint32) net.IP {
+	ip := make(net.IP, len(this.base))
+	copy(ip, this.base)
+	tail := ip[len(ip)-4:]
+	binary.BigEndian.PutUint32(tail, binary.BigEndian.Uint32(tail)+offset+1)
+	return ip
+}
+
+// offset returns the offset of the IP in this pool, or false if it is not in the pool.
+func (this *FakeIPPool) offset(ip net.IP) (uint32, bool) {
+	if ipv4 := ip.To4(); ipv4 != nil && len(this.base) == net.IPv4len {
+		ip = ipv4
+	}
+	if len(ip) != len(this.base) {
+		return 0, false
+	}
+	prefixLen := len(ip) - 4
+	if !ip[:prefixLen].Equal(this.base[:prefixLen]) {
+		return 0, false
+	}
+	offset := binary.BigEndian.Uint32(ip[prefixLen:]) - binary.BigEndian.Uint32(this.base[prefixLen:])
+	if offset == 0 || offset > this.size {
+		return 0, false
+	}
+	return offset - 1, true
+}
+
+// Get returns the fake IP of the domain, handing out a new one if needed.
+func (this *FakeIPPool) Get(domain string) net.IP {
+	this.Lock()
+	defer this.Unlock()
+
+	if offset, found := this.domainToIP[domain]; found {
+		return this.ip(offset)
+	}
+
+	offset := this.next
+	this.next = (this.next + 1) % this.size
+	if oldDomain, found := this.ipToDomain[offset]; found {
+		delete(this.domainToIP, oldDomain)
+	}
+	this.domainToIP[domain] = offset
+	this.ipToDomain[offset] = domain
+	return this.ip(offset)
+}
+
+// Lookup returns the domain that the fake IP was handed out for.
+func (this *FakeIPPool) Lookup(ip net.IP) (string, bool) {
+	offset, ok := this.offset(ip)
+	if !ok {
+		return "", false
+	}
+
+	this.Lock()
+	defer this.Unlock()
+
+	domain, found := this.ipToDomain[offset]
+	return domain, found
+}
+
+// IsIPv6 returns true if the fake IPs are IPv6 addresses.
+func (this *FakeIPPool) IsIPv6() bool {
+	return len(this.base) == net.IPv6len
+}
@@ -0,0 +1,79 @@
+package dns_test
+
+import (
+	"net"
+	"testing"
+
+	. "v2ray.com/core/app/dns"
+	"v2ray.com/core/testing/assert"
+)
+
+func TestFakeIPPool(t *testing.T) {
+	assert := assert.On(t)
+
+	pool, err := NewFakeIPPool("198.18.0.0/15")
+	assert.Error(err).IsNil()
+
+	ip := pool.Get("v2ray.com")
+	assert.IP(ip).Equals(net.IP([]byte{198, 18, 0, 1}))
+	assert.IP(pool.Get("v2ray.com")).Equals(ip)
+	assert.IP(pool.Get("www.v2ray.com")).Equals(net.IP([]byte{198, 18, 0, 2}))
+
+	domain, found := pool.Lookup(ip)
+	assert.Bool(found).IsTrue()
+	assert.String(domain).Equals("v2ray.com")
+
+	domain, found = pool.Lookup(net.ParseIP("198.18.0.2"))
+	assert.Bool(found).IsTrue()
+	assert.String(domain).Equals("www.v2ray.com")
+
+	_, found = pool.Lookup(net.ParseIP("198.18.0.3"))
+	assert.Bool(found).IsFalse()
+	_, found = pool.Lookup(net.ParseIP("8.8.8.8"))
+	assert.Bool(found).IsFalse()
+}
+
+func TestFakeIPPoolRecycling(t *testing.T) {
+	assert := assert.On(t)
+
+	pool, err := NewFakeIPPool("10.0.0.0/30")
+	assert.Error(err).IsNil()
+
+	assert.IP(pool.Get("a.com")).Equals(net.IP([]byte{10, 0, 0, 1}))
+	assert.IP(pool.Get("b.com")).Equals(net.IP([]byte{10, 0, 0, 2}))
+	assert.IP(pool.Get("c.com")).Equals(net.IP([]byte{10, 0, 0, 1}))
+
+	_, found := pool.Lookup(net.IP([]byte{10, 0, 0, 3}))
+	assert.Bool(found).IsFalse()
+	domain, found := pool.Lookup(net.IP([]byte{10, 0, 0, 1}))
+	assert.Bool(found).IsTrue()
+	assert.String(domain).Equals("c.com")
+
+	assert.IP(pool.Get("a.com")).Equals(net.IP([]byte{10, 0, 0, 2}))
+	_, found = pool.Lookup(net.IP([]byte{10, 0, 0, 2}))
+	assert.Bool(found).IsTrue()
+}
+
+func TestFakeIPPoolIPv6(t *testing.T) {
+	assert := assert.On(t)
+
+	pool, err := NewFakeIPPool("fc00::/64")
+	assert.Error(err).IsNil()
+	assert.Bool(pool.IsIPv6()).IsTrue()
+
+	ip := pool.Get("v2ray.com")
+	assert.IP(ip).Equals(net.ParseIP("fc00::1"))
+	domain, found := pool.Lookup(ip)
+	assert.Bool(found).IsTrue()
+	assert.String(domain).Equals("v2ray.com")
+}
+
+func TestInvalidFakeIPRange(t *testing.T) {
+	assert := assert.On(t)
+
+	_, err := NewFakeIPPool("10.0.0.0/31")
+	assert.Error(err).Equals(ErrFakeIPRangeTooSmall)
+
+	_, err = NewFakeIPPool("10.0.0.0")
+	assert.Error(err).IsNotNil()
+}
@@ -0,0 +1,150 @@
+package dns
+
+import (
+	"bufio"
+	"net"
+	"os"
+	"path"
+	"strings"
+
+	"v2ray.com/core/common/log"
+)
+
+const (
+	// MaxAliasDepth is the maximum number of aliases followed when resolving a domain.
+	MaxAliasDepth = 8
+)
+
+// HostEntry is the static resolution of a domain, either to IPs or as an alias of another domain.
+type HostEntry struct {
+	IPs   []net.IP
+	Alias string
+}
+
+type hostPattern struct {
+	pattern string
+	entry   *HostEntry
+}
+
+func (this *hostPattern) match(domain string) bool {
+	if strings.HasPrefix(this.pattern, ".") {
+		return domain == this.pattern[1:] || strings.HasSuffix(domain, this.pattern)
+	}
+	matched, _ := path.Match(this.pattern, domain)
+	return matched
+}
+
+// StaticHosts resolves domains by exact domains or domain patterns. Exact domains take precedence over patterns, and
+// longer patterns take precedence over shorter ones.
+type StaticHosts struct {
+	exact    map[string]*HostEntry
+	patterns []*hostPattern
+}
+
+func NewStaticHosts() *StaticHosts {
+	return &StaticHosts{
+		exact: make(map[string]*HostEntry),
+	}
+}
+
+func isHostPattern(domain string) bool {
+	return strings.HasPrefix(domain, ".") || strings.Contains(domain, "*")
+}
+
+func (this *StaticHosts) entry(domain string) *HostEntry {
+	domain = strings.ToLower(domain)
+	if !isHostPattern(domain) {
+		entry, found := this.exact[domain]
+		if !found {
+			entry = new(HostEntry)
+			this.exact[domain] = entry
+		}
+		return entry
+	}
+
+	for _, p := range this.patterns {
+		if p.pattern == domain {
+			return p.entry
+		}
+	}
+	p := &hostPattern{
+		pattern: domain,
+		entry:   new(HostEntry),
+	}
+	// Keep patterns sorted by length, longest first.
+	idx := len(this.patterns)
+	for i, existing := range this.patterns {
+		if len(existing.pattern) < len(domain) {
+			idx = i
+			break
+		}
+	}
+	this.patterns = append(this.patterns, nil)
+	copy(this.patterns[idx+1:], this.patterns[idx:])
+	this.patterns[idx] = p
+	return p.entry
+}
+
+// AddIPs adds IPs to the domain pattern.
+func (this *StaticHosts) AddIPs(domain string, ips ...net.IP) {
+	entry := this.entry(domain)
+	entry.IPs = append(entry.IPs, ips...)
+}
+
+// SetAlias makes the domain pattern an alias of another domain.
+func (this *StaticHosts) SetAlias(domain string, alias string) {
+	this.entry(domain).Alias = strings.ToLower(alias)
+}
+
+// Lookup returns the static resolution of the domain, or nil if there is none.
+func (this *StaticHosts) Lookup(domain string) *HostEntry {
+	domain = strings.ToLower(strings.TrimSuffix(domain, "."))
+	if entry, found := this.exact[domain]; found {
+		return entry
+	}
+	for _, p := range this.patterns {
+		if p.match(domain) {
+			return p.entry
+		}
+	}
+	return nil
+}
+
+// LoadFile imports static hosts from a file in the format of /etc/hosts.
+func (this *StaticHosts) LoadFile(file string) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	count := 0
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if idx := strings.Index(line, "#"); idx >= 0 {
+			line = line[:idx]
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		ip := net.ParseIP(fields[0])
+		if ip == nil {
+			log.Warning("DNS: Invalid IP in hosts file ", file, ": ", fields[0])
+			continue
+		}
+		if ipv4 := ip.To4(); ipv4 != nil {
+			ip = ipv4
+		}
+		for _, domain := range fields[1:] {
+			this.AddIPs(domain, ip)
+			count++
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	log.Info("DNS: Imported ", count, " static hosts from ", file)
+	return nil
+}
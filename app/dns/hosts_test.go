@@ -0,0 +1,114 @@
+package dns_test
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"v2ray.com/core/app"
+	"v2ray.com/core/app/dispatcher"
+	dispatchers "v2ray.com/core/app/dispatcher/impl"
+	. "v2ray.com/core/app/dns"
+	v2net "v2ray.com/core/common/net"
+	"v2ray.com/core/testing/assert"
+)
+
+func TestStaticHostsPatterns(t *testing.T) {
+	assert := assert.On(t)
+
+	hosts := NewStaticHosts()
+	hosts.AddIPs("v2ray.com", net.IP([]byte{10, 0, 0, 1}))
+	hosts.AddIPs(".corp", net.IP([]byte{10, 0, 0, 2}))
+	hosts.AddIPs("*.internal.corp", net.IP([]byte{10, 0, 0, 3}), net.ParseIP("fd00::3"))
+	hosts.SetAlias("www.v2ray.com", "v2ray.com")
+
+	assert.IP(hosts.Lookup("V2Ray.com.").IPs[0]).Equals(net.IP([]byte{10, 0, 0, 1}))
+	assert.Pointer(hosts.Lookup("mail.v2ray.com")).IsNil()
+	assert.String(hosts.Lookup("www.v2ray.com").Alias).Equals("v2ray.com")
+
+	assert.IP(hosts.Lookup("corp").IPs[0]).Equals(net.IP([]byte{10, 0, 0, 2}))
+	assert.IP(hosts.Lookup("mail.corp").IPs[0]).Equals(net.IP([]byte{10, 0, 0, 2}))
+	assert.Pointer(hosts.Lookup("notcorp")).IsNil()
+
+	entry := hosts.Lookup("a.b.internal.corp")
+	assert.Int(len(entry.IPs)).Equals(2)
+	assert.IP(entry.IPs[0]).Equals(net.IP([]byte{10, 0, 0, 3}))
+	assert.IP(hosts.Lookup("internal.corp").IPs[0]).Equals(net.IP([]byte{10, 0, 0, 2}))
+}
+
+func TestStaticHostsFile(t *testing.T) {
+	assert := assert.On(t)
+
+	dir, err := ioutil.TempDir("", "v2ray-dns")
+	assert.Error(err).IsNil()
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "hosts")
+	assert.Error(ioutil.WriteFile(file, []byte(`# Comment
+127.0.0.1 localhost
+10.0.0.1  a.corp b.corp # inline comment
+10.0.0.2  a.corp
+fd00::1   a.corp
+
+invalid   c.corp
+`), 0644)).IsNil()
+
+	space := app.NewSpace()
+	space.BindApp(dispatcher.APP_ID, dispatchers.NewDefaultDispatcher(space))
+	server := NewCacheServer(space, &Config{
+		HostsFiles: []string{file},
+		StaticHosts: []*HostMapping{
+			{
+				Domain: "*.a.corp",
+				Alias:  "a.corp",
+			},
+			{
+				Domain: "d.corp",
+				Ip:     []*v2net.AddressPB{v2net.NewAddressPB(v2net.IPAddress([]byte{10, 0, 0, 4}))},
+			},
+		},
+		Hosts: map[string]*v2net.AddressPB{
+			"e.corp": v2net.NewAddressPB(v2net.DomainAddress("d.corp")),
+		},
+	})
+
+	ips := server.Resolve("a.corp", IPv4AndIPv6, nil).IPs
+	assert.Int(len(ips)).Equals(3)
+	assert.IP(ips[0]).Equals(net.IP([]byte{10, 0, 0, 1}))
+	assert.IP(ips[1]).Equals(net.IP([]byte{10, 0, 0, 2}))
+	assert.IP(ips[2]).Equals(net.ParseIP("fd00::1"))
+	assert.Int(len(server.Resolve("a.corp", IPv6Only, nil).IPs)).Equals(1)
+	assert.Int(len(server.Resolve("b.corp", IPv4AndIPv6, nil).IPs)).Equals(1)
+	assert.Int(len(server.Resolve("www.a.corp", IPv4Only, nil).IPs)).Equals(2)
+
+	ips = server.Resolve("e.corp", IPv4AndIPv6, nil).IPs
+	assert.Int(len(ips)).Equals(1)
+	assert.IP(ips[0]).Equals(net.IP([]byte{10, 0, 0, 4}))
+}
+
+func TestStaticHostsAliasLoop(t *testing.T) {
+	assert := assert.On(t)
+
+	space := app.NewSpace()
+	space.BindApp(dispatcher.APP_ID, dispatchers.NewDefaultDispatcher(space))
+	server := NewCacheServer(space, &Config{
+		Hosts: map[string]*v2net.AddressPB{
+			"a.corp": v2net.NewAddressPB(v2net.DomainAddress("b.corp")),
+			"b.corp": v2net.NewAddressPB(v2net.DomainAddress("a.corp")),
+		},
+	})
+	assert.Int(len(server.Resolve("a.corp", IPv4AndIPv6, nil).IPs)).Equals(0)
+}
+
+func TestMissingHostsFile(t *testing.T) {
+	assert := assert.On(t)
+
+	space := app.NewSpace()
+	space.BindApp(dispatcher.APP_ID, dispatchers.NewDefaultDispatcher(space))
+	NewCacheServer(space, &Config{
+		HostsFiles: []string{"/nonexistent/hosts"},
+	})
+	assert.Error(space.Initialize()).IsNotNil()
+}
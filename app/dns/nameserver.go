@@ -26,13 +26,64 @@ var (
 	pseudoDestination = v2net.UDPDestination(v2net.LocalHostIP, v2net.Port(53))
 )
 
+// ARecord is the answer to either an A or an AAAA query. A record without IPs is a negative one.
 type ARecord struct {
-	IPs    []net.IP
-	Expire time.Time
+	IPs     []net.IP
+	Created time.Time
+	Expire  time.Time
+	// Rcode is the response code of the answer.
+	Rcode int
+	// OriginalExpire is the expiry of the answer, if the record is kept after failing to refresh it. It is zero
+	// otherwise.
+	OriginalExpire time.Time
 }
 
+// NameServer sends queries to a name server. subnet is the EDNS Client Subnet attached to the queries, if not nil.
 type NameServer interface {
-	QueryA(domain string) <-chan *ARecord
+	QueryA(domain string, subnet *net.IPNet) <-chan *ARecord
+	QueryAAAA(domain string, subnet *net.IPNet) <-chan *ARecord
+}
+
+func buildQueryMsg(domain string, id uint16, qtype uint16, subnet *net.IPNet) *dns.Msg {
+	msg := new(dns.Msg)
+	msg.Id = id
+	msg.RecursionDesired = true
+	msg.Question = []dns.Question{
+		{
+			Name:   dns.Fqdn(domain),
+			Qtype:  qtype,
+			Qclass: dns.ClassINET,
+		}}
+	if subnet != nil {
+		setClientSubnet(msg, subnet)
+	}
+	return msg
+}
+
+// parseResponse collects the IPs and the minimum TTL of the answers in a DNS response.
+func parseResponse(msg *dns.Msg) *ARecord {
+	record := &ARecord{
+		IPs:   make([]net.IP, 0, 16),
+		Rcode: msg.Rcode,
+	}
+	ttl := DefaultTTL
+	for _, rr := range msg.Answer {
+		switch rr := rr.(type) {
+		case *dns.A:
+			record.IPs = append(record.IPs, rr.A)
+			if rr.Hdr.Ttl < ttl {
+				ttl = rr.Hdr.Ttl
+			}
+		case *dns.AAAA:
+			record.IPs = append(record.IPs, rr.AAAA)
+			if rr.Hdr.Ttl < ttl {
+				ttl = rr.Hdr.Ttl
+			}
+		}
+	}
+	record.Created = time.Now()
+	record.Expire = record.Created.Add(time.Second * time.Duration(ttl))
+	return record
 }
 
 type PendingRequest struct {
@@ -110,11 +161,7 @@ func (this *UDPNameServer) HandleResponse(dest v2net.Destination, payload *alloc
 		log.Warning("DNS: Failed to parse DNS response: ", err)
 		return
 	}
-	record := &ARecord{
-		IPs: make([]net.IP, 0, 16),
-	}
 	id := msg.Id
-	ttl := DefaultTTL
 	log.Debug("DNS: Handling response for id ", id, " content: ", msg.String())
 
 	this.Lock()
@@ -126,39 +173,22 @@ func (this *UDPNameServer) HandleResponse(dest v2net.Destination, payload *alloc
 	delete(this.requests, id)
 	this.Unlock()
 
-	for _, rr := range msg.Answer {
-		switch rr := rr.(type) {
-		case *dns.A:
-			record.IPs = append(record.IPs, rr.A)
-			if rr.Hdr.Ttl < ttl {
-				ttl = rr.Hdr.Ttl
-			}
-		case *dns.AAAA:
-			record.IPs = append(record.IPs, rr.AAAA)
-			if rr.Hdr.Ttl < ttl {
-				ttl = rr.Hdr.Ttl
-			}
-		}
-	}
-	record.Expire = time.Now().Add(time.Second * time.Duration(ttl))
-
+	record := parseResponse(msg)
 	request.response <- record
 	close(request.response)
 }
 
 func (this *UDPNameServer) BuildQueryA(domain string, id uint16) *alloc.Buffer {
-	buffer := alloc.NewBuffer()
-	msg := new(dns.Msg)
-	msg.Id = id
-	msg.RecursionDesired = true
-	msg.Question = []dns.Question{
-		{
-			Name:   dns.Fqdn(domain),
-			Qtype:  dns.TypeA,
-			Qclass: dns.ClassINET,
-		}}
+	return this.buildQuery(domain, id, dns.TypeA, nil)
+}
 
-	writtenBuffer, _ := msg.PackBuffer(buffer.Value)
+func (this *UDPNameServer) BuildQueryAAAA(domain string, id uint16) *alloc.Buffer {
+	return this.buildQuery(domain, id, dns.TypeAAAA, nil)
+}
+
+func (this *UDPNameServer) buildQuery(domain string, id uint16, qtype uint16, subnet *net.IPNet) *alloc.Buffer {
+	buffer := alloc.NewBuffer()
+	writtenBuffer, _ := buildQueryMsg(domain, id, qtype, subnet).PackBuffer(buffer.Value)
 	buffer.Slice(0, len(writtenBuffer))
 
 	return buffer
@@ -168,11 +198,19 @@ func (this *UDPNameServer) DispatchQuery(payload *alloc.Buffer) {
 	this.udpServer.Dispatch(&proxy.SessionInfo{Source: pseudoDestination, Destination: this.address}, payload, this.HandleResponse)
 }
 
-func (this *UDPNameServer) QueryA(domain string) <-chan *ARecord {
+func (this *UDPNameServer) QueryA(domain string, subnet *net.IPNet) <-chan *ARecord {
+	return this.query(domain, dns.TypeA, subnet)
+}
+
+func (this *UDPNameServer) QueryAAAA(domain string, subnet *net.IPNet) <-chan *ARecord {
+	return this.query(domain, dns.TypeAAAA, subnet)
+}
+
+func (this *UDPNameServer) query(domain string, qtype uint16, subnet *net.IPNet) <-chan *ARecord {
 	response := make(chan *ARecord, 1)
 	id := this.AssignUnusedID(response)
 
-	this.DispatchQuery(this.BuildQueryA(domain, id))
+	this.DispatchQuery(this.buildQuery(domain, id, qtype, subnet))
 
 	go func() {
 		for i := 0; i < 2; i++ {
@@ -181,7 +219,7 @@ func (this *UDPNameServer) QueryA(domain string) <-chan *ARecord {
 			_, found := this.requests[id]
 			this.Unlock()
 			if found {
-				this.DispatchQuery(this.BuildQueryA(domain, id))
+				this.DispatchQuery(this.buildQuery(domain, id, qtype, subnet))
 			} else {
 				break
 			}
@@ -194,7 +232,16 @@ func (this *UDPNameServer) QueryA(domain string) <-chan *ARecord {
 type LocalNameServer struct {
 }
 
-func (this *LocalNameServer) QueryA(domain string) <-chan *ARecord {
+// QueryA resolves the domain by the system resolver, which doesn't support EDNS Client Subnet.
+func (this *LocalNameServer) QueryA(domain string, subnet *net.IPNet) <-chan *ARecord {
+	return this.query(domain, false)
+}
+
+func (this *LocalNameServer) QueryAAAA(domain string, subnet *net.IPNet) <-chan *ARecord {
+	return this.query(domain, true)
+}
+
+func (this *LocalNameServer) query(domain string, ipv6 bool) <-chan *ARecord {
 	response := make(chan *ARecord, 1)
 
 	go func() {
@@ -206,9 +253,18 @@ func (this *LocalNameServer) QueryA(domain string) <-chan *ARecord {
 			return
 		}
 
+		filtered := make([]net.IP, 0, len(ips))
+		for _, ip := range ips {
+			if (ip.To4() == nil) == ipv6 {
+				filtered = append(filtered, ip)
+			}
+		}
+
+		now := time.Now()
 		response <- &ARecord{
-			IPs:    ips,
-			Expire: time.Now().Add(time.Second * time.Duration(DefaultTTL)),
+			IPs:     filtered,
+			Created: now,
+			Expire:  now.Add(time.Second * time.Duration(DefaultTTL)),
 		}
 	}()
 
@@ -0,0 +1,140 @@
+package dns
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/base64"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"v2ray.com/core/app/dispatcher"
+	"v2ray.com/core/common/log"
+	v2net "v2ray.com/core/common/net"
+	"v2ray.com/core/proxy"
+
+	"github.com/miekg/dns"
+)
+
+const (
+	dnsMessageType = "application/dns-message"
+	dnsURLTemplate = "{?dns}"
+	// maxMessageSize is the maximum size of a DNS message.
+	maxMessageSize = 65535
+)
+
+// HTTPSNameServer is a NameServer for DNS over HTTPS (RFC 8484). Queries are sent by POST, or by GET if the URL
+// contains the "{?dns}" template. Connections are kept alive and reused between queries.
+type HTTPSNameServer struct {
+	address    v2net.Destination
+	url        string
+	dispatcher dispatcher.PacketDispatcher
+	meta       *proxy.InboundHandlerMeta
+	client     *http.Client
+}
+
+// NewHTTPSNameServer creates an HTTPSNameServer. Queries time out after timeout, or QueryTimeout if it is 0.
+func NewHTTPSNameServer(address v2net.Destination, url string, timeout time.Duration, config *tls.Config, dispatcher dispatcher.PacketDispatcher) *HTTPSNameServer {
+	if timeout <= 0 {
+		timeout = QueryTimeout
+	}
+	server := &HTTPSNameServer{
+		address:    address,
+		url:        url,
+		dispatcher: dispatcher,
+		meta: &proxy.InboundHandlerMeta{
+			AllowPassiveConnection: false,
+		},
+	}
+	server.client = &http.Client{
+		Transport: &http.Transport{
+			Dial:            server.dial,
+			TLSClientConfig: config,
+		},
+		Timeout: timeout,
+	}
+	return server
+}
+
+// dial opens a connection to the name server through the dispatcher, regardless of the address in the URL.
+func (this *HTTPSNameServer) dial(network, addr string) (net.Conn, error) {
+	log.Info("DNS: Opening HTTPS connection to ", this.address)
+	return newRayConnection(this.dispatcher.DispatchToOutbound(this.meta, &proxy.SessionInfo{
+		Source:      pseudoDestination,
+		Destination: this.address,
+	}), this.address), nil
+}
+
+func (this *HTTPSNameServer) newRequest(payload []byte) (*http.Request, error) {
+	if strings.Contains(this.url, dnsURLTemplate) {
+		url := strings.Replace(this.url, dnsURLTemplate, "?dns="+base64.RawURLEncoding.EncodeToString(payload), 1)
+		return http.NewRequest("GET", url, nil)
+	}
+	request, err := http.NewRequest("POST", this.url, bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Content-Type", dnsMessageType)
+	return request, nil
+}
+
+func (this *HTTPSNameServer) exchange(msg *dns.Msg) (*dns.Msg, error) {
+	payload, err := msg.Pack()
+	if err != nil {
+		return nil, err
+	}
+	request, err := this.newRequest(payload)
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Accept", dnsMessageType)
+
+	response, err := this.client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		io.Copy(ioutil.Discard, response.Body)
+		return nil, errors.New("DNS: Unexpected HTTP status: " + response.Status)
+	}
+	body, err := ioutil.ReadAll(io.LimitReader(response.Body, maxMessageSize))
+	if err != nil {
+		return nil, err
+	}
+	reply := new(dns.Msg)
+	if err := reply.Unpack(body); err != nil {
+		return nil, err
+	}
+	return reply, nil
+}
+
+func (this *HTTPSNameServer) query(domain string, qtype uint16, subnet *net.IPNet) <-chan *ARecord {
+	response := make(chan *ARecord, 1)
+	go func() {
+		defer close(response)
+
+		// ID is set to 0 so that responses are cacheable by HTTP caches.
+		reply, err := this.exchange(buildQueryMsg(domain, 0, qtype, subnet))
+		if err != nil {
+			log.Warning("DNS: Failed to query ", domain, " over HTTPS: ", err)
+			return
+		}
+		log.Debug("DNS: Handling HTTPS response for ", domain, " content: ", reply.String())
+		response <- parseResponse(reply)
+	}()
+	return response
+}
+
+func (this *HTTPSNameServer) QueryA(domain string, subnet *net.IPNet) <-chan *ARecord {
+	return this.query(domain, dns.TypeA, subnet)
+}
+
+func (this *HTTPSNameServer) QueryAAAA(domain string, subnet *net.IPNet) <-chan *ARecord {
+	return this.query(domain, dns.TypeAAAA, subnet)
+}
@@ -0,0 +1,267 @@
+package dns
+
+import (
+	"crypto/tls"
+	"encoding/binary"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"v2ray.com/core/app/dispatcher"
+	"v2ray.com/core/common/dice"
+	"v2ray.com/core/common/log"
+	v2net "v2ray.com/core/common/net"
+	"v2ray.com/core/proxy"
+
+	"github.com/miekg/dns"
+)
+
+// tcpConnection is a single stream to a name server, carrying length-prefixed DNS messages.
+type tcpConnection struct {
+	sync.Mutex
+	writeLock    sync.Mutex
+	conn         net.Conn
+	requests     map[uint16]chan<- *ARecord
+	closed       bool
+	lastReceived time.Time
+}
+
+// TCPNameServer is a NameServer that sends queries over TCP, or over TLS if tlsConfig is set. Queries are
+// pipelined on a single connection, which is re-established when it gets closed.
+type TCPNameServer struct {
+	sync.Mutex
+	address    v2net.Destination
+	dispatcher dispatcher.PacketDispatcher
+	meta       *proxy.InboundHandlerMeta
+	tlsConfig  *tls.Config
+	conn       *tcpConnection
+	timeout    time.Duration
+}
+
+// NewTCPNameServer creates a TCPNameServer. Queries time out after timeout, or QueryTimeout if it is 0.
+func NewTCPNameServer(address v2net.Destination, timeout time.Duration, dispatcher dispatcher.PacketDispatcher) *TCPNameServer {
+	if timeout <= 0 {
+		timeout = QueryTimeout
+	}
+	return &TCPNameServer{
+		address:    address,
+		timeout:    timeout,
+		dispatcher: dispatcher,
+		meta: &proxy.InboundHandlerMeta{
+			AllowPassiveConnection: false,
+		},
+	}
+}
+
+// NewTLSNameServer creates a TCPNameServer for DNS over TLS (RFC 7858).
+func NewTLSNameServer(address v2net.Destination, timeout time.Duration, config *tls.Config, dispatcher dispatcher.PacketDispatcher) *TCPNameServer {
+	server := NewTCPNameServer(address, timeout, dispatcher)
+	server.tlsConfig = config
+	return server
+}
+
+func (this *TCPNameServer) getConnection() *tcpConnection {
+	this.Lock()
+	defer this.Unlock()
+
+	if this.conn != nil && !this.conn.isClosed() {
+		return this.conn
+	}
+
+	log.Info("DNS: Opening TCP connection to ", this.address)
+	var rawConn net.Conn = newRayConnection(this.dispatcher.DispatchToOutbound(this.meta, &proxy.SessionInfo{
+		Source:      pseudoDestination,
+		Destination: this.address,
+	}), this.address)
+	if this.tlsConfig != nil {
+		rawConn = tls.Client(rawConn, this.tlsConfig)
+	}
+	conn := &tcpConnection{
+		conn:     rawConn,
+		requests: make(map[uint16]chan<- *ARecord),
+	}
+	this.conn = conn
+	go conn.handleResponses()
+	return conn
+}
+
+func (this *tcpConnection) isClosed() bool {
+	this.Lock()
+	defer this.Unlock()
+
+	return this.closed
+}
+
+// assignID registers the response channel under a new message ID. It returns false if the connection is closed.
+func (this *tcpConnection) assignID(response chan<- *ARecord) (uint16, bool) {
+	this.Lock()
+	defer this.Unlock()
+
+	if this.closed {
+		return 0, false
+	}
+	for {
+		id := uint16(dice.Roll(65536))
+		if _, found := this.requests[id]; found {
+			continue
+		}
+		this.requests[id] = response
+		return id, true
+	}
+}
+
+func (this *tcpConnection) removeRequest(id uint16) (chan<- *ARecord, bool) {
+	this.Lock()
+	defer this.Unlock()
+
+	response, found := this.requests[id]
+	if found {
+		delete(this.requests, id)
+	}
+	return response, found
+}
+
+// timeout drops the request if it is still pending. The connection is closed if nothing has been received since the
+// request was sent, as the connection is likely broken.
+func (this *tcpConnection) timeout(id uint16, sent time.Time) {
+	response, found := this.removeRequest(id)
+	if !found {
+		return
+	}
+	close(response)
+
+	this.Lock()
+	broken := this.lastReceived.Before(sent)
+	this.Unlock()
+	if broken {
+		log.Info("DNS: Closing unresponsive TCP connection.")
+		this.close()
+	}
+}
+
+func (this *tcpConnection) close() {
+	this.Lock()
+	defer this.Unlock()
+
+	if this.closed {
+		return
+	}
+	this.closed = true
+	this.conn.Close()
+	for id, response := range this.requests {
+		close(response)
+		delete(this.requests, id)
+	}
+}
+
+func (this *tcpConnection) write(msg *dns.Msg) error {
+	payload, err := msg.Pack()
+	if err != nil {
+		return err
+	}
+	buffer := make([]byte, 2+len(payload))
+	binary.BigEndian.PutUint16(buffer, uint16(len(payload)))
+	copy(buffer[2:], payload)
+
+	this.writeLock.Lock()
+	defer this.writeLock.Unlock()
+	_, err = this.conn.Write(buffer)
+	return err
+}
+
+func (this *tcpConnection) handleResponses() {
+	defer this.close()
+
+	var lenBuffer [2]byte
+	for {
+		if _, err := io.ReadFull(this.conn, lenBuffer[:]); err != nil {
+			log.Info("DNS: TCP connection closed: ", err)
+			return
+		}
+		payload := make([]byte, binary.BigEndian.Uint16(lenBuffer[:]))
+		if _, err := io.ReadFull(this.conn, payload); err != nil {
+			log.Warning("DNS: Failed to read TCP response: ", err)
+			return
+		}
+		msg := new(dns.Msg)
+		if err := msg.Unpack(payload); err != nil {
+			log.Warning("DNS: Failed to parse DNS response: ", err)
+			continue
+		}
+		this.Lock()
+		this.lastReceived = time.Now()
+		this.Unlock()
+
+		log.Debug("DNS: Handling TCP response for id ", msg.Id, " content: ", msg.String())
+		response, found := this.removeRequest(msg.Id)
+		if !found {
+			continue
+		}
+		response <- parseResponse(msg)
+		close(response)
+	}
+}
+
+func (this *TCPNameServer) query(domain string, qtype uint16, subnet *net.IPNet) <-chan *ARecord {
+	response := make(chan *ARecord, 1)
+	go this.send(domain, qtype, subnet, response)
+	return response
+}
+
+// send sends the query and waits for its response until the timeout. Writing is bounded by the timeout as well, as
+// it includes the TLS handshake on a new connection, and the underlying ray has no deadline.
+func (this *TCPNameServer) send(domain string, qtype uint16, subnet *net.IPNet, response chan<- *ARecord) {
+	defer close(response)
+
+	timeout := time.NewTimer(this.timeout)
+	defer timeout.Stop()
+
+	// Retry once, in case the reused connection was closed by the remote.
+	for i := 0; i < 2; i++ {
+		conn := this.getConnection()
+		result := make(chan *ARecord, 1)
+		id, ok := conn.assignID(result)
+		if !ok {
+			continue
+		}
+		msg := buildQueryMsg(domain, id, qtype, subnet)
+		sent := time.Now()
+		written := make(chan error, 1)
+		go func() {
+			written <- conn.write(msg)
+		}()
+
+		select {
+		case err := <-written:
+			if err != nil {
+				log.Warning("DNS: Failed to send TCP query: ", err)
+				conn.removeRequest(id)
+				conn.close()
+				continue
+			}
+		case <-timeout.C:
+			log.Info("DNS: Timed out sending TCP query.")
+			conn.close()
+			return
+		}
+
+		select {
+		case record, open := <-result:
+			if open {
+				response <- record
+			}
+		case <-timeout.C:
+			conn.timeout(id, sent)
+		}
+		return
+	}
+}
+
+func (this *TCPNameServer) QueryA(domain string, subnet *net.IPNet) <-chan *ARecord {
+	return this.query(domain, dns.TypeA, subnet)
+}
+
+func (this *TCPNameServer) QueryAAAA(domain string, subnet *net.IPNet) <-chan *ARecord {
+	return this.query(domain, dns.TypeAAAA, subnet)
+}
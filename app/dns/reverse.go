@@ -0,0 +1,90 @@
+package dns
+
+import (
+	"container/list"
+	"net"
+	"sync"
+	"time"
+)
+
+const (
+	// ReverseMapSize is the maximum number of IPs whose domains are remembered.
+	ReverseMapSize = 16384
+)
+
+type reverseEntry struct {
+	ip     string
+	domain string
+	expire time.Time
+}
+
+// ReverseMap remembers which domain an IP was resolved from, until the answer expires. When it is full, the least
+// recently learned IP is forgotten.
+type ReverseMap struct {
+	sync.Mutex
+	capacity int
+	entries  *list.List
+	table    map[string]*list.Element
+}
+
+func NewReverseMap(capacity int) *ReverseMap {
+	if capacity <= 0 {
+		capacity = ReverseMapSize
+	}
+	return &ReverseMap{
+		capacity: capacity,
+		entries:  list.New(),
+		table:    make(map[string]*list.Element),
+	}
+}
+
+// Learn records that the IP was resolved from the domain, until expire.
+func (this *ReverseMap) Learn(ip net.IP, domain string, expire time.Time) {
+	this.Lock()
+	defer this.Unlock()
+
+	entry := &reverseEntry{
+		ip:     ip.String(),
+		domain: domain,
+		expire: expire,
+	}
+	if element, found := this.table[entry.ip]; found {
+		element.Value = entry
+		this.entries.MoveToFront(element)
+		return
+	}
+
+	this.table[entry.ip] = this.entries.PushFront(entry)
+	for this.entries.Len() > this.capacity {
+		this.removeElement(this.entries.Back())
+	}
+}
+
+// Lookup returns the domain that the IP was last resolved from, if the answer hasn't expired.
+func (this *ReverseMap) Lookup(ip net.IP) (string, bool) {
+	this.Lock()
+	defer this.Unlock()
+
+	element, found := this.table[ip.String()]
+	if !found {
+		return "", false
+	}
+	entry := element.Value.(*reverseEntry)
+	if entry.expire.Before(time.Now()) {
+		this.removeElement(element)
+		return "", false
+	}
+	return entry.domain, true
+}
+
+func (this *ReverseMap) Len() int {
+	this.Lock()
+	defer this.Unlock()
+
+	return this.entries.Len()
+}
+
+func (this *ReverseMap) removeElement(element *list.Element) {
+	this.entries.Remove(element)
+	delete(this.table, element.Value.(*reverseEntry).ip)
+}
@@ -0,0 +1,38 @@
+package dns_test
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	. "v2ray.com/core/app/dns"
+	"v2ray.com/core/testing/assert"
+)
+
+func TestReverseMap(t *testing.T) {
+	assert := assert.On(t)
+
+	reverse := NewReverseMap(2)
+	expire := time.Now().Add(time.Minute)
+	reverse.Learn(net.ParseIP("10.0.0.1"), "v2ray.com", expire)
+	reverse.Learn(net.ParseIP("2001:db8::1"), "v2ray.com", expire)
+
+	domain, found := reverse.Lookup(net.IP([]byte{10, 0, 0, 1}))
+	assert.Bool(found).IsTrue()
+	assert.String(domain).Equals("v2ray.com")
+
+	reverse.Learn(net.ParseIP("10.0.0.1"), "www.v2ray.com", expire)
+	domain, found = reverse.Lookup(net.ParseIP("10.0.0.1"))
+	assert.Bool(found).IsTrue()
+	assert.String(domain).Equals("www.v2ray.com")
+
+	// The least recently learned IP is forgotten.
+	reverse.Learn(net.ParseIP("10.0.0.2"), "v2ray.com", expire)
+	assert.Int(reverse.Len()).Equals(2)
+	_, found = reverse.Lookup(net.ParseIP("2001:db8::1"))
+	assert.Bool(found).IsFalse()
+
+	reverse.Learn(net.ParseIP("10.0.0.3"), "v2ray.com", time.Now().Add(-time.Second))
+	_, found = reverse.Lookup(net.ParseIP("10.0.0.3"))
+	assert.Bool(found).IsFalse()
+}
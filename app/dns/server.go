@@ -2,6 +2,7 @@ package dns
 
 import (
 	"net"
+	"strings"
 	"sync"
 	"time"
 
@@ -9,105 +10,689 @@ import (
 	"v2ray.com/core/app/dispatcher"
 	"v2ray.com/core/common/log"
 	v2net "v2ray.com/core/common/net"
+	"v2ray.com/core/common/signal"
 
 	"github.com/miekg/dns"
 )
 
 const (
 	QueryTimeout = time.Second * 8
+
+	DefaultNegativeTTL = time.Second * 60
+
+	// PrefetchMinHits is the number of hits before a record is considered hot and prefetched.
+	PrefetchMinHits = 2
+	// PrefetchWindow is the portion of the TTL before expiry, in which hot records are prefetched.
+	PrefetchWindow = 10 // percent
+	// RefreshRetryInterval is how long a record is kept after failing to refresh it, until it is refreshed again.
+	RefreshRetryInterval = time.Second * 30
+	// SweepInterval is how often records that can no longer be served are removed from the cache.
+	SweepInterval = time.Minute
 )
 
 type DomainRecord struct {
-	A *ARecord
+	A    *ARecord
+	AAAA *ARecord
+
+	hits       int
+	refreshing bool
+	// subnet is the client subnet that the records are bound to, or nil if they are shared by all clients.
+	subnet *net.IPNet
 }
 
 type CacheServer struct {
 	sync.RWMutex
-	space   app.Space
-	hosts   map[string]net.IP
-	records map[string]*DomainRecord
-	servers []NameServer
+	space     app.Space
+	hosts     *StaticHosts
+	records   map[string]*DomainRecord
+	servers   []*upstream
+	strategy  Config_QueryStrategy
+	headStart time.Duration
+	fakeIPs   *FakeIPPool
+	reverse   *ReverseMap
+	watchers  []RecordWatcher
+
+	negativeTTL time.Duration
+	staleTTL    time.Duration
+	prefetch    bool
+	cacheFile   string
+	cancel      *signal.CancelSignal
 }
 
 func NewCacheServer(space app.Space, config *Config) *CacheServer {
 	server := &CacheServer{
-		records: make(map[string]*DomainRecord),
-		servers: make([]NameServer, len(config.NameServers)),
-		hosts:   config.GetInternalHosts(),
+		records:   make(map[string]*DomainRecord),
+		reverse:   NewReverseMap(ReverseMapSize),
+		servers:   make([]*upstream, 0, len(config.NameServers)+len(config.Servers)),
+		strategy:  config.QueryStrategy,
+		headStart: time.Duration(config.HeadStart) * time.Millisecond,
+	}
+	if server.headStart == 0 {
+		server.headStart = DefaultHeadStart
+	}
+	hosts, hostsErr := config.BuildStaticHosts()
+	server.hosts = hosts
+	server.negativeTTL = time.Duration(config.NegativeTtl) * time.Second
+	if server.negativeTTL == 0 {
+		server.negativeTTL = DefaultNegativeTTL
 	}
+	server.staleTTL = time.Duration(config.StaleTtl) * time.Second
+	server.prefetch = config.Prefetch
+	server.cacheFile = config.CacheFile
+	if len(server.cacheFile) > 0 {
+		server.restoreSnapshot()
+	}
+	server.cancel = signal.NewCloseSignal()
+	go server.maintain()
 	space.InitializeApplication(func() error {
+		if hostsErr != nil {
+			return hostsErr
+		}
 		if !space.HasApp(dispatcher.APP_ID) {
 			log.Error("DNS: Dispatcher is not found in the space.")
 			return app.ErrMissingApplication
 		}
 
+		if len(config.FakeIpRange) > 0 {
+			pool, err := NewFakeIPPool(config.FakeIpRange)
+			if err != nil {
+				log.Error("DNS: Invalid fake IP range: ", err)
+				return err
+			}
+			server.fakeIPs = pool
+		}
+
+		globalClientSubnet, err := ParseClientSubnet(config.ClientSubnet)
+		if err != nil {
+			log.Error("DNS: Invalid client subnet: ", config.ClientSubnet)
+			return err
+		}
+
 		dispatcher := space.GetApp(dispatcher.APP_ID).(dispatcher.PacketDispatcher)
-		for idx, destPB := range config.NameServers {
-			address := destPB.Address.AsAddress()
-			if address.Family().IsDomain() && address.Domain() == "localhost" {
-				server.servers[idx] = &LocalNameServer{}
-			} else {
-				dest := destPB.AsDestination()
-				if dest.Network == v2net.Network_Unknown {
-					dest.Network = v2net.Network_UDP
+		for _, nsConfig := range config.GetNameServerConfigs() {
+			var matcher v2net.DomainMatcher
+			if len(nsConfig.Domains) > 0 {
+				domains := make(v2net.AnyDomainMatcher, 0, len(nsConfig.Domains))
+				for _, domain := range nsConfig.Domains {
+					m, err := v2net.NewDomainMatcher(domain)
+					if err != nil {
+						log.Error("DNS: Invalid domains of name server: ", err)
+						return err
+					}
+					domains = append(domains, m)
 				}
-				if dest.Network == v2net.Network_UDP {
-					server.servers[idx] = NewUDPNameServer(dest, dispatcher)
+				matcher = domains
+			}
+			clientSubnet := globalClientSubnet
+			if len(nsConfig.ClientSubnet) > 0 {
+				s, err := ParseClientSubnet(nsConfig.ClientSubnet)
+				if err != nil {
+					log.Error("DNS: Invalid client subnet of name server: ", nsConfig.ClientSubnet)
+					return err
 				}
+				clientSubnet = s
+			}
+			if nameServer := newNameServer(nsConfig, dispatcher); nameServer != nil {
+				timeout := time.Duration(nsConfig.Timeout) * time.Millisecond
+				server.servers = append(server.servers, newUpstream(nameServer, matcher, timeout, clientSubnet))
 			}
 		}
-		if len(config.NameServers) == 0 {
-			server.servers = append(server.servers, &LocalNameServer{})
+		if len(server.servers) == 0 {
+			server.servers = append(server.servers, newUpstream(&LocalNameServer{}, nil, 0, nil))
 		}
 		return nil
 	})
 	return server
 }
 
+func newNameServer(config *NameServerConfig, dispatcher dispatcher.PacketDispatcher) NameServer {
+	address := config.Address.Address.AsAddress()
+	if address.Family().IsDomain() && address.Domain() == "localhost" {
+		return &LocalNameServer{}
+	}
+	dest := config.Address.AsDestination()
+	timeout := time.Duration(config.Timeout) * time.Millisecond
+	switch config.Protocol {
+	case NameServerConfig_TLS:
+		dest.Network = v2net.Network_TCP
+		return NewTLSNameServer(dest, timeout, config.tlsConfig(), dispatcher)
+	case NameServerConfig_HTTPS:
+		dest.Network = v2net.Network_TCP
+		return NewHTTPSNameServer(dest, config.Url, timeout, config.tlsConfig(), dispatcher)
+	}
+	switch dest.Network {
+	case v2net.Network_Unknown, v2net.Network_UDP:
+		dest.Network = v2net.Network_UDP
+		return NewUDPNameServer(dest, dispatcher)
+	case v2net.Network_TCP:
+		return NewTCPNameServer(dest, timeout, dispatcher)
+	default:
+		log.Warning("DNS: Unsupported network of name server: ", dest)
+		return nil
+	}
+}
+
 func (this *CacheServer) Release() {
+	this.cancel.Cancel()
+	<-this.cancel.WaitForDone()
+	if len(this.cacheFile) > 0 {
+		this.saveSnapshot()
+	}
+}
+
+// maintain sweeps the cache every SweepInterval, and saves it every SnapshotInterval if there is a cache file, until
+// the server is released.
+func (this *CacheServer) maintain() {
+	sweepTicker := time.NewTicker(SweepInterval)
+	defer sweepTicker.Stop()
+	var snapshot <-chan time.Time
+	if len(this.cacheFile) > 0 {
+		snapshotTicker := time.NewTicker(SnapshotInterval)
+		defer snapshotTicker.Stop()
+		snapshot = snapshotTicker.C
+	}
+L:
+	for {
+		select {
+		case <-sweepTicker.C:
+			this.Sweep()
+		case <-snapshot:
+			this.saveSnapshot()
+		case <-this.cancel.WaitForCancel():
+			break L
+		}
+	}
+	this.cancel.Done()
+}
+
+// Private: Visible for testing.
+// Sweep removes the records that can no longer be served, so that the cache doesn't grow with queried names forever.
+func (this *CacheServer) Sweep() {
+	now := time.Now()
+	this.Lock()
+	defer this.Unlock()
+
+	for key, record := range this.records {
+		if record.refreshing {
+			continue
+		}
+		if (record.A != nil && this.staleUntil(record.A).After(now)) ||
+			(record.AAAA != nil && this.staleUntil(record.AAAA).After(now)) {
+			continue
+		}
+		delete(this.records, key)
+	}
+}
+
+// Private: Visible for testing.
+func (this *CacheServer) Size() int {
+	this.RLock()
+	defer this.RUnlock()
+	return len(this.records)
+}
+
+func (this *CacheServer) Watch(watcher RecordWatcher) {
+	this.Lock()
+	defer this.Unlock()
+
+	this.watchers = append(this.watchers, watcher)
+}
 
+func sameIPs(a []net.IP, b []net.IP) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for _, ipa := range a {
+		found := false
+		for _, ipb := range b {
+			if ipa.Equal(ipb) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+func (this *ARecord) valid() bool {
+	return this != nil && this.Expire.After(time.Now())
 }
 
 // Private: Visible for testing.
 func (this *CacheServer) GetCached(domain string) []net.IP {
+	a, aaaa := this.getCachedRecords(domain)
+	if a == nil && aaaa == nil {
+		return nil
+	}
+	ips := make([]net.IP, 0, 16)
+	if a != nil {
+		ips = append(ips, a.IPs...)
+	}
+	if aaaa != nil {
+		ips = append(ips, aaaa.IPs...)
+	}
+	return ips
+}
+
+// getCachedRecords returns the unexpired A and AAAA records of the domain, or nil if there is none.
+func (this *CacheServer) getCachedRecords(domain string) (*ARecord, *ARecord) {
 	this.RLock()
 	defer this.RUnlock()
 
-	if record, found := this.records[domain]; found && record.A.Expire.After(time.Now()) {
-		return record.A.IPs
+	record, found := this.records[domain]
+	if !found {
+		return nil, nil
 	}
-	return nil
+	var a, aaaa *ARecord
+	if record.A.valid() {
+		a = record.A
+	}
+	if record.AAAA.valid() {
+		aaaa = record.AAAA
+	}
+	return a, aaaa
 }
 
-func (this *CacheServer) Get(domain string) []net.IP {
-	if ip, found := this.hosts[domain]; found {
-		return []net.IP{ip}
+// lookupCache returns the cached A and AAAA records under the key that can be served, which may be stale. It also
+// tells whether the records should be refreshed in background, because they are stale or hot and about to expire.
+func (this *CacheServer) lookupCache(key string) (*ARecord, *ARecord, bool) {
+	this.Lock()
+	defer this.Unlock()
+
+	record, found := this.records[key]
+	if !found {
+		return nil, nil, false
 	}
+	record.hits++
 
-	domain = dns.Fqdn(domain)
-	ips := this.GetCached(domain)
-	if ips != nil {
-		return ips
+	now := time.Now()
+	refresh := false
+	servable := func(r *ARecord) *ARecord {
+		switch {
+		case r == nil:
+			return nil
+		case r.Expire.After(now):
+			if this.prefetch && record.hits >= PrefetchMinHits && r.inPrefetchWindow(now) {
+				refresh = true
+			}
+			return r
+		case this.staleUntil(r).After(now):
+			refresh = true
+			return r
+		default:
+			return nil
+		}
 	}
+	a := servable(record.A)
+	aaaa := servable(record.AAAA)
+	return a, aaaa, refresh
+}
 
-	for _, server := range this.servers {
-		response := server.QueryA(domain)
-		select {
-		case a, open := <-response:
-			if !open || a == nil {
-				continue
+// staleUntil returns the time until which the record may be served, including the time it may be served stale. For
+// a record kept after failing to refresh it, the time is based on its original expiry. Negative records are never
+// served stale.
+func (this *CacheServer) staleUntil(record *ARecord) time.Time {
+	if len(record.IPs) == 0 {
+		return record.Expire
+	}
+	if !record.OriginalExpire.IsZero() {
+		return record.OriginalExpire.Add(this.staleTTL)
+	}
+	return record.Expire.Add(this.staleTTL)
+}
+
+// inPrefetchWindow returns true if the record is about to expire.
+func (this *ARecord) inPrefetchWindow(now time.Time) bool {
+	ttl := this.Expire.Sub(this.Created)
+	return this.Expire.Sub(now) < ttl*PrefetchWindow/100
+}
+
+// refresh queries the domain again in background, unless it is being refreshed already.
+func (this *CacheServer) refresh(domain string, subnet *net.IPNet) {
+	this.Lock()
+	record, found := this.records[recordKey(domain, subnet)]
+	if !found || record.refreshing {
+		this.Unlock()
+		return
+	}
+	record.refreshing = true
+	queryA := record.A != nil
+	queryAAAA := record.AAAA != nil
+	this.Unlock()
+
+	go func() {
+		log.Debug("DNS: Refreshing domain ", domain)
+		var client net.IP
+		if subnet != nil {
+			client = subnet.IP
+		}
+		a, aaaa := this.query(domain, client, queryA, queryAAAA)
+
+		// Records are replaced only by actual answers. Without one, the stale records are kept being served.
+		now := time.Now()
+		this.Lock()
+		if queryA {
+			if a == nil {
+				a = this.retainRecord(record.A, now)
+			} else {
+				a = this.normalizeRecord(a)
 			}
-			this.Lock()
-			this.records[domain] = &DomainRecord{
-				A: a,
+		}
+		if queryAAAA {
+			if aaaa == nil {
+				aaaa = this.retainRecord(record.AAAA, now)
+			} else {
+				aaaa = this.normalizeRecord(aaaa)
 			}
-			this.Unlock()
-			log.Debug("DNS: Returning ", len(a.IPs), " IPs for domain ", domain)
-			return a.IPs
-		case <-time.After(QueryTimeout):
 		}
+		this.Unlock()
+		this.updateRecords(domain, subnet, a, aaaa)
+
+		this.Lock()
+		record.refreshing = false
+		record.hits = 0
+		this.Unlock()
+	}()
+}
+
+// retainRecord returns the record to keep after failing to refresh it. It is a copy of the record that doesn't
+// expire before RefreshRetryInterval, so that it is not refreshed again too soon, but never beyond the time the
+// original record may be served. After that, the record is replaced by a negative one.
+func (this *CacheServer) retainRecord(record *ARecord, now time.Time) *ARecord {
+	if record == nil {
+		return nil
+	}
+	staleUntil := this.staleUntil(record)
+	if !staleUntil.After(now) {
+		return this.normalizeRecord(nil)
+	}
+	expire := now.Add(RefreshRetryInterval)
+	if record.Expire.After(expire) {
+		return record
+	}
+	if expire.After(staleUntil) {
+		expire = staleUntil
+	}
+	originalExpire := record.OriginalExpire
+	if originalExpire.IsZero() {
+		originalExpire = record.Expire
+	}
+	return &ARecord{
+		IPs:            record.IPs,
+		Created:        now,
+		Expire:         expire,
+		Rcode:          record.Rcode,
+		OriginalExpire: originalExpire,
+	}
+}
+
+// normalizeRecord turns a failed answer into a negative record, and bounds the TTL of negative records.
+func (this *CacheServer) normalizeRecord(record *ARecord) *ARecord {
+	now := time.Now()
+	expire := now.Add(this.negativeTTL)
+	if record == nil {
+		return &ARecord{
+			Created: now,
+			Expire:  expire,
+			Rcode:   dns.RcodeServerFailure,
+		}
+	}
+	if len(record.IPs) == 0 && record.Expire.After(expire) {
+		record.Expire = expire
+	}
+	return record
+}
+
+func (this *CacheServer) updateRecords(domain string, subnet *net.IPNet, a *ARecord, aaaa *ARecord) {
+	this.Lock()
+	key := recordKey(domain, subnet)
+	record, found := this.records[key]
+	if !found {
+		record = &DomainRecord{
+			subnet: subnet,
+		}
+		this.records[key] = record
 	}
+	changed := false
+	if a != nil {
+		changed = changed || (record.A != nil && !sameIPs(record.A.IPs, a.IPs))
+		record.A = a
+	}
+	if aaaa != nil {
+		changed = changed || (record.AAAA != nil && !sameIPs(record.AAAA.IPs, aaaa.IPs))
+		record.AAAA = aaaa
+	}
+	watchers := this.watchers
+	this.Unlock()
+
+	this.learn(domain, a)
+	this.learn(domain, aaaa)
+
+	if changed {
+		log.Debug("DNS: IPs changed for domain ", domain)
+		for _, watcher := range watchers {
+			watcher(strings.TrimSuffix(domain, "."))
+		}
+	}
+}
+
+// learn remembers the domain of the IPs in the record, for as long as the record may be served.
+func (this *CacheServer) learn(domain string, record *ARecord) {
+	if record == nil {
+		return
+	}
+	domain = strings.TrimSuffix(domain, ".")
+	expire := this.staleUntil(record)
+	for _, ip := range record.IPs {
+		this.reverse.Learn(ip, domain, expire)
+	}
+}
+
+// recordKey returns the key of the records of the domain for the client subnet in the cache.
+func recordKey(domain string, subnet *net.IPNet) string {
+	if subnet == nil {
+		return domain
+	}
+	return domain + "|" + subnet.String()
+}
 
-	log.Debug("DNS: Returning nil for domain ", domain)
+// clientSubnetOf returns the client subnet that the records of the domain are bound to for the client. As answers
+// may differ per EDNS Client Subnet, they are cached per client subnet if any name server of the domain derives the
+// subnet from the client. It returns nil if the records can be shared by all clients.
+func (this *CacheServer) clientSubnetOf(domain string, client net.IP) *net.IPNet {
+	if client == nil {
+		return nil
+	}
+	for _, server := range this.servers {
+		if server.matcher != nil && !server.matches(domain) {
+			continue
+		}
+		if server.clientSubnet.IsAuto() {
+			return server.clientSubnet.For(client)
+		}
+	}
 	return nil
 }
+
+// serversFor returns the name servers to query for the domain. Name servers serving the domain explicitly come
+// first, followed by the ones serving all domains. Benched name servers are skipped, unless all of them are benched.
+func (this *CacheServer) serversFor(domain string) []*upstream {
+	candidates := make([]*upstream, 0, len(this.servers))
+	for _, server := range this.servers {
+		if server.matches(domain) {
+			candidates = append(candidates, server)
+		}
+	}
+	for _, server := range this.servers {
+		if server.matcher == nil {
+			candidates = append(candidates, server)
+		}
+	}
+
+	servers := make([]*upstream, 0, len(candidates))
+	for _, server := range candidates {
+		if !server.benched() {
+			servers = append(servers, server)
+		}
+	}
+	if len(servers) == 0 {
+		return candidates
+	}
+	return servers
+}
+
+// query sends A and/or AAAA queries to the name servers serving the domain, as per the query strategy.
+func (this *CacheServer) query(domain string, client net.IP, queryA bool, queryAAAA bool) (*ARecord, *ARecord) {
+	servers := this.serversFor(domain)
+	switch this.strategy {
+	case Config_Parallel:
+		return queryParallel(servers, 0, domain, client, queryA, queryAAAA)
+	case Config_PreferFirst:
+		return queryParallel(servers, this.headStart, domain, client, queryA, queryAAAA)
+	default:
+		return querySequential(servers, domain, client, queryA, queryAAAA)
+	}
+}
+
+func (this *CacheServer) FakeIP(domain string) net.IP {
+	if this.fakeIPs == nil {
+		return nil
+	}
+	if this.hosts.Lookup(domain) != nil {
+		return nil
+	}
+	return this.fakeIPs.Get(domain)
+}
+
+func (this *CacheServer) LookupFakeIP(ip net.IP) (string, bool) {
+	if this.fakeIPs == nil {
+		return "", false
+	}
+	return this.fakeIPs.Lookup(ip)
+}
+
+func (this *CacheServer) LookupDomain(ip net.IP) (string, bool) {
+	return this.reverse.Lookup(ip)
+}
+
+func (this *CacheServer) Resolve(domain string, option IPOption, client net.IP) *Answer {
+	for depth := 0; depth <= MaxAliasDepth; depth++ {
+		entry := this.hosts.Lookup(domain)
+		if entry == nil {
+			return this.resolve(domain, option, client)
+		}
+		if len(entry.Alias) == 0 {
+			var ipv4, ipv6 []net.IP
+			for _, ip := range entry.IPs {
+				if ip.To4() != nil {
+					ipv4 = append(ipv4, ip)
+				} else {
+					ipv6 = append(ipv6, ip)
+				}
+			}
+			return &Answer{
+				IPs:   option.Apply(ipv4, ipv6),
+				Rcode: dns.RcodeSuccess,
+			}
+		}
+		log.Debug("DNS: Resolving ", domain, " as alias of ", entry.Alias)
+		domain = entry.Alias
+	}
+	log.Warning("DNS: Too many aliases for domain ", domain)
+	return &Answer{
+		Rcode: dns.RcodeServerFailure,
+	}
+}
+
+// resolve returns the answer of the domain from the cache or name servers.
+func (this *CacheServer) resolve(domain string, option IPOption, client net.IP) *Answer {
+	domain = dns.Fqdn(domain)
+	subnet := this.clientSubnetOf(domain, client)
+	a, aaaa, refresh := this.lookupCache(recordKey(domain, subnet))
+	queryA := option.WantIPv4() && a == nil
+	queryAAAA := option.WantIPv6() && aaaa == nil
+	if queryA || queryAAAA {
+		newA, newAAAA := this.query(domain, client, queryA, queryAAAA)
+		if queryA {
+			newA = this.normalizeRecord(newA)
+			a = newA
+		}
+		if queryAAAA {
+			newAAAA = this.normalizeRecord(newAAAA)
+			aaaa = newAAAA
+		}
+		this.updateRecords(domain, subnet, newA, newAAAA)
+	} else if refresh {
+		this.refresh(domain, subnet)
+	}
+
+	if !option.WantIPv4() {
+		a = nil
+	}
+	if !option.WantIPv6() {
+		aaaa = nil
+	}
+	if a == nil && aaaa == nil {
+		log.Debug("DNS: Returning nil for domain ", domain)
+		return &Answer{
+			Rcode: dns.RcodeServerFailure,
+		}
+	}
+	var ipv4, ipv6 []net.IP
+	if a != nil {
+		ipv4 = a.IPs
+	}
+	if aaaa != nil {
+		ipv6 = aaaa.IPs
+	}
+	answer := &Answer{
+		IPs: option.Apply(ipv4, ipv6),
+		TTL: remainingTTL(a, aaaa),
+	}
+	answer.Rcode = answerRcode(len(answer.IPs) > 0, a, aaaa)
+	log.Debug("DNS: Returning ", len(answer.IPs), " IPs for domain ", domain)
+	return answer
+}
+
+// remainingTTL returns the least remaining TTL of the records, which is at least a second, even for stale records.
+func remainingTTL(records ...*ARecord) time.Duration {
+	now := time.Now()
+	ttl := time.Duration(-1)
+	for _, record := range records {
+		if record == nil {
+			continue
+		}
+		if remaining := record.Expire.Sub(now); ttl < 0 || remaining < ttl {
+			ttl = remaining
+		}
+	}
+	if ttl < time.Second {
+		ttl = time.Second
+	}
+	return ttl
+}
+
+// answerRcode returns the response code of an answer made of the records. Without any IP, the answer is NXDOMAIN if
+// the domain doesn't exist, or SERVFAIL if name servers failed to answer.
+func answerRcode(hasIPs bool, records ...*ARecord) int {
+	if hasIPs {
+		return dns.RcodeSuccess
+	}
+	rcode := dns.RcodeSuccess
+	for _, record := range records {
+		if record == nil {
+			continue
+		}
+		switch record.Rcode {
+		case dns.RcodeNameError:
+			return dns.RcodeNameError
+		case dns.RcodeSuccess:
+		default:
+			rcode = dns.RcodeServerFailure
+		}
+	}
+	return rcode
+}
@@ -1,8 +1,22 @@
 package dns_test
 
 import (
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"io/ioutil"
 	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
+	"time"
+
+	"github.com/miekg/dns"
 
 	"v2ray.com/core/app"
 	"v2ray.com/core/app/dispatcher"
@@ -50,7 +64,770 @@ func TestDnsAdd(t *testing.T) {
 	space.BindApp(APP_ID, server)
 	space.Initialize()
 
-	ips := server.Get(domain)
+	ips := server.Resolve(domain, IPv4AndIPv6, nil).IPs
 	assert.Int(len(ips)).Equals(1)
 	assert.IP(ips[0].To4()).Equals(net.IP([]byte{127, 0, 0, 1}))
 }
+
+func TestIPOption(t *testing.T) {
+	assert := assert.On(t)
+
+	ipv4 := []net.IP{net.IP([]byte{1, 2, 3, 4})}
+	ipv6 := []net.IP{net.ParseIP("2001:db8::1")}
+
+	assert.Int(len(IPv4AndIPv6.Apply(ipv4, ipv6))).Equals(2)
+	assert.IP(IPv4AndIPv6.Apply(ipv4, ipv6)[0]).Equals(ipv4[0])
+	assert.IP(IPv4Only.Apply(ipv4, ipv6)[0]).Equals(ipv4[0])
+	assert.IP(IPv6Only.Apply(ipv4, ipv6)[0]).Equals(ipv6[0])
+	assert.Int(len(IPv6Only.Apply(ipv4, nil))).Equals(0)
+	assert.IP(PreferIPv4.Apply(nil, ipv6)[0]).Equals(ipv6[0])
+	assert.IP(PreferIPv6.Apply(ipv4, ipv6)[0]).Equals(ipv6[0])
+	assert.IP(PreferIPv6.Apply(ipv4, nil)[0]).Equals(ipv4[0])
+}
+
+func TestStaticHostsWithIPOption(t *testing.T) {
+	assert := assert.On(t)
+
+	space := app.NewSpace()
+	space.BindApp(dispatcher.APP_ID, dispatchers.NewDefaultDispatcher(space))
+	server := NewCacheServer(space, &Config{
+		Hosts: map[string]*v2net.AddressPB{
+			"v6.v2ray.com": {
+				Address: &v2net.AddressPB_Ip{
+					Ip: net.ParseIP("2001:db8::1"),
+				},
+			},
+		},
+	})
+
+	ips := server.Resolve("v6.v2ray.com", PreferIPv4, nil).IPs
+	assert.Int(len(ips)).Equals(1)
+	assert.IP(ips[0]).Equals(net.ParseIP("2001:db8::1"))
+	assert.Int(len(server.Resolve("v6.v2ray.com", IPv4Only, nil).IPs)).Equals(0)
+}
+
+// newTestDNSHandler creates a handler that answers A queries with the given IP.
+func newTestDNSHandler(ip string) dns.Handler {
+	return dns.HandlerFunc(func(w dns.ResponseWriter, r *dns.Msg) {
+		resp := new(dns.Msg)
+		resp.SetReply(r)
+		if r.Question[0].Qtype == dns.TypeA {
+			rr, _ := dns.NewRR(r.Question[0].Name + " 300 IN A " + ip)
+			resp.Answer = append(resp.Answer, rr)
+		}
+		w.WriteMsg(resp)
+	})
+}
+
+var testDNSHandler = newTestDNSHandler("10.0.0.1")
+
+// startTCPDNSServer starts a DNS server over TCP on a random local port.
+func startTCPDNSServer(handler dns.Handler) (*dns.Server, *v2net.DestinationPB, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, nil, err
+	}
+	dnsServer := &dns.Server{
+		Listener: listener,
+		Handler:  handler,
+	}
+	go dnsServer.ActivateAndServe()
+	return dnsServer, &v2net.DestinationPB{
+		Network: v2net.Network_TCP,
+		Address: v2net.NewAddressPB(v2net.LocalHostIP),
+		Port:    uint32(listener.Addr().(*net.TCPAddr).Port),
+	}, nil
+}
+
+// newTestSpace creates a space with a freedom outbound and a CacheServer of the given config.
+func newTestSpace(config *Config) (app.Space, *CacheServer) {
+	space := app.NewSpace()
+
+	outboundHandlerManager := proxyman.NewDefaultOutboundHandlerManager()
+	outboundHandlerManager.SetDefaultHandler(
+		freedom.NewFreedomConnection(
+			&freedom.Config{},
+			space,
+			&proxy.OutboundHandlerMeta{
+				Address: v2net.AnyIP,
+				StreamSettings: &internet.StreamConfig{
+					Network: v2net.Network_RawTCP,
+				},
+			}))
+	space.BindApp(proxyman.APP_ID_OUTBOUND_MANAGER, outboundHandlerManager)
+	space.BindApp(dispatcher.APP_ID, dispatchers.NewDefaultDispatcher(space))
+
+	server := NewCacheServer(space, config)
+	space.BindApp(APP_ID, server)
+	return space, server
+}
+
+func TestTCPNameServer(t *testing.T) {
+	assert := assert.On(t)
+
+	dnsServer, dest, err := startTCPDNSServer(testDNSHandler)
+	assert.Error(err).IsNil()
+	defer dnsServer.Shutdown()
+
+	space, server := newTestSpace(&Config{
+		NameServers: []*v2net.DestinationPB{dest},
+	})
+	assert.Error(space.Initialize()).IsNil()
+
+	ips := server.Resolve("tcp.v2ray.com", IPv4AndIPv6, nil).IPs
+	assert.Int(len(ips)).Equals(1)
+	assert.IP(ips[0].To4()).Equals(net.IP([]byte{10, 0, 0, 1}))
+
+	// The second query is sent over the same connection.
+	ips = server.Resolve("tcp2.v2ray.com", IPv4AndIPv6, nil).IPs
+	assert.Int(len(ips)).Equals(1)
+}
+
+func TestDomainScopedNameServer(t *testing.T) {
+	assert := assert.On(t)
+
+	publicServer, publicDest, err := startTCPDNSServer(newTestDNSHandler("10.0.0.1"))
+	assert.Error(err).IsNil()
+	defer publicServer.Shutdown()
+
+	corpServer, corpDest, err := startTCPDNSServer(newTestDNSHandler("10.0.0.2"))
+	assert.Error(err).IsNil()
+	defer corpServer.Shutdown()
+
+	space, server := newTestSpace(&Config{
+		Servers: []*NameServerConfig{
+			{
+				Address: publicDest,
+			},
+			{
+				Address: corpDest,
+				Domains: []string{"regexp:\\.corp$"},
+			},
+		},
+	})
+	assert.Error(space.Initialize()).IsNil()
+
+	ips := server.Resolve("mail.corp", IPv4AndIPv6, nil).IPs
+	assert.Int(len(ips)).Equals(1)
+	assert.IP(ips[0].To4()).Equals(net.IP([]byte{10, 0, 0, 2}))
+
+	ips = server.Resolve("www.v2ray.com", IPv4AndIPv6, nil).IPs
+	assert.Int(len(ips)).Equals(1)
+	assert.IP(ips[0].To4()).Equals(net.IP([]byte{10, 0, 0, 1}))
+
+	ips = server.Resolve("corp.v2ray.com", IPv4AndIPv6, nil).IPs
+	assert.Int(len(ips)).Equals(1)
+	assert.IP(ips[0].To4()).Equals(net.IP([]byte{10, 0, 0, 1}))
+}
+
+// startSilentServer starts a TCP server that accepts connections but never answers.
+func startSilentServer() (net.Listener, *v2net.DestinationPB, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, nil, err
+	}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go io.Copy(ioutil.Discard, conn)
+		}
+	}()
+	return listener, &v2net.DestinationPB{
+		Network: v2net.Network_TCP,
+		Address: v2net.NewAddressPB(v2net.LocalHostIP),
+		Port:    uint32(listener.Addr().(*net.TCPAddr).Port),
+	}, nil
+}
+
+func TestParallelQueryStrategy(t *testing.T) {
+	assert := assert.On(t)
+
+	silentServer, silentDest, err := startSilentServer()
+	assert.Error(err).IsNil()
+	defer silentServer.Close()
+
+	dnsServer, dest, err := startTCPDNSServer(testDNSHandler)
+	assert.Error(err).IsNil()
+	defer dnsServer.Shutdown()
+
+	for _, strategy := range []Config_QueryStrategy{Config_Parallel, Config_PreferFirst} {
+		space, server := newTestSpace(&Config{
+			NameServers:   []*v2net.DestinationPB{silentDest, dest},
+			QueryStrategy: strategy,
+			HeadStart:     50,
+		})
+		assert.Error(space.Initialize()).IsNil()
+
+		start := time.Now()
+		ips := server.Resolve("parallel.v2ray.com", IPv4AndIPv6, nil).IPs
+		assert.Int(len(ips)).Equals(1)
+		assert.IP(ips[0].To4()).Equals(net.IP([]byte{10, 0, 0, 1}))
+		assert.Bool(time.Since(start) < QueryTimeout/2).IsTrue()
+	}
+}
+
+func TestBenchUnhealthyNameServer(t *testing.T) {
+	assert := assert.On(t)
+
+	silentServer, silentDest, err := startSilentServer()
+	assert.Error(err).IsNil()
+	defer silentServer.Close()
+
+	dnsServer, dest, err := startTCPDNSServer(testDNSHandler)
+	assert.Error(err).IsNil()
+	defer dnsServer.Shutdown()
+
+	space, server := newTestSpace(&Config{
+		Servers: []*NameServerConfig{
+			{
+				Address: silentDest,
+				Timeout: 100,
+			},
+			{
+				Address: dest,
+			},
+		},
+	})
+	assert.Error(space.Initialize()).IsNil()
+
+	for i := 0; i < BenchThreshold; i++ {
+		ips := server.Resolve(fmt.Sprint("bench", i, ".v2ray.com"), IPv4AndIPv6, nil).IPs
+		assert.Int(len(ips)).Equals(1)
+	}
+
+	// The silent server is benched, so the query goes to the healthy one directly.
+	start := time.Now()
+	ips := server.Resolve("benched.v2ray.com", IPv4AndIPv6, nil).IPs
+	assert.Int(len(ips)).Equals(1)
+	assert.Bool(time.Since(start) < time.Millisecond*100).IsTrue()
+}
+
+func TestInvalidDomainScopedNameServer(t *testing.T) {
+	assert := assert.On(t)
+
+	space, _ := newTestSpace(&Config{
+		Servers: []*NameServerConfig{{
+			Address: &v2net.DestinationPB{
+				Network: v2net.Network_UDP,
+				Address: v2net.NewAddressPB(v2net.LocalHostIP),
+				Port:    53,
+			},
+			Domains: []string{"regexp:("},
+		}},
+	})
+	assert.Error(space.Initialize()).IsNotNil()
+}
+
+func TestTLSNameServer(t *testing.T) {
+	assert := assert.On(t)
+
+	// Borrow the self-signed certificate of httptest.
+	httpServer := httptest.NewTLSServer(http.NotFoundHandler())
+	defer httpServer.Close()
+
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", httpServer.TLS)
+	assert.Error(err).IsNil()
+	dnsServer := &dns.Server{
+		Listener: listener,
+		Net:      "tcp-tls",
+		Handler:  testDNSHandler,
+	}
+	go dnsServer.ActivateAndServe()
+	defer dnsServer.Shutdown()
+
+	space, server := newTestSpace(&Config{
+		Servers: []*NameServerConfig{{
+			Address: &v2net.DestinationPB{
+				Network: v2net.Network_TCP,
+				Address: v2net.NewAddressPB(v2net.LocalHostIP),
+				Port:    uint32(listener.Addr().(*net.TCPAddr).Port),
+			},
+			Protocol:      NameServerConfig_TLS,
+			AllowInsecure: true,
+		}},
+	})
+	assert.Error(space.Initialize()).IsNil()
+
+	ips := server.Resolve("tls.v2ray.com", IPv4AndIPv6, nil).IPs
+	assert.Int(len(ips)).Equals(1)
+	assert.IP(ips[0].To4()).Equals(net.IP([]byte{10, 0, 0, 1}))
+
+	ips = server.Resolve("tls2.v2ray.com", IPv4AndIPv6, nil).IPs
+	assert.Int(len(ips)).Equals(1)
+}
+
+func TestTLSNameServerTimeout(t *testing.T) {
+	assert := assert.On(t)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Error(err).IsNil()
+	defer listener.Close()
+	accepted := make(chan bool, 4)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			accepted <- true
+			go io.Copy(ioutil.Discard, conn)
+		}
+	}()
+
+	space, _ := newTestSpace(&Config{})
+	assert.Error(space.Initialize()).IsNil()
+	nameServer := NewTLSNameServer(
+		v2net.TCPDestination(v2net.LocalHostIP, v2net.Port(listener.Addr().(*net.TCPAddr).Port)),
+		time.Millisecond*500,
+		&tls.Config{InsecureSkipVerify: true},
+		space.GetApp(dispatcher.APP_ID).(dispatcher.PacketDispatcher))
+
+	// The TLS handshake never completes, but the query returns at once, and times out eventually.
+	start := time.Now()
+	response := nameServer.QueryA("timeout.v2ray.com", nil)
+	assert.Bool(time.Since(start) < time.Second).IsTrue()
+	select {
+	case record, open := <-response:
+		assert.Bool(open).IsFalse()
+		assert.Bool(record == nil).IsTrue()
+	case <-time.After(time.Second * 2):
+		t.Fatal("Query didn't time out.")
+	}
+	<-accepted
+
+	// The broken connection is not reused.
+	nameServer.QueryA("timeout2.v2ray.com", nil)
+	select {
+	case <-accepted:
+	case <-time.After(time.Second * 2):
+		t.Fatal("No new connection.")
+	}
+}
+
+// dohHandler serves DNS over HTTPS with testDNSHandler, by both GET and POST.
+type dohHandler struct {
+	methods []string
+}
+
+func (this *dohHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	this.methods = append(this.methods, r.Method)
+
+	var payload []byte
+	var err error
+	if r.Method == "GET" {
+		payload, err = base64.RawURLEncoding.DecodeString(r.URL.Query().Get("dns"))
+	} else {
+		payload, err = ioutil.ReadAll(r.Body)
+	}
+	msg := new(dns.Msg)
+	if err != nil || msg.Unpack(payload) != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+
+	resp := new(dns.Msg)
+	resp.SetReply(msg)
+	if msg.Question[0].Qtype == dns.TypeA {
+		rr, _ := dns.NewRR(msg.Question[0].Name + " 300 IN A 10.0.0.2")
+		resp.Answer = append(resp.Answer, rr)
+	}
+	reply, _ := resp.Pack()
+	w.Header().Set("Content-Type", "application/dns-message")
+	w.Write(reply)
+}
+
+func TestHTTPSNameServer(t *testing.T) {
+	assert := assert.On(t)
+
+	handler := new(dohHandler)
+	httpServer := httptest.NewTLSServer(handler)
+	defer httpServer.Close()
+
+	port := uint32(httpServer.Listener.Addr().(*net.TCPAddr).Port)
+	newConfig := func(url string) *NameServerConfig {
+		return &NameServerConfig{
+			Address: &v2net.DestinationPB{
+				Network: v2net.Network_TCP,
+				Address: v2net.NewAddressPB(v2net.LocalHostIP),
+				Port:    port,
+			},
+			Protocol:      NameServerConfig_HTTPS,
+			Url:           url,
+			AllowInsecure: true,
+		}
+	}
+
+	space, server := newTestSpace(&Config{
+		Servers: []*NameServerConfig{newConfig(httpServer.URL + "/dns-query")},
+	})
+	assert.Error(space.Initialize()).IsNil()
+
+	ips := server.Resolve("doh.v2ray.com", IPv4AndIPv6, nil).IPs
+	assert.Int(len(ips)).Equals(1)
+	assert.IP(ips[0].To4()).Equals(net.IP([]byte{10, 0, 0, 2}))
+	assert.String(handler.methods[0]).Equals("POST")
+
+	handler.methods = nil
+	space, server = newTestSpace(&Config{
+		Servers: []*NameServerConfig{newConfig(httpServer.URL + "/dns-query{?dns}")},
+	})
+	assert.Error(space.Initialize()).IsNil()
+
+	ips = server.Resolve("doh2.v2ray.com", IPv4AndIPv6, nil).IPs
+	assert.Int(len(ips)).Equals(1)
+	assert.String(handler.methods[0]).Equals("GET")
+}
+
+// countingHandler answers A queries of domains starting with "empty" with no IP, and other A queries with 10.0.0.1,
+// in the given TTL. It counts the A queries.
+type countingHandler struct {
+	sync.Mutex
+	ttl     int
+	queries int
+}
+
+func (this *countingHandler) ServeDNS(w dns.ResponseWriter, r *dns.Msg) {
+	resp := new(dns.Msg)
+	resp.SetReply(r)
+	question := r.Question[0]
+	if question.Qtype == dns.TypeA {
+		this.Lock()
+		this.queries++
+		this.Unlock()
+		if !strings.HasPrefix(question.Name, "empty") {
+			rr, _ := dns.NewRR(fmt.Sprint(question.Name, " ", this.ttl, " IN A 10.0.0.1"))
+			resp.Answer = append(resp.Answer, rr)
+		}
+	}
+	w.WriteMsg(resp)
+}
+
+func (this *countingHandler) Queries() int {
+	this.Lock()
+	defer this.Unlock()
+	return this.queries
+}
+
+func TestNegativeCache(t *testing.T) {
+	assert := assert.On(t)
+
+	handler := &countingHandler{ttl: 300}
+	dnsServer, dest, err := startTCPDNSServer(handler)
+	assert.Error(err).IsNil()
+	defer dnsServer.Shutdown()
+
+	space, server := newTestSpace(&Config{
+		NameServers: []*v2net.DestinationPB{dest},
+		NegativeTtl: 1,
+	})
+	assert.Error(space.Initialize()).IsNil()
+
+	assert.Int(len(server.Resolve("empty.v2ray.com", IPv4Only, nil).IPs)).Equals(0)
+	assert.Int(len(server.Resolve("empty.v2ray.com", IPv4Only, nil).IPs)).Equals(0)
+	assert.Int(handler.Queries()).Equals(1)
+
+	time.Sleep(time.Millisecond * 1100)
+	assert.Int(len(server.Resolve("empty.v2ray.com", IPv4Only, nil).IPs)).Equals(0)
+	assert.Int(handler.Queries()).Equals(2)
+}
+
+func TestNegativeCacheOfFailures(t *testing.T) {
+	assert := assert.On(t)
+
+	silentServer, silentDest, err := startSilentServer()
+	assert.Error(err).IsNil()
+	defer silentServer.Close()
+
+	space, server := newTestSpace(&Config{
+		Servers: []*NameServerConfig{{
+			Address: silentDest,
+			Timeout: 100,
+		}},
+	})
+	assert.Error(space.Initialize()).IsNil()
+
+	assert.Int(len(server.Resolve("silent.v2ray.com", IPv4Only, nil).IPs)).Equals(0)
+
+	start := time.Now()
+	assert.Int(len(server.Resolve("silent.v2ray.com", IPv4Only, nil).IPs)).Equals(0)
+	assert.Bool(time.Since(start) < time.Millisecond*50).IsTrue()
+}
+
+func TestSweepExpiredRecords(t *testing.T) {
+	assert := assert.On(t)
+
+	handler := &countingHandler{ttl: 300}
+	dnsServer, dest, err := startTCPDNSServer(handler)
+	assert.Error(err).IsNil()
+	defer dnsServer.Shutdown()
+
+	space, server := newTestSpace(&Config{
+		NameServers: []*v2net.DestinationPB{dest},
+		NegativeTtl: 1,
+		StaleTtl:    60,
+	})
+	assert.Error(space.Initialize()).IsNil()
+	defer server.Release()
+
+	for i := 0; i < 10; i++ {
+		assert.Int(len(server.Resolve(fmt.Sprint("empty", i, ".v2ray.com"), IPv4Only, nil).IPs)).Equals(0)
+	}
+	assert.Int(len(server.Resolve("kept.v2ray.com", IPv4Only, nil).IPs)).Equals(1)
+	assert.Int(server.Size()).Equals(11)
+
+	// Negative records are removed once they expire, regardless of the stale TTL.
+	time.Sleep(time.Millisecond * 1100)
+	server.Sweep()
+	assert.Int(server.Size()).Equals(1)
+	assert.Int(len(server.GetCached("kept.v2ray.com."))).Equals(1)
+}
+
+func TestServeStale(t *testing.T) {
+	assert := assert.On(t)
+
+	handler := &countingHandler{ttl: 1}
+	dnsServer, dest, err := startTCPDNSServer(handler)
+	assert.Error(err).IsNil()
+	defer dnsServer.Shutdown()
+
+	space, server := newTestSpace(&Config{
+		NameServers: []*v2net.DestinationPB{dest},
+		StaleTtl:    60,
+	})
+	assert.Error(space.Initialize()).IsNil()
+
+	assert.Int(len(server.Resolve("stale.v2ray.com", IPv4Only, nil).IPs)).Equals(1)
+	assert.Int(handler.Queries()).Equals(1)
+
+	time.Sleep(time.Millisecond * 1100)
+	assert.Int(len(server.Resolve("stale.v2ray.com", IPv4Only, nil).IPs)).Equals(1)
+	time.Sleep(time.Millisecond * 100)
+	assert.Int(handler.Queries()).Equals(2)
+}
+
+func TestServeStaleOnRefreshFailure(t *testing.T) {
+	assert := assert.On(t)
+
+	handler := &countingHandler{ttl: 1}
+	dnsServer, dest, err := startTCPDNSServer(handler)
+	assert.Error(err).IsNil()
+
+	space, server := newTestSpace(&Config{
+		Servers: []*NameServerConfig{{
+			Address: dest,
+			Timeout: 100,
+		}},
+		StaleTtl: 60,
+	})
+	assert.Error(space.Initialize()).IsNil()
+
+	assert.Int(len(server.Resolve("stale.v2ray.com", IPv4Only, nil).IPs)).Equals(1)
+	dnsServer.Shutdown()
+
+	time.Sleep(time.Millisecond * 1100)
+	assert.Int(len(server.Resolve("stale.v2ray.com", IPv4Only, nil).IPs)).Equals(1)
+
+	// The refresh fails, and the stale IP is still served.
+	time.Sleep(time.Millisecond * 300)
+	ips := server.Resolve("stale.v2ray.com", IPv4Only, nil).IPs
+	assert.Int(len(ips)).Equals(1)
+	assert.IP(ips[0].To4()).Equals(net.IP([]byte{10, 0, 0, 1}))
+	assert.Int(len(server.GetCached("stale.v2ray.com."))).Equals(1)
+}
+
+func TestStaleTTLBoundsFailedRefreshes(t *testing.T) {
+	assert := assert.On(t)
+
+	handler := &countingHandler{ttl: 1}
+	dnsServer, dest, err := startTCPDNSServer(handler)
+	assert.Error(err).IsNil()
+
+	space, server := newTestSpace(&Config{
+		Servers: []*NameServerConfig{{
+			Address: dest,
+			Timeout: 100,
+		}},
+		StaleTtl: 1,
+	})
+	assert.Error(space.Initialize()).IsNil()
+
+	assert.Int(len(server.Resolve("stale.v2ray.com", IPv4Only, nil).IPs)).Equals(1)
+	dnsServer.Shutdown()
+
+	// The record is served stale, while refreshing it fails.
+	time.Sleep(time.Millisecond * 1100)
+	assert.Int(len(server.Resolve("stale.v2ray.com", IPv4Only, nil).IPs)).Equals(1)
+	time.Sleep(time.Millisecond * 300)
+	assert.Int(len(server.Resolve("stale.v2ray.com", IPv4Only, nil).IPs)).Equals(1)
+
+	// The name server is still down beyond the stale TTL, so the record is no longer served.
+	time.Sleep(time.Millisecond * 800)
+	answer := server.Resolve("stale.v2ray.com", IPv4Only, nil)
+	assert.Int(len(answer.IPs)).Equals(0)
+	assert.Int(answer.Rcode).Equals(dns.RcodeServerFailure)
+}
+
+func TestPrefetch(t *testing.T) {
+	assert := assert.On(t)
+
+	handler := &countingHandler{ttl: 1}
+	dnsServer, dest, err := startTCPDNSServer(handler)
+	assert.Error(err).IsNil()
+	defer dnsServer.Shutdown()
+
+	space, server := newTestSpace(&Config{
+		NameServers: []*v2net.DestinationPB{dest},
+		Prefetch:    true,
+	})
+	assert.Error(space.Initialize()).IsNil()
+
+	assert.Int(len(server.Resolve("hot.v2ray.com", IPv4Only, nil).IPs)).Equals(1)
+	assert.Int(len(server.Resolve("hot.v2ray.com", IPv4Only, nil).IPs)).Equals(1)
+	assert.Int(handler.Queries()).Equals(1)
+
+	time.Sleep(time.Millisecond * 950)
+	assert.Int(len(server.Resolve("hot.v2ray.com", IPv4Only, nil).IPs)).Equals(1)
+	time.Sleep(time.Millisecond * 100)
+	assert.Int(handler.Queries()).Equals(2)
+	assert.Int(len(server.GetCached("hot.v2ray.com."))).Equals(1)
+}
+
+func TestCacheSnapshot(t *testing.T) {
+	assert := assert.On(t)
+
+	dir, err := ioutil.TempDir("", "v2ray-dns")
+	assert.Error(err).IsNil()
+	defer os.RemoveAll(dir)
+	cacheFile := filepath.Join(dir, "cache.json")
+
+	dnsServer, dest, err := startTCPDNSServer(testDNSHandler)
+	assert.Error(err).IsNil()
+
+	space, server := newTestSpace(&Config{
+		NameServers: []*v2net.DestinationPB{dest},
+		CacheFile:   cacheFile,
+	})
+	assert.Error(space.Initialize()).IsNil()
+	assert.Int(len(server.Resolve("snapshot.v2ray.com", IPv4Only, nil).IPs)).Equals(1)
+	server.Release()
+	dnsServer.Shutdown()
+
+	_, server = newTestSpace(&Config{
+		NameServers: []*v2net.DestinationPB{dest},
+		CacheFile:   cacheFile,
+	})
+	ips := server.GetCached("snapshot.v2ray.com.")
+	assert.Int(len(ips)).Equals(1)
+	assert.IP(ips[0].To4()).Equals(net.IP([]byte{10, 0, 0, 1}))
+}
+
+// clientSubnetHandler answers A queries with the address of the EDNS Client Subnet, or 10.0.0.1 if there is none.
+var clientSubnetHandler = dns.HandlerFunc(func(w dns.ResponseWriter, r *dns.Msg) {
+	ip := net.IP([]byte{10, 0, 0, 1})
+	if opt := r.IsEdns0(); opt != nil {
+		for _, option := range opt.Option {
+			if subnet, ok := option.(*dns.EDNS0_SUBNET); ok {
+				ip = subnet.Address
+			}
+		}
+	}
+	resp := new(dns.Msg)
+	resp.SetReply(r)
+	if r.Question[0].Qtype == dns.TypeA {
+		rr, _ := dns.NewRR(r.Question[0].Name + " 300 IN A " + ip.String())
+		resp.Answer = append(resp.Answer, rr)
+	}
+	w.WriteMsg(resp)
+})
+
+func TestClientSubnetOfNameServer(t *testing.T) {
+	assert := assert.On(t)
+
+	dnsServer, dest, err := startTCPDNSServer(clientSubnetHandler)
+	assert.Error(err).IsNil()
+	defer dnsServer.Shutdown()
+
+	space, server := newTestSpace(&Config{
+		Servers: []*NameServerConfig{
+			{Address: dest, Domains: []string{"fixed.v2ray.com"}, ClientSubnet: "1.2.3.4"},
+			{Address: dest},
+		},
+		ClientSubnet: "auto",
+	})
+	assert.Error(space.Initialize()).IsNil()
+
+	ips := server.Resolve("fixed.v2ray.com", IPv4Only, nil).IPs
+	assert.Int(len(ips)).Equals(1)
+	assert.IP(ips[0].To4()).Equals(net.IP([]byte{1, 2, 3, 0}))
+
+	ips = server.Resolve("auto.v2ray.com", IPv4Only, net.ParseIP("5.6.7.8")).IPs
+	assert.Int(len(ips)).Equals(1)
+	assert.IP(ips[0].To4()).Equals(net.IP([]byte{5, 6, 7, 0}))
+
+	ips = server.Resolve("none.v2ray.com", IPv4Only, nil).IPs
+	assert.Int(len(ips)).Equals(1)
+	assert.IP(ips[0].To4()).Equals(net.IP([]byte{10, 0, 0, 1}))
+}
+
+func TestCachePerClientSubnet(t *testing.T) {
+	assert := assert.On(t)
+
+	dnsServer, dest, err := startTCPDNSServer(clientSubnetHandler)
+	assert.Error(err).IsNil()
+	defer dnsServer.Shutdown()
+
+	space, server := newTestSpace(&Config{
+		NameServers:  []*v2net.DestinationPB{dest},
+		ClientSubnet: "auto",
+	})
+	assert.Error(space.Initialize()).IsNil()
+
+	ips := server.Resolve("ecs.v2ray.com", IPv4Only, net.ParseIP("5.6.7.8")).IPs
+	assert.Int(len(ips)).Equals(1)
+	assert.IP(ips[0].To4()).Equals(net.IP([]byte{5, 6, 7, 0}))
+
+	ips = server.Resolve("ecs.v2ray.com", IPv4Only, net.ParseIP("9.9.9.9")).IPs
+	assert.Int(len(ips)).Equals(1)
+	assert.IP(ips[0].To4()).Equals(net.IP([]byte{9, 9, 9, 0}))
+
+	ips = server.Resolve("ecs.v2ray.com", IPv4Only, net.ParseIP("5.6.7.9")).IPs
+	assert.Int(len(ips)).Equals(1)
+	assert.IP(ips[0].To4()).Equals(net.IP([]byte{5, 6, 7, 0}))
+
+	ips = server.Resolve("ecs.v2ray.com", IPv4Only, nil).IPs
+	assert.Int(len(ips)).Equals(1)
+	assert.IP(ips[0].To4()).Equals(net.IP([]byte{10, 0, 0, 1}))
+}
+
+func TestInvalidClientSubnet(t *testing.T) {
+	assert := assert.On(t)
+
+	space, _ := newTestSpace(&Config{
+		ClientSubnet: "v2ray.com",
+	})
+	assert.Error(space.Initialize()).IsNotNil()
+}
+
+func TestLookupDomain(t *testing.T) {
+	assert := assert.On(t)
+
+	dnsServer, dest, err := startTCPDNSServer(testDNSHandler)
+	assert.Error(err).IsNil()
+	defer dnsServer.Shutdown()
+
+	space, server := newTestSpace(&Config{
+		NameServers: []*v2net.DestinationPB{dest},
+	})
+	assert.Error(space.Initialize()).IsNil()
+
+	_, found := server.LookupDomain(net.ParseIP("10.0.0.1"))
+	assert.Bool(found).IsFalse()
+
+	assert.Int(len(server.Resolve("reverse.v2ray.com", IPv4Only, nil).IPs)).Equals(1)
+	domain, found := server.LookupDomain(net.ParseIP("10.0.0.1"))
+	assert.Bool(found).IsTrue()
+	assert.String(domain).Equals("reverse.v2ray.com")
+}
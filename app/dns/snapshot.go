@@ -0,0 +1,83 @@
+package dns
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"time"
+
+	"v2ray.com/core/common/log"
+)
+
+const (
+	// SnapshotInterval is how often the cache is saved to the cache file.
+	SnapshotInterval = time.Minute * 5
+)
+
+// saveSnapshot writes all unexpired records to the cache file. Stale records are kept if they can still be served.
+// Records bound to client subnets are not saved.
+func (this *CacheServer) saveSnapshot() {
+	this.RLock()
+	now := time.Now()
+	records := make(map[string]*DomainRecord, len(this.records))
+	for domain, record := range this.records {
+		if record.subnet != nil {
+			continue
+		}
+		snapshot := &DomainRecord{}
+		if record.A != nil && this.staleUntil(record.A).After(now) {
+			snapshot.A = record.A
+		}
+		if record.AAAA != nil && this.staleUntil(record.AAAA).After(now) {
+			snapshot.AAAA = record.AAAA
+		}
+		if snapshot.A != nil || snapshot.AAAA != nil {
+			records[domain] = snapshot
+		}
+	}
+	data, err := json.Marshal(records)
+	this.RUnlock()
+
+	if err != nil {
+		log.Warning("DNS: Failed to encode cache: ", err)
+		return
+	}
+	// Write to a temporary file first, so that the cache file is never left half-written.
+	tmpFile := this.cacheFile + ".tmp"
+	if err := ioutil.WriteFile(tmpFile, data, 0600); err != nil {
+		log.Warning("DNS: Failed to save cache to ", this.cacheFile, ": ", err)
+		return
+	}
+	if err := os.Rename(tmpFile, this.cacheFile); err != nil {
+		log.Warning("DNS: Failed to save cache to ", this.cacheFile, ": ", err)
+		return
+	}
+	log.Debug("DNS: Saved ", len(records), " domains to ", this.cacheFile)
+}
+
+// restoreSnapshot loads records from the cache file, if it exists.
+func (this *CacheServer) restoreSnapshot() {
+	data, err := ioutil.ReadFile(this.cacheFile)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Warning("DNS: Failed to read cache from ", this.cacheFile, ": ", err)
+		}
+		return
+	}
+	records := make(map[string]*DomainRecord)
+	if err := json.Unmarshal(data, &records); err != nil {
+		log.Warning("DNS: Failed to decode cache from ", this.cacheFile, ": ", err)
+		return
+	}
+
+	this.Lock()
+	defer this.Unlock()
+	for domain, record := range records {
+		if record != nil {
+			this.records[domain] = record
+			this.learn(domain, record.A)
+			this.learn(domain, record.AAAA)
+		}
+	}
+	log.Info("DNS: Restored ", len(records), " domains from ", this.cacheFile)
+}
@@ -0,0 +1,169 @@
+package dns
+
+import (
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"v2ray.com/core/common/log"
+	v2net "v2ray.com/core/common/net"
+)
+
+const (
+	// BenchThreshold is the number of consecutive failures before a name server is benched.
+	BenchThreshold = 3
+	// BenchDuration is how long a benched name server is skipped.
+	BenchDuration = time.Second * 30
+
+	DefaultHeadStart = time.Millisecond * 200
+)
+
+// upstream is a name server in CacheServer, along with its settings and health.
+type upstream struct {
+	sync.Mutex
+	server       NameServer
+	matcher      v2net.DomainMatcher // Domains served by the name server, or nil if it serves all domains.
+	timeout      time.Duration
+	clientSubnet *ClientSubnet
+	failures     int
+	benchedUntil time.Time
+}
+
+func newUpstream(server NameServer, matcher v2net.DomainMatcher, timeout time.Duration, clientSubnet *ClientSubnet) *upstream {
+	if timeout <= 0 {
+		timeout = QueryTimeout
+	}
+	return &upstream{
+		server:       server,
+		matcher:      matcher,
+		timeout:      timeout,
+		clientSubnet: clientSubnet,
+	}
+}
+
+// matches returns true if the name server serves the domain explicitly.
+func (this *upstream) matches(domain string) bool {
+	if this.matcher == nil {
+		return false
+	}
+	return this.matcher.Match(strings.ToLower(strings.TrimSuffix(domain, ".")))
+}
+
+func (this *upstream) benched() bool {
+	this.Lock()
+	defer this.Unlock()
+
+	return this.benchedUntil.After(time.Now())
+}
+
+func (this *upstream) report(success bool) {
+	this.Lock()
+	defer this.Unlock()
+
+	if success {
+		this.failures = 0
+		return
+	}
+	this.failures++
+	if this.failures >= BenchThreshold {
+		log.Warning("DNS: Benching name server for ", BenchDuration, " after ", this.failures, " failures.")
+		this.failures = 0
+		this.benchedUntil = time.Now().Add(BenchDuration)
+	}
+}
+
+// query sends A and/or AAAA queries in parallel to the name server, and waits for them until timeout. client is the
+// address of the DNS client, if any.
+func (this *upstream) query(domain string, client net.IP, queryA bool, queryAAAA bool) (*ARecord, *ARecord) {
+	subnet := this.clientSubnet.For(client)
+	var responseA, responseAAAA <-chan *ARecord
+	if queryA {
+		responseA = this.server.QueryA(domain, subnet)
+	}
+	if queryAAAA {
+		responseAAAA = this.server.QueryAAAA(domain, subnet)
+	}
+
+	var a, aaaa *ARecord
+	timeout := time.After(this.timeout)
+L:
+	for responseA != nil || responseAAAA != nil {
+		select {
+		case record, open := <-responseA:
+			if open {
+				a = record
+			}
+			responseA = nil
+		case record, open := <-responseAAAA:
+			if open {
+				aaaa = record
+			}
+			responseAAAA = nil
+		case <-timeout:
+			break L
+		}
+	}
+	this.report(a != nil || aaaa != nil)
+	return a, aaaa
+}
+
+type queryResult struct {
+	a    *ARecord
+	aaaa *ARecord
+}
+
+// querySequential queries the upstreams one after another, until one of them answers.
+func querySequential(upstreams []*upstream, domain string, client net.IP, queryA bool, queryAAAA bool) (*ARecord, *ARecord) {
+	for _, u := range upstreams {
+		if a, aaaa := u.query(domain, client, queryA, queryAAAA); a != nil || aaaa != nil {
+			return a, aaaa
+		}
+	}
+	return nil, nil
+}
+
+// queryParallel queries the first upstream, and the others after headStart or as soon as the first one fails.
+// The first answer is returned. All upstreams are queried at the same time if headStart is 0.
+func queryParallel(upstreams []*upstream, headStart time.Duration, domain string, client net.IP, queryA bool, queryAAAA bool) (*ARecord, *ARecord) {
+	if len(upstreams) == 0 {
+		return nil, nil
+	}
+
+	results := make(chan queryResult, len(upstreams))
+	start := func(u *upstream) {
+		go func() {
+			a, aaaa := u.query(domain, client, queryA, queryAAAA)
+			results <- queryResult{a: a, aaaa: aaaa}
+		}()
+	}
+
+	started := 1
+	start(upstreams[0])
+	startOthers := func() {
+		for ; started < len(upstreams); started++ {
+			start(upstreams[started])
+		}
+	}
+	var headStartTimer <-chan time.Time
+	if headStart > 0 {
+		headStartTimer = time.After(headStart)
+	} else {
+		startOthers()
+	}
+
+	for finished := 0; finished < started || started < len(upstreams); {
+		select {
+		case result := <-results:
+			if result.a != nil || result.aaaa != nil {
+				return result.a, result.aaaa
+			}
+			finished++
+			startOthers()
+		case <-headStartTimer:
+			headStartTimer = nil
+			startOthers()
+		}
+	}
+	return nil, nil
+}
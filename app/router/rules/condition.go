@@ -2,8 +2,6 @@ package rules
 
 import (
 	"net"
-	"regexp"
-	"strings"
 
 	v2net "v2ray.com/core/common/net"
 )
@@ -62,44 +60,34 @@ func (this *AnyCondition) Len() int {
 	return len(*this)
 }
 
-type PlainDomainMatcher struct {
-	pattern string
+// DomainMatcher matches destinations whose domain matches.
+type DomainMatcher struct {
+	matcher v2net.DomainMatcher
 }
 
-func NewPlainDomainMatcher(pattern string) *PlainDomainMatcher {
-	return &PlainDomainMatcher{
-		pattern: pattern,
+func NewDomainMatcher(matcher v2net.DomainMatcher) *DomainMatcher {
+	return &DomainMatcher{
+		matcher: matcher,
 	}
 }
 
-func (this *PlainDomainMatcher) Apply(dest v2net.Destination) bool {
-	if !dest.Address.Family().IsDomain() {
-		return false
-	}
-	domain := dest.Address.Domain()
-	return strings.Contains(domain, this.pattern)
+func NewPlainDomainMatcher(pattern string) *DomainMatcher {
+	return NewDomainMatcher(v2net.PlainDomainMatcher(pattern))
 }
 
-type RegexpDomainMatcher struct {
-	pattern *regexp.Regexp
-}
-
-func NewRegexpDomainMatcher(pattern string) (*RegexpDomainMatcher, error) {
-	r, err := regexp.Compile(pattern)
+func NewRegexpDomainMatcher(pattern string) (*DomainMatcher, error) {
+	matcher, err := v2net.NewRegexpDomainMatcher(pattern)
 	if err != nil {
 		return nil, err
 	}
-	return &RegexpDomainMatcher{
-		pattern: r,
-	}, nil
+	return NewDomainMatcher(matcher), nil
 }
 
-func (this *RegexpDomainMatcher) Apply(dest v2net.Destination) bool {
+func (this *DomainMatcher) Apply(dest v2net.Destination) bool {
 	if !dest.Address.Family().IsDomain() {
 		return false
 	}
-	domain := dest.Address.Domain()
-	return this.pattern.MatchString(strings.ToLower(domain))
+	return this.matcher.Match(dest.Address.Domain())
 }
 
 type CIDRMatcher struct {
@@ -1,6 +1,9 @@
 package rules
 
 import (
+	"time"
+
+	"v2ray.com/core/app/dns"
 	v2net "v2ray.com/core/common/net"
 )
 
@@ -24,4 +27,15 @@ var (
 type RouterRuleConfig struct {
 	Rules          []*Rule
 	DomainStrategy DomainStrategy
+	// IPOption specifies which IPs of a domain are tried against the rules, when domains are resolved.
+	IPOption dns.IPOption
+	// ReverseLookup evaluates the rules also against the domain that an IP destination was recently resolved from.
+	ReverseLookup bool
+
+	// CacheSize is the maximum number of cached routing decisions.
+	CacheSize int
+	// CacheTTL is how long a successful routing decision is cached.
+	CacheTTL time.Duration
+	// NegativeCacheTTL is how long a failed routing decision is cached.
+	NegativeCacheTTL time.Duration
 }
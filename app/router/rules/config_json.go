@@ -6,7 +6,9 @@ import (
 	"encoding/json"
 	"errors"
 	"strings"
+	"time"
 
+	"v2ray.com/core/app/dns"
 	router "v2ray.com/core/app/router"
 	"v2ray.com/core/common/collect"
 	"v2ray.com/core/common/log"
@@ -18,12 +20,71 @@ type JsonRule struct {
 	OutboundTag string `json:"outboundTag"`
 }
 
-func parseFieldRule(msg json.RawMessage) (*Rule, error) {
+func parseDomainCondition(list *collect.StringList, sets *RuleSets) (Condition, error) {
+	anyCond := NewAnyCondition()
+	for _, rawDomain := range *list {
+		var matcher Condition
+		if IsSetReference(rawDomain) {
+			set, err := sets.DomainSet(SetName(rawDomain))
+			if err != nil {
+				return nil, errors.New("Router: Unknown domain set: " + rawDomain)
+			}
+			matcher = set
+		} else {
+			rawMatcher, err := v2net.NewDomainMatcher(rawDomain)
+			if err != nil {
+				return nil, err
+			}
+			matcher = NewDomainMatcher(rawMatcher)
+		}
+		anyCond.Add(matcher)
+	}
+	return anyCond, nil
+}
+
+func parseIPCondition(list *collect.StringList, sets *RuleSets) (Condition, error) {
+	anyCond := NewAnyCondition()
+	for _, ipStr := range *list {
+		if IsSetReference(ipStr) {
+			set, err := sets.IPSet(SetName(ipStr))
+			if err != nil {
+				return nil, errors.New("Router: Unknown IP set: " + ipStr)
+			}
+			anyCond.Add(set)
+			continue
+		}
+		cidrMatcher, err := NewCIDRMatcher(ipStr)
+		if err != nil {
+			log.Error("Router: Invalid IP range in router rule: ", err)
+			return nil, err
+		}
+		anyCond.Add(cidrMatcher)
+	}
+	return anyCond, nil
+}
+
+func parsePortCondition(data json.RawMessage, sets *RuleSets) (Condition, error) {
+	var setRef string
+	if err := json.Unmarshal(data, &setRef); err == nil && IsSetReference(setRef) {
+		set, err := sets.PortSet(SetName(setRef))
+		if err != nil {
+			return nil, errors.New("Router: Unknown port set: " + setRef)
+		}
+		return set, nil
+	}
+	portRange := new(v2net.PortRange)
+	if err := json.Unmarshal(data, portRange); err != nil {
+		return nil, err
+	}
+	return NewPortMatcher(*portRange), nil
+}
+
+func parseFieldRule(msg json.RawMessage, sets *RuleSets) (*Rule, error) {
 	type RawFieldRule struct {
 		JsonRule
 		Domain  *collect.StringList `json:"domain"`
 		IP      *collect.StringList `json:"ip"`
-		Port    *v2net.PortRange    `json:"port"`
+		Port    json.RawMessage     `json:"port"`
 		Network *v2net.NetworkList  `json:"network"`
 	}
 	rawFieldRule := new(RawFieldRule)
@@ -34,37 +95,26 @@ func parseFieldRule(msg json.RawMessage) (*Rule, error) {
 	conds := NewConditionChan()
 
 	if rawFieldRule.Domain != nil && rawFieldRule.Domain.Len() > 0 {
-		anyCond := NewAnyCondition()
-		for _, rawDomain := range *(rawFieldRule.Domain) {
-			var matcher Condition
-			if strings.HasPrefix(rawDomain, "regexp:") {
-				rawMatcher, err := NewRegexpDomainMatcher(rawDomain[7:])
-				if err != nil {
-					return nil, err
-				}
-				matcher = rawMatcher
-			} else {
-				matcher = NewPlainDomainMatcher(rawDomain)
-			}
-			anyCond.Add(matcher)
+		cond, err := parseDomainCondition(rawFieldRule.Domain, sets)
+		if err != nil {
+			return nil, err
 		}
-		conds.Add(anyCond)
+		conds.Add(cond)
 	}
 
 	if rawFieldRule.IP != nil && rawFieldRule.IP.Len() > 0 {
-		anyCond := NewAnyCondition()
-		for _, ipStr := range *(rawFieldRule.IP) {
-			cidrMatcher, err := NewCIDRMatcher(ipStr)
-			if err != nil {
-				log.Error("Router: Invalid IP range in router rule: ", err)
-				return nil, err
-			}
-			anyCond.Add(cidrMatcher)
+		cond, err := parseIPCondition(rawFieldRule.IP, sets)
+		if err != nil {
+			return nil, err
 		}
-		conds.Add(anyCond)
+		conds.Add(cond)
 	}
-	if rawFieldRule.Port != nil {
-		conds.Add(NewPortMatcher(*rawFieldRule.Port))
+	if len(rawFieldRule.Port) > 0 && string(rawFieldRule.Port) != "null" {
+		cond, err := parsePortCondition(rawFieldRule.Port, sets)
+		if err != nil {
+			return nil, err
+		}
+		conds.Add(cond)
 	}
 	if rawFieldRule.Network != nil {
 		conds.Add(NewNetworkMatcher(rawFieldRule.Network))
@@ -78,40 +128,90 @@ func parseFieldRule(msg json.RawMessage) (*Rule, error) {
 	}, nil
 }
 
+// ParseRuleSets parses the named domain, IP and port sets. Each set is compiled into a single condition.
+func ParseRuleSets(data []byte) (*RuleSets, error) {
+	type JsonRuleSets struct {
+		Domain map[string]*collect.StringList `json:"domain"`
+		IP     map[string]*collect.StringList `json:"ip"`
+		Port   map[string][]json.RawMessage   `json:"port"`
+	}
+	jsonSets := new(JsonRuleSets)
+	if err := json.Unmarshal(data, jsonSets); err != nil {
+		return nil, err
+	}
+	sets := NewRuleSets()
+	// Sets can't refer to other sets, so they are parsed against an empty collection.
+	empty := NewRuleSets()
+	for name, list := range jsonSets.Domain {
+		cond, err := parseDomainCondition(list, empty)
+		if err != nil {
+			return nil, err
+		}
+		sets.AddDomainSet(name, cond)
+	}
+	for name, list := range jsonSets.IP {
+		cond, err := parseIPCondition(list, empty)
+		if err != nil {
+			return nil, err
+		}
+		sets.AddIPSet(name, cond)
+	}
+	for name, list := range jsonSets.Port {
+		anyCond := NewAnyCondition()
+		for _, rawPort := range list {
+			cond, err := parsePortCondition(rawPort, empty)
+			if err != nil {
+				return nil, err
+			}
+			anyCond.Add(cond)
+		}
+		sets.AddPortSet(name, anyCond)
+	}
+	return sets, nil
+}
+
 func ParseRule(msg json.RawMessage) *Rule {
-	rawRule := new(JsonRule)
-	err := json.Unmarshal(msg, rawRule)
+	return ParseRuleWithSets(msg, NewRuleSets())
+}
+
+// ParseRuleWithSets parses a rule whose domain, IP and port fields may refer to the given named sets. It returns nil
+// if the rule is invalid.
+func ParseRuleWithSets(msg json.RawMessage, sets *RuleSets) *Rule {
+	rule, err := parseRule(msg, sets)
 	if err != nil {
-		log.Error("Router: Invalid router rule: ", err)
+		log.Error(err)
 		return nil
 	}
-	if rawRule.Type == "field" {
+	return rule
+}
 
-		fieldrule, err := parseFieldRule(msg)
+func parseRule(msg json.RawMessage, sets *RuleSets) (*Rule, error) {
+	rawRule := new(JsonRule)
+	err := json.Unmarshal(msg, rawRule)
+	if err != nil {
+		return nil, errors.New("Router: Invalid router rule: " + err.Error())
+	}
+	switch rawRule.Type {
+	case "field":
+		fieldrule, err := parseFieldRule(msg, sets)
 		if err != nil {
-			log.Error("Invalid field rule: ", err)
-			return nil
+			return nil, errors.New("Router: Invalid field rule: " + err.Error())
 		}
-		return fieldrule
-	}
-	if rawRule.Type == "chinaip" {
+		return fieldrule, nil
+	case "chinaip":
 		chinaiprule, err := parseChinaIPRule(msg)
 		if err != nil {
-			log.Error("Router: Invalid chinaip rule: ", err)
-			return nil
+			return nil, errors.New("Router: Invalid chinaip rule: " + err.Error())
 		}
-		return chinaiprule
-	}
-	if rawRule.Type == "chinasites" {
+		return chinaiprule, nil
+	case "chinasites":
 		chinasitesrule, err := parseChinaSitesRule(msg)
 		if err != nil {
-			log.Error("Invalid chinasites rule: ", err)
-			return nil
+			return nil, errors.New("Router: Invalid chinasites rule: " + err.Error())
 		}
-		return chinasitesrule
+		return chinasitesrule, nil
 	}
-	log.Error("Unknown router rule type: ", rawRule.Type)
-	return nil
+	return nil, errors.New("Router: Unknown router rule type: " + rawRule.Type)
 }
 
 func init() {
@@ -119,6 +219,14 @@ func init() {
 		type JsonConfig struct {
 			RuleList       []json.RawMessage `json:"rules"`
 			DomainStrategy string            `json:"domainStrategy"`
+			IPStrategy     string            `json:"ipStrategy"`
+			ReverseLookup  bool              `json:"reverseLookup"`
+			Sets           json.RawMessage   `json:"sets"`
+			Cache          *struct {
+				Size        int    `json:"size"`
+				TTL         uint32 `json:"ttl"`
+				NegativeTTL uint32 `json:"negativeTtl"`
+			} `json:"cache"`
 		}
 		jsonConfig := new(JsonConfig)
 		if err := json.Unmarshal(data, jsonConfig); err != nil {
@@ -134,8 +242,37 @@ func init() {
 		} else if domainStrategy == "ipifnonmatch" {
 			config.DomainStrategy = UseIPIfNonMatch
 		}
+		switch strings.ToLower(jsonConfig.IPStrategy) {
+		case "ipv4":
+			config.IPOption = dns.IPv4Only
+		case "ipv6":
+			config.IPOption = dns.IPv6Only
+		case "preferipv4":
+			config.IPOption = dns.PreferIPv4
+		case "preferipv6":
+			config.IPOption = dns.PreferIPv6
+		}
+		config.ReverseLookup = jsonConfig.ReverseLookup
+		if jsonConfig.Cache != nil {
+			config.CacheSize = jsonConfig.Cache.Size
+			config.CacheTTL = time.Second * time.Duration(jsonConfig.Cache.TTL)
+			config.NegativeCacheTTL = time.Second * time.Duration(jsonConfig.Cache.NegativeTTL)
+		}
+		sets := NewRuleSets()
+		if len(jsonConfig.Sets) > 0 {
+			parsedSets, err := ParseRuleSets(jsonConfig.Sets)
+			if err != nil {
+				log.Error("Router: Invalid named sets: ", err)
+				return nil, err
+			}
+			sets = parsedSets
+		}
 		for idx, rawRule := range jsonConfig.RuleList {
-			rule := ParseRule(rawRule)
+			rule, err := parseRule(rawRule, sets)
+			if err != nil {
+				log.Error(err)
+				return nil, err
+			}
 			config.Rules[idx] = rule
 		}
 		return config, nil
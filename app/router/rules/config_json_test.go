@@ -5,6 +5,7 @@ package rules_test
 import (
 	"testing"
 
+	"v2ray.com/core/app/router"
 	. "v2ray.com/core/app/router/rules"
 	v2net "v2ray.com/core/common/net"
 	"v2ray.com/core/testing/assert"
@@ -49,3 +50,87 @@ func TestIPRule(t *testing.T) {
 	assert.Bool(rule.Apply(v2net.TCPDestination(v2net.IPAddress([]byte{127, 0, 0, 1}), 80))).IsFalse()
 	assert.Bool(rule.Apply(v2net.TCPDestination(v2net.IPAddress([]byte{192, 0, 0, 1}), 80))).IsTrue()
 }
+
+func TestRuleWithNamedSets(t *testing.T) {
+	assert := assert.On(t)
+
+	sets, err := ParseRuleSets([]byte(`{
+    "domain": {
+      "corp": ["corp.example.com", "regexp:\\.internal$"]
+    },
+    "ip": {
+      "lan": ["10.0.0.0/8", "192.168.0.0/16"]
+    },
+    "port": {
+      "web": [80, "443", "8000-8100"]
+    }
+  }`))
+	assert.Error(err).IsNil()
+
+	domainRule := ParseRuleWithSets([]byte(`{
+    "type": "field",
+    "domain": ["set:corp", "ooxx.com"],
+    "port": "set:web",
+    "outboundTag": "corp"
+  }`), sets)
+	assert.Pointer(domainRule).IsNotNil()
+	assert.Bool(domainRule.Apply(v2net.TCPDestination(v2net.DomainAddress("www.corp.example.com"), 443))).IsTrue()
+	assert.Bool(domainRule.Apply(v2net.TCPDestination(v2net.DomainAddress("git.internal"), 8080))).IsTrue()
+	assert.Bool(domainRule.Apply(v2net.TCPDestination(v2net.DomainAddress("www.ooxx.com"), 80))).IsTrue()
+	assert.Bool(domainRule.Apply(v2net.TCPDestination(v2net.DomainAddress("www.corp.example.com"), 22))).IsFalse()
+	assert.Bool(domainRule.Apply(v2net.TCPDestination(v2net.DomainAddress("www.aabb.com"), 80))).IsFalse()
+
+	ipRule := ParseRuleWithSets([]byte(`{
+    "type": "field",
+    "ip": ["set:lan"],
+    "outboundTag": "direct"
+  }`), sets)
+	assert.Pointer(ipRule).IsNotNil()
+	assert.Bool(ipRule.Apply(v2net.TCPDestination(v2net.IPAddress([]byte{10, 1, 2, 3}), 80))).IsTrue()
+	assert.Bool(ipRule.Apply(v2net.TCPDestination(v2net.IPAddress([]byte{192, 168, 1, 1}), 80))).IsTrue()
+	assert.Bool(ipRule.Apply(v2net.TCPDestination(v2net.IPAddress([]byte{8, 8, 8, 8}), 80))).IsFalse()
+
+	unknownRule := ParseRuleWithSets([]byte(`{
+    "type": "field",
+    "ip": ["set:unknown"],
+    "outboundTag": "direct"
+  }`), sets)
+	assert.Pointer(unknownRule).IsNil()
+}
+
+func TestReverseLookupParsing(t *testing.T) {
+	assert := assert.On(t)
+
+	config, err := router.CreateRouterConfig("rules", []byte(`{
+    "reverseLookup": true,
+    "rules": []
+  }`))
+	assert.Error(err).IsNil()
+	assert.Bool(config.(*RouterRuleConfig).ReverseLookup).IsTrue()
+}
+
+func TestUnknownSetFailsConfig(t *testing.T) {
+	assert := assert.On(t)
+
+	_, err := router.CreateRouterConfig("rules", []byte(`{
+    "sets": {
+      "domain": {
+        "corp": ["corp.example.com"]
+      }
+    },
+    "rules": [{
+      "type": "field",
+      "domain": ["set:copr"],
+      "outboundTag": "corp"
+    }]
+  }`))
+	assert.Error(err).IsNotNil()
+
+	_, err = router.CreateRouterConfig("rules", []byte(`{
+    "rules": [{
+      "type": "field",
+      "outboundTag": "direct"
+    }]
+  }`))
+	assert.Error(err).IsNotNil()
+}
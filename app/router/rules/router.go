@@ -24,7 +24,7 @@ type Router struct {
 func NewRouter(config *RouterRuleConfig, space app.Space) *Router {
 	r := &Router{
 		config: config,
-		cache:  NewRoutingTable(),
+		cache:  NewRoutingTable(config.CacheSize, config.CacheTTL, config.NegativeCacheTTL),
 	}
 	space.InitializeApplication(func() error {
 		if !space.HasApp(dns.APP_ID) {
@@ -32,6 +32,7 @@ func NewRouter(config *RouterRuleConfig, space app.Space) *Router {
 			return app.ErrMissingApplication
 		}
 		r.dnsServer = space.GetApp(dns.APP_ID).(dns.Server)
+		r.dnsServer.Watch(r.onDomainChange)
 		return nil
 	})
 	return r
@@ -41,9 +42,14 @@ func (this *Router) Release() {
 
 }
 
+func (this *Router) onDomainChange(domain string) {
+	log.Debug("Router: Invalidating cached routes for ", domain)
+	this.cache.InvalidateDomain(domain)
+}
+
 // Private: Visible for testing.
 func (this *Router) ResolveIP(dest v2net.Destination) []v2net.Destination {
-	ips := this.dnsServer.Get(dest.Address.Domain())
+	ips := this.dnsServer.Resolve(dest.Address.Domain(), this.config.IPOption, nil).IPs
 	if len(ips) == 0 {
 		return nil
 	}
@@ -58,9 +64,25 @@ func (this *Router) ResolveIP(dest v2net.Destination) []v2net.Destination {
 	return dests
 }
 
-func (this *Router) takeDetourWithoutCache(dest v2net.Destination) (string, error) {
+// lookupDomain returns the destination with the domain that the IP destination was recently resolved from.
+func (this *Router) lookupDomain(dest v2net.Destination) (v2net.Destination, bool) {
+	if dest.Address.Family().IsDomain() {
+		return dest, false
+	}
+	domain, found := this.dnsServer.LookupDomain(dest.Address.IP())
+	if !found {
+		return dest, false
+	}
+	return v2net.Destination{
+		Network: dest.Network,
+		Address: v2net.DomainAddress(domain),
+		Port:    dest.Port,
+	}, true
+}
+
+func (this *Router) takeDetourWithoutCache(dest v2net.Destination, domainDest v2net.Destination, hasDomain bool) (string, error) {
 	for _, rule := range this.config.Rules {
-		if rule.Apply(dest) {
+		if rule.Apply(dest) || (hasDomain && rule.Apply(domainDest)) {
 			return rule.Tag, nil
 		}
 	}
@@ -84,10 +106,25 @@ func (this *Router) takeDetourWithoutCache(dest v2net.Destination) (string, erro
 
 func (this *Router) TakeDetour(dest v2net.Destination) (string, error) {
 	destStr := dest.String()
+	domain := ""
+	if dest.Address.Family().IsDomain() {
+		domain = dest.Address.Domain()
+	}
+	var domainDest v2net.Destination
+	hasDomain := false
+	if this.config.ReverseLookup {
+		domainDest, hasDomain = this.lookupDomain(dest)
+		if hasDomain {
+			// The decision depends on the learned domain, which may differ next time.
+			domain = domainDest.Address.Domain()
+			destStr += " (" + domain + ")"
+		}
+	}
+
 	found, tag, err := this.cache.Get(destStr)
 	if !found {
-		tag, err := this.takeDetourWithoutCache(dest)
-		this.cache.Set(destStr, tag, err)
+		tag, err := this.takeDetourWithoutCache(dest, domainDest, hasDomain)
+		this.cache.Set(destStr, domain, tag, err)
 		return tag, err
 	}
 	return tag, err
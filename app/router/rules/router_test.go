@@ -1,17 +1,23 @@
 package rules_test
 
 import (
+	"net"
 	"testing"
 
+	"github.com/miekg/dns"
+
 	"v2ray.com/core/app"
 	"v2ray.com/core/app/dispatcher"
 	dispatchers "v2ray.com/core/app/dispatcher/impl"
-	"v2ray.com/core/app/dns"
+	v2dns "v2ray.com/core/app/dns"
 	"v2ray.com/core/app/proxyman"
 	"v2ray.com/core/app/router"
 	. "v2ray.com/core/app/router/rules"
 	v2net "v2ray.com/core/common/net"
+	"v2ray.com/core/proxy"
+	"v2ray.com/core/proxy/freedom"
 	"v2ray.com/core/testing/assert"
+	"v2ray.com/core/transport/internet"
 )
 
 func TestSimpleRouter(t *testing.T) {
@@ -27,7 +33,7 @@ func TestSimpleRouter(t *testing.T) {
 	}
 
 	space := app.NewSpace()
-	space.BindApp(dns.APP_ID, dns.NewCacheServer(space, &dns.Config{}))
+	space.BindApp(v2dns.APP_ID, v2dns.NewCacheServer(space, &v2dns.Config{}))
 	space.BindApp(dispatcher.APP_ID, dispatchers.NewDefaultDispatcher(space))
 	space.BindApp(proxyman.APP_ID_OUTBOUND_MANAGER, proxyman.NewDefaultOutboundHandlerManager())
 	r := NewRouter(config, space)
@@ -38,3 +44,70 @@ func TestSimpleRouter(t *testing.T) {
 	assert.Error(err).IsNil()
 	assert.String(tag).Equals("test")
 }
+
+func TestReverseLookupRouter(t *testing.T) {
+	assert := assert.On(t)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Error(err).IsNil()
+	dnsServer := &dns.Server{
+		Listener: listener,
+		Handler: dns.HandlerFunc(func(w dns.ResponseWriter, r *dns.Msg) {
+			resp := new(dns.Msg)
+			resp.SetReply(r)
+			if r.Question[0].Qtype == dns.TypeA {
+				rr, _ := dns.NewRR(r.Question[0].Name + " 300 IN A 10.0.0.1")
+				resp.Answer = append(resp.Answer, rr)
+			}
+			w.WriteMsg(resp)
+		}),
+	}
+	go dnsServer.ActivateAndServe()
+	defer dnsServer.Shutdown()
+
+	config := &RouterRuleConfig{
+		Rules: []*Rule{
+			{
+				Tag:       "test",
+				Condition: NewPlainDomainMatcher("v2ray.com"),
+			},
+		},
+		ReverseLookup: true,
+	}
+
+	space := app.NewSpace()
+	dnsServerApp := v2dns.NewCacheServer(space, &v2dns.Config{
+		NameServers: []*v2net.DestinationPB{{
+			Network: v2net.Network_TCP,
+			Address: v2net.NewAddressPB(v2net.LocalHostIP),
+			Port:    uint32(listener.Addr().(*net.TCPAddr).Port),
+		}},
+	})
+	space.BindApp(v2dns.APP_ID, dnsServerApp)
+	space.BindApp(dispatcher.APP_ID, dispatchers.NewDefaultDispatcher(space))
+	outboundHandlerManager := proxyman.NewDefaultOutboundHandlerManager()
+	outboundHandlerManager.SetDefaultHandler(
+		freedom.NewFreedomConnection(
+			&freedom.Config{},
+			space,
+			&proxy.OutboundHandlerMeta{
+				Address: v2net.AnyIP,
+				StreamSettings: &internet.StreamConfig{
+					Network: v2net.Network_RawTCP,
+				},
+			}))
+	space.BindApp(proxyman.APP_ID_OUTBOUND_MANAGER, outboundHandlerManager)
+	r := NewRouter(config, space)
+	space.BindApp(router.APP_ID, r)
+	assert.Error(space.Initialize()).IsNil()
+
+	ipDest := v2net.TCPDestination(v2net.IPAddress([]byte{10, 0, 0, 1}), 80)
+	_, err = r.TakeDetour(ipDest)
+	assert.Error(err).Equals(ErrNoRuleApplicable)
+
+	// The IP is learned from the answer of the DNS query.
+	assert.Int(len(dnsServerApp.Resolve("www.v2ray.com", v2dns.IPv4Only, nil).IPs)).Equals(1)
+	tag, err := r.TakeDetour(ipDest)
+	assert.Error(err).IsNil()
+	assert.String(tag).Equals("test")
+}
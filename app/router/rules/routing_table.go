@@ -1,70 +1,129 @@
 package rules
 
 import (
+	"container/list"
 	"sync"
 	"time"
 )
 
-type RoutingEntry struct {
-	tag    string
-	err    error
-	expire time.Time
-}
+const (
+	DefaultRoutingCacheSize        = 1024
+	DefaultRoutingCacheTTL         = time.Hour
+	DefaultRoutingCacheNegativeTTL = time.Minute
+)
 
-func (this *RoutingEntry) Extend() {
-	this.expire = time.Now().Add(time.Hour)
+type RoutingEntry struct {
+	destination string
+	domain      string
+	tag         string
+	err         error
+	expire      time.Time
 }
 
 func (this *RoutingEntry) Expired() bool {
 	return this.expire.Before(time.Now())
 }
 
+// RoutingTable is a bounded LRU cache of routing decisions.
 type RoutingTable struct {
-	sync.RWMutex
-	table map[string]*RoutingEntry
+	sync.Mutex
+	capacity    int
+	ttl         time.Duration
+	negativeTTL time.Duration
+	entries     *list.List
+	table       map[string]*list.Element
 }
 
-func NewRoutingTable() *RoutingTable {
+// NewRoutingTable creates a RoutingTable holding at most capacity entries. Successful decisions live for ttl,
+// and failed ones for negativeTTL. Zero values fall back to defaults.
+func NewRoutingTable(capacity int, ttl time.Duration, negativeTTL time.Duration) *RoutingTable {
+	if capacity <= 0 {
+		capacity = DefaultRoutingCacheSize
+	}
+	if ttl <= 0 {
+		ttl = DefaultRoutingCacheTTL
+	}
+	if negativeTTL <= 0 {
+		negativeTTL = DefaultRoutingCacheNegativeTTL
+	}
 	return &RoutingTable{
-		table: make(map[string]*RoutingEntry),
+		capacity:    capacity,
+		ttl:         ttl,
+		negativeTTL: negativeTTL,
+		entries:     list.New(),
+		table:       make(map[string]*list.Element),
 	}
 }
 
-func (this *RoutingTable) Cleanup() {
+// Set caches the routing decision for the destination. domain is the domain of the destination, if any,
+// so that the entry can be invalidated when the domain resolves differently.
+func (this *RoutingTable) Set(destination string, domain string, tag string, err error) {
 	this.Lock()
 	defer this.Unlock()
 
-	for key, value := range this.table {
-		if value.Expired() {
-			delete(this.table, key)
-		}
+	ttl := this.ttl
+	if err != nil {
+		ttl = this.negativeTTL
 	}
-}
-
-func (this *RoutingTable) Set(destination string, tag string, err error) {
-	this.Lock()
-	defer this.Unlock()
-
 	entry := &RoutingEntry{
-		tag: tag,
-		err: err,
+		destination: destination,
+		domain:      domain,
+		tag:         tag,
+		err:         err,
+		expire:      time.Now().Add(ttl),
 	}
-	entry.Extend()
-	this.table[destination] = entry
 
-	if len(this.table) > 1000 {
-		go this.Cleanup()
+	if element, found := this.table[destination]; found {
+		element.Value = entry
+		this.entries.MoveToFront(element)
+		return
+	}
+
+	this.table[destination] = this.entries.PushFront(entry)
+	for this.entries.Len() > this.capacity {
+		this.removeElement(this.entries.Back())
 	}
 }
 
 func (this *RoutingTable) Get(destination string) (bool, string, error) {
-	this.RLock()
-	defer this.RUnlock()
+	this.Lock()
+	defer this.Unlock()
 
-	entry, found := this.table[destination]
+	element, found := this.table[destination]
 	if !found {
 		return false, "", nil
 	}
-	entry.Extend()
+	entry := element.Value.(*RoutingEntry)
+	if entry.Expired() {
+		this.removeElement(element)
+		return false, "", nil
+	}
+	this.entries.MoveToFront(element)
 	return true, entry.tag, entry.err
 }
+
+// InvalidateDomain removes all entries whose destination is the given domain.
+func (this *RoutingTable) InvalidateDomain(domain string) {
+	this.Lock()
+	defer this.Unlock()
+
+	for element := this.entries.Front(); element != nil; {
+		next := element.Next()
+		if element.Value.(*RoutingEntry).domain == domain {
+			this.removeElement(element)
+		}
+		element = next
+	}
+}
+
+func (this *RoutingTable) Len() int {
+	this.Lock()
+	defer this.Unlock()
+
+	return this.entries.Len()
+}
+
+func (this *RoutingTable) removeElement(element *list.Element) {
+	this.entries.Remove(element)
+	delete(this.table, element.Value.(*RoutingEntry).destination)
+}
@@ -0,0 +1,62 @@
+package rules_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	. "v2ray.com/core/app/router/rules"
+	"v2ray.com/core/testing/assert"
+)
+
+func TestRoutingTableEviction(t *testing.T) {
+	assert := assert.On(t)
+
+	table := NewRoutingTable(2, time.Hour, time.Minute)
+	table.Set("tcp:a.com:80", "a.com", "a", nil)
+	table.Set("tcp:b.com:80", "b.com", "b", nil)
+
+	found, tag, _ := table.Get("tcp:a.com:80")
+	assert.Bool(found).IsTrue()
+	assert.String(tag).Equals("a")
+
+	table.Set("tcp:c.com:80", "c.com", "c", nil)
+	assert.Int(table.Len()).Equals(2)
+
+	found, _, _ = table.Get("tcp:b.com:80")
+	assert.Bool(found).IsFalse()
+	found, _, _ = table.Get("tcp:a.com:80")
+	assert.Bool(found).IsTrue()
+}
+
+func TestRoutingTableNegativeTTL(t *testing.T) {
+	assert := assert.On(t)
+
+	table := NewRoutingTable(16, time.Hour, time.Millisecond*10)
+	table.Set("tcp:a.com:80", "a.com", "", errors.New("test"))
+	table.Set("tcp:b.com:80", "b.com", "b", nil)
+
+	found, _, err := table.Get("tcp:a.com:80")
+	assert.Bool(found).IsTrue()
+	assert.Error(err).IsNotNil()
+
+	time.Sleep(time.Millisecond * 50)
+	found, _, _ = table.Get("tcp:a.com:80")
+	assert.Bool(found).IsFalse()
+	found, _, _ = table.Get("tcp:b.com:80")
+	assert.Bool(found).IsTrue()
+}
+
+func TestRoutingTableInvalidation(t *testing.T) {
+	assert := assert.On(t)
+
+	table := NewRoutingTable(16, time.Hour, time.Minute)
+	table.Set("tcp:a.com:80", "a.com", "a", nil)
+	table.Set("tcp:a.com:443", "a.com", "a", nil)
+	table.Set("tcp:b.com:80", "b.com", "b", nil)
+
+	table.InvalidateDomain("a.com")
+	assert.Int(table.Len()).Equals(1)
+	found, _, _ := table.Get("tcp:a.com:443")
+	assert.Bool(found).IsFalse()
+}
@@ -0,0 +1,71 @@
+package rules
+
+import (
+	"errors"
+	"strings"
+)
+
+const (
+	setPrefix = "set:"
+)
+
+var (
+	ErrSetNotFound = errors.New("Router: Named set not found.")
+)
+
+// RuleSets holds named conditions that are compiled once and shared by all rules referencing them.
+type RuleSets struct {
+	domain map[string]Condition
+	ip     map[string]Condition
+	port   map[string]Condition
+}
+
+func NewRuleSets() *RuleSets {
+	return &RuleSets{
+		domain: make(map[string]Condition),
+		ip:     make(map[string]Condition),
+		port:   make(map[string]Condition),
+	}
+}
+
+func (this *RuleSets) AddDomainSet(name string, cond Condition) {
+	this.domain[name] = cond
+}
+
+func (this *RuleSets) AddIPSet(name string, cond Condition) {
+	this.ip[name] = cond
+}
+
+func (this *RuleSets) AddPortSet(name string, cond Condition) {
+	this.port[name] = cond
+}
+
+func (this *RuleSets) DomainSet(name string) (Condition, error) {
+	return findSet(this.domain, name)
+}
+
+func (this *RuleSets) IPSet(name string) (Condition, error) {
+	return findSet(this.ip, name)
+}
+
+func (this *RuleSets) PortSet(name string) (Condition, error) {
+	return findSet(this.port, name)
+}
+
+func findSet(sets map[string]Condition, name string) (Condition, error) {
+	cond, found := sets[name]
+	if !found {
+		return nil, ErrSetNotFound
+	}
+	return cond, nil
+}
+
+// IsSetReference returns true if the given rule entry refers to a named set, i.e. "set:name".
+func IsSetReference(entry string) bool {
+	return strings.HasPrefix(entry, setPrefix)
+}
+
+// SetName returns the name of the set that the given entry refers to.
+func SetName(entry string) string {
+	return strings.TrimPrefix(entry, setPrefix)
+}
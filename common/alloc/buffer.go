@@ -190,9 +190,8 @@ func (b *Buffer) FillFrom(reader io.Reader) (int, error) {
 	begin := b.Len()
 	b.Value = b.Value[:cap(b.Value)]
 	nBytes, err := reader.Read(b.Value[begin:])
-	if err == nil {
-		b.Value = b.Value[:begin+nBytes]
-	}
+	// Data may come along with an error, such as io.EOF.
+	b.Value = b.Value[:begin+nBytes]
 	return nBytes, err
 }
 
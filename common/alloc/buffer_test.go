@@ -1,6 +1,7 @@
 package alloc_test
 
 import (
+	"io"
 	"testing"
 
 	. "v2ray.com/core/common/alloc"
@@ -58,3 +59,23 @@ func TestBufferString(t *testing.T) {
 	buffer.AppendString("Test String")
 	assert.String(buffer.String()).Equals("Test String")
 }
+
+// eofReader returns its data along with io.EOF.
+type eofReader []byte
+
+func (this eofReader) Read(b []byte) (int, error) {
+	return copy(b, this), io.EOF
+}
+
+func TestBufferFillFromEOF(t *testing.T) {
+	assert := assert.On(t)
+
+	buffer := NewBuffer().Clear()
+	defer buffer.Release()
+
+	buffer.AppendString("ab")
+	nBytes, err := buffer.FillFrom(eofReader("cd"))
+	assert.Error(err).Equals(io.EOF)
+	assert.Int(nBytes).Equals(2)
+	assert.String(buffer.String()).Equals("abcd")
+}
@@ -187,6 +187,22 @@ func (this *domainAddress) Equals(another Address) bool {
 	return this.Domain() == anotherDomain.Domain()
 }
 
+// NewAddressPB converts an Address into its protobuf representation.
+func NewAddressPB(address Address) *AddressPB {
+	if address.Family().IsDomain() {
+		return &AddressPB{
+			Address: &AddressPB_Domain{
+				Domain: address.Domain(),
+			},
+		}
+	}
+	return &AddressPB{
+		Address: &AddressPB_Ip{
+			Ip: []byte(address.IP()),
+		},
+	}
+}
+
 func (this *AddressPB) AsAddress() Address {
 	if this == nil {
 		return nil
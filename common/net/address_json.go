@@ -11,17 +11,6 @@ func (this *AddressPB) UnmarshalJSON(data []byte) error {
 	if err := json.Unmarshal(data, &rawStr); err != nil {
 		return err
 	}
-	addr := ParseAddress(rawStr)
-	switch addr.Family() {
-	case AddressFamilyIPv4, AddressFamilyIPv6:
-		this.Address = &AddressPB_Ip{
-			Ip: []byte(addr.IP()),
-		}
-	case AddressFamilyDomain:
-		this.Address = &AddressPB_Domain{
-			Domain: addr.Domain(),
-		}
-	}
-
+	*this = *NewAddressPB(ParseAddress(rawStr))
 	return nil
 }
@@ -0,0 +1,62 @@
+package net
+
+import (
+	"regexp"
+	"strings"
+)
+
+// A DomainMatcher decides whether a domain matches a pattern.
+type DomainMatcher interface {
+	Match(domain string) bool
+}
+
+// PlainDomainMatcher matches domains containing the pattern.
+type PlainDomainMatcher string
+
+func (this PlainDomainMatcher) Match(domain string) bool {
+	return strings.Contains(domain, string(this))
+}
+
+// RegexpDomainMatcher matches domains in lower case against a regular expression.
+type RegexpDomainMatcher struct {
+	pattern *regexp.Regexp
+}
+
+func NewRegexpDomainMatcher(pattern string) (*RegexpDomainMatcher, error) {
+	r, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	return &RegexpDomainMatcher{
+		pattern: r,
+	}, nil
+}
+
+func (this *RegexpDomainMatcher) Match(domain string) bool {
+	return this.pattern.MatchString(strings.ToLower(domain))
+}
+
+// AnyDomainMatcher matches domains that any of its matchers matches.
+type AnyDomainMatcher []DomainMatcher
+
+func (this AnyDomainMatcher) Match(domain string) bool {
+	for _, matcher := range this {
+		if matcher.Match(domain) {
+			return true
+		}
+	}
+	return false
+}
+
+// NewDomainMatcher creates a DomainMatcher from a pattern, which is either "regexp:" followed by a regular
+// expression, or a plain string that matches domains containing it.
+func NewDomainMatcher(pattern string) (DomainMatcher, error) {
+	if strings.HasPrefix(pattern, "regexp:") {
+		matcher, err := NewRegexpDomainMatcher(pattern[7:])
+		if err != nil {
+			return nil, err
+		}
+		return matcher, nil
+	}
+	return PlainDomainMatcher(pattern), nil
+}
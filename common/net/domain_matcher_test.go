@@ -0,0 +1,29 @@
+package net_test
+
+import (
+	"testing"
+
+	. "v2ray.com/core/common/net"
+	"v2ray.com/core/testing/assert"
+)
+
+func TestDomainMatcher(t *testing.T) {
+	assert := assert.On(t)
+
+	plain, err := NewDomainMatcher("v2ray.com")
+	assert.Error(err).IsNil()
+	assert.Bool(plain.Match("www.v2ray.com")).IsTrue()
+	assert.Bool(plain.Match("v2ray.org")).IsFalse()
+
+	regexp, err := NewDomainMatcher("regexp:^v2ray\\.com$")
+	assert.Error(err).IsNil()
+	assert.Bool(regexp.Match("V2Ray.com")).IsTrue()
+	assert.Bool(regexp.Match("www.v2ray.com")).IsFalse()
+
+	_, err = NewDomainMatcher("regexp:(")
+	assert.Error(err).IsNotNil()
+
+	any := AnyDomainMatcher{plain, PlainDomainMatcher("v2ray.org")}
+	assert.Bool(any.Match("www.v2ray.org")).IsTrue()
+	assert.Bool(any.Match("v2ray.net")).IsFalse()
+}
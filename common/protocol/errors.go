@@ -5,6 +5,7 @@ import (
 )
 
 var (
-	ErrInvalidUser    = errors.New("Invalid user.")
-	ErrInvalidVersion = errors.New("Invalid version.")
+	ErrInvalidUser       = errors.New("Invalid user.")
+	ErrInvalidVersion    = errors.New("Invalid version.")
+	ErrValidatorReleased = errors.New("User validator is released.")
 )
@@ -10,6 +10,8 @@ type RequestCommand byte
 const (
 	RequestCommandTCP = RequestCommand(0x01)
 	RequestCommandUDP = RequestCommand(0x02)
+	// RequestCommandMux carries multiple streams in one connection.
+	RequestCommandMux = RequestCommand(0x03)
 )
 
 type RequestOption byte
@@ -31,13 +33,26 @@ func (this *RequestOption) Clear(option RequestOption) {
 	*this = (*this & (^option))
 }
 
+// SecurityType is the method of encrypting the body of a request and its response.
+type SecurityType byte
+
+const (
+	// SecurityTypeLegacy encrypts the body as a stream with AES-128-CFB.
+	SecurityTypeLegacy           = SecurityType(0)
+	SecurityTypeAES128GCM        = SecurityType(1)
+	SecurityTypeChacha20Poly1305 = SecurityType(2)
+	// SecurityTypeNone leaves the body unencrypted.
+	SecurityTypeNone = SecurityType(3)
+)
+
 type RequestHeader struct {
-	Version byte
-	User    *User
-	Command RequestCommand
-	Option  RequestOption
-	Address v2net.Address
-	Port    v2net.Port
+	Version  byte
+	User     *User
+	Command  RequestCommand
+	Option   RequestOption
+	Security SecurityType
+	Address  v2net.Address
+	Port     v2net.Port
 }
 
 func (this *RequestHeader) Destination() v2net.Destination {
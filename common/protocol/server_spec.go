@@ -97,8 +97,12 @@ func (this *ServerSpec) AddUser(user *User) {
 	this.users = append(this.users, user)
 }
 
+// PickUser returns a random user of the server, or nil if the server has no user.
 func (this *ServerSpec) PickUser() *User {
 	userCount := len(this.users)
+	if userCount == 0 {
+		return nil
+	}
 	return this.users[dice.Roll(userCount)]
 }
 
@@ -10,6 +10,7 @@ import (
 var (
 	ErrUserMissing    = errors.New("User is not specified.")
 	ErrAccountMissing = errors.New("Account is not specified.")
+	ErrEmailMissing   = errors.New("Email is not specified.")
 	ErrNonMessageType = errors.New("Not a protobuf message.")
 )
 
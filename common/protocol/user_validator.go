@@ -9,4 +9,6 @@ type UserValidator interface {
 
 	Add(user *User) error
 	Get(timeHash []byte) (*User, Timestamp, bool)
+	// Remove removes all users of the email. It returns false if there is no such user, or the email is empty.
+	Remove(email string) bool
 }
@@ -0,0 +1,26 @@
+package dns
+
+import (
+	v2net "v2ray.com/core/common/net"
+)
+
+const (
+	DefaultTTL = uint32(60)
+)
+
+func (this *Config) GetTTL() uint32 {
+	if this.Ttl == 0 {
+		return DefaultTTL
+	}
+	return this.Ttl
+}
+
+// GetUpstream returns the upstream name server on the given network, or false if it is not set.
+func (this *Config) GetUpstream(network v2net.Network) (v2net.Destination, bool) {
+	if this.Server == nil {
+		return v2net.Destination{}, false
+	}
+	dest := this.Server.AsDestination()
+	dest.Network = network
+	return dest, true
+}
@@ -0,0 +1,72 @@
+// Code generated by protoc-gen-go.
+// source: v2ray.com/core/proxy/dns/config.proto
+// DO NOT EDIT!
+
+/*
+Package dns is a generated protocol buffer package.
+
+It is generated from these files:
+	v2ray.com/core/proxy/dns/config.proto
+
+It has these top-level messages:
+	Config
+*/
+package dns
+
+import proto "github.com/golang/protobuf/proto"
+import fmt "fmt"
+import math "math"
+import v2ray_core_common_net2 "v2ray.com/core/common/net"
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ = proto.Marshal
+var _ = fmt.Errorf
+var _ = math.Inf
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the proto package it is being compiled against.
+// A compilation error at this line likely means your copy of the
+// proto package needs to be updated.
+const _ = proto.ProtoPackageIsVersion2 // please upgrade the proto package
+
+type Config struct {
+	// Upstream name server for queries other than A and AAAA. Such queries are refused if it is not set.
+	Server *v2ray_core_common_net2.DestinationPB `protobuf:"bytes,1,opt,name=server" json:"server,omitempty"`
+	// TTL in seconds of answers to A and AAAA queries from static hosts or fake IPs. Answers from name servers carry
+	// the remaining TTL of the cached records instead. Defaults to 60 seconds.
+	Ttl uint32 `protobuf:"varint,2,opt,name=ttl" json:"ttl,omitempty"`
+}
+
+func (m *Config) Reset()                    { *m = Config{} }
+func (m *Config) String() string            { return proto.CompactTextString(m) }
+func (*Config) ProtoMessage()               {}
+func (*Config) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{0} }
+
+func (m *Config) GetServer() *v2ray_core_common_net2.DestinationPB {
+	if m != nil {
+		return m.Server
+	}
+	return nil
+}
+
+func init() {
+	proto.RegisterType((*Config)(nil), "v2ray.core.proxy.dns.Config")
+}
+
+func init() { proto.RegisterFile("v2ray.com/core/proxy/dns/config.proto", fileDescriptor0) }
+
+var fileDescriptor0 = []byte{
+	// 187 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x09, 0x6e, 0x88, 0x02, 0xff, 0x6c, 0xce, 0x41, 0x0b, 0x82, 0x40,
+	0x10, 0x05, 0x60, 0x4c, 0xf0, 0xb0, 0x12, 0x84, 0x74, 0x90, 0x4e, 0x12, 0x05, 0x42, 0x31, 0x0b,
+	0x76, 0xed, 0x64, 0xfd, 0x00, 0xf1, 0x14, 0xdd, 0x6c, 0x77, 0x0b, 0xa1, 0x9d, 0x91, 0xdd, 0x45,
+	0xf2, 0xdf, 0x87, 0x5a, 0x14, 0xd1, 0x6d, 0x0e, 0xef, 0x7d, 0xf3, 0xd8, 0xba, 0xcd, 0x4c, 0xd5,
+	0x81, 0x20, 0xcd, 0x05, 0x19, 0xc5, 0x1b, 0x43, 0x8f, 0x8e, 0x4b, 0xb4, 0x5c, 0x10, 0x5e, 0xeb,
+	0x1b, 0x34, 0x86, 0x1c, 0x45, 0xf3, 0x77, 0xcc, 0x28, 0x18, 0x22, 0x20, 0xd1, 0x2e, 0x36, 0x3f,
+	0x65, 0x41, 0x5a, 0x13, 0x72, 0x54, 0x8e, 0x4b, 0x65, 0x5d, 0x8d, 0x95, 0xab, 0x09, 0x47, 0x62,
+	0x79, 0x62, 0xc1, 0x61, 0x20, 0xa3, 0x3d, 0x0b, 0xac, 0x32, 0xad, 0x32, 0xb1, 0x97, 0x78, 0x69,
+	0x98, 0xad, 0xe0, 0x4b, 0x1f, 0x0d, 0x40, 0xe5, 0xe0, 0xf8, 0x31, 0x8a, 0xbc, 0x7c, 0x75, 0xa2,
+	0x19, 0xf3, 0x9d, 0xbb, 0xc7, 0x93, 0xc4, 0x4b, 0xa7, 0x65, 0x7f, 0xe6, 0x5b, 0x16, 0x0b, 0xd2,
+	0xf0, 0x6f, 0x62, 0x1e, 0x8e, 0x3f, 0x8b, 0x7e, 0xc2, 0xd9, 0x97, 0x68, 0x2f, 0xc1, 0x30, 0x67,
+	0xf7, 0x1c, 0x00, 0x9f, 0x3c, 0x07, 0xf4, 0xfa, 0x00, 0x00, 0x00,
+}
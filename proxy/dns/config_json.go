@@ -0,0 +1,40 @@
+// +build json
+
+package dns
+
+import (
+	"encoding/json"
+	"errors"
+
+	v2net "v2ray.com/core/common/net"
+	"v2ray.com/core/proxy/registry"
+)
+
+func (this *Config) UnmarshalJSON(data []byte) error {
+	type DNSConfig struct {
+		Address *v2net.AddressPB `json:"address"`
+		Port    v2net.Port       `json:"port"`
+		TTL     uint32           `json:"ttl"`
+	}
+	rawConfig := new(DNSConfig)
+	if err := json.Unmarshal(data, rawConfig); err != nil {
+		return errors.New("DNS: Failed to parse config: " + err.Error())
+	}
+	if rawConfig.Address != nil {
+		port := rawConfig.Port
+		if port == 0 {
+			port = 53
+		}
+		this.Server = &v2net.DestinationPB{
+			Network: v2net.Network_UDP,
+			Address: rawConfig.Address,
+			Port:    uint32(port),
+		}
+	}
+	this.Ttl = rawConfig.TTL
+	return nil
+}
+
+func init() {
+	registry.RegisterInboundConfig("dns", func() interface{} { return new(Config) })
+}
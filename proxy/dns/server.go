@@ -0,0 +1,335 @@
+package dns
+
+import (
+	"encoding/binary"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"v2ray.com/core/app"
+	"v2ray.com/core/app/dispatcher"
+	v2dns "v2ray.com/core/app/dns"
+	"v2ray.com/core/common/alloc"
+	v2io "v2ray.com/core/common/io"
+	"v2ray.com/core/common/log"
+	v2net "v2ray.com/core/common/net"
+	"v2ray.com/core/proxy"
+	"v2ray.com/core/proxy/registry"
+	"v2ray.com/core/transport/internet"
+	"v2ray.com/core/transport/internet/udp"
+
+	"github.com/miekg/dns"
+)
+
+const (
+	// ConnectionTimeout is the idle timeout in seconds of TCP connections from clients.
+	ConnectionTimeout = uint32(16)
+)
+
+// Server is an inbound handler that serves DNS clients over UDP and TCP. A and AAAA queries are answered by the DNS
+// app, and other queries are forwarded to the upstream name server through the dispatcher.
+type Server struct {
+	sync.RWMutex
+	config           *Config
+	accepting        bool
+	meta             *proxy.InboundHandlerMeta
+	packetDispatcher dispatcher.PacketDispatcher
+	dnsServer        v2dns.Server
+	tcpListener      *internet.TCPHub
+	udpHub           *udp.UDPHub
+	udpServer        *udp.UDPServer
+}
+
+func NewServer(config *Config, space app.Space, meta *proxy.InboundHandlerMeta) *Server {
+	s := &Server{
+		config: config,
+		meta:   meta,
+	}
+	space.InitializeApplication(func() error {
+		if !space.HasApp(dispatcher.APP_ID) {
+			log.Error("DNS: Dispatcher is not found in the space.")
+			return app.ErrMissingApplication
+		}
+		if !space.HasApp(v2dns.APP_ID) {
+			log.Error("DNS: DNS server is not found in the space.")
+			return app.ErrMissingApplication
+		}
+		s.packetDispatcher = space.GetApp(dispatcher.APP_ID).(dispatcher.PacketDispatcher)
+		s.dnsServer = space.GetApp(v2dns.APP_ID).(v2dns.Server)
+		return nil
+	})
+	return s
+}
+
+func (this *Server) Port() v2net.Port {
+	return this.meta.Port
+}
+
+func (this *Server) Close() {
+	this.Lock()
+	defer this.Unlock()
+
+	this.accepting = false
+	if this.tcpListener != nil {
+		this.tcpListener.Close()
+		this.tcpListener = nil
+	}
+	if this.udpHub != nil {
+		this.udpHub.Close()
+		this.udpHub = nil
+	}
+}
+
+func (this *Server) Start() error {
+	this.Lock()
+	defer this.Unlock()
+
+	if this.accepting {
+		return nil
+	}
+
+	this.udpServer = udp.NewUDPServer(this.meta, this.packetDispatcher)
+	udpHub, err := udp.ListenUDP(this.meta.Address, this.meta.Port, udp.ListenOption{
+		Callback: this.handleUDPPackets,
+	})
+	if err != nil {
+		log.Error("DNS: Failed to listen on UDP ", this.meta.Address, ":", this.meta.Port, ": ", err)
+		return err
+	}
+
+	tcpListener, err := internet.ListenTCP(this.meta.Address, this.meta.Port, this.handleTCPConnection, this.meta.StreamSettings)
+	if err != nil {
+		log.Error("DNS: Failed to listen on TCP ", this.meta.Address, ":", this.meta.Port, ": ", err)
+		udpHub.Close()
+		return err
+	}
+
+	this.udpHub = udpHub
+	this.tcpListener = tcpListener
+	this.accepting = true
+	return nil
+}
+
+// resolve returns the answer of the domain, or its fake IP if fake IPs are enabled.
+func (this *Server) resolve(domain string, option v2dns.IPOption, client net.IP) *v2dns.Answer {
+	if fakeIP := this.dnsServer.FakeIP(domain); fakeIP != nil {
+		answer := &v2dns.Answer{
+			Rcode: dns.RcodeSuccess,
+		}
+		if (fakeIP.To4() != nil) == (option == v2dns.IPv4Only) {
+			answer.IPs = []net.IP{fakeIP}
+		}
+		return answer
+	}
+	return this.dnsServer.Resolve(domain, option, client)
+}
+
+// clientIP returns the IP of the DNS client, or nil if it is not known.
+func clientIP(source v2net.Destination) net.IP {
+	if source.Address == nil || source.Address.Family().IsDomain() {
+		return nil
+	}
+	return source.Address.IP()
+}
+
+// answer returns the reply to A and AAAA queries from the client, or nil if the query should be forwarded.
+func (this *Server) answer(msg *dns.Msg, client net.IP) *dns.Msg {
+	if msg.Opcode != dns.OpcodeQuery || len(msg.Question) != 1 {
+		return nil
+	}
+	question := msg.Question[0]
+	if question.Qclass != dns.ClassINET || (question.Qtype != dns.TypeA && question.Qtype != dns.TypeAAAA) {
+		return nil
+	}
+
+	domain := strings.ToLower(strings.TrimSuffix(question.Name, "."))
+	option := v2dns.IPv4Only
+	if question.Qtype == dns.TypeAAAA {
+		option = v2dns.IPv6Only
+	}
+	answer := this.resolve(domain, option, client)
+	log.Info("DNS: Answering ", domain, " with ", len(answer.IPs), " IPs, rcode ", dns.RcodeToString[answer.Rcode])
+
+	reply := new(dns.Msg)
+	reply.SetRcode(msg, answer.Rcode)
+	reply.RecursionAvailable = true
+	// Answers from name servers are cached by clients for no longer than they are cached here.
+	ttl := this.config.GetTTL()
+	if answer.TTL > 0 {
+		ttl = uint32(answer.TTL / time.Second)
+	}
+	header := dns.RR_Header{
+		Name:   question.Name,
+		Rrtype: question.Qtype,
+		Class:  dns.ClassINET,
+		Ttl:    ttl,
+	}
+	for _, ip := range answer.IPs {
+		if question.Qtype == dns.TypeA {
+			reply.Answer = append(reply.Answer, &dns.A{Hdr: header, A: ip})
+		} else {
+			reply.Answer = append(reply.Answer, &dns.AAAA{Hdr: header, AAAA: ip})
+		}
+	}
+	return reply
+}
+
+// refuse returns the reply to queries that can't be answered nor forwarded.
+func refuse(msg *dns.Msg) *dns.Msg {
+	reply := new(dns.Msg)
+	reply.SetRcode(msg, dns.RcodeRefused)
+	return reply
+}
+
+func (this *Server) handleUDPPackets(payload *alloc.Buffer, session *proxy.SessionInfo) {
+	msg := new(dns.Msg)
+	if err := msg.Unpack(payload.Value); err != nil {
+		log.Info("DNS: Invalid query from ", session.Source, ": ", err)
+		payload.Release()
+		return
+	}
+
+	reply := this.answer(msg, clientIP(session.Source))
+	if reply == nil {
+		if upstream, ok := this.config.GetUpstream(v2net.Network_UDP); ok {
+			log.Info("DNS: Forwarding query from ", session.Source, " to ", upstream)
+			this.udpServer.Dispatch(&proxy.SessionInfo{
+				Source:      session.Source,
+				Destination: upstream,
+			}, payload, this.handleUDPResponse)
+			return
+		}
+		reply = refuse(msg)
+	}
+	payload.Release()
+
+	replyBytes, err := reply.Pack()
+	if err != nil {
+		log.Warning("DNS: Failed to pack reply: ", err)
+		return
+	}
+	this.writeUDP(replyBytes, session.Source)
+}
+
+func (this *Server) handleUDPResponse(dest v2net.Destination, payload *alloc.Buffer) {
+	defer payload.Release()
+	this.writeUDP(payload.Value, dest)
+}
+
+func (this *Server) writeUDP(payload []byte, dest v2net.Destination) {
+	this.RLock()
+	defer this.RUnlock()
+
+	if !this.accepting {
+		return
+	}
+	this.udpHub.WriteTo(payload, dest)
+}
+
+func readTCPMessage(reader io.Reader) ([]byte, error) {
+	var lenBuffer [2]byte
+	if _, err := io.ReadFull(reader, lenBuffer[:]); err != nil {
+		return nil, err
+	}
+	payload := make([]byte, binary.BigEndian.Uint16(lenBuffer[:]))
+	if _, err := io.ReadFull(reader, payload); err != nil {
+		return nil, err
+	}
+	return payload, nil
+}
+
+func writeTCPMessage(writer io.Writer, payload []byte) error {
+	buffer := make([]byte, 2+len(payload))
+	binary.BigEndian.PutUint16(buffer, uint16(len(payload)))
+	copy(buffer[2:], payload)
+	_, err := writer.Write(buffer)
+	return err
+}
+
+// forwardTCP sends the query to the upstream name server over TCP, and returns its response.
+func (this *Server) forwardTCP(source v2net.Destination, upstream v2net.Destination, payload []byte) ([]byte, error) {
+	ray := this.packetDispatcher.DispatchToOutbound(this.meta, &proxy.SessionInfo{
+		Source:      source,
+		Destination: upstream,
+	})
+	defer ray.InboundOutput().Release()
+
+	buffer := alloc.NewBuffer().Clear()
+	if err := writeTCPMessage(buffer, payload); err != nil {
+		buffer.Release()
+		return nil, err
+	}
+	if err := ray.InboundInput().Write(buffer); err != nil {
+		buffer.Release()
+		return nil, err
+	}
+	ray.InboundInput().Close()
+
+	timer := time.AfterFunc(v2dns.QueryTimeout, func() {
+		ray.InboundOutput().Release()
+	})
+	defer timer.Stop()
+
+	reader := v2io.NewChanReader(ray.InboundOutput())
+	defer reader.Release()
+	return readTCPMessage(reader)
+}
+
+func (this *Server) handleTCPConnection(conn internet.Connection) {
+	defer conn.Close()
+
+	source := v2net.DestinationFromAddr(conn.RemoteAddr())
+	reader := v2net.NewTimeOutReader(ConnectionTimeout, conn)
+	defer reader.Release()
+
+	for {
+		payload, err := readTCPMessage(reader)
+		if err != nil {
+			if err != io.EOF {
+				log.Info("DNS: Failed to read query from ", source, ": ", err)
+			}
+			return
+		}
+		msg := new(dns.Msg)
+		if err := msg.Unpack(payload); err != nil {
+			log.Info("DNS: Invalid query from ", source, ": ", err)
+			return
+		}
+
+		var replyBytes []byte
+		if reply := this.answer(msg, clientIP(source)); reply != nil {
+			replyBytes, err = reply.Pack()
+		} else if upstream, ok := this.config.GetUpstream(v2net.Network_TCP); ok {
+			log.Info("DNS: Forwarding query from ", source, " to ", upstream)
+			replyBytes, err = this.forwardTCP(source, upstream, payload)
+		} else {
+			replyBytes, err = refuse(msg).Pack()
+		}
+		if err != nil {
+			log.Warning("DNS: Failed to handle query from ", source, ": ", err)
+			return
+		}
+		if err := writeTCPMessage(conn, replyBytes); err != nil {
+			log.Info("DNS: Failed to write reply to ", source, ": ", err)
+			return
+		}
+	}
+}
+
+type Factory struct{}
+
+func (this *Factory) StreamCapability() v2net.NetworkList {
+	return v2net.NetworkList{
+		Network: []v2net.Network{v2net.Network_RawTCP},
+	}
+}
+
+func (this *Factory) Create(space app.Space, rawConfig interface{}, meta *proxy.InboundHandlerMeta) (proxy.InboundHandler, error) {
+	return NewServer(rawConfig.(*Config), space, meta), nil
+}
+
+func init() {
+	registry.MustRegisterInboundHandlerCreator("dns", new(Factory))
+}
@@ -0,0 +1,327 @@
+package dns_test
+
+import (
+	"io"
+	"io/ioutil"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/miekg/dns"
+
+	"v2ray.com/core/app"
+	"v2ray.com/core/app/dispatcher"
+	dispatchers "v2ray.com/core/app/dispatcher/impl"
+	v2dns "v2ray.com/core/app/dns"
+	"v2ray.com/core/app/proxyman"
+	"v2ray.com/core/common/dice"
+	v2net "v2ray.com/core/common/net"
+	"v2ray.com/core/proxy"
+	. "v2ray.com/core/proxy/dns"
+	"v2ray.com/core/proxy/freedom"
+	"v2ray.com/core/testing/assert"
+	"v2ray.com/core/transport/internet"
+)
+
+// upstreamHandler answers A queries with 10.0.0.1 and TXT queries with "upstream", or NXDOMAIN for domains starting
+// with "nx".
+var upstreamHandler = dns.HandlerFunc(func(w dns.ResponseWriter, r *dns.Msg) {
+	resp := new(dns.Msg)
+	resp.SetReply(r)
+	if strings.HasPrefix(r.Question[0].Name, "nx") {
+		resp.Rcode = dns.RcodeNameError
+		w.WriteMsg(resp)
+		return
+	}
+	switch r.Question[0].Qtype {
+	case dns.TypeA:
+		rr, _ := dns.NewRR(r.Question[0].Name + " 300 IN A 10.0.0.1")
+		resp.Answer = append(resp.Answer, rr)
+	case dns.TypeTXT:
+		rr, _ := dns.NewRR(r.Question[0].Name + " 300 IN TXT upstream")
+		resp.Answer = append(resp.Answer, rr)
+	}
+	w.WriteMsg(resp)
+})
+
+// startUpstream starts a name server on the same port for both UDP and TCP.
+func startUpstream() (*dns.Server, *dns.Server, v2net.Port, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	port := listener.Addr().(*net.TCPAddr).Port
+	packetConn, err := net.ListenPacket("udp", listener.Addr().String())
+	if err != nil {
+		listener.Close()
+		return nil, nil, 0, err
+	}
+	tcpServer := &dns.Server{Listener: listener, Handler: upstreamHandler}
+	udpServer := &dns.Server{PacketConn: packetConn, Handler: upstreamHandler}
+	go tcpServer.ActivateAndServe()
+	go udpServer.ActivateAndServe()
+	return tcpServer, udpServer, v2net.Port(port), nil
+}
+
+func TestDNSInbound(t *testing.T) {
+	assert := assert.On(t)
+
+	tcpUpstream, udpUpstream, upstreamPort, err := startUpstream()
+	assert.Error(err).IsNil()
+	defer tcpUpstream.Shutdown()
+	defer udpUpstream.Shutdown()
+
+	space := app.NewSpace()
+	space.BindApp(dispatcher.APP_ID, dispatchers.NewDefaultDispatcher(space))
+	ohm := proxyman.NewDefaultOutboundHandlerManager()
+	ohm.SetDefaultHandler(
+		freedom.NewFreedomConnection(
+			&freedom.Config{},
+			space,
+			&proxy.OutboundHandlerMeta{
+				Address: v2net.AnyIP,
+				StreamSettings: &internet.StreamConfig{
+					Network: v2net.Network_RawTCP,
+				},
+			}))
+	space.BindApp(proxyman.APP_ID_OUTBOUND_MANAGER, ohm)
+	space.BindApp(v2dns.APP_ID, v2dns.NewCacheServer(space, &v2dns.Config{
+		NameServers: []*v2net.DestinationPB{{
+			Network: v2net.Network_TCP,
+			Address: v2net.NewAddressPB(v2net.LocalHostIP),
+			Port:    uint32(upstreamPort),
+		}},
+		Hosts: map[string]*v2net.AddressPB{
+			"hosts.v2ray.com": v2net.NewAddressPB(v2net.IPAddress([]byte{10, 0, 0, 9})),
+		},
+	}))
+
+	port := v2net.Port(dice.Roll(20000) + 10000)
+	server := NewServer(&Config{
+		Server: &v2net.DestinationPB{
+			Network: v2net.Network_UDP,
+			Address: v2net.NewAddressPB(v2net.LocalHostIP),
+			Port:    uint32(upstreamPort),
+		},
+	}, space, &proxy.InboundHandlerMeta{
+		Address: v2net.LocalHostIP,
+		Port:    port,
+		StreamSettings: &internet.StreamConfig{
+			Network: v2net.Network_RawTCP,
+		}})
+	defer server.Close()
+
+	assert.Error(space.Initialize()).IsNil()
+	assert.Error(server.Start()).IsNil()
+
+	address := net.JoinHostPort("127.0.0.1", port.String())
+	for _, network := range []string{"udp", "tcp"} {
+		client := &dns.Client{Net: network}
+
+		query := new(dns.Msg)
+		query.SetQuestion("hosts.v2ray.com.", dns.TypeA)
+		reply, _, err := client.Exchange(query, address)
+		assert.Error(err).IsNil()
+		assert.Int(len(reply.Answer)).Equals(1)
+		assert.IP(reply.Answer[0].(*dns.A).A.To4()).Equals(net.IP([]byte{10, 0, 0, 9}))
+		assert.Uint32(reply.Answer[0].Header().Ttl).Equals(DefaultTTL)
+
+		query.SetQuestion(network+".v2ray.com.", dns.TypeA)
+		reply, _, err = client.Exchange(query, address)
+		assert.Error(err).IsNil()
+		assert.Int(len(reply.Answer)).Equals(1)
+		assert.IP(reply.Answer[0].(*dns.A).A.To4()).Equals(net.IP([]byte{10, 0, 0, 1}))
+
+		query.SetQuestion(network+".v2ray.com.", dns.TypeAAAA)
+		reply, _, err = client.Exchange(query, address)
+		assert.Error(err).IsNil()
+		assert.Int(reply.Rcode).Equals(dns.RcodeSuccess)
+		assert.Int(len(reply.Answer)).Equals(0)
+
+		query.SetQuestion(network+".v2ray.com.", dns.TypeTXT)
+		reply, _, err = client.Exchange(query, address)
+		assert.Error(err).IsNil()
+		assert.Int(len(reply.Answer)).Equals(1)
+		assert.String(reply.Answer[0].(*dns.TXT).Txt[0]).Equals("upstream")
+	}
+}
+
+func TestDNSInboundWithoutUpstream(t *testing.T) {
+	assert := assert.On(t)
+
+	space := app.NewSpace()
+	space.BindApp(dispatcher.APP_ID, dispatchers.NewDefaultDispatcher(space))
+	space.BindApp(proxyman.APP_ID_OUTBOUND_MANAGER, proxyman.NewDefaultOutboundHandlerManager())
+	space.BindApp(v2dns.APP_ID, v2dns.NewCacheServer(space, &v2dns.Config{}))
+
+	port := v2net.Port(dice.Roll(20000) + 10000)
+	server := NewServer(&Config{}, space, &proxy.InboundHandlerMeta{
+		Address: v2net.LocalHostIP,
+		Port:    port,
+		StreamSettings: &internet.StreamConfig{
+			Network: v2net.Network_RawTCP,
+		}})
+	defer server.Close()
+
+	assert.Error(space.Initialize()).IsNil()
+	assert.Error(server.Start()).IsNil()
+
+	query := new(dns.Msg)
+	query.SetQuestion("v2ray.com.", dns.TypeMX)
+	reply, _, err := new(dns.Client).Exchange(query, net.JoinHostPort("127.0.0.1", port.String()))
+	assert.Error(err).IsNil()
+	assert.Int(reply.Rcode).Equals(dns.RcodeRefused)
+}
+
+func TestDNSInboundFakeIP(t *testing.T) {
+	assert := assert.On(t)
+
+	space := app.NewSpace()
+	space.BindApp(dispatcher.APP_ID, dispatchers.NewDefaultDispatcher(space))
+	space.BindApp(proxyman.APP_ID_OUTBOUND_MANAGER, proxyman.NewDefaultOutboundHandlerManager())
+	dnsServer := v2dns.NewCacheServer(space, &v2dns.Config{
+		FakeIpRange: "198.18.0.0/15",
+		Hosts: map[string]*v2net.AddressPB{
+			"hosts.v2ray.com": v2net.NewAddressPB(v2net.IPAddress([]byte{10, 0, 0, 9})),
+		},
+	})
+	space.BindApp(v2dns.APP_ID, dnsServer)
+
+	port := v2net.Port(dice.Roll(20000) + 10000)
+	server := NewServer(&Config{}, space, &proxy.InboundHandlerMeta{
+		Address: v2net.LocalHostIP,
+		Port:    port,
+		StreamSettings: &internet.StreamConfig{
+			Network: v2net.Network_RawTCP,
+		}})
+	defer server.Close()
+
+	assert.Error(space.Initialize()).IsNil()
+	assert.Error(server.Start()).IsNil()
+
+	address := net.JoinHostPort("127.0.0.1", port.String())
+	query := new(dns.Msg)
+	query.SetQuestion("fake.v2ray.com.", dns.TypeA)
+	reply, _, err := new(dns.Client).Exchange(query, address)
+	assert.Error(err).IsNil()
+	assert.Int(len(reply.Answer)).Equals(1)
+	fakeIP := reply.Answer[0].(*dns.A).A
+	assert.Bool(fakeIP.Equal(net.ParseIP("198.18.0.1"))).IsTrue()
+
+	domain, found := dnsServer.LookupFakeIP(fakeIP)
+	assert.Bool(found).IsTrue()
+	assert.String(domain).Equals("fake.v2ray.com")
+
+	query.SetQuestion("fake.v2ray.com.", dns.TypeAAAA)
+	reply, _, err = new(dns.Client).Exchange(query, address)
+	assert.Error(err).IsNil()
+	assert.Int(len(reply.Answer)).Equals(0)
+
+	// Static hosts are not faked.
+	query.SetQuestion("hosts.v2ray.com.", dns.TypeA)
+	reply, _, err = new(dns.Client).Exchange(query, address)
+	assert.Error(err).IsNil()
+	assert.Int(len(reply.Answer)).Equals(1)
+	assert.Bool(reply.Answer[0].(*dns.A).A.Equal(net.IP([]byte{10, 0, 0, 9}))).IsTrue()
+}
+
+// startInbound starts a DNS inbound with a DNS app that queries the name server at the given port over TCP.
+func startInbound(assert *assert.Assert, nameServerPort v2net.Port) (*Server, string) {
+	space := app.NewSpace()
+	space.BindApp(dispatcher.APP_ID, dispatchers.NewDefaultDispatcher(space))
+	ohm := proxyman.NewDefaultOutboundHandlerManager()
+	ohm.SetDefaultHandler(
+		freedom.NewFreedomConnection(
+			&freedom.Config{},
+			space,
+			&proxy.OutboundHandlerMeta{
+				Address: v2net.AnyIP,
+				StreamSettings: &internet.StreamConfig{
+					Network: v2net.Network_RawTCP,
+				},
+			}))
+	space.BindApp(proxyman.APP_ID_OUTBOUND_MANAGER, ohm)
+	space.BindApp(v2dns.APP_ID, v2dns.NewCacheServer(space, &v2dns.Config{
+		Servers: []*v2dns.NameServerConfig{{
+			Address: &v2net.DestinationPB{
+				Network: v2net.Network_TCP,
+				Address: v2net.NewAddressPB(v2net.LocalHostIP),
+				Port:    uint32(nameServerPort),
+			},
+			Timeout: 100,
+		}},
+	}))
+
+	port := v2net.Port(dice.Roll(20000) + 10000)
+	server := NewServer(&Config{}, space, &proxy.InboundHandlerMeta{
+		Address: v2net.LocalHostIP,
+		Port:    port,
+		StreamSettings: &internet.StreamConfig{
+			Network: v2net.Network_RawTCP,
+		}})
+
+	assert.Error(space.Initialize()).IsNil()
+	assert.Error(server.Start()).IsNil()
+	return server, net.JoinHostPort("127.0.0.1", port.String())
+}
+
+func TestDNSInboundRcodeAndTTL(t *testing.T) {
+	assert := assert.On(t)
+
+	tcpUpstream, udpUpstream, upstreamPort, err := startUpstream()
+	assert.Error(err).IsNil()
+	defer tcpUpstream.Shutdown()
+	defer udpUpstream.Shutdown()
+
+	server, address := startInbound(assert, upstreamPort)
+	defer server.Close()
+
+	// Answers carry the remaining TTL of the cached records.
+	query := new(dns.Msg)
+	query.SetQuestion("ttl.v2ray.com.", dns.TypeA)
+	reply, _, err := new(dns.Client).Exchange(query, address)
+	assert.Error(err).IsNil()
+	assert.Int(reply.Rcode).Equals(dns.RcodeSuccess)
+	assert.Int(len(reply.Answer)).Equals(1)
+	ttl := reply.Answer[0].Header().Ttl
+	assert.Bool(ttl > 290 && ttl <= 300).IsTrue()
+
+	// Nonexistent domains are NXDOMAIN, both from name servers and from the negative cache.
+	for i := 0; i < 2; i++ {
+		query.SetQuestion("nx.v2ray.com.", dns.TypeA)
+		reply, _, err = new(dns.Client).Exchange(query, address)
+		assert.Error(err).IsNil()
+		assert.Int(reply.Rcode).Equals(dns.RcodeNameError)
+		assert.Int(len(reply.Answer)).Equals(0)
+	}
+}
+
+func TestDNSInboundServerFailure(t *testing.T) {
+	assert := assert.On(t)
+
+	// The name server accepts connections but never answers.
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Error(err).IsNil()
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go io.Copy(ioutil.Discard, conn)
+		}
+	}()
+
+	server, address := startInbound(assert, v2net.Port(listener.Addr().(*net.TCPAddr).Port))
+	defer server.Close()
+
+	for i := 0; i < 2; i++ {
+		query := new(dns.Msg)
+		query.SetQuestion("fail.v2ray.com.", dns.TypeA)
+		reply, _, err := new(dns.Client).Exchange(query, address)
+		assert.Error(err).IsNil()
+		assert.Int(reply.Rcode).Equals(dns.RcodeServerFailure)
+		assert.Int(len(reply.Answer)).Equals(0)
+	}
+}
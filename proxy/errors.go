@@ -8,4 +8,6 @@ var (
 	ErrInvalidAuthentication  = errors.New("Invalid authentication.")
 	ErrInvalidProtocolVersion = errors.New("Invalid protocol version.")
 	ErrAlreadyListening       = errors.New("Already listening on another port.")
+	ErrBindNotSupported       = errors.New("BIND is not supported.")
+	ErrHandlerClosed          = errors.New("Handler is closed.")
 )
@@ -1 +1,24 @@
 package freedom
+
+import (
+	"v2ray.com/core/app/dns"
+)
+
+// IPOption returns the DNS IP option for this strategy.
+func (this Config_DomainStrategy) IPOption() dns.IPOption {
+	switch this {
+	case Config_USE_IP4:
+		return dns.IPv4Only
+	case Config_USE_IP6:
+		return dns.IPv6Only
+	case Config_PREFER_IP6:
+		return dns.PreferIPv6
+	default:
+		return dns.PreferIPv4
+	}
+}
+
+// UseIP returns true if domains should be resolved into IPs before dialing.
+func (this Config_DomainStrategy) UseIP() bool {
+	return this != Config_AS_IS
+}
@@ -21,8 +21,15 @@ func (this *Config) UnmarshalJSON(data []byte) error {
 	}
 	this.DomainStrategy = Config_AS_IS
 	domainStrategy := strings.ToLower(jsonConfig.DomainStrategy)
-	if domainStrategy == "useip" || domainStrategy == "use_ip" {
+	switch domainStrategy {
+	case "useip", "use_ip":
 		this.DomainStrategy = Config_USE_IP
+	case "useipv4", "use_ip4":
+		this.DomainStrategy = Config_USE_IP4
+	case "useipv6", "use_ip6":
+		this.DomainStrategy = Config_USE_IP6
+	case "preferipv6", "prefer_ip6":
+		this.DomainStrategy = Config_PREFER_IP6
 	}
 	this.Timeout = jsonConfig.Timeout
 	return nil
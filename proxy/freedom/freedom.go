@@ -2,6 +2,8 @@ package freedom
 
 import (
 	"io"
+	"net"
+	"time"
 
 	"v2ray.com/core/app"
 	"v2ray.com/core/app/dns"
@@ -18,6 +20,11 @@ import (
 	"v2ray.com/core/transport/ray"
 )
 
+const (
+	// bindTimeout is how long Bind waits for a connection from the destination.
+	bindTimeout = 2 * time.Minute
+)
+
 type FreedomConnection struct {
 	domainStrategy Config_DomainStrategy
 	timeout        uint32
@@ -32,7 +39,7 @@ func NewFreedomConnection(config *Config, space app.Space, meta *proxy.OutboundH
 		meta:           meta,
 	}
 	space.InitializeApplication(func() error {
-		if config.DomainStrategy == Config_USE_IP {
+		if config.DomainStrategy.UseIP() {
 			if !space.HasApp(dns.APP_ID) {
 				log.Error("Freedom: DNS server is not found in the space.")
 				return app.ErrMissingApplication
@@ -50,7 +57,7 @@ func (this *FreedomConnection) ResolveIP(destination v2net.Destination) v2net.De
 		return destination
 	}
 
-	ips := this.dns.Get(destination.Address.Domain())
+	ips := this.dns.Resolve(destination.Address.Domain(), this.domainStrategy.IPOption(), nil).IPs
 	if len(ips) == 0 {
 		log.Info("Freedom: DNS returns nil answer. Keep domain as is.")
 		return destination
@@ -75,7 +82,7 @@ func (this *FreedomConnection) Dispatch(destination v2net.Destination, payload *
 	defer ray.OutboundOutput().Close()
 
 	var conn internet.Connection
-	if this.domainStrategy == Config_USE_IP && destination.Address.Family().IsDomain() {
+	if this.domainStrategy.UseIP() && destination.Address.Family().IsDomain() {
 		destination = this.ResolveIP(destination)
 	}
 	err := retry.Timed(5, 100).On(func() error {
@@ -127,6 +134,127 @@ func (this *FreedomConnection) Dispatch(destination v2net.Destination, payload *
 	return nil
 }
 
+// Bind implements proxy.Binder. It listens on a random port of the sending address, and accepts the first connection
+// from the destination. Connections from other addresses are dropped, unless the destination is a domain or an
+// unspecified IP. If the sending address is unspecified, the address reported is the one of the local interface
+// towards the destination, if known.
+func (this *FreedomConnection) Bind(destination v2net.Destination, ray ray.OutboundRay) (*proxy.BindSession, error) {
+	localAddr := &net.TCPAddr{}
+	if this.meta.Address != nil && !this.meta.Address.Family().IsDomain() {
+		localAddr.IP = this.meta.Address.IP()
+	}
+	listener, err := net.ListenTCP("tcp", localAddr)
+	if err != nil {
+		log.Warning("Freedom: Failed to listen for ", destination, ": ", err)
+		return nil, err
+	}
+	listener.SetDeadline(time.Now().Add(bindTimeout))
+	log.Info("Freedom: Listening on ", listener.Addr(), " for ", destination)
+
+	accepted := make(chan v2net.Destination, 1)
+	go this.acceptBind(listener, destination, accepted, ray)
+
+	address := v2net.DestinationFromAddr(listener.Addr())
+	if address.Address.IP().IsUnspecified() {
+		if ip := localIPTowards(destination.Address); ip != nil {
+			address.Address = v2net.IPAddress(ip)
+		}
+	}
+	return &proxy.BindSession{
+		Address:  address,
+		Accepted: accepted,
+	}, nil
+}
+
+// localIPTowards returns the IP of the local interface that traffic to the address goes out from, or nil if it is
+// unknown.
+func localIPTowards(address v2net.Address) net.IP {
+	if address.Family().IsDomain() || address.IP().IsUnspecified() {
+		return nil
+	}
+	// Connecting a UDP socket only looks up the route. Nothing is sent, so the port doesn't matter.
+	conn, err := net.DialUDP("udp", nil, &net.UDPAddr{
+		IP:   address.IP(),
+		Port: 9,
+	})
+	if err != nil {
+		return nil
+	}
+	defer conn.Close()
+	return conn.LocalAddr().(*net.UDPAddr).IP
+}
+
+func (this *FreedomConnection) acceptBind(listener *net.TCPListener, destination v2net.Destination, accepted chan<- v2net.Destination, ray ray.OutboundRay) {
+	defer ray.OutboundInput().Release()
+	defer ray.OutboundOutput().Close()
+	defer close(accepted)
+
+	input := ray.OutboundInput()
+	output := ray.OutboundOutput()
+
+	// The input is read while waiting, so that the listener is closed as soon as the input ends, e.g. when the client
+	// is gone. Data read is sent once the connection is accepted.
+	first := make(chan *alloc.Buffer, 1)
+	go func() {
+		data, err := input.Read()
+		if err != nil {
+			listener.Close()
+			close(first)
+			return
+		}
+		first <- data
+	}()
+
+	conn, err := acceptFrom(listener, destination.Address)
+	listener.Close()
+	if err != nil {
+		log.Warning("Freedom: Failed to accept connection from ", destination, ": ", err)
+		return
+	}
+	defer conn.Close()
+
+	accepted <- v2net.DestinationFromAddr(conn.RemoteAddr())
+
+	go func() {
+		v2writer := v2io.NewAdaptiveWriter(conn)
+		defer v2writer.Release()
+
+		if data, open := <-first; open {
+			if err := v2writer.Write(data); err == nil {
+				v2io.Pipe(input, v2writer)
+			}
+		}
+		conn.CloseWrite()
+	}()
+
+	var reader io.Reader = conn
+	if this.timeout > 0 {
+		reader = v2net.NewTimeOutReader(this.timeout /* seconds */, conn)
+	}
+
+	v2reader := v2io.NewAdaptiveReader(reader)
+	v2io.Pipe(v2reader, output)
+	v2reader.Release()
+}
+
+// acceptFrom returns the first connection from the address.
+func acceptFrom(listener *net.TCPListener, address v2net.Address) (*net.TCPConn, error) {
+	for {
+		conn, err := listener.AcceptTCP()
+		if err != nil {
+			return nil, err
+		}
+		if address.Family().IsDomain() || address.IP().IsUnspecified() {
+			return conn, nil
+		}
+		if remoteAddr, ok := conn.RemoteAddr().(*net.TCPAddr); ok && remoteAddr.IP.Equal(address.IP()) {
+			return conn, nil
+		}
+		log.Warning("Freedom: Dropping connection from unexpected address ", conn.RemoteAddr())
+		conn.Close()
+	}
+}
+
 type FreedomFactory struct{}
 
 func (this *FreedomFactory) StreamCapability() v2net.NetworkList {
@@ -1,6 +1,7 @@
 package freedom_test
 
 import (
+	"net"
 	"testing"
 
 	"v2ray.com/core/app"
@@ -115,3 +116,48 @@ func TestIPResolution(t *testing.T) {
 	assert.Destination(ipDest).IsTCP()
 	assert.Address(ipDest.Address).Equals(v2net.LocalHostIP)
 }
+
+func TestBindFromUnexpectedAddress(t *testing.T) {
+	assert := assert.On(t)
+
+	space := app.NewSpace()
+	freedom := NewFreedomConnection(
+		&Config{},
+		space,
+		&proxy.OutboundHandlerMeta{
+			Address: v2net.LocalHostIP,
+			StreamSettings: &internet.StreamConfig{
+				Network: v2net.Network_RawTCP,
+			},
+		})
+	assert.Error(space.Initialize()).IsNil()
+
+	traffic := ray.NewRay()
+	expectedIP := net.IP([]byte{127, 0, 0, 2})
+	session, err := freedom.Bind(v2net.TCPDestination(v2net.IPAddress(expectedIP), 0), traffic)
+	assert.Error(err).IsNil()
+	boundAddr := &net.TCPAddr{
+		IP:   session.Address.Address.IP(),
+		Port: int(session.Address.Port),
+	}
+
+	conn, err := net.DialTCP("tcp", nil, boundAddr)
+	assert.Error(err).IsNil()
+	_, err = conn.Read(make([]byte, 1))
+	assert.Error(err).IsNotNil()
+	conn.Close()
+
+	conn, err = net.DialTCP("tcp", &net.TCPAddr{IP: expectedIP}, boundAddr)
+	assert.Error(err).IsNil()
+	defer conn.Close()
+
+	peerAddr, ok := <-session.Accepted
+	assert.Bool(ok).IsTrue()
+	assert.Destination(peerAddr).EqualsString("tcp:" + conn.LocalAddr().String())
+
+	_, err = conn.Write([]byte("Data from peer"))
+	assert.Error(err).IsNil()
+	payload, err := traffic.InboundOutput().Read()
+	assert.Error(err).IsNil()
+	assert.String(payload.String()).Equals("Data from peer")
+}
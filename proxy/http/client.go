@@ -1,4 +1,161 @@
 package http
 
+import (
+	"bufio"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/url"
+
+	"v2ray.com/core/app"
+	"v2ray.com/core/common/alloc"
+	v2io "v2ray.com/core/common/io"
+	"v2ray.com/core/common/log"
+	v2net "v2ray.com/core/common/net"
+	"v2ray.com/core/common/protocol"
+	"v2ray.com/core/common/retry"
+	"v2ray.com/core/proxy"
+	"v2ray.com/core/proxy/registry"
+	"v2ray.com/core/transport/internet"
+	"v2ray.com/core/transport/internet/tcp"
+	"v2ray.com/core/transport/ray"
+)
+
+var (
+	ErrUDPNotSupported = errors.New("HTTP|Client: UDP is not supported.")
+	ErrConnectRejected = errors.New("HTTP|Client: CONNECT request rejected by server.")
+)
+
+// Client is an HTTP proxy client, which tunnels TCP connections through an upstream HTTP proxy with CONNECT.
 type Client struct {
+	serverPicker protocol.ServerPicker
+	meta         *proxy.OutboundHandlerMeta
+}
+
+func NewClient(config *ClientConfig, space app.Space, meta *proxy.OutboundHandlerMeta) *Client {
+	serverList := protocol.NewServerList()
+	for _, rec := range config.Server {
+		serverList.AddServer(protocol.NewServerSpecFromPB(NewAccount, *rec))
+	}
+	return &Client{
+		serverPicker: protocol.NewRoundRobinServerPicker(serverList),
+		meta:         meta,
+	}
+}
+
+func (this *Client) Dispatch(destination v2net.Destination, payload *alloc.Buffer, ray ray.OutboundRay) error {
+	defer payload.Release()
+	defer ray.OutboundInput().Release()
+	defer ray.OutboundOutput().Close()
+
+	if destination.Network == v2net.Network_UDP {
+		log.Warning("HTTP|Client: UDP is not supported. Dropping request to ", destination)
+		return ErrUDPNotSupported
+	}
+
+	var server *protocol.ServerSpec
+	var conn internet.Connection
+
+	err := retry.Timed(5, 100).On(func() error {
+		server = this.serverPicker.PickServer()
+		rawConn, err := internet.Dial(this.meta.Address, server.Destination(), this.meta.StreamSettings)
+		if err != nil {
+			return err
+		}
+		conn = rawConn
+		return nil
+	})
+	if err != nil {
+		log.Warning("HTTP|Client: Failed to find an available destination: ", err)
+		return err
+	}
+	defer conn.Close()
+	conn.SetReusable(false)
+
+	reader, err := connect(conn, server.PickUser(), destination)
+	if err != nil {
+		log.Warning("HTTP|Client: Failed to connect to ", destination, " via ", server.Destination(), ": ", err)
+		return err
+	}
+	log.Info("HTTP|Client: Tunneling request to ", destination, " via ", server.Destination())
+
+	if !payload.IsEmpty() {
+		if _, err := conn.Write(payload.Value); err != nil {
+			log.Warning("HTTP|Client: Failed to write payload: ", err)
+			return err
+		}
+	}
+
+	go func() {
+		v2writer := v2io.NewAdaptiveWriter(conn)
+		defer v2writer.Release()
+
+		v2io.Pipe(ray.OutboundInput(), v2writer)
+		if tcpConn, ok := conn.(*tcp.RawConnection); ok {
+			tcpConn.CloseWrite()
+		}
+	}()
+
+	v2reader := v2io.NewAdaptiveReader(reader)
+	v2io.Pipe(v2reader, ray.OutboundOutput())
+	v2reader.Release()
+
+	return nil
+}
+
+// connect sends a CONNECT request to the server, authenticating as the user if any. It returns the reader of the
+// tunnel, which may hold data from the destination read along with the response.
+func connect(conn internet.Connection, user *protocol.User, destination v2net.Destination) (*bufio.Reader, error) {
+	request := &http.Request{
+		Method:     "CONNECT",
+		URL:        &url.URL{Host: destination.NetAddr()},
+		Host:       destination.NetAddr(),
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     make(http.Header),
+	}
+	request.Header.Set("Proxy-Connection", "Keep-Alive")
+	if user != nil {
+		rawAccount, err := user.GetTypedAccount(NewAccount())
+		if err != nil {
+			return nil, err
+		}
+		account := rawAccount.(*Account)
+		credential := base64.StdEncoding.EncodeToString([]byte(account.Username + ":" + account.Password))
+		request.Header.Set("Proxy-Authorization", "Basic "+credential)
+	}
+	if err := request.Write(conn); err != nil {
+		return nil, err
+	}
+
+	reader := bufio.NewReader(conn)
+	response, err := http.ReadResponse(reader, request)
+	if err != nil {
+		return nil, err
+	}
+	if response.StatusCode != http.StatusOK {
+		log.Info("HTTP|Client: Server replied with status ", response.Status)
+		if response.StatusCode == http.StatusProxyAuthRequired {
+			return nil, proxy.ErrInvalidAuthentication
+		}
+		return nil, ErrConnectRejected
+	}
+	return reader, nil
+}
+
+type ClientFactory struct{}
+
+func (this *ClientFactory) StreamCapability() v2net.NetworkList {
+	return v2net.NetworkList{
+		Network: []v2net.Network{v2net.Network_TCP, v2net.Network_KCP, v2net.Network_WebSocket},
+	}
+}
+
+func (this *ClientFactory) Create(space app.Space, rawConfig interface{}, meta *proxy.OutboundHandlerMeta) (proxy.OutboundHandler, error) {
+	return NewClient(rawConfig.(*ClientConfig), space, meta), nil
+}
+
+func init() {
+	registry.MustRegisterOutboundHandlerCreator("http", new(ClientFactory))
 }
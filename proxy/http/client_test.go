@@ -0,0 +1,129 @@
+package http_test
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+
+	"v2ray.com/core/app"
+	"v2ray.com/core/common/alloc"
+	v2net "v2ray.com/core/common/net"
+	"v2ray.com/core/common/protocol"
+	"v2ray.com/core/proxy"
+	. "v2ray.com/core/proxy/http"
+	"v2ray.com/core/testing/assert"
+	"v2ray.com/core/testing/servers/tcp"
+	"v2ray.com/core/transport/internet"
+	"v2ray.com/core/transport/ray"
+)
+
+func processMessage(data []byte) []byte {
+	buffer := make([]byte, 0, 2048)
+	buffer = append(buffer, []byte("Processed: ")...)
+	buffer = append(buffer, data...)
+	return buffer
+}
+
+// startConnectProxy starts a minimal HTTP proxy which only accepts CONNECT requests from "v2ray:pass".
+func startConnectProxy(assert *assert.Assert) (net.Listener, v2net.Port) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Error(err).IsNil()
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				reader := bufio.NewReader(conn)
+				request, err := http.ReadRequest(reader)
+				if err != nil {
+					return
+				}
+				username, password, ok := parseProxyAuthorization(request)
+				if request.Method != "CONNECT" || !ok || username != "v2ray" || password != "pass" {
+					conn.Write([]byte("HTTP/1.1 407 Proxy Authentication Required\r\nProxy-Authenticate: Basic realm=\"proxy\"\r\n\r\n"))
+					return
+				}
+				target, err := net.Dial("tcp", request.Host)
+				if err != nil {
+					conn.Write([]byte("HTTP/1.1 502 Bad Gateway\r\n\r\n"))
+					return
+				}
+				defer target.Close()
+				conn.Write([]byte("HTTP/1.1 200 Connection established\r\n\r\n"))
+				go io.Copy(target, reader)
+				io.Copy(conn, target)
+			}()
+		}
+	}()
+
+	return listener, v2net.Port(listener.Addr().(*net.TCPAddr).Port)
+}
+
+func parseProxyAuthorization(request *http.Request) (string, string, bool) {
+	request.Header.Set("Authorization", request.Header.Get("Proxy-Authorization"))
+	return request.BasicAuth()
+}
+
+func newTestClient(assert *assert.Assert, port v2net.Port, password string) *Client {
+	account, err := (&Account{Username: "v2ray", Password: password}).AsAny()
+	assert.Error(err).IsNil()
+	return NewClient(&ClientConfig{
+		Server: []*protocol.ServerSpecPB{{
+			Address: v2net.NewAddressPB(v2net.LocalHostIP),
+			Port:    uint32(port),
+			User:    []*protocol.User{{Account: account}},
+		}},
+	}, app.NewSpace(), &proxy.OutboundHandlerMeta{
+		Address: v2net.AnyIP,
+		StreamSettings: &internet.StreamConfig{
+			Network: v2net.Network_TCP,
+		},
+	})
+}
+
+func TestHTTPClientConnect(t *testing.T) {
+	assert := assert.On(t)
+
+	tcpServer := &tcp.Server{
+		MsgProcessor: processMessage,
+	}
+	_, err := tcpServer.Start()
+	assert.Error(err).IsNil()
+	defer tcpServer.Close()
+
+	listener, port := startConnectProxy(assert)
+	defer listener.Close()
+
+	client := newTestClient(assert, port, "pass")
+
+	traffic := ray.NewRay()
+	payload := alloc.NewLocalBuffer(2048).Clear().Append([]byte("Data to be sent to remote"))
+	go client.Dispatch(v2net.TCPDestination(v2net.LocalHostIP, tcpServer.Port), payload, traffic)
+	traffic.InboundInput().Close()
+
+	response, err := traffic.InboundOutput().Read()
+	assert.Error(err).IsNil()
+	assert.Bytes(response.Value).Equals([]byte("Processed: Data to be sent to remote"))
+}
+
+func TestHTTPClientWrongPassword(t *testing.T) {
+	assert := assert.On(t)
+
+	listener, port := startConnectProxy(assert)
+	defer listener.Close()
+
+	client := newTestClient(assert, port, "wrong")
+
+	traffic := ray.NewRay()
+	err := client.Dispatch(v2net.TCPDestination(v2net.DomainAddress("v2ray.com"), 443), alloc.NewLocalBuffer(2048).Clear(), traffic)
+	assert.Error(err).Equals(proxy.ErrInvalidAuthentication)
+
+	err = client.Dispatch(v2net.UDPDestination(v2net.LocalHostIP, 53), alloc.NewLocalBuffer(2048).Clear(), ray.NewRay())
+	assert.Error(err).Equals(ErrUDPNotSupported)
+}
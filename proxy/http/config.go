@@ -1 +1,46 @@
 package http
+
+import (
+	"v2ray.com/core/common/protocol"
+
+	"github.com/golang/protobuf/ptypes"
+	google_protobuf "github.com/golang/protobuf/ptypes/any"
+)
+
+func (this *Account) Equals(another protocol.Account) bool {
+	if account, ok := another.(*Account); ok {
+		return this.Username == account.Username
+	}
+	return false
+}
+
+func (this *Account) AsAccount() (protocol.Account, error) {
+	return this, nil
+}
+
+func NewAccount() protocol.AsAccount {
+	return &Account{}
+}
+
+func (this *Account) AsAny() (*google_protobuf.Any, error) {
+	return ptypes.MarshalAny(this)
+}
+
+// HasUsers returns true if clients must authenticate themselves.
+func (this *ServerConfig) HasUsers() bool {
+	return len(this.User) > 0
+}
+
+// FindUser returns the user with the given username and password, or nil if there is none.
+func (this *ServerConfig) FindUser(username, password string) *protocol.User {
+	for _, user := range this.User {
+		account := new(Account)
+		if _, err := user.GetTypedAccount(account); err != nil {
+			continue
+		}
+		if account.Username == username && account.Password == password {
+			return user
+		}
+	}
+	return nil
+}
@@ -9,6 +9,7 @@ It is generated from these files:
 	v2ray.com/core/proxy/http/config.proto
 
 It has these top-level messages:
+	Account
 	ServerConfig
 	ClientConfig
 */
@@ -17,6 +18,8 @@ package http
 import proto "github.com/golang/protobuf/proto"
 import fmt "fmt"
 import math "math"
+import v2ray_core_common_protocol "v2ray.com/core/common/protocol"
+import v2ray_core_common_protocol1 "v2ray.com/core/common/protocol"
 
 // Reference imports to suppress errors if they are not otherwise used.
 var _ = proto.Marshal
@@ -29,26 +32,56 @@ var _ = math.Inf
 // proto package needs to be updated.
 const _ = proto.ProtoPackageIsVersion2 // please upgrade the proto package
 
+// Account of an HTTP proxy, used in Basic authentication.
+type Account struct {
+	Username string `protobuf:"bytes,1,opt,name=username" json:"username,omitempty"`
+	Password string `protobuf:"bytes,2,opt,name=password" json:"password,omitempty"`
+}
+
+func (m *Account) Reset()                    { *m = Account{} }
+func (m *Account) String() string            { return proto.CompactTextString(m) }
+func (*Account) ProtoMessage()               {}
+func (*Account) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{0} }
+
 // Config for HTTP proxy server.
 type ServerConfig struct {
 	Timeout uint32 `protobuf:"varint,1,opt,name=timeout" json:"timeout,omitempty"`
+	// Users allowed to use the proxy, each with an Account. No authentication is required if empty.
+	User []*v2ray_core_common_protocol.User `protobuf:"bytes,2,rep,name=user" json:"user,omitempty"`
 }
 
 func (m *ServerConfig) Reset()                    { *m = ServerConfig{} }
 func (m *ServerConfig) String() string            { return proto.CompactTextString(m) }
 func (*ServerConfig) ProtoMessage()               {}
-func (*ServerConfig) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{0} }
+func (*ServerConfig) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{1} }
+
+func (m *ServerConfig) GetUser() []*v2ray_core_common_protocol.User {
+	if m != nil {
+		return m.User
+	}
+	return nil
+}
 
 // ClientConfig for HTTP proxy client.
 type ClientConfig struct {
+	// Servers of the upstream HTTP proxy. Their users carry an Account, if authentication is required.
+	Server []*v2ray_core_common_protocol1.ServerSpecPB `protobuf:"bytes,1,rep,name=server" json:"server,omitempty"`
 }
 
 func (m *ClientConfig) Reset()                    { *m = ClientConfig{} }
 func (m *ClientConfig) String() string            { return proto.CompactTextString(m) }
 func (*ClientConfig) ProtoMessage()               {}
-func (*ClientConfig) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{1} }
+func (*ClientConfig) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{2} }
+
+func (m *ClientConfig) GetServer() []*v2ray_core_common_protocol1.ServerSpecPB {
+	if m != nil {
+		return m.Server
+	}
+	return nil
+}
 
 func init() {
+	proto.RegisterType((*Account)(nil), "v2ray.core.proxy.http.Account")
 	proto.RegisterType((*ServerConfig)(nil), "v2ray.core.proxy.http.ServerConfig")
 	proto.RegisterType((*ClientConfig)(nil), "v2ray.core.proxy.http.ClientConfig")
 }
@@ -56,15 +89,22 @@ func init() {
 func init() { proto.RegisterFile("v2ray.com/core/proxy/http/config.proto", fileDescriptor0) }
 
 var fileDescriptor0 = []byte{
-	// 147 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x09, 0x6e, 0x88, 0x02, 0xff, 0xe2, 0x52, 0x2b, 0x33, 0x2a, 0x4a,
-	0xac, 0xd4, 0x4b, 0xce, 0xcf, 0xd5, 0x4f, 0xce, 0x2f, 0x4a, 0xd5, 0x2f, 0x28, 0xca, 0xaf, 0xa8,
-	0xd4, 0xcf, 0x28, 0x29, 0x29, 0xd0, 0x4f, 0xce, 0xcf, 0x4b, 0xcb, 0x4c, 0xd7, 0x2b, 0x28, 0xca,
-	0x2f, 0xc9, 0x17, 0x12, 0x85, 0xa9, 0x2b, 0x4a, 0xd5, 0x03, 0xab, 0xd1, 0x03, 0xa9, 0x51, 0xd2,
-	0xe0, 0xe2, 0x09, 0x4e, 0x2d, 0x2a, 0x4b, 0x2d, 0x72, 0x06, 0x2b, 0x16, 0x92, 0xe0, 0x62, 0x2f,
-	0xc9, 0xcc, 0x4d, 0xcd, 0x2f, 0x2d, 0x91, 0x60, 0x54, 0x60, 0xd4, 0xe0, 0x0d, 0x82, 0x71, 0x95,
-	0xf8, 0xb8, 0x78, 0x9c, 0x73, 0x32, 0x53, 0xf3, 0x4a, 0x20, 0x2a, 0x9d, 0xf4, 0xb8, 0x24, 0x93,
-	0xf3, 0x73, 0xf5, 0xb0, 0x1a, 0xeb, 0xc4, 0x0d, 0x51, 0x14, 0x00, 0xb2, 0x3a, 0x8a, 0x05, 0x24,
-	0x94, 0xc4, 0x06, 0x76, 0x87, 0x31, 0x20, 0x00, 0x00, 0xff, 0xff, 0xc7, 0x71, 0x5d, 0x1f, 0xb1,
-	0x00, 0x00, 0x00,
+	// 267 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x09, 0x6e, 0x88, 0x02, 0xff, 0x84, 0x90, 0x51, 0x4b, 0xc3, 0x30,
+	0x14, 0x85, 0xd9, 0x1c, 0x9b, 0x66, 0xf3, 0xa5, 0x20, 0xd4, 0x3e, 0x95, 0x3e, 0x48, 0x7d, 0x49,
+	0x64, 0xfa, 0x03, 0x5c, 0xf7, 0x07, 0x4a, 0x87, 0x2f, 0x3e, 0x28, 0x35, 0x5e, 0xb5, 0xb0, 0xe4,
+	0x86, 0x9b, 0x74, 0xba, 0x7f, 0x2f, 0x49, 0x56, 0x11, 0x11, 0x7d, 0x3c, 0xbd, 0xe7, 0x7c, 0x3d,
+	0x27, 0xec, 0x62, 0xb7, 0xa4, 0x76, 0xcf, 0x25, 0x2a, 0x21, 0x91, 0x40, 0x18, 0xc2, 0x8f, 0xbd,
+	0x78, 0x73, 0xce, 0x08, 0x89, 0xfa, 0xa5, 0x7b, 0xe5, 0x86, 0xd0, 0x61, 0x72, 0x36, 0xf8, 0x08,
+	0x78, 0xf0, 0x70, 0xef, 0xc9, 0x2e, 0x7f, 0xc4, 0x25, 0x2a, 0x85, 0x5a, 0x84, 0x8c, 0xc4, 0xad,
+	0xe8, 0x2d, 0x50, 0x24, 0x64, 0x57, 0xff, 0x58, 0x2d, 0xd0, 0x0e, 0xe8, 0xd1, 0x1a, 0x90, 0x31,
+	0x51, 0xac, 0xd8, 0x6c, 0x25, 0x25, 0xf6, 0xda, 0x25, 0x19, 0x3b, 0xf6, 0x28, 0xdd, 0x2a, 0x48,
+	0x47, 0xf9, 0xa8, 0x3c, 0x69, 0xbe, 0xb4, 0xbf, 0x99, 0xd6, 0xda, 0x77, 0xa4, 0xe7, 0x74, 0x1c,
+	0x6f, 0x83, 0x2e, 0x1e, 0xd8, 0x62, 0x13, 0xb8, 0xeb, 0x30, 0x26, 0x49, 0xd9, 0xcc, 0x75, 0x0a,
+	0xb0, 0x77, 0x01, 0x73, 0xda, 0x0c, 0x32, 0xb9, 0x61, 0x13, 0x4f, 0x4c, 0xc7, 0xf9, 0x51, 0x39,
+	0x5f, 0xe6, 0xfc, 0xdb, 0xde, 0xd8, 0x94, 0x0f, 0x4d, 0xf9, 0x9d, 0x05, 0x6a, 0x82, 0xbb, 0xa8,
+	0xd9, 0x62, 0xbd, 0xed, 0x40, 0xbb, 0x03, 0xff, 0x96, 0x4d, 0xe3, 0x8e, 0x74, 0x14, 0x38, 0xe5,
+	0x5f, 0x9c, 0xd8, 0x6c, 0x63, 0x40, 0xd6, 0x55, 0x73, 0xc8, 0x55, 0x9c, 0x9d, 0x4b, 0x54, 0xfc,
+	0xd7, 0xe7, 0xae, 0xe6, 0xf1, 0x37, 0xb5, 0x27, 0xdc, 0x4f, 0xfc, 0xa7, 0xa7, 0x69, 0xc0, 0x5d,
+	0x7f, 0x0e, 0x00, 0xbe, 0x75, 0x4e, 0x6e, 0xc9, 0x01, 0x00, 0x00,
 }
@@ -6,13 +6,16 @@ import (
 	"encoding/json"
 	"errors"
 
+	v2net "v2ray.com/core/common/net"
+	"v2ray.com/core/common/protocol"
 	"v2ray.com/core/proxy/registry"
 )
 
 // UnmarshalJSON implements json.Unmarshaler
 func (this *ServerConfig) UnmarshalJSON(data []byte) error {
 	type JsonConfig struct {
-		Timeout uint32 `json:"timeout"`
+		Timeout  uint32            `json:"timeout"`
+		Accounts []json.RawMessage `json:"accounts"`
 	}
 	jsonConfig := new(JsonConfig)
 	if err := json.Unmarshal(data, jsonConfig); err != nil {
@@ -20,9 +23,81 @@ func (this *ServerConfig) UnmarshalJSON(data []byte) error {
 	}
 	this.Timeout = jsonConfig.Timeout
 
+	for _, rawAccount := range jsonConfig.Accounts {
+		user := new(protocol.User)
+		if err := json.Unmarshal(rawAccount, user); err != nil {
+			return errors.New("HTTP: Failed to parse user: " + err.Error())
+		}
+		account := new(Account)
+		if err := json.Unmarshal(rawAccount, account); err != nil {
+			return errors.New("HTTP: Failed to parse account: " + err.Error())
+		}
+		anyAccount, err := account.AsAny()
+		if err != nil {
+			return err
+		}
+		user.Account = anyAccount
+		this.User = append(this.User, user)
+	}
+
+	return nil
+}
+
+func (this *Account) UnmarshalJSON(data []byte) error {
+	type JsonConfig struct {
+		Username string `json:"user"`
+		Password string `json:"pass"`
+	}
+	jsonConfig := new(JsonConfig)
+	if err := json.Unmarshal(data, jsonConfig); err != nil {
+		return errors.New("HTTP: Failed to parse account: " + err.Error())
+	}
+	this.Username = jsonConfig.Username
+	this.Password = jsonConfig.Password
+	return nil
+}
+
+func (this *ClientConfig) UnmarshalJSON(data []byte) error {
+	type ServerConfig struct {
+		Address *v2net.AddressPB  `json:"address"`
+		Port    v2net.Port        `json:"port"`
+		Users   []json.RawMessage `json:"users"`
+	}
+	type JsonConfig struct {
+		Servers []*ServerConfig `json:"servers"`
+	}
+	jsonConfig := new(JsonConfig)
+	if err := json.Unmarshal(data, jsonConfig); err != nil {
+		return errors.New("HTTP|Client: Failed to parse config: " + err.Error())
+	}
+	this.Server = make([]*protocol.ServerSpecPB, len(jsonConfig.Servers))
+	for idx, serverConfig := range jsonConfig.Servers {
+		server := &protocol.ServerSpecPB{
+			Address: serverConfig.Address,
+			Port:    uint32(serverConfig.Port),
+		}
+		for _, rawUser := range serverConfig.Users {
+			user := new(protocol.User)
+			if err := json.Unmarshal(rawUser, user); err != nil {
+				return errors.New("HTTP|Client: Failed to parse user: " + err.Error())
+			}
+			account := new(Account)
+			if err := json.Unmarshal(rawUser, account); err != nil {
+				return errors.New("HTTP|Client: Failed to parse account: " + err.Error())
+			}
+			anyAccount, err := account.AsAny()
+			if err != nil {
+				return err
+			}
+			user.Account = anyAccount
+			server.User = append(server.User, user)
+		}
+		this.Server[idx] = server
+	}
 	return nil
 }
 
 func init() {
 	registry.RegisterInboundConfig("http", func() interface{} { return new(ServerConfig) })
+	registry.RegisterOutboundConfig("http", func() interface{} { return new(ClientConfig) })
 }
@@ -1,3 +1,61 @@
 // +build json
 
 package http_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	. "v2ray.com/core/proxy/http"
+	"v2ray.com/core/testing/assert"
+)
+
+func TestClientConfigParsing(t *testing.T) {
+	assert := assert.On(t)
+
+	rawJson := `{
+    "servers": [{
+      "address": "127.0.0.1",
+      "port": 3128,
+      "users": [{"user": "v2ray", "pass": "v2ray-password", "level": 1, "email": "love@v2ray.com"}]
+    }]
+  }`
+
+	config := new(ClientConfig)
+	err := json.Unmarshal([]byte(rawJson), config)
+	assert.Error(err).IsNil()
+	assert.Int(len(config.Server)).Equals(1)
+	assert.Uint32(config.Server[0].Port).Equals(3128)
+	assert.Int(len(config.Server[0].User)).Equals(1)
+
+	user := config.Server[0].User[0]
+	assert.Uint32(user.Level).Equals(1)
+	assert.String(user.Email).Equals("love@v2ray.com")
+
+	account := new(Account)
+	_, err = user.GetTypedAccount(account)
+	assert.Error(err).IsNil()
+	assert.String(account.Username).Equals("v2ray")
+	assert.String(account.Password).Equals("v2ray-password")
+}
+
+func TestServerConfigParsing(t *testing.T) {
+	assert := assert.On(t)
+
+	rawJson := `{
+    "timeout": 10,
+    "accounts": [{"user": "v2ray", "pass": "v2ray-password", "level": 1, "email": "love@v2ray.com"}]
+  }`
+
+	config := new(ServerConfig)
+	err := json.Unmarshal([]byte(rawJson), config)
+	assert.Error(err).IsNil()
+	assert.Uint32(config.Timeout).Equals(10)
+	assert.Int(len(config.User)).Equals(1)
+	assert.Uint32(config.User[0].Level).Equals(1)
+
+	user := config.FindUser("v2ray", "v2ray-password")
+	assert.Bool(user == nil).IsFalse()
+	assert.String(user.Email).Equals("love@v2ray.com")
+	assert.Bool(config.FindUser("v2ray", "wrong") == nil).IsTrue()
+}
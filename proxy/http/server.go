@@ -2,6 +2,7 @@ package http
 
 import (
 	"bufio"
+	"encoding/base64"
 	"io"
 	"net"
 	"net/http"
@@ -15,6 +16,7 @@ import (
 	v2io "v2ray.com/core/common/io"
 	"v2ray.com/core/common/log"
 	v2net "v2ray.com/core/common/net"
+	"v2ray.com/core/common/protocol"
 	"v2ray.com/core/proxy"
 	"v2ray.com/core/proxy/registry"
 	"v2ray.com/core/transport/internet"
@@ -98,12 +100,43 @@ func (this *Server) handleConnection(conn internet.Connection) {
 	timedReader := v2net.NewTimeOutReader(this.config.Timeout, conn)
 	reader := bufio.NewReaderSize(timedReader, 2048)
 
+	var upstream *plainUpstream
+	defer func() {
+		if upstream != nil {
+			upstream.Close()
+		}
+	}()
+
+	for {
+		request, session, err := this.readRequest(conn, reader)
+		if err != nil {
+			return
+		}
+		if strings.ToUpper(request.Method) == "CONNECT" {
+			if upstream != nil {
+				upstream.Close()
+				upstream = nil
+			}
+			this.handleConnect(request, session, reader, conn)
+			return
+		}
+		var keepAlive bool
+		upstream, keepAlive = this.handlePlainHTTP(request, session, reader, conn, upstream)
+		if !keepAlive {
+			return
+		}
+	}
+}
+
+// readRequest reads the next request from the client and authenticates it. The client is challenged if the
+// authentication fails.
+func (this *Server) readRequest(conn internet.Connection, reader *bufio.Reader) (*http.Request, *proxy.SessionInfo, error) {
 	request, err := http.ReadRequest(reader)
 	if err != nil {
 		if err != io.EOF {
 			log.Warning("HTTP: Failed to read http request: ", err)
 		}
-		return
+		return nil, nil, err
 	}
 	log.Info("HTTP: Request to Method [", request.Method, "] Host [", request.Host, "] with URL [", request.URL, "]")
 	defaultPort := v2net.Port(80)
@@ -117,18 +150,46 @@ func (this *Server) handleConnection(conn internet.Connection) {
 	dest, err := parseHost(host, defaultPort)
 	if err != nil {
 		log.Warning("HTTP: Malformed proxy host (", host, "): ", err)
-		return
+		return nil, nil, err
+	}
+	var user *protocol.User
+	if this.config.HasUsers() {
+		username, password, ok := parseBasicAuth(request.Header.Get("Proxy-Authorization"))
+		if ok {
+			user = this.config.FindUser(username, password)
+		}
+		if user == nil {
+			log.Access(conn.RemoteAddr(), request.URL, log.AccessRejected, proxy.ErrInvalidAuthentication)
+			response := this.GenerateResponse(407, "Proxy Authentication Required")
+			response.Header.Set("Proxy-Authenticate", "Basic realm=\"proxy\"")
+			response.Write(conn)
+			return nil, nil, proxy.ErrInvalidAuthentication
+		}
 	}
 	log.Access(conn.RemoteAddr(), request.URL, log.AccessAccepted, "")
 	session := &proxy.SessionInfo{
 		Source:      v2net.DestinationFromAddr(conn.RemoteAddr()),
 		Destination: dest,
+		User:        user,
 	}
-	if strings.ToUpper(request.Method) == "CONNECT" {
-		this.handleConnect(request, session, reader, conn)
-	} else {
-		this.handlePlainHTTP(request, session, reader, conn)
+	return request, session, nil
+}
+
+// parseBasicAuth parses the credential of Basic authentication, as in a Proxy-Authorization header.
+func parseBasicAuth(auth string) (string, string, bool) {
+	const prefix = "Basic "
+	if len(auth) < len(prefix) || !strings.EqualFold(auth[:len(prefix)], prefix) {
+		return "", "", false
+	}
+	credential, err := base64.StdEncoding.DecodeString(auth[len(prefix):])
+	if err != nil {
+		return "", "", false
 	}
+	idx := strings.IndexByte(string(credential), ':')
+	if idx < 0 {
+		return "", "", false
+	}
+	return string(credential[:idx]), string(credential[idx+1:]), true
 }
 
 func (this *Server) handleConnect(request *http.Request, session *proxy.SessionInfo, reader io.Reader, writer io.Writer) {
@@ -187,9 +248,9 @@ func StripHopByHopHeaders(request *http.Request) {
 	request.Header.Del("Transfer-Encoding")
 	request.Header.Del("Upgrade")
 
-	// TODO: support keep-alive
+	// Whether to keep the connection alive is decided by request.Close.
 	connections := request.Header.Get("Connection")
-	request.Header.Set("Connection", "close")
+	request.Header.Del("Connection")
 	if len(connections) == 0 {
 		return
 	}
@@ -214,52 +275,121 @@ func (this *Server) GenerateResponse(statusCode int, status string) *http.Respon
 	}
 }
 
-func (this *Server) handlePlainHTTP(request *http.Request, session *proxy.SessionInfo, reader *bufio.Reader, writer io.Writer) {
+// plainUpstream is a dispatched connection to a destination, which serves successive plain HTTP requests of a
+// client, as long as they are for the same destination.
+type plainUpstream struct {
+	destination v2net.Destination
+	ray         ray.InboundRay
+	reader      *bufio.Reader
+}
+
+func (this *plainUpstream) writeRequest(request *http.Request) {
+	requestWriter := v2io.NewBufferedWriter(v2io.NewChainWriter(this.ray.InboundInput()))
+	if err := request.Write(requestWriter); err != nil {
+		log.Warning("HTTP: Failed to write request: ", err)
+		return
+	}
+	requestWriter.Flush()
+}
+
+// readResponse reads the response to the request. It returns io.EOF if the upstream ends before any byte of the
+// response.
+func (this *plainUpstream) readResponse(request *http.Request) (*http.Response, error) {
+	if this.reader == nil {
+		this.reader = bufio.NewReader(v2io.NewChanReader(this.ray.InboundOutput()))
+	}
+	if _, err := this.reader.Peek(1); err != nil {
+		return nil, err
+	}
+	return http.ReadResponse(this.reader, request)
+}
+
+func (this *plainUpstream) Close() {
+	this.ray.InboundInput().Close()
+	this.ray.InboundOutput().Release()
+}
+
+// handlePlainHTTP forwards the request through the upstream, or a newly dispatched one if the upstream is for
+// another destination. It returns the upstream for the next request, and whether the client connection stays alive.
+func (this *Server) handlePlainHTTP(request *http.Request, session *proxy.SessionInfo, reader *bufio.Reader, writer io.Writer, upstream *plainUpstream) (*plainUpstream, bool) {
 	if len(request.URL.Host) <= 0 {
 		response := this.GenerateResponse(400, "Bad Request")
 		response.Write(writer)
 
-		return
+		return upstream, false
 	}
 
 	request.Host = request.URL.Host
 	StripHopByHopHeaders(request)
 
-	ray := this.packetDispatcher.DispatchToOutbound(this.meta, session)
-	defer ray.InboundInput().Close()
-	defer ray.InboundOutput().Release()
+	if upstream != nil && !upstream.destination.Equals(session.Destination) {
+		upstream.Close()
+		upstream = nil
+	}
+	reused := upstream != nil
+	if upstream == nil {
+		upstream = this.dispatchPlainUpstream(session)
+	}
 
 	var finish sync.WaitGroup
+	requestWritten := make(chan bool)
 	finish.Add(1)
 	go func() {
 		defer finish.Done()
-		requestWriter := v2io.NewBufferedWriter(v2io.NewChainWriter(ray.InboundInput()))
-		err := request.Write(requestWriter)
-		if err != nil {
-			log.Warning("HTTP: Failed to write request: ", err)
-			return
-		}
-		requestWriter.Flush()
+		defer close(requestWritten)
+		upstream.writeRequest(request)
 	}()
 
+	keepAlive := !request.Close
 	finish.Add(1)
 	go func() {
 		defer finish.Done()
-		responseReader := bufio.NewReader(v2io.NewChanReader(ray.InboundOutput()))
-		response, err := http.ReadResponse(responseReader, request)
+		response, err := upstream.readResponse(request)
+		// The origin may have closed the reused connection while it was idle. The request is sent again through a
+		// new one, if nothing has been received and the request has no body to be sent again.
+		if err == io.EOF && reused && request.ContentLength == 0 && len(request.TransferEncoding) == 0 {
+			log.Info("HTTP: Reused connection to ", session.Destination, " is closed. Dispatching again.")
+			<-requestWritten
+			upstream.Close()
+			upstream = this.dispatchPlainUpstream(session)
+			upstream.writeRequest(request)
+			response, err = upstream.readResponse(request)
+		}
 		if err != nil {
 			log.Warning("HTTP: Failed to read response: ", err)
 			response = this.GenerateResponse(503, "Service Unavailable")
+			keepAlive = false
+		}
+		if response.Close {
+			keepAlive = false
 		}
 		responseWriter := v2io.NewBufferedWriter(writer)
 		err = response.Write(responseWriter)
 		if err != nil {
 			log.Warning("HTTP: Failed to write response: ", err)
+			keepAlive = false
 			return
 		}
 		responseWriter.Flush()
+		// A response without length is delimited by the end of connection.
+		if response.ContentLength < 0 && len(response.TransferEncoding) == 0 {
+			keepAlive = false
+		}
 	}()
 	finish.Wait()
+
+	if !keepAlive {
+		upstream.Close()
+		return nil, false
+	}
+	return upstream, true
+}
+
+func (this *Server) dispatchPlainUpstream(session *proxy.SessionInfo) *plainUpstream {
+	return &plainUpstream{
+		destination: session.Destination,
+		ray:         this.packetDispatcher.DispatchToOutbound(this.meta, session),
+	}
 }
 
 type ServerFactory struct{}
@@ -2,17 +2,30 @@ package http_test
 
 import (
 	"bufio"
+	"io/ioutil"
+	"net"
 	"net/http"
+	"net/http/httptest"
 	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	"v2ray.com/core/app"
+	"v2ray.com/core/app/dispatcher"
+	dispatchers "v2ray.com/core/app/dispatcher/impl"
 	testdispatcher "v2ray.com/core/app/dispatcher/testing"
+	"v2ray.com/core/app/proxyman"
+	"v2ray.com/core/common/alloc"
 	"v2ray.com/core/common/dice"
 	v2net "v2ray.com/core/common/net"
+	"v2ray.com/core/common/protocol"
 	"v2ray.com/core/proxy"
+	"v2ray.com/core/proxy/freedom"
 	. "v2ray.com/core/proxy/http"
 	"v2ray.com/core/testing/assert"
 	"v2ray.com/core/transport/internet"
+	"v2ray.com/core/transport/ray"
 
 	_ "v2ray.com/core/transport/internet/tcp"
 )
@@ -27,6 +40,7 @@ Foo: foo
 Bar: bar
 Proxy-Connection: keep-alive
 Proxy-Authenticate: abc
+Proxy-Authorization: Basic djJyYXk6cGFzcw==
 User-Agent: Mozilla/5.0 (Macintosh; U; Intel Mac OS X; de-de) AppleWebKit/523.10.3 (KHTML, like Gecko) Version/3.0.4 Safari/523.10
 Accept-Encoding: gzip
 Accept-Charset: ISO-8859-1,UTF-8;q=0.7,*;q=0.7
@@ -42,13 +56,15 @@ Accept-Language: de,en;q=0.7,en-us;q=0.3
 	assert.String(req.Header.Get("Connection")).Equals("keep-alive,Foo, Bar")
 	assert.String(req.Header.Get("Proxy-Connection")).Equals("keep-alive")
 	assert.String(req.Header.Get("Proxy-Authenticate")).Equals("abc")
+	assert.String(req.Header.Get("Proxy-Authorization")).Equals("Basic djJyYXk6cGFzcw==")
 
 	StripHopByHopHeaders(req)
-	assert.String(req.Header.Get("Connection")).Equals("close")
+	assert.String(req.Header.Get("Connection")).Equals("")
 	assert.String(req.Header.Get("Foo")).Equals("")
 	assert.String(req.Header.Get("Bar")).Equals("")
 	assert.String(req.Header.Get("Proxy-Connection")).Equals("")
 	assert.String(req.Header.Get("Proxy-Authenticate")).Equals("")
+	assert.String(req.Header.Get("Proxy-Authorization")).Equals("")
 }
 
 func TestNormalGetRequest(t *testing.T) {
@@ -77,3 +93,207 @@ func TestNormalGetRequest(t *testing.T) {
 	assert.Error(err).IsNil()
 	assert.Int(resp.StatusCode).Equals(400)
 }
+
+func TestProxyAuthentication(t *testing.T) {
+	assert := assert.On(t)
+
+	testPacketDispatcher := testdispatcher.NewTestPacketDispatcher(nil)
+
+	account, err := (&Account{Username: "v2ray", Password: "pass"}).AsAny()
+	assert.Error(err).IsNil()
+
+	port := v2net.Port(dice.Roll(20000) + 10000)
+	httpProxy := NewServer(
+		&ServerConfig{
+			User: []*protocol.User{{Email: "love@v2ray.com", Account: account}},
+		},
+		testPacketDispatcher,
+		&proxy.InboundHandlerMeta{
+			Address: v2net.LocalHostIP,
+			Port:    port,
+			StreamSettings: &internet.StreamConfig{
+				Network: v2net.Network_RawTCP,
+			}})
+	defer httpProxy.Close()
+	assert.Error(httpProxy.Start()).IsNil()
+
+	conn, err := net.Dial("tcp", "127.0.0.1:"+port.String())
+	assert.Error(err).IsNil()
+	_, err = conn.Write([]byte("CONNECT v2ray.com:443 HTTP/1.1\r\nHost: v2ray.com:443\r\n\r\n"))
+	assert.Error(err).IsNil()
+	resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+	assert.Error(err).IsNil()
+	assert.Int(resp.StatusCode).Equals(407)
+	assert.String(resp.Header.Get("Proxy-Authenticate")).Equals("Basic realm=\"proxy\"")
+	conn.Close()
+
+	err = newTestClient(assert, port, "wrong").Dispatch(
+		v2net.TCPDestination(v2net.DomainAddress("v2ray.com"), 443), alloc.NewLocalBuffer(2048).Clear(), ray.NewRay())
+	assert.Error(err).Equals(proxy.ErrInvalidAuthentication)
+
+	traffic := ray.NewRay()
+	payload := alloc.NewLocalBuffer(2048).Clear().Append([]byte("Data"))
+	go newTestClient(assert, port, "pass").Dispatch(v2net.TCPDestination(v2net.DomainAddress("v2ray.com"), 443), payload, traffic)
+	assert.Destination(<-testPacketDispatcher.Destination).EqualsString("tcp:v2ray.com:443")
+
+	response, err := traffic.InboundOutput().Read()
+	assert.Error(err).IsNil()
+	assert.Bytes(response.Value).Equals([]byte("Processed: Data"))
+	traffic.InboundInput().Close()
+}
+
+// startDirectProxy starts an HTTP proxy which sends traffic out directly.
+func startDirectProxy(assert *assert.Assert) (v2net.Port, *Server) {
+	space := app.NewSpace()
+	packetDispatcher := dispatchers.NewDefaultDispatcher(space)
+	space.BindApp(dispatcher.APP_ID, packetDispatcher)
+	ohm := proxyman.NewDefaultOutboundHandlerManager()
+	ohm.SetDefaultHandler(
+		freedom.NewFreedomConnection(
+			&freedom.Config{},
+			space,
+			&proxy.OutboundHandlerMeta{
+				Address: v2net.AnyIP,
+				StreamSettings: &internet.StreamConfig{
+					Network: v2net.Network_RawTCP,
+				},
+			}))
+	space.BindApp(proxyman.APP_ID_OUTBOUND_MANAGER, ohm)
+	assert.Error(space.Initialize()).IsNil()
+
+	port := v2net.Port(dice.Roll(20000) + 10000)
+	httpProxy := NewServer(
+		&ServerConfig{},
+		packetDispatcher,
+		&proxy.InboundHandlerMeta{
+			Address: v2net.LocalHostIP,
+			Port:    port,
+			StreamSettings: &internet.StreamConfig{
+				Network: v2net.Network_RawTCP,
+			}})
+	assert.Error(httpProxy.Start()).IsNil()
+	return port, httpProxy
+}
+
+// startCountingServer starts an HTTP server which replies with the path of requests, and counts its connections.
+func startCountingServer(connections *int32) *httptest.Server {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.URL.Path))
+	}))
+	server.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			atomic.AddInt32(connections, 1)
+		}
+	}
+	server.Start()
+	return server
+}
+
+func TestKeepAlive(t *testing.T) {
+	assert := assert.On(t)
+
+	var connections1, connections2 int32
+	server1 := startCountingServer(&connections1)
+	defer server1.Close()
+	server2 := startCountingServer(&connections2)
+	defer server2.Close()
+
+	port, httpProxy := startDirectProxy(assert)
+	defer httpProxy.Close()
+
+	conn, err := net.Dial("tcp", "127.0.0.1:"+port.String())
+	assert.Error(err).IsNil()
+	defer conn.Close()
+
+	// Two pipelined requests to the first server, and one to the second.
+	paths := []string{"/a", "/b", "/c"}
+	servers := []string{server1.URL, server1.URL, server2.URL}
+	for idx, path := range paths {
+		_, err := conn.Write([]byte("GET " + servers[idx] + path + " HTTP/1.1\r\nHost: " + strings.TrimPrefix(servers[idx], "http://") + "\r\n\r\n"))
+		assert.Error(err).IsNil()
+	}
+
+	reader := bufio.NewReader(conn)
+	for _, path := range paths {
+		resp, err := http.ReadResponse(reader, nil)
+		assert.Error(err).IsNil()
+		assert.Int(resp.StatusCode).Equals(200)
+		assert.Bool(resp.Close).IsFalse()
+		body, err := ioutil.ReadAll(resp.Body)
+		assert.Error(err).IsNil()
+		assert.String(string(body)).Equals(path)
+	}
+	assert.Int(int(atomic.LoadInt32(&connections1))).Equals(1)
+	assert.Int(int(atomic.LoadInt32(&connections2))).Equals(1)
+
+	// The connection is closed as the client asks.
+	_, err = conn.Write([]byte("GET " + server1.URL + "/d HTTP/1.1\r\nHost: " + strings.TrimPrefix(server1.URL, "http://") + "\r\nConnection: close\r\n\r\n"))
+	assert.Error(err).IsNil()
+	resp, err := http.ReadResponse(reader, nil)
+	assert.Error(err).IsNil()
+	assert.Bool(resp.Close).IsTrue()
+	body, err := ioutil.ReadAll(resp.Body)
+	assert.Error(err).IsNil()
+	assert.String(string(body)).Equals("/d")
+	_, err = reader.ReadByte()
+	assert.Error(err).IsNotNil()
+}
+
+// startClosingServer starts an HTTP server which replies "OK" to the first request of each connection, and closes the
+// connection afterwards without telling the client, as if it has been idle for too long. It counts its connections.
+func startClosingServer(connections *int32) (net.Listener, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			atomic.AddInt32(connections, 1)
+			go func() {
+				defer conn.Close()
+				if _, err := http.ReadRequest(bufio.NewReader(conn)); err != nil {
+					return
+				}
+				conn.Write([]byte("HTTP/1.1 200 OK\r\nContent-Length: 2\r\n\r\nOK"))
+			}()
+		}
+	}()
+	return listener, nil
+}
+
+func TestKeepAliveWithClosedUpstream(t *testing.T) {
+	assert := assert.On(t)
+
+	var connections int32
+	listener, err := startClosingServer(&connections)
+	assert.Error(err).IsNil()
+	defer listener.Close()
+
+	port, httpProxy := startDirectProxy(assert)
+	defer httpProxy.Close()
+
+	conn, err := net.Dial("tcp", "127.0.0.1:"+port.String())
+	assert.Error(err).IsNil()
+	defer conn.Close()
+
+	address := listener.Addr().String()
+	reader := bufio.NewReader(conn)
+	for i := 0; i < 2; i++ {
+		_, err := conn.Write([]byte("GET http://" + address + "/ HTTP/1.1\r\nHost: " + address + "\r\n\r\n"))
+		assert.Error(err).IsNil()
+		resp, err := http.ReadResponse(reader, nil)
+		assert.Error(err).IsNil()
+		assert.Int(resp.StatusCode).Equals(200)
+		body, err := ioutil.ReadAll(resp.Body)
+		assert.Error(err).IsNil()
+		assert.String(string(body)).Equals("OK")
+
+		// Wait for the upstream to be closed.
+		time.Sleep(time.Millisecond * 100)
+	}
+	assert.Int(int(atomic.LoadInt32(&connections))).Equals(2)
+}
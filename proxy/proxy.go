@@ -51,3 +51,27 @@ type OutboundHandler interface {
 	// Dispatch sends one or more Packets to its destination.
 	Dispatch(destination v2net.Destination, payload *alloc.Buffer, ray ray.OutboundRay) error
 }
+
+// A UserManager is an InboundHandler whose users can be changed while it is running.
+type UserManager interface {
+	// AddUser adds a user, or replaces the existing user with the same email. Users without email are rejected.
+	AddUser(user *protocol.User) error
+	// RemoveUser removes the user of the email. An empty email is rejected.
+	RemoveUser(email string) error
+}
+
+// A BindSession is a pending BIND request, i.e. a request to accept a connection from the destination.
+type BindSession struct {
+	// Address is the address that the destination is expected to connect to.
+	Address v2net.Destination
+	// Accepted receives the address of the first connection from the destination. It is closed without a value if no
+	// connection is accepted.
+	Accepted <-chan v2net.Destination
+}
+
+// A Binder is an OutboundHandler that is able to accept connections from destinations.
+type Binder interface {
+	// Bind starts waiting for a connection from the destination. Traffic of the accepted connection is relayed
+	// through the ray.
+	Bind(destination v2net.Destination, ray ray.OutboundRay) (*BindSession, error)
+}
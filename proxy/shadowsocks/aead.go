@@ -0,0 +1,143 @@
+package shadowsocks
+
+import (
+	"crypto/cipher"
+	"io"
+
+	"v2ray.com/core/common/alloc"
+	"v2ray.com/core/common/log"
+	"v2ray.com/core/common/serial"
+	"v2ray.com/core/transport"
+)
+
+const (
+	// AEADMaxChunkSize is the maximum size of payload in one AEAD chunk.
+	AEADMaxChunkSize = 0x3FFF
+)
+
+// increaseNonce increases the nonce by one, as a little endian integer.
+func increaseNonce(nonce []byte) {
+	for i := range nonce {
+		nonce[i]++
+		if nonce[i] != 0 {
+			return
+		}
+	}
+}
+
+// AEADReader reads a stream of sealed chunks. Each chunk consists of a sealed 2-byte length and the sealed payload.
+type AEADReader struct {
+	reader io.Reader
+	aead   cipher.AEAD
+	nonce  []byte
+	buffer *alloc.Buffer
+}
+
+func NewAEADReader(reader io.Reader, aead cipher.AEAD) *AEADReader {
+	return &AEADReader{
+		reader: reader,
+		aead:   aead,
+		nonce:  make([]byte, aead.NonceSize()),
+	}
+}
+
+func (this *AEADReader) open(data []byte) ([]byte, error) {
+	plain, err := this.aead.Open(data[:0], this.nonce, data, nil)
+	if err != nil {
+		log.Debug("Shadowsocks|AEAD: Failed to open chunk: ", err)
+		return nil, transport.ErrCorruptedPacket
+	}
+	increaseNonce(this.nonce)
+	return plain, nil
+}
+
+func (this *AEADReader) readChunk() (*alloc.Buffer, error) {
+	overhead := this.aead.Overhead()
+	buffer := alloc.NewLargeBuffer()
+	if _, err := io.ReadFull(this.reader, buffer.Value[:2+overhead]); err != nil {
+		buffer.Release()
+		return nil, err
+	}
+	sizeBytes, err := this.open(buffer.Value[:2+overhead])
+	if err != nil {
+		buffer.Release()
+		return nil, err
+	}
+	size := int(serial.BytesToUint16(sizeBytes))
+	if size > AEADMaxChunkSize {
+		buffer.Release()
+		return nil, transport.ErrCorruptedPacket
+	}
+	if _, err := io.ReadFull(this.reader, buffer.Value[:size+overhead]); err != nil {
+		buffer.Release()
+		return nil, err
+	}
+	if _, err := this.open(buffer.Value[:size+overhead]); err != nil {
+		buffer.Release()
+		return nil, err
+	}
+	buffer.Slice(0, size)
+	return buffer, nil
+}
+
+// Read implements io.Reader. It returns the payload of the chunks in order.
+func (this *AEADReader) Read(b []byte) (int, error) {
+	for this.buffer == nil || this.buffer.IsEmpty() {
+		this.buffer.Release()
+		this.buffer = nil
+		buffer, err := this.readChunk()
+		if err != nil {
+			return 0, err
+		}
+		this.buffer = buffer
+	}
+	return this.buffer.Read(b)
+}
+
+func (this *AEADReader) Release() {
+	this.buffer.Release()
+	this.buffer = nil
+	this.reader = nil
+}
+
+// AEADWriter writes data as sealed chunks.
+type AEADWriter struct {
+	writer io.Writer
+	aead   cipher.AEAD
+	nonce  []byte
+}
+
+func NewAEADWriter(writer io.Writer, aead cipher.AEAD) *AEADWriter {
+	return &AEADWriter{
+		writer: writer,
+		aead:   aead,
+		nonce:  make([]byte, aead.NonceSize()),
+	}
+}
+
+func (this *AEADWriter) seal(dst []byte, data []byte) []byte {
+	dst = this.aead.Seal(dst, this.nonce, data, nil)
+	increaseNonce(this.nonce)
+	return dst
+}
+
+// Write implements io.Writer. Data larger than AEADMaxChunkSize is split into multiple chunks.
+func (this *AEADWriter) Write(b []byte) (int, error) {
+	buffer := alloc.NewLargeBuffer()
+	defer buffer.Release()
+
+	written := 0
+	for written < len(b) {
+		size := len(b) - written
+		if size > AEADMaxChunkSize {
+			size = AEADMaxChunkSize
+		}
+		chunk := this.seal(buffer.Value[:0], serial.Uint16ToBytes(uint16(size), nil))
+		chunk = this.seal(chunk, b[written:written+size])
+		if _, err := this.writer.Write(chunk); err != nil {
+			return written, err
+		}
+		written += size
+	}
+	return written, nil
+}
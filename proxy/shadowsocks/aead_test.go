@@ -0,0 +1,85 @@
+package shadowsocks_test
+
+import (
+	"bytes"
+	"crypto/rand"
+	"io"
+	"testing"
+
+	"v2ray.com/core/common/alloc"
+	v2net "v2ray.com/core/common/net"
+	. "v2ray.com/core/proxy/shadowsocks"
+	"v2ray.com/core/testing/assert"
+	"v2ray.com/core/transport"
+)
+
+func TestAEADChunks(t *testing.T) {
+	assert := assert.On(t)
+
+	account := &Account{Password: "v2ray-password", CipherType: CipherType_AES_256_GCM}
+	cipher, err := account.GetCipher()
+	assert.Error(err).IsNil()
+	aeadCipher := cipher.(*AEADCipher)
+
+	salt := make([]byte, aeadCipher.IVSize())
+	rand.Read(salt)
+
+	aead, err := aeadCipher.NewAEAD(account.GetCipherKey(), salt)
+	assert.Error(err).IsNil()
+
+	payload := make([]byte, AEADMaxChunkSize*2+100)
+	rand.Read(payload)
+
+	stream := new(bytes.Buffer)
+	writer := NewAEADWriter(stream, aead)
+	nBytes, err := writer.Write(payload[:10])
+	assert.Error(err).IsNil()
+	assert.Int(nBytes).Equals(10)
+	nBytes, err = writer.Write(payload[10:])
+	assert.Error(err).IsNil()
+	assert.Int(nBytes).Equals(len(payload) - 10)
+	// 4 chunks, each with a sealed length and sealed payload.
+	assert.Int(stream.Len()).Equals(len(payload) + 4*(2+2*aead.Overhead()))
+
+	sealed := stream.Bytes()
+	tampered := make([]byte, len(sealed))
+	copy(tampered, sealed)
+	tampered[len(tampered)-1] ^= 1
+
+	aead, err = aeadCipher.NewAEAD(account.GetCipherKey(), salt)
+	assert.Error(err).IsNil()
+	actualPayload := make([]byte, len(payload))
+	_, err = io.ReadFull(NewAEADReader(bytes.NewReader(sealed), aead), actualPayload)
+	assert.Error(err).IsNil()
+	assert.Bytes(actualPayload).Equals(payload)
+
+	aead, err = aeadCipher.NewAEAD(account.GetCipherKey(), salt)
+	assert.Error(err).IsNil()
+	_, err = io.ReadFull(NewAEADReader(bytes.NewReader(tampered), aead), actualPayload)
+	assert.Error(err).Equals(transport.ErrCorruptedPacket)
+}
+
+func TestAEADUDPPacket(t *testing.T) {
+	assert := assert.On(t)
+
+	account := &Account{Password: "v2ray-password", CipherType: CipherType_CHACHA20_POLY1305}
+	cipher, err := account.GetCipher()
+	assert.Error(err).IsNil()
+
+	request := &Request{
+		Address: v2net.DomainAddress("www.v2ray.com"),
+		Port:    v2net.Port(53),
+	}
+	packet, err := EncodeUDPPacket(request, alloc.NewLocalBuffer(2048).Clear().AppendBytes(1, 2, 3, 4), cipher, account.GetCipherKey())
+	assert.Error(err).IsNil()
+
+	decoded, err := DecodeUDPPacket(packet, cipher, account.GetCipherKey())
+	assert.Error(err).IsNil()
+	assert.Address(decoded.Address).Equals(v2net.DomainAddress("www.v2ray.com"))
+	assert.Port(decoded.Port).Equals(v2net.Port(53))
+	assert.Bytes(decoded.UDPPayload.Value).Equals([]byte{1, 2, 3, 4})
+
+	packet.Value[len(packet.Value)-1] ^= 1
+	_, err = DecodeUDPPacket(packet, cipher, account.GetCipherKey())
+	assert.Error(err).Equals(transport.ErrCorruptedPacket)
+}
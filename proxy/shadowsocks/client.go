@@ -0,0 +1,261 @@
+package shadowsocks
+
+import (
+	"crypto/rand"
+	"io"
+
+	"v2ray.com/core/app"
+	"v2ray.com/core/common/alloc"
+	"v2ray.com/core/common/crypto"
+	v2io "v2ray.com/core/common/io"
+	"v2ray.com/core/common/log"
+	v2net "v2ray.com/core/common/net"
+	"v2ray.com/core/common/protocol"
+	"v2ray.com/core/common/retry"
+	"v2ray.com/core/proxy"
+	"v2ray.com/core/proxy/registry"
+	"v2ray.com/core/transport/internet"
+	"v2ray.com/core/transport/internet/tcp"
+	"v2ray.com/core/transport/ray"
+)
+
+const (
+	// UDPTimeout is the number of seconds that a UDP session is kept without any response.
+	UDPTimeout = 16
+)
+
+// Client is a Shadowsocks outbound handler, which sends traffic through one of the configured servers.
+type Client struct {
+	serverPicker protocol.ServerPicker
+	meta         *proxy.OutboundHandlerMeta
+}
+
+func NewClient(config *ClientConfig, space app.Space, meta *proxy.OutboundHandlerMeta) (*Client, error) {
+	serverList := protocol.NewServerList()
+	for _, rec := range config.Server {
+		serverList.AddServer(protocol.NewServerSpecFromPB(NewAccount, *rec))
+	}
+	return &Client{
+		serverPicker: protocol.NewRoundRobinServerPicker(serverList),
+		meta:         meta,
+	}, nil
+}
+
+func (this *Client) Dispatch(destination v2net.Destination, payload *alloc.Buffer, ray ray.OutboundRay) error {
+	defer payload.Release()
+	defer ray.OutboundInput().Release()
+	defer ray.OutboundOutput().Close()
+
+	var server *protocol.ServerSpec
+	var conn internet.Connection
+
+	err := retry.Timed(5, 100).On(func() error {
+		server = this.serverPicker.PickServer()
+		dest := server.Destination()
+		if destination.Network == v2net.Network_UDP {
+			dest = v2net.UDPDestination(dest.Address, dest.Port)
+		}
+		rawConn, err := internet.Dial(this.meta.Address, dest, this.meta.StreamSettings)
+		if err != nil {
+			return err
+		}
+		conn = rawConn
+		return nil
+	})
+	if err != nil {
+		log.Warning("Shadowsocks|Client: Failed to find an available destination: ", err)
+		return err
+	}
+	defer conn.Close()
+	conn.SetReusable(false)
+
+	user := server.PickUser()
+	if user == nil {
+		return protocol.ErrUserMissing
+	}
+	rawAccount, err := user.GetTypedAccount(NewAccount())
+	if err != nil {
+		log.Error("Shadowsocks|Client: Invalid account of ", server.Destination(), ": ", err)
+		return err
+	}
+	account := rawAccount.(*Account)
+	cipher, err := account.GetCipher()
+	if err != nil {
+		log.Error("Shadowsocks|Client: Invalid cipher of ", server.Destination(), ": ", err)
+		return err
+	}
+
+	request := &Request{
+		Address: destination.Address,
+		Port:    destination.Port,
+		OTA:     account.Ota,
+	}
+	if _, ok := cipher.(*AEADCipher); ok {
+		// Integrity is already provided by AEAD ciphers.
+		request.OTA = false
+	}
+	log.Info("Shadowsocks|Client: Tunneling request to ", destination, " via ", server.Destination())
+
+	if destination.Network == v2net.Network_UDP {
+		return this.transportUDP(conn, request, cipher, account.GetCipherKey(), payload, ray)
+	}
+	return this.transportTCP(conn, request, cipher, account.GetCipherKey(), payload, ray)
+}
+
+func (this *Client) transportTCP(conn internet.Connection, request *Request, cipher Cipher, key []byte, payload *alloc.Buffer, ray ray.OutboundRay) error {
+	iv := make([]byte, cipher.IVSize())
+	rand.Read(iv)
+
+	bufferedWriter := v2io.NewBufferedWriter(conn)
+	bufferedWriter.Write(iv)
+
+	var writer io.Writer
+	var auth *Authenticator
+	aeadCipher, isAEAD := cipher.(*AEADCipher)
+	if isAEAD {
+		aead, err := aeadCipher.NewAEAD(key, iv)
+		if err != nil {
+			log.Error("Shadowsocks|Client: Failed to create AEAD: ", err)
+			bufferedWriter.Release()
+			return err
+		}
+		writer = NewAEADWriter(bufferedWriter, aead)
+	} else {
+		stream, err := cipher.NewEncodingStream(key, iv)
+		if err != nil {
+			log.Error("Shadowsocks|Client: Failed to create encoding stream: ", err)
+			bufferedWriter.Release()
+			return err
+		}
+		writer = crypto.NewCryptionWriter(stream, bufferedWriter)
+		auth = NewAuthenticator(HeaderKeyGenerator(key, iv))
+	}
+	if err := WriteTCPRequest(request, writer, auth); err != nil {
+		log.Warning("Shadowsocks|Client: Failed to write request: ", err)
+		bufferedWriter.Release()
+		return err
+	}
+
+	var bodyWriter v2io.Writer = v2io.NewAdaptiveWriter(writer)
+	if request.OTA {
+		bodyWriter = NewChunkWriter(bodyWriter, NewAuthenticator(ChunkKeyGenerator(iv)))
+	}
+	if !payload.IsEmpty() {
+		if err := bodyWriter.Write(payload); err != nil {
+			log.Warning("Shadowsocks|Client: Failed to write payload: ", err)
+			bodyWriter.Release()
+			bufferedWriter.Release()
+			return err
+		}
+	}
+	bufferedWriter.SetCached(false)
+
+	go func() {
+		v2io.Pipe(ray.OutboundInput(), bodyWriter)
+		bodyWriter.Release()
+		bufferedWriter.Release()
+		if tcpConn, ok := conn.(*tcp.RawConnection); ok {
+			tcpConn.CloseWrite()
+		}
+	}()
+
+	respIv := make([]byte, cipher.IVSize())
+	if _, err := io.ReadFull(conn, respIv); err != nil {
+		log.Info("Shadowsocks|Client: Failed to read IV from server: ", err)
+		return err
+	}
+
+	var reader io.Reader
+	if isAEAD {
+		aead, err := aeadCipher.NewAEAD(key, respIv)
+		if err != nil {
+			log.Error("Shadowsocks|Client: Failed to create AEAD: ", err)
+			return err
+		}
+		reader = NewAEADReader(conn, aead)
+	} else {
+		respStream, err := cipher.NewDecodingStream(key, respIv)
+		if err != nil {
+			log.Error("Shadowsocks|Client: Failed to create decoding stream: ", err)
+			return err
+		}
+		reader = crypto.NewCryptionReader(respStream, conn)
+	}
+
+	v2reader := v2io.NewAdaptiveReader(reader)
+	v2io.Pipe(v2reader, ray.OutboundOutput())
+	v2reader.Release()
+	return nil
+}
+
+func (this *Client) transportUDP(conn internet.Connection, request *Request, cipher Cipher, key []byte, payload *alloc.Buffer, ray ray.OutboundRay) error {
+	writePacket := func(data *alloc.Buffer) error {
+		packet, err := EncodeUDPPacket(request, data, cipher, key)
+		if err != nil {
+			return err
+		}
+		defer packet.Release()
+		_, err = conn.Write(packet.Value)
+		return err
+	}
+
+	if !payload.IsEmpty() {
+		if err := writePacket(payload); err != nil {
+			log.Warning("Shadowsocks|Client: Failed to write UDP packet: ", err)
+			return err
+		}
+	}
+
+	input := ray.OutboundInput()
+	go func() {
+		for {
+			data, err := input.Read()
+			if err != nil {
+				return
+			}
+			err = writePacket(data)
+			data.Release()
+			if err != nil {
+				log.Info("Shadowsocks|Client: Failed to write UDP packet: ", err)
+				return
+			}
+		}
+	}()
+
+	output := ray.OutboundOutput()
+	reader := v2net.NewTimeOutReader(UDPTimeout, conn)
+	for {
+		buffer := alloc.NewBuffer()
+		nBytes, err := reader.Read(buffer.Value)
+		if err != nil {
+			buffer.Release()
+			return nil
+		}
+		buffer.Slice(0, nBytes)
+		response, err := DecodeUDPPacket(buffer, cipher, key)
+		buffer.Release()
+		if err != nil {
+			log.Info("Shadowsocks|Client: Invalid UDP packet from server: ", err)
+			continue
+		}
+		if err := output.Write(response.DetachUDPPayload()); err != nil {
+			return nil
+		}
+	}
+}
+
+type ClientFactory struct{}
+
+func (this *ClientFactory) StreamCapability() v2net.NetworkList {
+	return v2net.NetworkList{
+		Network: []v2net.Network{v2net.Network_RawTCP},
+	}
+}
+
+func (this *ClientFactory) Create(space app.Space, rawConfig interface{}, meta *proxy.OutboundHandlerMeta) (proxy.OutboundHandler, error) {
+	return NewClient(rawConfig.(*ClientConfig), space, meta)
+}
+
+func init() {
+	registry.MustRegisterOutboundHandlerCreator("shadowsocks", new(ClientFactory))
+}
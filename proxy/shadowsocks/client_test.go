@@ -0,0 +1,176 @@
+package shadowsocks_test
+
+import (
+	"testing"
+
+	"v2ray.com/core/app"
+	"v2ray.com/core/app/dispatcher"
+	dispatchers "v2ray.com/core/app/dispatcher/impl"
+	"v2ray.com/core/app/proxyman"
+	"v2ray.com/core/common/alloc"
+	"v2ray.com/core/common/dice"
+	v2net "v2ray.com/core/common/net"
+	"v2ray.com/core/common/protocol"
+	"v2ray.com/core/proxy"
+	"v2ray.com/core/proxy/freedom"
+	. "v2ray.com/core/proxy/shadowsocks"
+	"v2ray.com/core/testing/assert"
+	"v2ray.com/core/testing/servers/tcp"
+	"v2ray.com/core/testing/servers/udp"
+	"v2ray.com/core/transport/internet"
+	"v2ray.com/core/transport/ray"
+
+	"github.com/golang/protobuf/ptypes"
+)
+
+func processMessage(data []byte) []byte {
+	buffer := make([]byte, 0, 2048)
+	buffer = append(buffer, []byte("Processed: ")...)
+	buffer = append(buffer, data...)
+	return buffer
+}
+
+func newTestUser(assert *assert.Assert, cipherType CipherType, ota bool) *protocol.User {
+	account, err := ptypes.MarshalAny(&Account{
+		Password:   "v2ray-password",
+		CipherType: cipherType,
+		Ota:        ota,
+	})
+	assert.Error(err).IsNil()
+	return &protocol.User{
+		Account: account,
+	}
+}
+
+// startShadowsocksServer starts a Shadowsocks server with UDP enabled, which sends traffic out directly.
+func startShadowsocksServer(assert *assert.Assert, cipherType CipherType) v2net.Port {
+	return startServerWithConfig(assert, &ServerConfig{
+		UdpEnabled: true,
+		User:       newTestUser(assert, cipherType, false),
+	})
+}
+
+func startServerWithConfig(assert *assert.Assert, config *ServerConfig) v2net.Port {
+	space := app.NewSpace()
+	space.BindApp(dispatcher.APP_ID, dispatchers.NewDefaultDispatcher(space))
+	ohm := proxyman.NewDefaultOutboundHandlerManager()
+	ohm.SetDefaultHandler(
+		freedom.NewFreedomConnection(
+			&freedom.Config{},
+			space,
+			&proxy.OutboundHandlerMeta{
+				Address: v2net.AnyIP,
+				StreamSettings: &internet.StreamConfig{
+					Network: v2net.Network_RawTCP,
+				},
+			}))
+	space.BindApp(proxyman.APP_ID_OUTBOUND_MANAGER, ohm)
+
+	port := v2net.Port(dice.Roll(20000) + 10000)
+	server, err := NewServer(config, space, &proxy.InboundHandlerMeta{
+		Address: v2net.LocalHostIP,
+		Port:    port,
+		StreamSettings: &internet.StreamConfig{
+			Network: v2net.Network_RawTCP,
+		},
+	})
+	assert.Error(err).IsNil()
+	assert.Error(space.Initialize()).IsNil()
+	assert.Error(server.Start()).IsNil()
+	return port
+}
+
+func newTestClient(assert *assert.Assert, port v2net.Port, cipherType CipherType, ota bool) *Client {
+	return newClientOfUser(assert, port, newTestUser(assert, cipherType, ota))
+}
+
+func newClientOfUser(assert *assert.Assert, port v2net.Port, user *protocol.User) *Client {
+	client, err := NewClient(&ClientConfig{
+		Server: []*protocol.ServerSpecPB{{
+			Address: v2net.NewAddressPB(v2net.LocalHostIP),
+			Port:    uint32(port),
+			User:    []*protocol.User{user},
+		}},
+	}, app.NewSpace(), &proxy.OutboundHandlerMeta{
+		Address: v2net.AnyIP,
+		StreamSettings: &internet.StreamConfig{
+			Network: v2net.Network_RawTCP,
+		},
+	})
+	assert.Error(err).IsNil()
+	return client
+}
+
+func testTCPClient(assert *assert.Assert, cipherType CipherType, ota bool) {
+	testTCPTraffic(assert, newTestClient(assert, startShadowsocksServer(assert, cipherType), cipherType, ota))
+}
+
+func testTCPTraffic(assert *assert.Assert, client *Client) {
+	tcpServer := &tcp.Server{
+		MsgProcessor: processMessage,
+	}
+	_, err := tcpServer.Start()
+	assert.Error(err).IsNil()
+	defer tcpServer.Close()
+
+	traffic := ray.NewRay()
+	payload := alloc.NewLocalBuffer(2048).Clear().Append([]byte("Data to be sent to remote"))
+	go client.Dispatch(v2net.TCPDestination(v2net.LocalHostIP, tcpServer.Port), payload, traffic)
+	traffic.InboundInput().Close()
+
+	response, err := traffic.InboundOutput().Read()
+	assert.Error(err).IsNil()
+	assert.Bytes(response.Value).Equals([]byte("Processed: Data to be sent to remote"))
+}
+
+func TestShadowsocksClientTCP(t *testing.T) {
+	testTCPClient(assert.On(t), CipherType_CHACHA20_IEFT, false)
+}
+
+func TestShadowsocksClientTCPWithOTA(t *testing.T) {
+	testTCPClient(assert.On(t), CipherType_CHACHA20_IEFT, true)
+}
+
+func testUDPClient(assert *assert.Assert, cipherType CipherType, ota bool) {
+	testUDPTraffic(assert, newTestClient(assert, startShadowsocksServer(assert, cipherType), cipherType, ota))
+}
+
+func testUDPTraffic(assert *assert.Assert, client *Client) {
+	udpServer := &udp.Server{
+		MsgProcessor: processMessage,
+	}
+	dest, err := udpServer.Start()
+	assert.Error(err).IsNil()
+	defer udpServer.Close()
+
+	traffic := ray.NewRay()
+	payload := alloc.NewLocalBuffer(2048).Clear().Append([]byte("Packet to be sent to remote"))
+	go client.Dispatch(dest, payload, traffic)
+
+	response, err := traffic.InboundOutput().Read()
+	assert.Error(err).IsNil()
+	assert.Bytes(response.Value).Equals([]byte("Processed: Packet to be sent to remote"))
+
+	assert.Error(traffic.InboundInput().Write(alloc.NewLocalBuffer(2048).Clear().Append([]byte("Another packet")))).IsNil()
+	response, err = traffic.InboundOutput().Read()
+	assert.Error(err).IsNil()
+	assert.Bytes(response.Value).Equals([]byte("Processed: Another packet"))
+	traffic.InboundInput().Close()
+}
+
+func TestShadowsocksClientUDP(t *testing.T) {
+	testUDPClient(assert.On(t), CipherType_CHACHA20_IEFT, false)
+}
+
+func TestShadowsocksClientUDPWithOTA(t *testing.T) {
+	testUDPClient(assert.On(t), CipherType_CHACHA20_IEFT, true)
+}
+
+func TestShadowsocksClientAEAD(t *testing.T) {
+	assert := assert.On(t)
+
+	for _, cipherType := range []CipherType{CipherType_AES_128_GCM, CipherType_AES_256_GCM, CipherType_CHACHA20_POLY1305} {
+		testTCPClient(assert, cipherType, false)
+		testUDPClient(assert, cipherType, false)
+	}
+}
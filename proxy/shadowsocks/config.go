@@ -1,12 +1,22 @@
 package shadowsocks
 
 import (
+	"crypto/aes"
 	"crypto/cipher"
 	"crypto/md5"
+	"crypto/sha1"
 	"errors"
+	"io"
 
 	"v2ray.com/core/common/crypto"
 	"v2ray.com/core/common/protocol"
+
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/hkdf"
+)
+
+var (
+	ErrNotStreamCipher = errors.New("Shadowsocks: Not a stream cipher.")
 )
 
 func (this *Account) GetCipher() (Cipher, error) {
@@ -19,6 +29,12 @@ func (this *Account) GetCipher() (Cipher, error) {
 		return &ChaCha20{IVBytes: 8}, nil
 	case CipherType_CHACHA20_IEFT:
 		return &ChaCha20{IVBytes: 12}, nil
+	case CipherType_AES_128_GCM:
+		return &AEADCipher{KeyBytes: 16, AEADCreator: createAesGcm}, nil
+	case CipherType_AES_256_GCM:
+		return &AEADCipher{KeyBytes: 32, AEADCreator: createAesGcm}, nil
+	case CipherType_CHACHA20_POLY1305:
+		return &AEADCipher{KeyBytes: 32, AEADCreator: chacha20poly1305.New}, nil
 	default:
 		return nil, errors.New("Unsupported cipher.")
 	}
@@ -35,6 +51,10 @@ func (this *Account) AsAccount() (protocol.Account, error) {
 	return this, nil
 }
 
+func NewAccount() protocol.AsAccount {
+	return &Account{}
+}
+
 func (this *Account) GetCipherKey() []byte {
 	ct, err := this.GetCipher()
 	if err != nil {
@@ -92,6 +112,47 @@ func (this *ChaCha20) NewDecodingStream(key []byte, iv []byte) (cipher.Stream, e
 	return crypto.NewChaCha20Stream(key, iv), nil
 }
 
+// AEADCipher is a cipher with authenticated encryption. Each session derives its own subkey from the key and a random
+// salt, which takes the place of the IV.
+type AEADCipher struct {
+	KeyBytes    int
+	AEADCreator func(key []byte) (cipher.AEAD, error)
+}
+
+func (this *AEADCipher) KeySize() int {
+	return this.KeyBytes
+}
+
+// IVSize returns the size of the salt.
+func (this *AEADCipher) IVSize() int {
+	return this.KeyBytes
+}
+
+func (this *AEADCipher) NewEncodingStream(key []byte, iv []byte) (cipher.Stream, error) {
+	return nil, ErrNotStreamCipher
+}
+
+func (this *AEADCipher) NewDecodingStream(key []byte, iv []byte) (cipher.Stream, error) {
+	return nil, ErrNotStreamCipher
+}
+
+// NewAEAD creates the AEAD of a session with the given salt. The subkey is derived with HKDF-SHA1.
+func (this *AEADCipher) NewAEAD(key []byte, salt []byte) (cipher.AEAD, error) {
+	subkey := make([]byte, this.KeyBytes)
+	if _, err := io.ReadFull(hkdf.New(sha1.New, key, salt, []byte("ss-subkey")), subkey); err != nil {
+		return nil, err
+	}
+	return this.AEADCreator(subkey)
+}
+
+func createAesGcm(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
 func PasswordToCipherKey(password string, keySize int) []byte {
 	pwdBytes := []byte(password)
 	key := make([]byte, 0, keySize)
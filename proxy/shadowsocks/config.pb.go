@@ -35,11 +35,14 @@ const _ = proto.ProtoPackageIsVersion2 // please upgrade the proto package
 type CipherType int32
 
 const (
-	CipherType_UNKNOWN       CipherType = 0
-	CipherType_AES_128_CFB   CipherType = 1
-	CipherType_AES_256_CFB   CipherType = 2
-	CipherType_CHACHA20      CipherType = 3
-	CipherType_CHACHA20_IEFT CipherType = 4
+	CipherType_UNKNOWN           CipherType = 0
+	CipherType_AES_128_CFB       CipherType = 1
+	CipherType_AES_256_CFB       CipherType = 2
+	CipherType_CHACHA20          CipherType = 3
+	CipherType_CHACHA20_IEFT     CipherType = 4
+	CipherType_AES_128_GCM       CipherType = 5
+	CipherType_AES_256_GCM       CipherType = 6
+	CipherType_CHACHA20_POLY1305 CipherType = 7
 )
 
 var CipherType_name = map[int32]string{
@@ -48,13 +51,19 @@ var CipherType_name = map[int32]string{
 	2: "AES_256_CFB",
 	3: "CHACHA20",
 	4: "CHACHA20_IEFT",
+	5: "AES_128_GCM",
+	6: "AES_256_GCM",
+	7: "CHACHA20_POLY1305",
 }
 var CipherType_value = map[string]int32{
-	"UNKNOWN":       0,
-	"AES_128_CFB":   1,
-	"AES_256_CFB":   2,
-	"CHACHA20":      3,
-	"CHACHA20_IEFT": 4,
+	"UNKNOWN":           0,
+	"AES_128_CFB":       1,
+	"AES_256_CFB":       2,
+	"CHACHA20":          3,
+	"CHACHA20_IEFT":     4,
+	"AES_128_GCM":       5,
+	"AES_256_GCM":       6,
+	"CHACHA20_POLY1305": 7,
 }
 
 func (x CipherType) String() string {
@@ -65,6 +74,8 @@ func (CipherType) EnumDescriptor() ([]byte, []int) { return fileDescriptor0, []i
 type Account struct {
 	Password   string     `protobuf:"bytes,1,opt,name=password" json:"password,omitempty"`
 	CipherType CipherType `protobuf:"varint,2,opt,name=cipher_type,json=cipherType,enum=v2ray.core.proxy.shadowsocks.CipherType" json:"cipher_type,omitempty"`
+	// Whether one-time auth is enabled by the client.
+	Ota bool `protobuf:"varint,3,opt,name=ota" json:"ota,omitempty"`
 }
 
 func (m *Account) Reset()                    { *m = Account{} }
@@ -75,6 +86,8 @@ func (*Account) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{0}
 type ServerConfig struct {
 	UdpEnabled bool                             `protobuf:"varint,1,opt,name=udp_enabled,json=udpEnabled" json:"udp_enabled,omitempty"`
 	User       *v2ray_core_common_protocol.User `protobuf:"bytes,2,opt,name=user" json:"user,omitempty"`
+	// More users on the same inbound. With more than one user in total, all of them must use AEAD ciphers.
+	Users []*v2ray_core_common_protocol.User `protobuf:"bytes,3,rep,name=users" json:"users,omitempty"`
 }
 
 func (m *ServerConfig) Reset()                    { *m = ServerConfig{} }
@@ -89,6 +102,13 @@ func (m *ServerConfig) GetUser() *v2ray_core_common_protocol.User {
 	return nil
 }
 
+func (m *ServerConfig) GetUsers() []*v2ray_core_common_protocol.User {
+	if m != nil {
+		return m.Users
+	}
+	return nil
+}
+
 type ClientConfig struct {
 	Server []*v2ray_core_common_protocol1.ServerSpecPB `protobuf:"bytes,1,rep,name=server" json:"server,omitempty"`
 }
@@ -115,29 +135,32 @@ func init() {
 func init() { proto.RegisterFile("v2ray.com/core/proxy/shadowsocks/config.proto", fileDescriptor0) }
 
 var fileDescriptor0 = []byte{
-	// 370 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x09, 0x6e, 0x88, 0x02, 0xff, 0x84, 0x50, 0xdd, 0xaf, 0xd2, 0x30,
-	0x14, 0x77, 0xf7, 0xde, 0x5c, 0xf0, 0x14, 0x75, 0xf6, 0x89, 0x10, 0x13, 0x17, 0x9e, 0xa6, 0x89,
-	0x1d, 0xce, 0x8f, 0xf8, 0xe0, 0x83, 0xdb, 0x02, 0x81, 0x98, 0x20, 0x19, 0x10, 0x13, 0x63, 0xb2,
-	0x8c, 0xae, 0x0a, 0x91, 0xad, 0x4d, 0xbb, 0x81, 0xfb, 0xef, 0x0d, 0x2d, 0x43, 0xe4, 0x01, 0xdf,
-	0x7a, 0x4e, 0x7f, 0x9f, 0x07, 0x5e, 0xed, 0x7c, 0x99, 0xd6, 0x84, 0xf2, 0xdc, 0xa3, 0x5c, 0x32,
-	0x4f, 0x48, 0xfe, 0xbb, 0xf6, 0xd4, 0x3a, 0xcd, 0xf8, 0x5e, 0x71, 0xfa, 0x4b, 0x79, 0x94, 0x17,
-	0x3f, 0x36, 0x3f, 0x89, 0x90, 0xbc, 0xe4, 0xf8, 0x59, 0x03, 0x97, 0x8c, 0x68, 0x28, 0x39, 0x83,
-	0xf6, 0x5e, 0x5c, 0x88, 0x51, 0x9e, 0xe7, 0xbc, 0xf0, 0x34, 0x95, 0xf2, 0xad, 0x57, 0x29, 0x26,
-	0x8d, 0x50, 0x6f, 0xf0, 0x1f, 0xa8, 0x62, 0x72, 0xc7, 0x64, 0xa2, 0x04, 0xa3, 0x86, 0xd1, 0x17,
-	0xd0, 0x0a, 0x28, 0xe5, 0x55, 0x51, 0xe2, 0x1e, 0xb4, 0x45, 0xaa, 0xd4, 0x9e, 0xcb, 0xac, 0x6b,
-	0x39, 0x96, 0xfb, 0x30, 0x3e, 0xcd, 0x78, 0x02, 0x88, 0x6e, 0xc4, 0x9a, 0xc9, 0xa4, 0xac, 0x05,
-	0xeb, 0xde, 0x38, 0x96, 0xfb, 0xd8, 0x77, 0xc9, 0xb5, 0xdc, 0x24, 0xd2, 0x84, 0x45, 0x2d, 0x58,
-	0x0c, 0xf4, 0xf4, 0xee, 0x33, 0xe8, 0xcc, 0x75, 0x8c, 0x48, 0x9f, 0x00, 0x3f, 0x07, 0x54, 0x65,
-	0x22, 0x61, 0x45, 0xba, 0xda, 0x32, 0xe3, 0xdc, 0x8e, 0xa1, 0xca, 0xc4, 0xd0, 0x6c, 0xf0, 0x5b,
-	0xb8, 0x3b, 0x54, 0xd4, 0xa6, 0xc8, 0x77, 0xce, 0x4d, 0x4d, 0x3f, 0xd2, 0xf4, 0x23, 0x4b, 0xc5,
-	0x64, 0xac, 0xd1, 0xfd, 0x19, 0x74, 0xa2, 0xed, 0x86, 0x15, 0xe5, 0xd1, 0xe6, 0x13, 0xdc, 0x9b,
-	0xf6, 0x5d, 0xcb, 0xb9, 0x75, 0xd1, 0xbf, 0xe1, 0x2f, 0x75, 0x4c, 0xc0, 0xb9, 0x60, 0x74, 0x16,
-	0xc6, 0x47, 0xde, 0xcb, 0xef, 0x00, 0x7f, 0x2b, 0x61, 0x04, 0xad, 0xe5, 0xf4, 0xf3, 0xf4, 0xcb,
-	0xd7, 0xa9, 0xfd, 0x00, 0x3f, 0x01, 0x14, 0x0c, 0xe7, 0xc9, 0x6b, 0xff, 0x43, 0x12, 0x8d, 0x42,
-	0xdb, 0x6a, 0x16, 0xfe, 0xbb, 0xf7, 0x7a, 0x71, 0x83, 0x3b, 0xd0, 0x8e, 0xc6, 0x41, 0x34, 0x0e,
-	0xfc, 0x81, 0x7d, 0x8b, 0x9f, 0xc2, 0xa3, 0x66, 0x4a, 0x26, 0xc3, 0xd1, 0xc2, 0xbe, 0x0b, 0x3f,
-	0x82, 0x43, 0x79, 0x7e, 0xf5, 0xa2, 0x21, 0x32, 0x5d, 0x66, 0x87, 0x98, 0xdf, 0xd0, 0xd9, 0xcf,
-	0xea, 0x5e, 0x47, 0x7f, 0xf3, 0x27, 0x00, 0x00, 0xff, 0xff, 0x62, 0xda, 0x5c, 0xcd, 0x79, 0x02,
-	0x00, 0x00,
+	// 428 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x09, 0x6e, 0x88, 0x02, 0xff, 0x8c, 0x51, 0x41, 0x6f, 0xd3, 0x30,
+	0x18, 0xc5, 0xcb, 0xd6, 0x96, 0x2f, 0x05, 0x32, 0x4b, 0x48, 0x51, 0x85, 0x44, 0xd4, 0x53, 0x40,
+	0xc2, 0xe9, 0x32, 0x36, 0x71, 0xe0, 0x40, 0x1b, 0x75, 0x6c, 0x02, 0xba, 0x2a, 0xdd, 0x84, 0xe0,
+	0x12, 0x65, 0xae, 0x61, 0x15, 0x4d, 0x6c, 0xd9, 0xc9, 0x46, 0xae, 0xfc, 0x07, 0x8e, 0xfc, 0x57,
+	0x14, 0x7b, 0x29, 0x11, 0x87, 0xc2, 0x29, 0xf6, 0xcb, 0x7b, 0x4f, 0xef, 0x3d, 0xc3, 0x8b, 0x9b,
+	0x50, 0xa6, 0x15, 0xa1, 0x3c, 0x0b, 0x28, 0x97, 0x2c, 0x10, 0x92, 0x7f, 0xaf, 0x02, 0x75, 0x9d,
+	0x2e, 0xf9, 0xad, 0xe2, 0xf4, 0x9b, 0x0a, 0x28, 0xcf, 0xbf, 0xac, 0xbe, 0x12, 0x21, 0x79, 0xc1,
+	0xf1, 0x93, 0x86, 0x2e, 0x19, 0xd1, 0x54, 0xd2, 0xa2, 0x0e, 0x9e, 0xfd, 0x65, 0x46, 0x79, 0x96,
+	0xf1, 0x3c, 0xd0, 0x52, 0xca, 0xd7, 0x41, 0xa9, 0x98, 0x34, 0x46, 0x83, 0xd1, 0x3f, 0xa8, 0x8a,
+	0xc9, 0x1b, 0x26, 0x13, 0x25, 0x18, 0x35, 0x8a, 0xe1, 0x0f, 0x04, 0xdd, 0x31, 0xa5, 0xbc, 0xcc,
+	0x0b, 0x3c, 0x80, 0x9e, 0x48, 0x95, 0xba, 0xe5, 0x72, 0xe9, 0x22, 0x0f, 0xf9, 0xf7, 0xe3, 0xcd,
+	0x1d, 0x9f, 0x81, 0x4d, 0x57, 0xe2, 0x9a, 0xc9, 0xa4, 0xa8, 0x04, 0x73, 0x77, 0x3c, 0xe4, 0x3f,
+	0x0c, 0x7d, 0xb2, 0x2d, 0x38, 0x89, 0xb4, 0xe0, 0xa2, 0x12, 0x2c, 0x06, 0xba, 0x39, 0x63, 0x07,
+	0x2c, 0x5e, 0xa4, 0xae, 0xe5, 0x21, 0xbf, 0x17, 0xd7, 0xc7, 0xe1, 0x2f, 0x04, 0xfd, 0x85, 0x8e,
+	0x16, 0xe9, 0x59, 0xf0, 0x53, 0xb0, 0xcb, 0xa5, 0x48, 0x58, 0x9e, 0x5e, 0xad, 0x99, 0x09, 0xd3,
+	0x8b, 0xa1, 0x5c, 0x8a, 0xa9, 0x41, 0xf0, 0x4b, 0xd8, 0xad, 0x6b, 0xeb, 0x1c, 0x76, 0xe8, 0xb5,
+	0x73, 0x98, 0xce, 0xa4, 0xe9, 0x4c, 0x2e, 0x15, 0x93, 0xb1, 0x66, 0xe3, 0x63, 0xd8, 0xab, 0xbf,
+	0xca, 0xb5, 0x3c, 0xeb, 0xbf, 0x64, 0x86, 0x3e, 0x9c, 0x43, 0x3f, 0x5a, 0xaf, 0x58, 0x5e, 0xdc,
+	0xc5, 0x7b, 0x03, 0x1d, 0xb3, 0xa4, 0x8b, 0xb4, 0x91, 0xbf, 0xcd, 0xc8, 0x14, 0x5b, 0x08, 0x46,
+	0xe7, 0x93, 0xf8, 0x4e, 0xf7, 0xfc, 0x27, 0x02, 0xf8, 0x33, 0x0f, 0xb6, 0xa1, 0x7b, 0x39, 0x7b,
+	0x37, 0x3b, 0xff, 0x38, 0x73, 0xee, 0xe1, 0x47, 0x60, 0x8f, 0xa7, 0x8b, 0xe4, 0x20, 0x7c, 0x95,
+	0x44, 0x27, 0x13, 0x07, 0x35, 0x40, 0x78, 0x74, 0xac, 0x81, 0x1d, 0xdc, 0x87, 0x5e, 0x74, 0x3a,
+	0x8e, 0x4e, 0xc7, 0xe1, 0xc8, 0xb1, 0xf0, 0x3e, 0x3c, 0x68, 0x6e, 0xc9, 0xd9, 0xf4, 0xe4, 0xc2,
+	0xd9, 0x6d, 0x5b, 0xbc, 0x8d, 0x3e, 0x38, 0x7b, 0x6d, 0x8b, 0x1a, 0xe8, 0xe0, 0xc7, 0xb0, 0xbf,
+	0x11, 0xcd, 0xcf, 0xdf, 0x7f, 0x3a, 0x38, 0x1c, 0x1d, 0x39, 0xdd, 0xc9, 0x6b, 0xf0, 0x28, 0xcf,
+	0xb6, 0x3e, 0xeb, 0xc4, 0x36, 0x2b, 0xcc, 0xeb, 0x82, 0x9f, 0xed, 0xd6, 0x9f, 0xab, 0x8e, 0x2e,
+	0x7d, 0xf8, 0x7b, 0x00, 0x55, 0x23, 0xee, 0xfc, 0xff, 0x02, 0x00, 0x00,
 }
@@ -9,20 +9,76 @@ import (
 
 	"v2ray.com/core/common"
 	"v2ray.com/core/common/log"
+	v2net "v2ray.com/core/common/net"
 	"v2ray.com/core/common/protocol"
 	"v2ray.com/core/proxy/registry"
 
 	"github.com/golang/protobuf/ptypes"
 )
 
+func parseCipherType(cipher string) (CipherType, error) {
+	switch strings.ToLower(cipher) {
+	case "aes-256-cfb":
+		return CipherType_AES_256_CFB, nil
+	case "aes-128-cfb":
+		return CipherType_AES_128_CFB, nil
+	case "chacha20":
+		return CipherType_CHACHA20, nil
+	case "chacha20-ietf":
+		return CipherType_CHACHA20_IEFT, nil
+	case "aes-128-gcm":
+		return CipherType_AES_128_GCM, nil
+	case "aes-256-gcm":
+		return CipherType_AES_256_GCM, nil
+	case "chacha20-poly1305", "chacha20-ietf-poly1305":
+		return CipherType_CHACHA20_POLY1305, nil
+	default:
+		log.Error("Shadowsocks: Unknown cipher method: ", cipher)
+		return CipherType_UNKNOWN, common.ErrBadConfiguration
+	}
+}
+
+func parseServerUser(cipher string, password string, level byte, email string) (*protocol.User, error) {
+	if len(password) == 0 {
+		log.Error("Shadowsocks: Password is not specified.")
+		return nil, common.ErrBadConfiguration
+	}
+	account := &Account{
+		Password: password,
+	}
+	cipherType, err := parseCipherType(cipher)
+	if err != nil {
+		return nil, err
+	}
+	account.CipherType = cipherType
+
+	anyAccount, err := ptypes.MarshalAny(account)
+	if err != nil {
+		log.Error("Shadowsocks: Failed to create account: ", err)
+		return nil, common.ErrBadConfiguration
+	}
+	return &protocol.User{
+		Email:   email,
+		Level:   uint32(level),
+		Account: anyAccount,
+	}, nil
+}
+
 func (this *ServerConfig) UnmarshalJSON(data []byte) error {
-	type JsonConfig struct {
+	type UserConfig struct {
 		Cipher   string `json:"method"`
 		Password string `json:"password"`
-		UDP      bool   `json:"udp"`
 		Level    byte   `json:"level"`
 		Email    string `json:"email"`
 	}
+	type JsonConfig struct {
+		Cipher   string        `json:"method"`
+		Password string        `json:"password"`
+		UDP      bool          `json:"udp"`
+		Level    byte          `json:"level"`
+		Email    string        `json:"email"`
+		Clients  []*UserConfig `json:"clients"`
+	}
 	jsonConfig := new(JsonConfig)
 	if err := json.Unmarshal(data, jsonConfig); err != nil {
 		return errors.New("Shadowsocks: Failed to parse config: " + err.Error())
@@ -30,42 +86,94 @@ func (this *ServerConfig) UnmarshalJSON(data []byte) error {
 
 	this.UdpEnabled = jsonConfig.UDP
 
-	if len(jsonConfig.Password) == 0 {
-		log.Error("Shadowsocks: Password is not specified.")
-		return common.ErrBadConfiguration
-	}
-	account := &Account{
-		Password: jsonConfig.Password,
+	if len(jsonConfig.Password) > 0 || len(jsonConfig.Clients) == 0 {
+		user, err := parseServerUser(jsonConfig.Cipher, jsonConfig.Password, jsonConfig.Level, jsonConfig.Email)
+		if err != nil {
+			return err
+		}
+		this.User = user
 	}
-	jsonConfig.Cipher = strings.ToLower(jsonConfig.Cipher)
-	switch jsonConfig.Cipher {
-	case "aes-256-cfb":
-		account.CipherType = CipherType_AES_256_CFB
-	case "aes-128-cfb":
-		account.CipherType = CipherType_AES_128_CFB
-	case "chacha20":
-		account.CipherType = CipherType_CHACHA20
-	case "chacha20-ietf":
-		account.CipherType = CipherType_CHACHA20_IEFT
-	default:
-		log.Error("Shadowsocks: Unknown cipher method: ", jsonConfig.Cipher)
-		return common.ErrBadConfiguration
+
+	for _, client := range jsonConfig.Clients {
+		cipher := client.Cipher
+		if len(cipher) == 0 {
+			cipher = jsonConfig.Cipher
+		}
+		user, err := parseServerUser(cipher, client.Password, client.Level, client.Email)
+		if err != nil {
+			return err
+		}
+		this.Users = append(this.Users, user)
 	}
 
-	anyAccount, err := ptypes.MarshalAny(account)
-	if err != nil {
-		log.Error("Shadowsocks: Failed to create account: ", err)
-		return common.ErrBadConfiguration
+	return nil
+}
+
+func (this *ClientConfig) UnmarshalJSON(data []byte) error {
+	type ServerConfig struct {
+		Address  *v2net.AddressPB `json:"address"`
+		Port     v2net.Port       `json:"port"`
+		Cipher   string           `json:"method"`
+		Password string           `json:"password"`
+		OTA      bool             `json:"ota"`
+		Level    byte             `json:"level"`
+		Email    string           `json:"email"`
 	}
-	this.User = &protocol.User{
-		Email:   jsonConfig.Email,
-		Level:   uint32(jsonConfig.Level),
-		Account: anyAccount,
+	type JsonConfig struct {
+		Servers []*ServerConfig `json:"servers"`
+	}
+	jsonConfig := new(JsonConfig)
+	if err := json.Unmarshal(data, jsonConfig); err != nil {
+		return errors.New("Shadowsocks|Client: Failed to parse config: " + err.Error())
+	}
+	if len(jsonConfig.Servers) == 0 {
+		log.Error("Shadowsocks|Client: 0 server configured.")
+		return common.ErrBadConfiguration
 	}
 
+	this.Server = make([]*protocol.ServerSpecPB, len(jsonConfig.Servers))
+	for idx, server := range jsonConfig.Servers {
+		if server.Address == nil {
+			log.Error("Shadowsocks|Client: Address is not set.")
+			return common.ErrBadConfiguration
+		}
+		if server.Port == 0 {
+			log.Error("Shadowsocks|Client: Invalid port.")
+			return common.ErrBadConfiguration
+		}
+		if len(server.Password) == 0 {
+			log.Error("Shadowsocks|Client: Password is not specified.")
+			return common.ErrBadConfiguration
+		}
+		cipherType, err := parseCipherType(server.Cipher)
+		if err != nil {
+			return err
+		}
+		anyAccount, err := ptypes.MarshalAny(&Account{
+			Password:   server.Password,
+			CipherType: cipherType,
+			Ota:        server.OTA,
+		})
+		if err != nil {
+			log.Error("Shadowsocks|Client: Failed to create account: ", err)
+			return common.ErrBadConfiguration
+		}
+		this.Server[idx] = &protocol.ServerSpecPB{
+			Address: server.Address,
+			Port:    uint32(server.Port),
+			User: []*protocol.User{
+				{
+					Email:   server.Email,
+					Level:   uint32(server.Level),
+					Account: anyAccount,
+				},
+			},
+		}
+	}
 	return nil
 }
 
 func init() {
 	registry.RegisterInboundConfig("shadowsocks", func() interface{} { return new(ServerConfig) })
+	registry.RegisterOutboundConfig("shadowsocks", func() interface{} { return new(ClientConfig) })
 }
@@ -30,3 +30,74 @@ func TestConfigParsing(t *testing.T) {
 	assert.Int(cipher.KeySize()).Equals(16)
 	assert.Bytes(account.GetCipherKey()).Equals([]byte{160, 224, 26, 2, 22, 110, 9, 80, 65, 52, 80, 20, 38, 243, 224, 241})
 }
+
+func TestMultiUserConfigParsing(t *testing.T) {
+	assert := assert.On(t)
+
+	rawJson := `{
+    "method": "aes-128-gcm",
+    "clients": [{
+      "password": "password-1",
+      "email": "1@v2ray.com"
+    }, {
+      "method": "chacha20-ietf-poly1305",
+      "password": "password-2",
+      "level": 1
+    }]
+  }`
+
+	config := new(ServerConfig)
+	err := json.Unmarshal([]byte(rawJson), config)
+	assert.Error(err).IsNil()
+	assert.Bool(config.User == nil).IsTrue()
+	assert.Int(len(config.Users)).Equals(2)
+	assert.String(config.Users[0].Email).Equals("1@v2ray.com")
+	assert.Uint32(config.Users[1].Level).Equals(1)
+
+	account := new(Account)
+	_, err = config.Users[0].GetTypedAccount(account)
+	assert.Error(err).IsNil()
+	assert.Bool(account.CipherType == CipherType_AES_128_GCM).IsTrue()
+	_, err = config.Users[1].GetTypedAccount(account)
+	assert.Error(err).IsNil()
+	assert.Bool(account.CipherType == CipherType_CHACHA20_POLY1305).IsTrue()
+	assert.String(account.Password).Equals("password-2")
+
+	err = json.Unmarshal([]byte(`{"method": "aes-128-gcm"}`), new(ServerConfig))
+	assert.Error(err).IsNotNil()
+}
+
+func TestClientConfigParsing(t *testing.T) {
+	assert := assert.On(t)
+
+	rawJson := `{
+    "servers": [{
+      "address": "127.0.0.1",
+      "port": 8388,
+      "method": "chacha20-ietf",
+      "password": "v2ray-password",
+      "ota": true
+    }]
+  }`
+
+	config := new(ClientConfig)
+	err := json.Unmarshal([]byte(rawJson), config)
+	assert.Error(err).IsNil()
+	assert.Int(len(config.Server)).Equals(1)
+	assert.Uint32(config.Server[0].Port).Equals(8388)
+
+	account := new(Account)
+	_, err = config.Server[0].User[0].GetTypedAccount(account)
+	assert.Error(err).IsNil()
+	assert.Bool(account.Ota).IsTrue()
+	assert.String(account.Password).Equals("v2ray-password")
+
+	err = json.Unmarshal([]byte(`{"servers": [{"address": "127.0.0.1", "port": 8388, "method": "chacha20-ietf-poly1305", "password": "p"}]}`), config)
+	assert.Error(err).IsNil()
+	_, err = config.Server[0].User[0].GetTypedAccount(account)
+	assert.Error(err).IsNil()
+	assert.Bool(account.CipherType == CipherType_CHACHA20_POLY1305).IsTrue()
+
+	err = json.Unmarshal([]byte(`{"servers": [{"address": "127.0.0.1", "port": 8388, "method": "rc4", "password": "p"}]}`), new(ClientConfig))
+	assert.Error(err).IsNotNil()
+}
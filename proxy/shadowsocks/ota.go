@@ -7,6 +7,7 @@ import (
 	"io"
 
 	"v2ray.com/core/common/alloc"
+	v2io "v2ray.com/core/common/io"
 	"v2ray.com/core/common/log"
 	"v2ray.com/core/common/serial"
 	"v2ray.com/core/transport"
@@ -100,3 +101,29 @@ func (this *ChunkReader) Read() (*alloc.Buffer, error) {
 
 	return buffer, nil
 }
+
+type ChunkWriter struct {
+	writer v2io.Writer
+	auth   *Authenticator
+}
+
+func NewChunkWriter(writer v2io.Writer, auth *Authenticator) *ChunkWriter {
+	return &ChunkWriter{
+		writer: writer,
+		auth:   auth,
+	}
+}
+
+func (this *ChunkWriter) Release() {
+	this.writer.Release()
+	this.writer = nil
+	this.auth = nil
+}
+
+func (this *ChunkWriter) Write(payload *alloc.Buffer) error {
+	totalLength := payload.Len()
+	authBytes := this.auth.Authenticate(nil, payload.Value)
+	payload.Prepend(authBytes)
+	payload.PrependUint16(uint16(totalLength))
+	return this.writer.Write(payload)
+}
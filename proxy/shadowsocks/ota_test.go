@@ -4,6 +4,7 @@ import (
 	"testing"
 
 	"v2ray.com/core/common/alloc"
+	v2io "v2ray.com/core/common/io"
 	. "v2ray.com/core/proxy/shadowsocks"
 	"v2ray.com/core/testing/assert"
 )
@@ -22,3 +23,23 @@ func TestNormalChunkReading(t *testing.T) {
 	payload.PrependBytes(3, 4)
 	assert.Bytes(payload.Value).Equals([]byte{3, 4, 11, 12, 13, 14, 15, 16, 17, 18})
 }
+
+func TestChunkWriting(t *testing.T) {
+	assert := assert.On(t)
+
+	iv := []byte{21, 22, 23, 24, 25, 26, 27, 28, 29, 30, 31, 32, 33, 34, 35, 36}
+	buffer := alloc.NewBuffer().Clear()
+	writer := NewChunkWriter(v2io.NewAdaptiveWriter(buffer), NewAuthenticator(ChunkKeyGenerator(iv)))
+	assert.Error(writer.Write(alloc.NewBuffer().Clear().AppendBytes(11, 12, 13, 14, 15, 16, 17, 18))).IsNil()
+	assert.Error(writer.Write(alloc.NewBuffer().Clear().AppendBytes(19, 20))).IsNil()
+	assert.Bytes(buffer.Value[:20]).Equals([]byte{
+		0, 8, 39, 228, 69, 96, 133, 39, 254, 26, 201, 70, 11, 12, 13, 14, 15, 16, 17, 18})
+
+	reader := NewChunkReader(buffer, NewAuthenticator(ChunkKeyGenerator(iv)))
+	payload, err := reader.Read()
+	assert.Error(err).IsNil()
+	assert.Bytes(payload.Value).Equals([]byte{11, 12, 13, 14, 15, 16, 17, 18})
+	payload, err = reader.Read()
+	assert.Error(err).IsNil()
+	assert.Bytes(payload.Value).Equals([]byte{19, 20})
+}
@@ -2,9 +2,11 @@ package shadowsocks
 
 import (
 	"bytes"
+	"crypto/rand"
 	"io"
 
 	"v2ray.com/core/common/alloc"
+	"v2ray.com/core/common/crypto"
 	"v2ray.com/core/common/log"
 	v2net "v2ray.com/core/common/net"
 	"v2ray.com/core/proxy"
@@ -55,6 +57,10 @@ func ReadRequest(reader io.Reader, auth *Authenticator, udp bool) (*Request, err
 
 	addrType := (buffer.Value[0] & 0x0F)
 	if (buffer.Value[0] & 0x10) == 0x10 {
+		if auth == nil {
+			log.Warning("Shadowsocks: OTA is not allowed here.")
+			return nil, transport.ErrCorruptedPacket
+		}
 		request.OTA = true
 	}
 	switch addrType {
@@ -140,3 +146,96 @@ func ReadRequest(reader io.Reader, auth *Authenticator, udp bool) (*Request, err
 
 	return request, nil
 }
+
+// appendAddress appends the address type, address and port of the request to the buffer.
+func (this *Request) appendAddress(buffer *alloc.Buffer) {
+	var otaFlag byte
+	if this.OTA {
+		otaFlag = 0x10
+	}
+	switch this.Address.Family() {
+	case v2net.AddressFamilyIPv4:
+		buffer.AppendBytes(AddrTypeIPv4 | otaFlag).Append(this.Address.IP())
+	case v2net.AddressFamilyIPv6:
+		buffer.AppendBytes(AddrTypeIPv6 | otaFlag).Append(this.Address.IP())
+	case v2net.AddressFamilyDomain:
+		buffer.AppendBytes(AddrTypeDomain|otaFlag, byte(len(this.Address.Domain()))).Append([]byte(this.Address.Domain()))
+	}
+	buffer.AppendUint16(this.Port.Value())
+}
+
+// WriteTCPRequest writes the header of the request. With OTA, the header is followed by its authentication.
+func WriteTCPRequest(request *Request, writer io.Writer, auth *Authenticator) error {
+	buffer := alloc.NewSmallBuffer().Clear()
+	defer buffer.Release()
+
+	request.appendAddress(buffer)
+	if request.OTA {
+		buffer.Value = auth.Authenticate(buffer.Value, buffer.Value)
+	}
+	_, err := writer.Write(buffer.Value)
+	return err
+}
+
+// EncodeUDPPacket encrypts the payload, along with the header of the request, into a UDP packet. With an AEAD cipher,
+// the packet is sealed as a whole under a fresh salt, and OTA is not used.
+func EncodeUDPPacket(request *Request, payload *alloc.Buffer, cipher Cipher, key []byte) (*alloc.Buffer, error) {
+	ivLen := cipher.IVSize()
+	packet := alloc.NewBuffer().Slice(0, ivLen)
+	rand.Read(packet.Value)
+	iv := packet.Value[:ivLen]
+
+	request.appendAddress(packet)
+	packet.Append(payload.Value)
+
+	if aeadCipher, ok := cipher.(*AEADCipher); ok {
+		aead, err := aeadCipher.NewAEAD(key, iv)
+		if err != nil {
+			packet.Release()
+			return nil, err
+		}
+		packet.Value = aead.Seal(packet.Value[:ivLen], make([]byte, aead.NonceSize()), packet.Value[ivLen:], nil)
+		return packet, nil
+	}
+
+	if request.OTA {
+		authenticator := NewAuthenticator(HeaderKeyGenerator(key, iv))
+		packet.Value = authenticator.Authenticate(packet.Value, packet.Value[ivLen:])
+	}
+
+	stream, err := cipher.NewEncodingStream(key, iv)
+	if err != nil {
+		packet.Release()
+		return nil, err
+	}
+	stream.XORKeyStream(packet.Value[ivLen:], packet.Value[ivLen:])
+	return packet, nil
+}
+
+// DecodeUDPPacket decrypts the UDP packet into a request with its payload.
+func DecodeUDPPacket(packet *alloc.Buffer, cipher Cipher, key []byte) (*Request, error) {
+	ivLen := cipher.IVSize()
+	if packet.Len() <= ivLen {
+		return nil, transport.ErrCorruptedPacket
+	}
+	iv := packet.Value[:ivLen]
+
+	if aeadCipher, ok := cipher.(*AEADCipher); ok {
+		aead, err := aeadCipher.NewAEAD(key, iv)
+		if err != nil {
+			return nil, err
+		}
+		payload, err := aead.Open(packet.Value[ivLen:ivLen], make([]byte, aead.NonceSize()), packet.Value[ivLen:], nil)
+		if err != nil {
+			return nil, transport.ErrCorruptedPacket
+		}
+		return ReadRequest(bytes.NewReader(payload), nil, true)
+	}
+
+	stream, err := cipher.NewDecodingStream(key, iv)
+	if err != nil {
+		return nil, err
+	}
+	reader := crypto.NewCryptionReader(stream, bytes.NewReader(packet.Value[ivLen:]))
+	return ReadRequest(reader, NewAuthenticator(HeaderKeyGenerator(key, iv)), true)
+}
@@ -2,6 +2,7 @@
 package shadowsocks
 
 import (
+	"bytes"
 	"crypto/rand"
 	"io"
 	"sync"
@@ -24,8 +25,7 @@ import (
 type Server struct {
 	packetDispatcher dispatcher.PacketDispatcher
 	config           *ServerConfig
-	cipher           Cipher
-	cipherKey        []byte
+	users            *userSet
 	meta             *proxy.InboundHandlerMeta
 	accepting        bool
 	tcpHub           *internet.TCPHub
@@ -34,22 +34,19 @@ type Server struct {
 }
 
 func NewServer(config *ServerConfig, space app.Space, meta *proxy.InboundHandlerMeta) (*Server, error) {
-	if config.GetUser() == nil {
-		return nil, protocol.ErrUserMissing
+	var users []*protocol.User
+	if config.GetUser() != nil {
+		users = append(users, config.GetUser())
 	}
-	account := new(Account)
-	if _, err := config.GetUser().GetTypedAccount(account); err != nil {
-		return nil, err
-	}
-	cipher, err := account.GetCipher()
+	users = append(users, config.GetUsers()...)
+	userSet, err := newUserSet(users)
 	if err != nil {
 		return nil, err
 	}
 	s := &Server{
-		config:    config,
-		meta:      meta,
-		cipher:    cipher,
-		cipherKey: account.GetCipherKey(),
+		config: config,
+		meta:   meta,
+		users:  userSet,
 	}
 
 	space.InitializeApplication(func() error {
@@ -113,19 +110,19 @@ func (this *Server) handlerUDPPayload(payload *alloc.Buffer, session *proxy.Sess
 	defer payload.Release()
 
 	source := session.Source
-	ivLen := this.cipher.IVSize()
-	iv := payload.Value[:ivLen]
-	payload.SliceFrom(ivLen)
-
-	stream, err := this.cipher.NewDecodingStream(this.cipherKey, iv)
-	if err != nil {
-		log.Error("Shadowsocks: Failed to create decoding stream: ", err)
-		return
+	user := this.users.Single()
+	if user == nil {
+		user = this.users.Find(source.Address, func(user *serverUser) bool {
+			return user.matchUDP(payload.Value)
+		})
+		if user == nil {
+			log.Access(source, "", log.AccessRejected, proxy.ErrInvalidAuthentication)
+			log.Warning("Shadowsocks: Unknown user of packet from ", source)
+			return
+		}
 	}
 
-	reader := crypto.NewCryptionReader(stream, payload)
-
-	request, err := ReadRequest(reader, NewAuthenticator(HeaderKeyGenerator(this.cipherKey, iv)), true)
+	request, err := DecodeUDPPacket(payload, user.cipher, user.key)
 	if err != nil {
 		if err != io.EOF {
 			log.Access(source, "", log.AccessRejected, err)
@@ -136,48 +133,46 @@ func (this *Server) handlerUDPPayload(payload *alloc.Buffer, session *proxy.Sess
 	//defer request.Release()
 
 	dest := v2net.UDPDestination(request.Address, request.Port)
-	log.Access(source, dest, log.AccessAccepted, "")
+	log.Access(source, dest, log.AccessAccepted, user.user.Email)
 	log.Info("Shadowsocks: Tunnelling request to ", dest)
 
-	this.udpServer.Dispatch(&proxy.SessionInfo{Source: source, Destination: dest}, request.DetachUDPPayload(), func(destination v2net.Destination, payload *alloc.Buffer) {
+	this.udpServer.Dispatch(&proxy.SessionInfo{Source: source, Destination: dest, User: user.user}, request.DetachUDPPayload(), func(destination v2net.Destination, payload *alloc.Buffer) {
 		defer payload.Release()
 
-		response := alloc.NewBuffer().Slice(0, ivLen)
-		defer response.Release()
-
-		rand.Read(response.Value)
-		respIv := response.Value
-
-		stream, err := this.cipher.NewEncodingStream(this.cipherKey, respIv)
+		response, err := EncodeUDPPacket(&Request{Address: request.Address, Port: request.Port}, payload, user.cipher, user.key)
 		if err != nil {
-			log.Error("Shadowsocks: Failed to create encoding stream: ", err)
+			log.Error("Shadowsocks: Failed to encode UDP response: ", err)
 			return
 		}
+		defer response.Release()
 
-		writer := crypto.NewCryptionWriter(stream, response)
-
-		switch request.Address.Family() {
-		case v2net.AddressFamilyIPv4:
-			writer.Write([]byte{AddrTypeIPv4})
-			writer.Write(request.Address.IP())
-		case v2net.AddressFamilyIPv6:
-			writer.Write([]byte{AddrTypeIPv6})
-			writer.Write(request.Address.IP())
-		case v2net.AddressFamilyDomain:
-			writer.Write([]byte{AddrTypeDomain, byte(len(request.Address.Domain()))})
-			writer.Write([]byte(request.Address.Domain()))
-		}
-
-		writer.Write(request.Port.Bytes(nil))
-		writer.Write(payload.Value)
+		this.udpHub.WriteTo(response.Value, source)
+	})
+}
 
-		if request.OTA {
-			respAuth := NewAuthenticator(HeaderKeyGenerator(this.cipherKey, respIv))
-			respAuth.Authenticate(response.Value, response.Value[ivLen:])
+// readIV reads the IV, or the salt, of the connection, and identifies its user if there are more than one. The
+// returned reader continues right after the IV.
+func (this *Server) readIV(conn internet.Connection, reader io.Reader, buffer *alloc.Buffer) (*serverUser, []byte, io.Reader, error) {
+	if user := this.users.Single(); user != nil {
+		iv := buffer.Value[:user.cipher.IVSize()]
+		if _, err := io.ReadFull(reader, iv); err != nil {
+			return nil, nil, nil, err
 		}
+		return user, iv, reader, nil
+	}
 
-		this.udpHub.WriteTo(response.Value, source)
+	header := buffer.Value[:this.users.HeaderSize()]
+	if _, err := io.ReadFull(reader, header); err != nil {
+		return nil, nil, nil, err
+	}
+	user := this.users.Find(v2net.DestinationFromAddr(conn.RemoteAddr()).Address, func(user *serverUser) bool {
+		return user.matchTCP(header)
 	})
+	if user == nil {
+		return nil, nil, nil, proxy.ErrInvalidAuthentication
+	}
+	ivLen := user.cipher.IVSize()
+	return user, header[:ivLen], io.MultiReader(bytes.NewReader(header[ivLen:]), reader), nil
 }
 
 func (this *Server) handleConnection(conn internet.Connection) {
@@ -192,8 +187,7 @@ func (this *Server) handleConnection(conn internet.Connection) {
 	bufferedReader := v2io.NewBufferedReader(timedReader)
 	defer bufferedReader.Release()
 
-	ivLen := this.cipher.IVSize()
-	_, err := io.ReadFull(bufferedReader, buffer.Value[:ivLen])
+	user, iv, ivReader, err := this.readIV(conn, bufferedReader, buffer)
 	if err != nil {
 		if err != io.EOF {
 			log.Access(conn.RemoteAddr(), "", log.AccessRejected, err)
@@ -202,17 +196,26 @@ func (this *Server) handleConnection(conn internet.Connection) {
 		return
 	}
 
-	iv := buffer.Value[:ivLen]
-
-	stream, err := this.cipher.NewDecodingStream(this.cipherKey, iv)
-	if err != nil {
-		log.Error("Shadowsocks: Failed to create decoding stream: ", err)
-		return
+	var reader io.Reader
+	var auth *Authenticator
+	if aeadCipher, ok := user.cipher.(*AEADCipher); ok {
+		aead, err := aeadCipher.NewAEAD(user.key, iv)
+		if err != nil {
+			log.Error("Shadowsocks: Failed to create AEAD: ", err)
+			return
+		}
+		reader = NewAEADReader(ivReader, aead)
+	} else {
+		stream, err := user.cipher.NewDecodingStream(user.key, iv)
+		if err != nil {
+			log.Error("Shadowsocks: Failed to create decoding stream: ", err)
+			return
+		}
+		reader = crypto.NewCryptionReader(stream, ivReader)
+		auth = NewAuthenticator(HeaderKeyGenerator(user.key, iv))
 	}
 
-	reader := crypto.NewCryptionReader(stream, bufferedReader)
-
-	request, err := ReadRequest(reader, NewAuthenticator(HeaderKeyGenerator(this.cipherKey, iv)), false)
+	request, err := ReadRequest(reader, auth, false)
 	if err != nil {
 		log.Access(conn.RemoteAddr(), "", log.AccessRejected, err)
 		log.Warning("Shadowsocks: Invalid request from ", conn.RemoteAddr(), ": ", err)
@@ -221,16 +224,17 @@ func (this *Server) handleConnection(conn internet.Connection) {
 	defer request.Release()
 	bufferedReader.SetCached(false)
 
-	userSettings := this.config.GetUser().GetSettings()
+	userSettings := user.user.GetSettings()
 	timedReader.SetTimeOut(userSettings.PayloadReadTimeout)
 
 	dest := v2net.TCPDestination(request.Address, request.Port)
-	log.Access(conn.RemoteAddr(), dest, log.AccessAccepted, "")
+	log.Access(conn.RemoteAddr(), dest, log.AccessAccepted, user.user.Email)
 	log.Info("Shadowsocks: Tunnelling request to ", dest)
 
 	ray := this.packetDispatcher.DispatchToOutbound(this.meta, &proxy.SessionInfo{
 		Source:      v2net.DestinationFromAddr(conn.RemoteAddr()),
 		Destination: dest,
+		User:        user.user,
 	})
 	defer ray.InboundOutput().Release()
 
@@ -238,25 +242,11 @@ func (this *Server) handleConnection(conn internet.Connection) {
 	writeFinish.Lock()
 	go func() {
 		if payload, err := ray.InboundOutput().Read(); err == nil {
-			payload.SliceBack(ivLen)
-			rand.Read(payload.Value[:ivLen])
-
-			stream, err := this.cipher.NewEncodingStream(this.cipherKey, payload.Value[:ivLen])
-			if err != nil {
-				log.Error("Shadowsocks: Failed to create encoding stream: ", err)
-				return
+			if aeadCipher, ok := user.cipher.(*AEADCipher); ok {
+				writeAEADResponse(conn, aeadCipher, user.key, payload, ray.InboundOutput())
+			} else {
+				writeStreamResponse(conn, user.cipher, user.key, payload, ray.InboundOutput())
 			}
-			stream.XORKeyStream(payload.Value[ivLen:], payload.Value[ivLen:])
-
-			conn.Write(payload.Value)
-			payload.Release()
-
-			writer := crypto.NewCryptionWriter(stream, conn)
-			v2writer := v2io.NewAdaptiveWriter(writer)
-
-			v2io.Pipe(ray.InboundOutput(), v2writer)
-			writer.Release()
-			v2writer.Release()
 		}
 		writeFinish.Unlock()
 	}()
@@ -276,6 +266,56 @@ func (this *Server) handleConnection(conn internet.Connection) {
 	writeFinish.Lock()
 }
 
+// writeStreamResponse writes the IV and the first payload in one write, followed by the rest of the response.
+func writeStreamResponse(conn internet.Connection, cipher Cipher, key []byte, payload *alloc.Buffer, output v2io.Reader) {
+	ivLen := cipher.IVSize()
+	payload.SliceBack(ivLen)
+	rand.Read(payload.Value[:ivLen])
+
+	stream, err := cipher.NewEncodingStream(key, payload.Value[:ivLen])
+	if err != nil {
+		log.Error("Shadowsocks: Failed to create encoding stream: ", err)
+		payload.Release()
+		return
+	}
+	stream.XORKeyStream(payload.Value[ivLen:], payload.Value[ivLen:])
+
+	conn.Write(payload.Value)
+	payload.Release()
+
+	writer := crypto.NewCryptionWriter(stream, conn)
+	v2writer := v2io.NewAdaptiveWriter(writer)
+
+	v2io.Pipe(output, v2writer)
+	writer.Release()
+	v2writer.Release()
+}
+
+// writeAEADResponse writes the salt and the first chunk in one write, followed by the rest of the response.
+func writeAEADResponse(conn internet.Connection, aeadCipher *AEADCipher, key []byte, payload *alloc.Buffer, output v2io.Reader) {
+	salt := make([]byte, aeadCipher.IVSize())
+	rand.Read(salt)
+	aead, err := aeadCipher.NewAEAD(key, salt)
+	if err != nil {
+		log.Error("Shadowsocks: Failed to create AEAD: ", err)
+		payload.Release()
+		return
+	}
+
+	bufferedWriter := v2io.NewBufferedWriter(conn)
+	defer bufferedWriter.Release()
+	bufferedWriter.Write(salt)
+
+	v2writer := v2io.NewAdaptiveWriter(NewAEADWriter(bufferedWriter, aead))
+	defer v2writer.Release()
+	if err := v2writer.Write(payload); err != nil {
+		return
+	}
+	bufferedWriter.SetCached(false)
+
+	v2io.Pipe(output, v2writer)
+}
+
 type ServerFactory struct{}
 
 func (this *ServerFactory) StreamCapability() v2net.NetworkList {
@@ -0,0 +1,76 @@
+package shadowsocks_test
+
+import (
+	"testing"
+
+	"v2ray.com/core/app"
+	"v2ray.com/core/common/alloc"
+	v2net "v2ray.com/core/common/net"
+	"v2ray.com/core/common/protocol"
+	"v2ray.com/core/proxy"
+	. "v2ray.com/core/proxy/shadowsocks"
+	"v2ray.com/core/testing/assert"
+	"v2ray.com/core/transport/internet"
+	"v2ray.com/core/transport/ray"
+
+	"github.com/golang/protobuf/ptypes"
+)
+
+func newUserWithPassword(assert *assert.Assert, cipherType CipherType, password string, email string) *protocol.User {
+	account, err := ptypes.MarshalAny(&Account{
+		Password:   password,
+		CipherType: cipherType,
+	})
+	assert.Error(err).IsNil()
+	return &protocol.User{
+		Email:   email,
+		Account: account,
+	}
+}
+
+func TestMultiUserServer(t *testing.T) {
+	assert := assert.On(t)
+
+	users := []*protocol.User{
+		newUserWithPassword(assert, CipherType_AES_128_GCM, "password-1", "1@v2ray.com"),
+		newUserWithPassword(assert, CipherType_AES_256_GCM, "password-2", "2@v2ray.com"),
+		newUserWithPassword(assert, CipherType_CHACHA20_POLY1305, "password-3", "3@v2ray.com"),
+	}
+	port := startServerWithConfig(assert, &ServerConfig{
+		UdpEnabled: true,
+		User:       users[0],
+		Users:      users[1:],
+	})
+
+	for _, user := range users {
+		client := newClientOfUser(assert, port, user)
+		// Twice for the fast path.
+		testTCPTraffic(assert, client)
+		testTCPTraffic(assert, client)
+		testUDPTraffic(assert, client)
+	}
+
+	client := newClientOfUser(assert, port, newUserWithPassword(assert, CipherType_AES_256_GCM, "password-4", ""))
+	traffic := ray.NewRay()
+	go client.Dispatch(v2net.TCPDestination(v2net.LocalHostIP, v2net.Port(80)), alloc.NewLocalBuffer(2048).Clear().Append([]byte("Data")), traffic)
+	traffic.InboundInput().Close()
+	_, err := traffic.InboundOutput().Read()
+	assert.Error(err).IsNotNil()
+}
+
+func TestMultiUserStreamCipher(t *testing.T) {
+	assert := assert.On(t)
+
+	_, err := NewServer(&ServerConfig{
+		User: newUserWithPassword(assert, CipherType_AES_128_GCM, "password-1", ""),
+		Users: []*protocol.User{
+			newUserWithPassword(assert, CipherType_AES_128_CFB, "password-2", ""),
+		},
+	}, app.NewSpace(), &proxy.InboundHandlerMeta{
+		Address: v2net.LocalHostIP,
+		StreamSettings: &internet.StreamConfig{
+			Network: v2net.Network_RawTCP,
+		},
+	})
+	assert.Error(err).Equals(ErrMultiUserStreamCipher)
+}
@@ -0,0 +1,159 @@
+package shadowsocks
+
+import (
+	"errors"
+	"sync"
+
+	"v2ray.com/core/common/alloc"
+	v2net "v2ray.com/core/common/net"
+	"v2ray.com/core/common/protocol"
+)
+
+const (
+	// maxCachedSources is the maximum number of sources whose last user is remembered.
+	maxCachedSources = 4096
+)
+
+var (
+	ErrMultiUserStreamCipher = errors.New("Shadowsocks: Only AEAD ciphers are supported with multiple users.")
+)
+
+// serverUser is a user of a Shadowsocks server, with its cipher and key ready for use.
+type serverUser struct {
+	user   *protocol.User
+	cipher Cipher
+	key    []byte
+}
+
+func newServerUser(user *protocol.User) (*serverUser, error) {
+	account := new(Account)
+	if _, err := user.GetTypedAccount(account); err != nil {
+		return nil, err
+	}
+	cipher, err := account.GetCipher()
+	if err != nil {
+		return nil, err
+	}
+	return &serverUser{
+		user:   user,
+		cipher: cipher,
+		key:    account.GetCipherKey(),
+	}, nil
+}
+
+// headerSize returns the size of the salt and the sealed length of the first chunk.
+func (this *serverUser) headerSize() int {
+	aeadCipher := this.cipher.(*AEADCipher)
+	aead, err := aeadCipher.NewAEAD(this.key, make([]byte, aeadCipher.IVSize()))
+	if err != nil {
+		return 0
+	}
+	return aeadCipher.IVSize() + 2 + aead.Overhead()
+}
+
+// matchTCP returns true if the header of a TCP connection is sealed with the key of the user.
+func (this *serverUser) matchTCP(header []byte) bool {
+	aeadCipher := this.cipher.(*AEADCipher)
+	saltLen := aeadCipher.IVSize()
+	aead, err := aeadCipher.NewAEAD(this.key, header[:saltLen])
+	if err != nil {
+		return false
+	}
+	end := saltLen + 2 + aead.Overhead()
+	if len(header) < end {
+		return false
+	}
+	var sizeBytes [2]byte
+	_, err = aead.Open(sizeBytes[:0], make([]byte, aead.NonceSize()), header[saltLen:end], nil)
+	return err == nil
+}
+
+// matchUDP returns true if the UDP packet is sealed with the key of the user.
+func (this *serverUser) matchUDP(packet []byte) bool {
+	aeadCipher := this.cipher.(*AEADCipher)
+	saltLen := aeadCipher.IVSize()
+	if len(packet) <= saltLen {
+		return false
+	}
+	aead, err := aeadCipher.NewAEAD(this.key, packet[:saltLen])
+	if err != nil {
+		return false
+	}
+	buffer := alloc.NewBuffer()
+	defer buffer.Release()
+	_, err = aead.Open(buffer.Value[:0], make([]byte, aead.NonceSize()), packet[saltLen:], nil)
+	return err == nil
+}
+
+// userSet is the set of users of a Shadowsocks server. With more than one user, the user of a connection or a packet
+// is identified by trying the key of each user. The user last identified for a source is tried first next time.
+type userSet struct {
+	sync.Mutex
+	users      []*serverUser
+	lastUsers  map[string]*serverUser
+	headerSize int
+}
+
+func newUserSet(users []*protocol.User) (*userSet, error) {
+	if len(users) == 0 {
+		return nil, protocol.ErrUserMissing
+	}
+	set := &userSet{
+		users:     make([]*serverUser, 0, len(users)),
+		lastUsers: make(map[string]*serverUser),
+	}
+	for _, user := range users {
+		serverUser, err := newServerUser(user)
+		if err != nil {
+			return nil, err
+		}
+		if len(users) > 1 {
+			if _, ok := serverUser.cipher.(*AEADCipher); !ok {
+				return nil, ErrMultiUserStreamCipher
+			}
+			if size := serverUser.headerSize(); size > set.headerSize {
+				set.headerSize = size
+			}
+		}
+		set.users = append(set.users, serverUser)
+	}
+	return set, nil
+}
+
+// Single returns the only user of the set, or nil if there are more.
+func (this *userSet) Single() *serverUser {
+	if len(this.users) == 1 {
+		return this.users[0]
+	}
+	return nil
+}
+
+// HeaderSize returns the number of bytes needed to identify the user of a TCP connection.
+func (this *userSet) HeaderSize() int {
+	return this.headerSize
+}
+
+// Find returns the first user that matches, or nil if none.
+func (this *userSet) Find(source v2net.Address, match func(*serverUser) bool) *serverUser {
+	key := source.String()
+
+	this.Lock()
+	lastUser := this.lastUsers[key]
+	this.Unlock()
+
+	if lastUser != nil && match(lastUser) {
+		return lastUser
+	}
+	for _, user := range this.users {
+		if user != lastUser && match(user) {
+			this.Lock()
+			if len(this.lastUsers) >= maxCachedSources {
+				this.lastUsers = make(map[string]*serverUser)
+			}
+			this.lastUsers[key] = user
+			this.Unlock()
+			return user
+		}
+	}
+	return nil
+}
@@ -0,0 +1,261 @@
+package socks
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+
+	"v2ray.com/core/app"
+	"v2ray.com/core/common/alloc"
+	v2io "v2ray.com/core/common/io"
+	"v2ray.com/core/common/log"
+	v2net "v2ray.com/core/common/net"
+	"v2ray.com/core/common/protocol"
+	"v2ray.com/core/common/retry"
+	"v2ray.com/core/proxy"
+	"v2ray.com/core/proxy/registry"
+	socks "v2ray.com/core/proxy/socks/protocol"
+	"v2ray.com/core/transport/internet"
+	"v2ray.com/core/transport/internet/tcp"
+	"v2ray.com/core/transport/ray"
+)
+
+const (
+	// UDPTimeout is the number of seconds that a UDP association is kept without any response.
+	UDPTimeout = 16
+)
+
+var (
+	ErrRequestRejected = errors.New("Socks|Client: Request rejected by server.")
+)
+
+// Client is a SOCKS 5 outbound handler, which sends traffic through one of the configured SOCKS servers.
+type Client struct {
+	serverPicker protocol.ServerPicker
+	meta         *proxy.OutboundHandlerMeta
+}
+
+func NewClient(config *ClientConfig, space app.Space, meta *proxy.OutboundHandlerMeta) *Client {
+	serverList := protocol.NewServerList()
+	for _, rec := range config.Server {
+		serverList.AddServer(protocol.NewServerSpecFromPB(NewAccount, *rec))
+	}
+	return &Client{
+		serverPicker: protocol.NewRoundRobinServerPicker(serverList),
+		meta:         meta,
+	}
+}
+
+func (this *Client) Dispatch(destination v2net.Destination, payload *alloc.Buffer, ray ray.OutboundRay) error {
+	defer payload.Release()
+	defer ray.OutboundInput().Release()
+	defer ray.OutboundOutput().Close()
+
+	var server *protocol.ServerSpec
+	var conn internet.Connection
+
+	err := retry.Timed(5, 100).On(func() error {
+		server = this.serverPicker.PickServer()
+		rawConn, err := internet.Dial(this.meta.Address, server.Destination(), this.meta.StreamSettings)
+		if err != nil {
+			return err
+		}
+		conn = rawConn
+		return nil
+	})
+	if err != nil {
+		log.Warning("Socks|Client: Failed to find an available destination: ", err)
+		return err
+	}
+	defer conn.Close()
+	conn.SetReusable(false)
+
+	command := socks.CmdConnect
+	target := destination
+	if destination.Network == v2net.Network_UDP {
+		command = socks.CmdUdpAssociate
+		// Packets are sent from an address that is not known yet.
+		target = v2net.TCPDestination(v2net.AnyIP, 0)
+	}
+	response, err := handshake(conn, server.PickUser(), command, target)
+	if err != nil {
+		log.Warning("Socks|Client: Failed to handshake with ", server.Destination(), ": ", err)
+		return err
+	}
+	log.Info("Socks|Client: Tunneling request to ", destination, " via ", server.Destination())
+
+	if command == socks.CmdUdpAssociate {
+		relay := response.Destination()
+		if !relay.Address.Family().IsDomain() && relay.Address.IP().IsUnspecified() {
+			relay.Address = server.Destination().Address
+		}
+		return this.transportUDP(conn, v2net.UDPDestination(relay.Address, relay.Port), destination, payload, ray)
+	}
+	this.transportTCP(conn, payload, ray)
+	return nil
+}
+
+// handshake authenticates with the server as the user, if any, and sends the request.
+func handshake(conn io.ReadWriter, user *protocol.User, command byte, dest v2net.Destination) (*socks.Socks5Response, error) {
+	var account *Account
+	methods := []byte{socks.AuthNotRequired}
+	if user != nil {
+		rawAccount, err := user.GetTypedAccount(NewAccount())
+		if err != nil {
+			return nil, err
+		}
+		account = rawAccount.(*Account)
+		methods = append(methods, socks.AuthUserPass)
+	}
+
+	if err := socks.WriteAuthenticationRequest(conn, methods...); err != nil {
+		return nil, err
+	}
+	method, err := socks.ReadAuthenticationResponse(conn)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case method == socks.AuthNotRequired:
+	case method == socks.AuthUserPass && account != nil:
+		if err := socks.WriteUserPassRequest(conn, account.Username, account.Password); err != nil {
+			return nil, err
+		}
+		status, err := socks.ReadUserPassResponse(conn)
+		if err != nil {
+			return nil, err
+		}
+		if status != 0 {
+			return nil, proxy.ErrInvalidAuthentication
+		}
+	default:
+		return nil, ErrUnsupportedAuthMethod
+	}
+
+	if err := socks.NewSocks5Request(command, dest).Write(conn); err != nil {
+		return nil, err
+	}
+	response, err := socks.ReadResponse(conn)
+	if err != nil {
+		return nil, err
+	}
+	if response.Error != socks.ErrorSuccess {
+		log.Info("Socks|Client: Server replied with error ", response.Error)
+		return nil, ErrRequestRejected
+	}
+	return response, nil
+}
+
+func (this *Client) transportTCP(conn internet.Connection, payload *alloc.Buffer, ray ray.OutboundRay) {
+	input := ray.OutboundInput()
+	output := ray.OutboundOutput()
+
+	if !payload.IsEmpty() {
+		conn.Write(payload.Value)
+	}
+
+	go func() {
+		v2writer := v2io.NewAdaptiveWriter(conn)
+		defer v2writer.Release()
+
+		v2io.Pipe(input, v2writer)
+		if tcpConn, ok := conn.(*tcp.RawConnection); ok {
+			tcpConn.CloseWrite()
+		}
+	}()
+
+	v2reader := v2io.NewAdaptiveReader(conn)
+	v2io.Pipe(v2reader, output)
+	v2reader.Release()
+}
+
+// transportUDP relays packets to the destination through the UDP relay of the server. The association lasts until
+// the control connection is closed, or no response comes in for a while.
+func (this *Client) transportUDP(control internet.Connection, relay v2net.Destination, destination v2net.Destination, payload *alloc.Buffer, ray ray.OutboundRay) error {
+	conn, err := internet.Dial(this.meta.Address, relay, this.meta.StreamSettings)
+	if err != nil {
+		log.Warning("Socks|Client: Failed to connect to UDP relay ", relay, ": ", err)
+		return err
+	}
+	defer conn.Close()
+
+	go func() {
+		io.Copy(ioutil.Discard, control)
+		conn.Close()
+	}()
+
+	writePacket := func(data *alloc.Buffer) error {
+		request := &socks.Socks5UDPRequest{
+			Address: destination.Address,
+			Port:    destination.Port,
+			Data:    data,
+		}
+		packet := alloc.NewBuffer().Clear()
+		defer packet.Release()
+		request.Write(packet)
+		_, err := conn.Write(packet.Value)
+		return err
+	}
+
+	if !payload.IsEmpty() {
+		if err := writePacket(payload); err != nil {
+			log.Warning("Socks|Client: Failed to write UDP packet: ", err)
+			return err
+		}
+	}
+
+	input := ray.OutboundInput()
+	go func() {
+		for {
+			data, err := input.Read()
+			if err != nil {
+				return
+			}
+			err = writePacket(data)
+			data.Release()
+			if err != nil {
+				log.Info("Socks|Client: Failed to write UDP packet: ", err)
+				return
+			}
+		}
+	}()
+
+	output := ray.OutboundOutput()
+	reader := v2net.NewTimeOutReader(UDPTimeout, conn)
+	for {
+		buffer := alloc.NewBuffer()
+		nBytes, err := reader.Read(buffer.Value)
+		if err != nil {
+			buffer.Release()
+			return nil
+		}
+		response, err := socks.ReadUDPRequest(buffer.Value[:nBytes])
+		buffer.Release()
+		if err != nil {
+			log.Info("Socks|Client: Invalid UDP packet from relay: ", err)
+			continue
+		}
+		if response.Data == nil {
+			continue
+		}
+		if err := output.Write(response.Data); err != nil {
+			return nil
+		}
+	}
+}
+
+type ClientFactory struct{}
+
+func (this *ClientFactory) StreamCapability() v2net.NetworkList {
+	return v2net.NetworkList{
+		Network: []v2net.Network{v2net.Network_RawTCP},
+	}
+}
+
+func (this *ClientFactory) Create(space app.Space, rawConfig interface{}, meta *proxy.OutboundHandlerMeta) (proxy.OutboundHandler, error) {
+	return NewClient(rawConfig.(*ClientConfig), space, meta), nil
+}
+
+func init() {
+	registry.MustRegisterOutboundHandlerCreator("socks", new(ClientFactory))
+}
@@ -0,0 +1,187 @@
+package socks_test
+
+import (
+	"testing"
+
+	"v2ray.com/core/app"
+	"v2ray.com/core/app/dispatcher"
+	dispatchers "v2ray.com/core/app/dispatcher/impl"
+	"v2ray.com/core/app/proxyman"
+	"v2ray.com/core/common/alloc"
+	"v2ray.com/core/common/dice"
+	v2net "v2ray.com/core/common/net"
+	"v2ray.com/core/common/protocol"
+	"v2ray.com/core/proxy"
+	"v2ray.com/core/proxy/freedom"
+	. "v2ray.com/core/proxy/socks"
+	"v2ray.com/core/testing/assert"
+	"v2ray.com/core/testing/servers/tcp"
+	"v2ray.com/core/testing/servers/udp"
+	"v2ray.com/core/transport/internet"
+	"v2ray.com/core/transport/ray"
+)
+
+func processMessage(data []byte) []byte {
+	buffer := make([]byte, 0, 2048)
+	buffer = append(buffer, []byte("Processed: ")...)
+	buffer = append(buffer, data...)
+	return buffer
+}
+
+// startSocksServer starts a SOCKS server that requires the account "v2ray:pass" and sends traffic out directly.
+func startSocksServer(assert *assert.Assert) v2net.Port {
+	return startSocksServerWithConfig(assert, &ServerConfig{
+		AuthType:   AuthType_PASSWORD,
+		Accounts:   map[string]string{"v2ray": "pass"},
+		Address:    v2net.NewAddressPB(v2net.LocalHostIP),
+		UdpEnabled: true,
+		Timeout:    30,
+	})
+}
+
+func startSocksServerWithConfig(assert *assert.Assert, config *ServerConfig) v2net.Port {
+	return startSocksServerWithOutbound(assert, config, func(space app.Space) proxy.OutboundHandler {
+		return freedom.NewFreedomConnection(
+			&freedom.Config{},
+			space,
+			&proxy.OutboundHandlerMeta{
+				Address: v2net.AnyIP,
+				StreamSettings: &internet.StreamConfig{
+					Network: v2net.Network_RawTCP,
+				},
+			})
+	})
+}
+
+func startSocksServerWithOutbound(assert *assert.Assert, config *ServerConfig, createOutbound func(app.Space) proxy.OutboundHandler) v2net.Port {
+	space := app.NewSpace()
+	space.BindApp(dispatcher.APP_ID, dispatchers.NewDefaultDispatcher(space))
+	ohm := proxyman.NewDefaultOutboundHandlerManager()
+	ohm.SetDefaultHandler(createOutbound(space))
+	space.BindApp(proxyman.APP_ID_OUTBOUND_MANAGER, ohm)
+
+	port := v2net.Port(dice.Roll(20000) + 10000)
+	server := NewServer(config, space, &proxy.InboundHandlerMeta{
+		Address: v2net.LocalHostIP,
+		Port:    port,
+		StreamSettings: &internet.StreamConfig{
+			Network: v2net.Network_RawTCP,
+		},
+	})
+	assert.Error(space.Initialize()).IsNil()
+	assert.Error(server.Start()).IsNil()
+	return port
+}
+
+func newTestClient(assert *assert.Assert, port v2net.Port, password string) *Client {
+	account, err := (&Account{Username: "v2ray", Password: password}).AsAny()
+	assert.Error(err).IsNil()
+	return NewClient(&ClientConfig{
+		Server: []*protocol.ServerSpecPB{{
+			Address: v2net.NewAddressPB(v2net.LocalHostIP),
+			Port:    uint32(port),
+			User:    []*protocol.User{{Account: account}},
+		}},
+	}, app.NewSpace(), &proxy.OutboundHandlerMeta{
+		Address: v2net.AnyIP,
+		StreamSettings: &internet.StreamConfig{
+			Network: v2net.Network_RawTCP,
+		},
+	})
+}
+
+func TestSocksClientTCP(t *testing.T) {
+	assert := assert.On(t)
+
+	tcpServer := &tcp.Server{
+		MsgProcessor: processMessage,
+	}
+	_, err := tcpServer.Start()
+	assert.Error(err).IsNil()
+	defer tcpServer.Close()
+
+	client := newTestClient(assert, startSocksServer(assert), "pass")
+
+	traffic := ray.NewRay()
+	payload := alloc.NewLocalBuffer(2048).Clear().Append([]byte("Data to be sent to remote"))
+	go client.Dispatch(v2net.TCPDestination(v2net.LocalHostIP, tcpServer.Port), payload, traffic)
+	traffic.InboundInput().Close()
+
+	response, err := traffic.InboundOutput().Read()
+	assert.Error(err).IsNil()
+	assert.Bytes(response.Value).Equals([]byte("Processed: Data to be sent to remote"))
+}
+
+func TestSocksClientUDP(t *testing.T) {
+	assert := assert.On(t)
+
+	udpServer := &udp.Server{
+		MsgProcessor: processMessage,
+	}
+	dest, err := udpServer.Start()
+	assert.Error(err).IsNil()
+	defer udpServer.Close()
+
+	client := newTestClient(assert, startSocksServer(assert), "pass")
+
+	traffic := ray.NewRay()
+	payload := alloc.NewLocalBuffer(2048).Clear().Append([]byte("Packet to be sent to remote"))
+	go client.Dispatch(dest, payload, traffic)
+
+	response, err := traffic.InboundOutput().Read()
+	assert.Error(err).IsNil()
+	assert.Bytes(response.Value).Equals([]byte("Processed: Packet to be sent to remote"))
+
+	assert.Error(traffic.InboundInput().Write(alloc.NewLocalBuffer(2048).Clear().Append([]byte("Another packet")))).IsNil()
+	response, err = traffic.InboundOutput().Read()
+	assert.Error(err).IsNil()
+	assert.Bytes(response.Value).Equals([]byte("Processed: Another packet"))
+	traffic.InboundInput().Close()
+}
+
+func TestSocksClientWithWrongPassword(t *testing.T) {
+	assert := assert.On(t)
+
+	client := newTestClient(assert, startSocksServer(assert), "wrong")
+
+	traffic := ray.NewRay()
+	err := client.Dispatch(v2net.TCPDestination(v2net.LocalHostIP, 80), alloc.NewLocalBuffer(2048).Clear(), traffic)
+	assert.Error(err).Equals(proxy.ErrInvalidAuthentication)
+}
+
+func TestSocksServerUsers(t *testing.T) {
+	assert := assert.On(t)
+
+	tcpServer := &tcp.Server{
+		MsgProcessor: processMessage,
+	}
+	_, err := tcpServer.Start()
+	assert.Error(err).IsNil()
+	defer tcpServer.Close()
+
+	account, err := (&Account{Username: "v2ray", Password: "pass"}).AsAny()
+	assert.Error(err).IsNil()
+	port := startSocksServerWithConfig(assert, &ServerConfig{
+		AuthType: AuthType_PASSWORD,
+		User: []*protocol.User{{
+			Level:   1,
+			Email:   "love@v2ray.com",
+			Account: account,
+		}},
+		Address: v2net.NewAddressPB(v2net.LocalHostIP),
+		Timeout: 30,
+	})
+
+	err = newTestClient(assert, port, "wrong").Dispatch(
+		v2net.TCPDestination(v2net.LocalHostIP, tcpServer.Port), alloc.NewLocalBuffer(2048).Clear(), ray.NewRay())
+	assert.Error(err).Equals(proxy.ErrInvalidAuthentication)
+
+	traffic := ray.NewRay()
+	payload := alloc.NewLocalBuffer(2048).Clear().Append([]byte("Data to be sent to remote"))
+	go newTestClient(assert, port, "pass").Dispatch(v2net.TCPDestination(v2net.LocalHostIP, tcpServer.Port), payload, traffic)
+	traffic.InboundInput().Close()
+
+	response, err := traffic.InboundOutput().Read()
+	assert.Error(err).IsNil()
+	assert.Bytes(response.Value).Equals([]byte("Processed: Data to be sent to remote"))
+}
@@ -34,14 +34,36 @@ func (this *Account) AsAny() (*google_protobuf.Any, error) {
 }
 
 func (this *ServerConfig) HasAccount(username, password string) bool {
+	return this.FindUser(username, password) != nil
+}
+
+// FindUser returns the user with the given username and password, or nil if there is none. Users in the deprecated
+// accounts map have neither level nor email.
+func (this *ServerConfig) FindUser(username, password string) *protocol.User {
+	for _, user := range this.User {
+		account := new(Account)
+		if _, err := user.GetTypedAccount(account); err != nil {
+			continue
+		}
+		if account.Username == username && account.Password == password {
+			return user
+		}
+	}
+
 	if this.Accounts == nil {
-		return false
+		return nil
 	}
 	storedPassed, found := this.Accounts[username]
-	if !found {
-		return false
+	if !found || storedPassed != password {
+		return nil
+	}
+	anyAccount, err := (&Account{Username: username, Password: password}).AsAny()
+	if err != nil {
+		return nil
+	}
+	return &protocol.User{
+		Account: anyAccount,
 	}
-	return storedPassed == password
 }
 
 func (this *ServerConfig) GetNetAddress() v2net.Address {
@@ -58,11 +80,11 @@ const (
 
 func (this *ServerConfig) UnmarshalJSON(data []byte) error {
 	type SocksConfig struct {
-		AuthMethod string           `json:"auth"`
-		Accounts   []*Account       `json:"accounts"`
-		UDP        bool             `json:"udp"`
-		Host       *v2net.AddressPB `json:"ip"`
-		Timeout    uint32           `json:"timeout"`
+		AuthMethod string            `json:"auth"`
+		Accounts   []json.RawMessage `json:"accounts"`
+		UDP        bool              `json:"udp"`
+		Host       *v2net.AddressPB  `json:"ip"`
+		Timeout    uint32            `json:"timeout"`
 	}
 
 	rawConfig := new(SocksConfig)
@@ -78,11 +100,21 @@ func (this *ServerConfig) UnmarshalJSON(data []byte) error {
 		return common.ErrBadConfiguration
 	}
 
-	if len(rawConfig.Accounts) > 0 {
-		this.Accounts = make(map[string]string, len(rawConfig.Accounts))
-		for _, account := range rawConfig.Accounts {
-			this.Accounts[account.Username] = account.Password
+	for _, rawAccount := range rawConfig.Accounts {
+		user := new(protocol.User)
+		if err := json.Unmarshal(rawAccount, user); err != nil {
+			return errors.New("Socks: Failed to parse user: " + err.Error())
+		}
+		account := new(Account)
+		if err := json.Unmarshal(rawAccount, account); err != nil {
+			return errors.New("Socks: Failed to parse account: " + err.Error())
+		}
+		anyAccount, err := account.AsAny()
+		if err != nil {
+			return err
 		}
+		user.Account = anyAccount
+		this.User = append(this.User, user)
 	}
 
 	this.UdpEnabled = rawConfig.UDP
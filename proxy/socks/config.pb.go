@@ -19,6 +19,7 @@ import proto "github.com/golang/protobuf/proto"
 import fmt "fmt"
 import math "math"
 import v2ray_core_common_net "v2ray.com/core/common/net"
+import v2ray_core_common_protocol "v2ray.com/core/common/protocol"
 import v2ray_core_common_protocol1 "v2ray.com/core/common/protocol"
 
 // Reference imports to suppress errors if they are not otherwise used.
@@ -64,11 +65,15 @@ func (*Account) ProtoMessage()               {}
 func (*Account) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{0} }
 
 type ServerConfig struct {
-	AuthType   AuthType                         `protobuf:"varint,1,opt,name=auth_type,json=authType,enum=v2ray.core.proxy.socks.AuthType" json:"auth_type,omitempty"`
+	AuthType AuthType `protobuf:"varint,1,opt,name=auth_type,json=authType,enum=v2ray.core.proxy.socks.AuthType" json:"auth_type,omitempty"`
+	// Deprecated. Use user instead.
 	Accounts   map[string]string                `protobuf:"bytes,2,rep,name=accounts" json:"accounts,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
 	Address    *v2ray_core_common_net.AddressPB `protobuf:"bytes,3,opt,name=address" json:"address,omitempty"`
 	UdpEnabled bool                             `protobuf:"varint,4,opt,name=udp_enabled,json=udpEnabled" json:"udp_enabled,omitempty"`
-	Timeout    uint32                           `protobuf:"varint,5,opt,name=timeout" json:"timeout,omitempty"`
+	// Read timeout in seconds. If 0, the timeout of the user level applies to authenticated users.
+	Timeout uint32 `protobuf:"varint,5,opt,name=timeout" json:"timeout,omitempty"`
+	// Users allowed with password authentication, each with an Account.
+	User []*v2ray_core_common_protocol.User `protobuf:"bytes,6,rep,name=user" json:"user,omitempty"`
 }
 
 func (m *ServerConfig) Reset()                    { *m = ServerConfig{} }
@@ -90,6 +95,13 @@ func (m *ServerConfig) GetAddress() *v2ray_core_common_net.AddressPB {
 	return nil
 }
 
+func (m *ServerConfig) GetUser() []*v2ray_core_common_protocol.User {
+	if m != nil {
+		return m.User
+	}
+	return nil
+}
+
 type ClientConfig struct {
 	Server []*v2ray_core_common_protocol1.ServerSpecPB `protobuf:"bytes,1,rep,name=server" json:"server,omitempty"`
 }
@@ -116,32 +128,34 @@ func init() {
 func init() { proto.RegisterFile("v2ray.com/core/proxy/socks/config.proto", fileDescriptor0) }
 
 var fileDescriptor0 = []byte{
-	// 428 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x09, 0x6e, 0x88, 0x02, 0xff, 0x74, 0x91, 0x51, 0x6b, 0xdb, 0x30,
-	0x10, 0xc7, 0xe7, 0x64, 0x69, 0xdc, 0x73, 0x3a, 0x82, 0x18, 0xc3, 0xf8, 0x65, 0x26, 0x30, 0x66,
-	0xf6, 0x20, 0x97, 0xec, 0x65, 0x74, 0x0c, 0xe6, 0x74, 0x85, 0x3d, 0xb5, 0xc6, 0xe9, 0x18, 0xec,
-	0x25, 0xa8, 0xf2, 0x6d, 0x0d, 0xb5, 0x25, 0x21, 0xc9, 0xd9, 0xfc, 0x31, 0xf7, 0x8d, 0x46, 0x2c,
-	0xbb, 0xac, 0x25, 0x7d, 0xd3, 0xe9, 0xfe, 0xf7, 0xbf, 0xbb, 0xdf, 0xc1, 0xdb, 0xdd, 0x52, 0xb3,
-	0x96, 0x72, 0x59, 0xa7, 0x5c, 0x6a, 0x4c, 0x95, 0x96, 0x7f, 0xda, 0xd4, 0x48, 0x7e, 0x67, 0x52,
-	0x2e, 0xc5, 0xcf, 0xed, 0x2f, 0xaa, 0xb4, 0xb4, 0x92, 0xbc, 0x1a, 0x84, 0x1a, 0x69, 0x27, 0xa2,
-	0x9d, 0x28, 0x7a, 0x6c, 0xc0, 0x65, 0x5d, 0x4b, 0x91, 0x0a, 0xb4, 0x29, 0x2b, 0x4b, 0x8d, 0xc6,
-	0x38, 0x83, 0xe8, 0xf4, 0xb0, 0xb0, 0x4b, 0x72, 0x59, 0xa5, 0x06, 0xf5, 0x0e, 0xf5, 0xc6, 0x28,
-	0xe4, 0xae, 0x62, 0x91, 0xc1, 0x34, 0xe3, 0x5c, 0x36, 0xc2, 0x92, 0x08, 0xfc, 0xc6, 0xa0, 0x16,
-	0xac, 0xc6, 0xd0, 0x8b, 0xbd, 0xe4, 0xb8, 0xb8, 0x8f, 0xf7, 0x39, 0xc5, 0x8c, 0xf9, 0x2d, 0x75,
-	0x19, 0x8e, 0x5c, 0x6e, 0x88, 0x17, 0x7f, 0x47, 0x30, 0x5b, 0x77, 0xc6, 0xe7, 0xdd, 0x32, 0xe4,
-	0x13, 0x1c, 0xb3, 0xc6, 0xde, 0x6e, 0x6c, 0xab, 0x9c, 0xd3, 0x8b, 0x65, 0x4c, 0x0f, 0xaf, 0x46,
-	0xb3, 0xc6, 0xde, 0x5e, 0xb7, 0x0a, 0x0b, 0x9f, 0xf5, 0x2f, 0x72, 0x09, 0x3e, 0x73, 0x23, 0x99,
-	0x70, 0x14, 0x8f, 0x93, 0x60, 0xb9, 0x7c, 0xaa, 0xfa, 0xff, 0xb6, 0xb4, 0xdf, 0xc3, 0x5c, 0x08,
-	0xab, 0xdb, 0xe2, 0xde, 0x83, 0x9c, 0xc1, 0xb4, 0xa7, 0x14, 0x8e, 0x63, 0x2f, 0x09, 0x1e, 0x0e,
-	0xe3, 0x10, 0x51, 0x81, 0x96, 0x66, 0x4e, 0x95, 0xaf, 0x8a, 0xa1, 0x80, 0xbc, 0x86, 0xa0, 0x29,
-	0xd5, 0x06, 0x05, 0xbb, 0xa9, 0xb0, 0x0c, 0x9f, 0xc7, 0x5e, 0xe2, 0x17, 0xd0, 0x94, 0xea, 0xc2,
-	0xfd, 0x90, 0x10, 0xa6, 0x76, 0x5b, 0xa3, 0x6c, 0x6c, 0x38, 0x89, 0xbd, 0xe4, 0xa4, 0x18, 0xc2,
-	0xe8, 0x23, 0x9c, 0x3c, 0x98, 0x88, 0xcc, 0x61, 0x7c, 0x87, 0x6d, 0x8f, 0x76, 0xff, 0x24, 0x2f,
-	0x61, 0xb2, 0x63, 0x55, 0x83, 0x3d, 0x52, 0x17, 0x9c, 0x8d, 0x3e, 0x78, 0x8b, 0x1c, 0x66, 0xe7,
-	0xd5, 0x16, 0x85, 0xed, 0x91, 0x7e, 0x86, 0x23, 0x77, 0xbb, 0xd0, 0xeb, 0x88, 0x24, 0x07, 0x56,
-	0x18, 0xae, 0xdc, 0x53, 0x59, 0x2b, 0xe4, 0xf9, 0xaa, 0xe8, 0xeb, 0xde, 0xbd, 0x01, 0x7f, 0x60,
-	0x4d, 0x02, 0x98, 0x5e, 0x5e, 0x6d, 0xb2, 0x6f, 0xd7, 0x5f, 0xe7, 0xcf, 0xc8, 0x0c, 0xfc, 0x3c,
-	0x5b, 0xaf, 0xbf, 0x5f, 0x15, 0x5f, 0xe6, 0xde, 0xea, 0x14, 0x22, 0x2e, 0xeb, 0x27, 0x78, 0xaf,
-	0x02, 0x37, 0x4e, 0xbe, 0xef, 0xf4, 0x63, 0xd2, 0xfd, 0xdd, 0x1c, 0x75, 0x7d, 0xdf, 0xff, 0x0b,
-	0x00, 0x00, 0xff, 0xff, 0xb4, 0x25, 0x49, 0xd6, 0xe6, 0x02, 0x00, 0x00,
+	// 450 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x09, 0x6e, 0x88, 0x02, 0xff, 0x84, 0x51, 0xc1, 0x6e, 0xd3, 0x40,
+	0x10, 0xc5, 0x49, 0x93, 0xb8, 0xe3, 0x14, 0x45, 0x2b, 0x84, 0x2c, 0x5f, 0xb0, 0x22, 0x21, 0x0c,
+	0x87, 0x75, 0x15, 0x38, 0xa0, 0x22, 0x24, 0x9c, 0x52, 0x89, 0x53, 0x6b, 0x39, 0xad, 0x90, 0xb8,
+	0x44, 0xee, 0x7a, 0xa0, 0x51, 0x63, 0xef, 0x6a, 0x77, 0x1d, 0xf0, 0x17, 0xf0, 0xdb, 0xc8, 0xbb,
+	0x76, 0x45, 0x51, 0x43, 0x6f, 0x3b, 0x3b, 0x6f, 0xde, 0xbc, 0x79, 0x0f, 0x5e, 0xed, 0x16, 0x32,
+	0x6f, 0x28, 0xe3, 0x65, 0xcc, 0xb8, 0xc4, 0x58, 0x48, 0xfe, 0xab, 0x89, 0x15, 0x67, 0xb7, 0x2a,
+	0x66, 0xbc, 0xfa, 0xbe, 0xf9, 0x41, 0x85, 0xe4, 0x9a, 0x93, 0xe7, 0x3d, 0x50, 0x22, 0x35, 0x20,
+	0x6a, 0x40, 0xc1, 0xbf, 0x04, 0x8c, 0x97, 0x25, 0xaf, 0xe2, 0x0a, 0x75, 0x9c, 0x17, 0x85, 0x44,
+	0xa5, 0x2c, 0x41, 0xf0, 0xfa, 0x61, 0xa0, 0x69, 0x32, 0xbe, 0x8d, 0x6b, 0x85, 0xb2, 0x83, 0x1e,
+	0x3f, 0x02, 0x55, 0x28, 0x77, 0x28, 0xd7, 0x4a, 0x20, 0xb3, 0x13, 0xf3, 0x04, 0x26, 0x09, 0x63,
+	0xbc, 0xae, 0x34, 0x09, 0xc0, 0x6d, 0xa9, 0xaa, 0xbc, 0x44, 0xdf, 0x09, 0x9d, 0xe8, 0x30, 0xbb,
+	0xab, 0xdb, 0x9e, 0xc8, 0x95, 0xfa, 0xc9, 0x65, 0xe1, 0x0f, 0x6c, 0xaf, 0xaf, 0xe7, 0xbf, 0x87,
+	0x30, 0x5d, 0x19, 0xe2, 0x53, 0x73, 0x37, 0xf9, 0x08, 0x87, 0x79, 0xad, 0x6f, 0xd6, 0xba, 0x11,
+	0x96, 0xe9, 0xe9, 0x22, 0xa4, 0x0f, 0xbb, 0x40, 0x93, 0x5a, 0xdf, 0x5c, 0x36, 0x02, 0x33, 0x37,
+	0xef, 0x5e, 0xe4, 0x1c, 0xdc, 0xdc, 0x4a, 0x52, 0xfe, 0x20, 0x1c, 0x46, 0xde, 0x62, 0xb1, 0x6f,
+	0xfa, 0xef, 0xb5, 0xb4, 0xbb, 0x43, 0x9d, 0x55, 0x5a, 0x36, 0xd9, 0x1d, 0x07, 0x39, 0x81, 0x49,
+	0x67, 0xa8, 0x3f, 0x0c, 0x9d, 0xc8, 0xbb, 0x2f, 0xc6, 0x5a, 0x44, 0x2b, 0xd4, 0x34, 0xb1, 0xa8,
+	0x74, 0x99, 0xf5, 0x03, 0xe4, 0x05, 0x78, 0x75, 0x21, 0xd6, 0x58, 0xe5, 0xd7, 0x5b, 0x2c, 0xfc,
+	0x83, 0xd0, 0x89, 0xdc, 0x0c, 0xea, 0x42, 0x9c, 0xd9, 0x1f, 0xe2, 0xc3, 0x44, 0x6f, 0x4a, 0xe4,
+	0xb5, 0xf6, 0x47, 0xa1, 0x13, 0x1d, 0x65, 0x7d, 0x49, 0xde, 0xc1, 0x41, 0x6b, 0x9f, 0x3f, 0x0e,
+	0x87, 0x7b, 0x76, 0xf6, 0xb1, 0xd0, 0x2b, 0x85, 0x32, 0x33, 0xe8, 0xe0, 0x03, 0x1c, 0xdd, 0xbb,
+	0x83, 0xcc, 0x60, 0x78, 0x8b, 0x4d, 0x17, 0x48, 0xfb, 0x24, 0xcf, 0x60, 0xb4, 0xcb, 0xb7, 0x35,
+	0x76, 0x41, 0xd8, 0xe2, 0x64, 0xf0, 0xde, 0x99, 0xa7, 0x30, 0x3d, 0xdd, 0x6e, 0xb0, 0xd2, 0x5d,
+	0x10, 0x9f, 0x60, 0x6c, 0x13, 0xf7, 0x1d, 0x23, 0x22, 0xfa, 0x9f, 0x08, 0xeb, 0xe5, 0x4a, 0x20,
+	0x4b, 0x97, 0x59, 0x37, 0xf7, 0xe6, 0x25, 0xb8, 0x7d, 0x42, 0xc4, 0x83, 0xc9, 0xf9, 0xc5, 0x3a,
+	0xb9, 0xba, 0xfc, 0x32, 0x7b, 0x42, 0xa6, 0xe0, 0xa6, 0xc9, 0x6a, 0xf5, 0xf5, 0x22, 0xfb, 0x3c,
+	0x73, 0x96, 0xc7, 0x10, 0x30, 0x5e, 0xee, 0x49, 0x69, 0xe9, 0x59, 0x39, 0x69, 0xbb, 0xe9, 0xdb,
+	0xc8, 0xfc, 0x5d, 0x8f, 0xcd, 0xde, 0xb7, 0x7f, 0x06, 0x00, 0x1d, 0x8b, 0x26, 0x4c, 0x47, 0x03,
+	0x00, 0x00,
 }
@@ -19,3 +19,41 @@ func TestDefaultIPAddress(t *testing.T) {
 	assert.Error(err).IsNil()
 	assert.Address(socksConfig.(*ServerConfig).GetNetAddress()).EqualsString("127.0.0.1")
 }
+
+func TestAccountsParsing(t *testing.T) {
+	assert := assert.On(t)
+
+	socksConfig, err := registry.CreateInboundConfig("socks", []byte(`{
+    "auth": "password",
+    "accounts": [
+      {"user": "v2ray", "pass": "pass", "level": 1, "email": "love@v2ray.com"},
+      {"user": "guest", "pass": "guest"}
+    ]
+  }`))
+	assert.Error(err).IsNil()
+	config := socksConfig.(*ServerConfig)
+	assert.Int(len(config.User)).Equals(2)
+
+	user := config.FindUser("v2ray", "pass")
+	assert.Bool(user == nil).IsFalse()
+	assert.Uint32(user.Level).Equals(1)
+	assert.String(user.Email).Equals("love@v2ray.com")
+	assert.Bool(config.HasAccount("guest", "guest")).IsTrue()
+	assert.Bool(config.HasAccount("guest", "pass")).IsFalse()
+}
+
+func TestLegacyAccounts(t *testing.T) {
+	assert := assert.On(t)
+
+	config := &ServerConfig{
+		Accounts: map[string]string{"v2ray": "pass"},
+	}
+	user := config.FindUser("v2ray", "pass")
+	assert.Bool(user == nil).IsFalse()
+
+	account := new(Account)
+	_, err := user.GetTypedAccount(account)
+	assert.Error(err).IsNil()
+	assert.String(account.Username).Equals("v2ray")
+	assert.Bool(config.FindUser("v2ray", "wrong") == nil).IsTrue()
+}
@@ -0,0 +1,131 @@
+package protocol
+
+import (
+	"errors"
+	"io"
+
+	"v2ray.com/core/common/alloc"
+	v2net "v2ray.com/core/common/net"
+	"v2ray.com/core/proxy"
+	"v2ray.com/core/transport"
+)
+
+var (
+	ErrCredentialTooLong = errors.New("Socks: Username or password is too long.")
+)
+
+// WriteAuthenticationRequest writes the auth methods that the client supports.
+func WriteAuthenticationRequest(writer io.Writer, methods ...byte) error {
+	buffer := make([]byte, 0, 2+len(methods))
+	buffer = append(buffer, socksVersion, byte(len(methods)))
+	buffer = append(buffer, methods...)
+	_, err := writer.Write(buffer)
+	return err
+}
+
+// ReadAuthenticationResponse returns the auth method chosen by the server.
+func ReadAuthenticationResponse(reader io.Reader) (byte, error) {
+	var buffer [2]byte
+	if _, err := io.ReadFull(reader, buffer[:]); err != nil {
+		return 0, err
+	}
+	if buffer[0] != socksVersion {
+		return 0, proxy.ErrInvalidProtocolVersion
+	}
+	return buffer[1], nil
+}
+
+func WriteUserPassRequest(writer io.Writer, username string, password string) error {
+	if len(username) > 255 || len(password) > 255 {
+		return ErrCredentialTooLong
+	}
+	buffer := make([]byte, 0, 3+len(username)+len(password))
+	// Version of the username/password sub-negotiation is 0x01, as per RFC 1929.
+	buffer = append(buffer, 0x01, byte(len(username)))
+	buffer = append(buffer, username...)
+	buffer = append(buffer, byte(len(password)))
+	buffer = append(buffer, password...)
+	_, err := writer.Write(buffer)
+	return err
+}
+
+// ReadUserPassResponse returns the status of username/password authentication, where 0 means success.
+func ReadUserPassResponse(reader io.Reader) (byte, error) {
+	var buffer [2]byte
+	if _, err := io.ReadFull(reader, buffer[:]); err != nil {
+		return 0, err
+	}
+	return buffer[1], nil
+}
+
+// NewSocks5Request creates a request of the command to the destination.
+func NewSocks5Request(command byte, dest v2net.Destination) *Socks5Request {
+	request := &Socks5Request{
+		Version: socksVersion,
+		Command: command,
+		Port:    dest.Port,
+	}
+	switch dest.Address.Family() {
+	case v2net.AddressFamilyIPv4:
+		request.AddrType = AddrTypeIPv4
+		copy(request.IPv4[:], dest.Address.IP())
+	case v2net.AddressFamilyIPv6:
+		request.AddrType = AddrTypeIPv6
+		copy(request.IPv6[:], dest.Address.IP())
+	case v2net.AddressFamilyDomain:
+		request.AddrType = AddrTypeDomain
+		request.Domain = dest.Address.Domain()
+	}
+	return request
+}
+
+func (request *Socks5Request) Write(writer io.Writer) error {
+	buffer := alloc.NewSmallBuffer().Clear()
+	defer buffer.Release()
+
+	buffer.AppendBytes(request.Version, request.Command, 0x00 /* reserved */, request.AddrType)
+	switch request.AddrType {
+	case AddrTypeIPv4:
+		buffer.Append(request.IPv4[:])
+	case AddrTypeDomain:
+		buffer.AppendBytes(byte(len(request.Domain))).Append([]byte(request.Domain))
+	case AddrTypeIPv6:
+		buffer.Append(request.IPv6[:])
+	}
+	buffer.AppendUint16(request.Port.Value())
+	_, err := writer.Write(buffer.Value)
+	return err
+}
+
+// ReadResponse reads the reply to a request. The reply has the same layout as a request, with the command field
+// carrying the error code.
+func ReadResponse(reader io.Reader) (*Socks5Response, error) {
+	request, err := ReadRequest(reader)
+	if err != nil {
+		return nil, err
+	}
+	if request.Version != socksVersion {
+		return nil, transport.ErrCorruptedPacket
+	}
+	return &Socks5Response{
+		Version:  request.Version,
+		Error:    request.Command,
+		AddrType: request.AddrType,
+		IPv4:     request.IPv4,
+		Domain:   request.Domain,
+		IPv6:     request.IPv6,
+		Port:     request.Port,
+	}, nil
+}
+
+// Destination returns the bound address in the response.
+func (r *Socks5Response) Destination() v2net.Destination {
+	switch r.AddrType {
+	case AddrTypeIPv4:
+		return v2net.TCPDestination(v2net.IPAddress(r.IPv4[:]), r.Port)
+	case AddrTypeIPv6:
+		return v2net.TCPDestination(v2net.IPAddress(r.IPv6[:]), r.Port)
+	default:
+		return v2net.TCPDestination(v2net.ParseAddress(r.Domain), r.Port)
+	}
+}
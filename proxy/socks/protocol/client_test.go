@@ -0,0 +1,52 @@
+package protocol
+
+import (
+	"bytes"
+	"testing"
+
+	v2net "v2ray.com/core/common/net"
+	"v2ray.com/core/testing/assert"
+)
+
+func TestClientAuthentication(t *testing.T) {
+	assert := assert.On(t)
+
+	buffer := bytes.NewBuffer(make([]byte, 0, 64))
+	assert.Error(WriteAuthenticationRequest(buffer, AuthNotRequired, AuthUserPass)).IsNil()
+	request, _, err := ReadAuthentication(buffer)
+	assert.Error(err).IsNil()
+	assert.Bool(request.HasAuthMethod(AuthUserPass)).IsTrue()
+
+	assert.Error(WriteUserPassRequest(buffer, "v2ray", "pass")).IsNil()
+	upRequest, err := ReadUserPassRequest(buffer)
+	assert.Error(err).IsNil()
+	assert.String(upRequest.Username()).Equals("v2ray")
+	assert.String(upRequest.Password()).Equals("pass")
+
+	WriteUserPassResponse(buffer, NewSocks5UserPassResponse(0xFF))
+	status, err := ReadUserPassResponse(buffer)
+	assert.Error(err).IsNil()
+	assert.Byte(status).Equals(0xFF)
+}
+
+func TestClientRequest(t *testing.T) {
+	assert := assert.On(t)
+
+	buffer := bytes.NewBuffer(make([]byte, 0, 64))
+	dest := v2net.TCPDestination(v2net.DomainAddress("v2ray.com"), 443)
+	assert.Error(NewSocks5Request(CmdConnect, dest).Write(buffer)).IsNil()
+	request, err := ReadRequest(buffer)
+	assert.Error(err).IsNil()
+	assert.Byte(request.Command).Equals(CmdConnect)
+	assert.Destination(request.Destination()).EqualsString("tcp:v2ray.com:443")
+
+	response := NewSocks5Response()
+	response.Error = ErrorSuccess
+	response.SetIPv4([]byte{10, 0, 0, 1})
+	response.Port = 1080
+	response.Write(buffer)
+	parsedResponse, err := ReadResponse(buffer)
+	assert.Error(err).IsNil()
+	assert.Byte(parsedResponse.Error).Equals(ErrorSuccess)
+	assert.Destination(parsedResponse.Destination()).EqualsString("tcp:10.0.0.1:1080")
+}
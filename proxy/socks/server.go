@@ -3,6 +3,7 @@ package socks
 import (
 	"errors"
 	"io"
+	"net"
 	"sync"
 	"time"
 
@@ -11,11 +12,13 @@ import (
 	v2io "v2ray.com/core/common/io"
 	"v2ray.com/core/common/log"
 	v2net "v2ray.com/core/common/net"
+	v2protocol "v2ray.com/core/common/protocol"
 	"v2ray.com/core/proxy"
 	"v2ray.com/core/proxy/registry"
 	"v2ray.com/core/proxy/socks/protocol"
 	"v2ray.com/core/transport/internet"
 	"v2ray.com/core/transport/internet/udp"
+	"v2ray.com/core/transport/ray"
 )
 
 var (
@@ -34,14 +37,17 @@ type Server struct {
 	udpHub           *udp.UDPHub
 	udpAddress       v2net.Destination
 	udpServer        *udp.UDPServer
-	meta             *proxy.InboundHandlerMeta
+	// udpUsers is the user of each client IP with an ongoing UDP association, guarded by udpMutex.
+	udpUsers map[string]*v2protocol.User
+	meta     *proxy.InboundHandlerMeta
 }
 
 // NewServer creates a new Server object.
 func NewServer(config *ServerConfig, space app.Space, meta *proxy.InboundHandlerMeta) *Server {
 	s := &Server{
-		config: config,
-		meta:   meta,
+		config:   config,
+		meta:     meta,
+		udpUsers: make(map[string]*v2protocol.User),
 	}
 	space.InitializeApplication(func() error {
 		if !space.HasApp(dispatcher.APP_ID) {
@@ -123,11 +129,11 @@ func (this *Server) handleConnection(connection internet.Connection) {
 	if err != nil && err == protocol.Socks4Downgrade {
 		this.handleSocks4(clientAddr, reader, writer, auth4)
 	} else {
-		this.handleSocks5(clientAddr, reader, writer, auth)
+		this.handleSocks5(clientAddr, v2net.DestinationFromAddr(connection.LocalAddr()), timedReader, reader, writer, auth)
 	}
 }
 
-func (this *Server) handleSocks5(clientAddr v2net.Destination, reader *v2io.BufferedReader, writer *v2io.BufferedWriter, auth protocol.Socks5AuthenticationRequest) error {
+func (this *Server) handleSocks5(clientAddr v2net.Destination, localAddr v2net.Destination, timedReader *v2net.TimeOutReader, reader *v2io.BufferedReader, writer *v2io.BufferedWriter, auth protocol.Socks5AuthenticationRequest) error {
 	expectedAuthMethod := protocol.AuthNotRequired
 	if this.config.AuthType == AuthType_PASSWORD {
 		expectedAuthMethod = protocol.AuthUserPass
@@ -152,6 +158,7 @@ func (this *Server) handleSocks5(clientAddr v2net.Destination, reader *v2io.Buff
 		log.Error("Socks: failed to write authentication: ", err)
 		return err
 	}
+	var user *v2protocol.User
 	if this.config.AuthType == AuthType_PASSWORD {
 		upRequest, err := protocol.ReadUserPassRequest(reader)
 		if err != nil {
@@ -159,7 +166,8 @@ func (this *Server) handleSocks5(clientAddr v2net.Destination, reader *v2io.Buff
 			return err
 		}
 		status := byte(0)
-		if !this.config.HasAccount(upRequest.Username(), upRequest.Password()) {
+		user = this.config.FindUser(upRequest.Username(), upRequest.Password())
+		if user == nil {
 			status = byte(0xFF)
 		}
 		upResponse := protocol.NewSocks5UserPassResponse(status)
@@ -181,12 +189,21 @@ func (this *Server) handleSocks5(clientAddr v2net.Destination, reader *v2io.Buff
 		log.Warning("Socks: failed to read request: ", err)
 		return err
 	}
+	if user != nil && this.config.Timeout == 0 {
+		// The configured timeout takes precedence over the one of the user level, so that existing configs keep
+		// working.
+		timedReader.SetTimeOut(user.GetSettings().PayloadReadTimeout)
+	}
 
 	if request.Command == protocol.CmdUdpAssociate && this.config.UdpEnabled {
-		return this.handleUDP(reader, writer)
+		return this.handleUDP(clientAddr, reader, writer, user)
+	}
+
+	if request.Command == protocol.CmdBind {
+		return this.handleBind(clientAddr, localAddr, reader, writer, request, user)
 	}
 
-	if request.Command == protocol.CmdBind || request.Command == protocol.CmdUdpAssociate {
+	if request.Command == protocol.CmdUdpAssociate {
 		response := protocol.NewSocks5Response()
 		response.Error = protocol.ErrorCommandNotSupported
 		response.Port = v2net.Port(0)
@@ -222,6 +239,7 @@ func (this *Server) handleSocks5(clientAddr v2net.Destination, reader *v2io.Buff
 	session := &proxy.SessionInfo{
 		Source:      clientAddr,
 		Destination: dest,
+		User:        user,
 	}
 	log.Info("Socks: TCP Connect request to ", dest)
 	log.Access(clientAddr, dest, log.AccessAccepted, "")
@@ -230,7 +248,134 @@ func (this *Server) handleSocks5(clientAddr v2net.Destination, reader *v2io.Buff
 	return nil
 }
 
-func (this *Server) handleUDP(reader io.Reader, writer *v2io.BufferedWriter) error {
+// handleBind serves a BIND request. The first reply carries the address that the destination should connect to, and
+// the second reply carries the address of the connection accepted. localAddr is the address that the client connected
+// to.
+func (this *Server) handleBind(clientAddr v2net.Destination, localAddr v2net.Destination, reader *v2io.BufferedReader, writer *v2io.BufferedWriter, request *protocol.Socks5Request, user *v2protocol.User) error {
+	dest := request.Destination()
+	session := &proxy.SessionInfo{
+		Source:      clientAddr,
+		Destination: dest,
+		User:        user,
+	}
+
+	var err error
+	var link ray.InboundRay
+	var bindSession *proxy.BindSession
+	if bindDispatcher, ok := this.packetDispatcher.(dispatcher.BindDispatcher); ok {
+		link, bindSession, err = bindDispatcher.DispatchBind(this.meta, session)
+	} else {
+		err = proxy.ErrBindNotSupported
+	}
+	if err != nil {
+		response := protocol.NewSocks5Response()
+		response.Error = protocol.ErrorCommandNotSupported
+		if err != proxy.ErrBindNotSupported {
+			response.Error = protocol.ErrorGeneralFailure
+		}
+		response.Port = v2net.Port(0)
+		response.SetIPv4([]byte{0, 0, 0, 0})
+		response.Write(writer)
+		writer.Flush()
+
+		log.Warning("Socks: Failed to bind for ", dest, ": ", err)
+		log.Access(clientAddr, dest, log.AccessRejected, err)
+		return err
+	}
+
+	input := link.InboundInput()
+	output := link.InboundOutput()
+	defer input.Close()
+	defer output.Release()
+
+	boundAddr := bindSession.Address
+	if boundAddr.Address.Family().IsDomain() || boundAddr.Address.IP().IsUnspecified() {
+		// The address that the client reached this server at is the best guess of a routable one.
+		boundAddr.Address = localAddr.Address
+	}
+	if err := writeBindResponse(writer, boundAddr); err != nil {
+		log.Error("Socks: failed to write response: ", err)
+		return err
+	}
+	log.Info("Socks: Bind request for ", dest, " on ", boundAddr)
+
+	reader.SetCached(false)
+	writer.SetCached(false)
+
+	// The control connection is read while waiting as well, so that the bind is cancelled as soon as the client
+	// disconnects. The client may stay idle regardless of the timeout until a connection is accepted.
+	waitOver := make(chan bool)
+	go func() {
+		v2reader := v2io.NewAdaptiveReader(reader)
+		defer v2reader.Release()
+
+		for {
+			data, err := v2reader.Read()
+			if err != nil {
+				if netErr, ok := err.(net.Error); ok && netErr.Timeout() && !isClosed(waitOver) {
+					continue
+				}
+				break
+			}
+			if err := input.Write(data); err != nil {
+				break
+			}
+		}
+		input.Close()
+	}()
+
+	peerAddr, ok := <-bindSession.Accepted
+	close(waitOver)
+	if !ok {
+		response := protocol.NewSocks5Response()
+		response.Error = protocol.ErrorGeneralFailure
+		response.Port = v2net.Port(0)
+		response.SetIPv4([]byte{0, 0, 0, 0})
+		response.Write(writer)
+		writer.Flush()
+		log.Warning("Socks: No connection from ", dest)
+		return nil
+	}
+	if err := writeBindResponse(writer, peerAddr); err != nil {
+		log.Error("Socks: failed to write response: ", err)
+		return err
+	}
+	log.Access(peerAddr, dest, log.AccessAccepted, "")
+
+	v2writer := v2io.NewAdaptiveWriter(writer)
+	defer v2writer.Release()
+
+	v2io.Pipe(output, v2writer)
+	output.Release()
+	return nil
+}
+
+func isClosed(signal <-chan bool) bool {
+	select {
+	case <-signal:
+		return true
+	default:
+		return false
+	}
+}
+
+func writeBindResponse(writer *v2io.BufferedWriter, addr v2net.Destination) error {
+	response := protocol.NewSocks5Response()
+	response.Error = protocol.ErrorSuccess
+	response.Port = addr.Port
+	switch addr.Address.Family() {
+	case v2net.AddressFamilyIPv4:
+		response.SetIPv4(addr.Address.IP())
+	case v2net.AddressFamilyIPv6:
+		response.SetIPv6(addr.Address.IP())
+	case v2net.AddressFamilyDomain:
+		response.SetDomain(addr.Address.Domain())
+	}
+	response.Write(writer)
+	return writer.Flush()
+}
+
+func (this *Server) handleUDP(clientAddr v2net.Destination, reader io.Reader, writer *v2io.BufferedWriter, user *v2protocol.User) error {
 	response := protocol.NewSocks5Response()
 	response.Error = protocol.ErrorSuccess
 
@@ -254,6 +399,21 @@ func (this *Server) handleUDP(reader io.Reader, writer *v2io.BufferedWriter) err
 		return err
 	}
 
+	// UDP packets are attributed to the user by the client IP, while the association lasts.
+	if user != nil {
+		clientIP := clientAddr.Address.String()
+		this.udpMutex.Lock()
+		this.udpUsers[clientIP] = user
+		this.udpMutex.Unlock()
+		defer func() {
+			this.udpMutex.Lock()
+			if this.udpUsers[clientIP] == user {
+				delete(this.udpUsers, clientIP)
+			}
+			this.udpMutex.Unlock()
+		}()
+	}
+
 	// The TCP connection closes after this method returns. We need to wait until
 	// the client closes it.
 	// TODO: get notified from UDP part
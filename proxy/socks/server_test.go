@@ -0,0 +1,317 @@
+package socks_test
+
+import (
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"v2ray.com/core/app"
+	"v2ray.com/core/app/dispatcher"
+	"v2ray.com/core/common/alloc"
+	"v2ray.com/core/common/dice"
+	v2net "v2ray.com/core/common/net"
+	v2protocol "v2ray.com/core/common/protocol"
+	"v2ray.com/core/proxy"
+	"v2ray.com/core/proxy/blackhole"
+	. "v2ray.com/core/proxy/socks"
+	"v2ray.com/core/proxy/socks/protocol"
+	"v2ray.com/core/testing/assert"
+	"v2ray.com/core/transport/internet"
+	"v2ray.com/core/transport/ray"
+)
+
+// requestBind connects to the SOCKS server and sends a BIND request for the destination.
+func requestBind(assert *assert.Assert, port v2net.Port, dest v2net.Destination) net.Conn {
+	conn, err := net.DialTCP("tcp", nil, &net.TCPAddr{
+		IP:   []byte{127, 0, 0, 1},
+		Port: int(port),
+	})
+	assert.Error(err).IsNil()
+
+	assert.Error(protocol.WriteAuthenticationRequest(conn, protocol.AuthNotRequired)).IsNil()
+	method, err := protocol.ReadAuthenticationResponse(conn)
+	assert.Error(err).IsNil()
+	assert.Byte(method).Equals(protocol.AuthNotRequired)
+
+	assert.Error(protocol.NewSocks5Request(protocol.CmdBind, dest).Write(conn)).IsNil()
+	return conn
+}
+
+func TestSocksServerBind(t *testing.T) {
+	assert := assert.On(t)
+
+	port := startSocksServerWithConfig(assert, &ServerConfig{
+		AuthType: AuthType_NO_AUTH,
+		Address:  v2net.NewAddressPB(v2net.LocalHostIP),
+		Timeout:  30,
+	})
+
+	conn := requestBind(assert, port, v2net.TCPDestination(v2net.LocalHostIP, 0))
+	defer conn.Close()
+
+	response, err := protocol.ReadResponse(conn)
+	assert.Error(err).IsNil()
+	assert.Byte(response.Error).Equals(protocol.ErrorSuccess)
+	boundAddr := response.Destination()
+	assert.Destination(boundAddr).EqualsString("tcp:127.0.0.1:" + boundAddr.Port.String())
+
+	peer, err := net.DialTCP("tcp", nil, &net.TCPAddr{
+		IP:   boundAddr.Address.IP(),
+		Port: int(boundAddr.Port),
+	})
+	assert.Error(err).IsNil()
+	defer peer.Close()
+
+	response, err = protocol.ReadResponse(conn)
+	assert.Error(err).IsNil()
+	assert.Byte(response.Error).Equals(protocol.ErrorSuccess)
+	assert.Destination(response.Destination()).EqualsString("tcp:" + peer.LocalAddr().String())
+
+	_, err = peer.Write([]byte("Data from peer"))
+	assert.Error(err).IsNil()
+	buffer := make([]byte, 14)
+	_, err = io.ReadFull(conn, buffer)
+	assert.Error(err).IsNil()
+	assert.String(string(buffer)).Equals("Data from peer")
+
+	_, err = conn.Write([]byte("Data to peer"))
+	assert.Error(err).IsNil()
+	buffer = make([]byte, 12)
+	_, err = io.ReadFull(peer, buffer)
+	assert.Error(err).IsNil()
+	assert.String(string(buffer)).Equals("Data to peer")
+}
+
+func TestSocksServerBindWithUnspecifiedSendThrough(t *testing.T) {
+	assert := assert.On(t)
+
+	// The address of UDP relay is not reachable, and must not be reported for BIND.
+	port := startSocksServerWithConfig(assert, &ServerConfig{
+		AuthType: AuthType_NO_AUTH,
+		Address:  v2net.NewAddressPB(v2net.IPAddress([]byte{10, 9, 8, 7})),
+		Timeout:  30,
+	})
+
+	for _, dest := range []v2net.Destination{
+		v2net.TCPDestination(v2net.LocalHostIP, 0),
+		v2net.TCPDestination(v2net.DomainAddress("localhost"), 0),
+	} {
+		conn := requestBind(assert, port, dest)
+
+		response, err := protocol.ReadResponse(conn)
+		assert.Error(err).IsNil()
+		assert.Byte(response.Error).Equals(protocol.ErrorSuccess)
+		boundAddr := response.Destination()
+		assert.Destination(boundAddr).EqualsString("tcp:127.0.0.1:" + boundAddr.Port.String())
+
+		peer, err := net.DialTCP("tcp", nil, &net.TCPAddr{
+			IP:   boundAddr.Address.IP(),
+			Port: int(boundAddr.Port),
+		})
+		assert.Error(err).IsNil()
+
+		response, err = protocol.ReadResponse(conn)
+		assert.Error(err).IsNil()
+		assert.Byte(response.Error).Equals(protocol.ErrorSuccess)
+
+		peer.Close()
+		conn.Close()
+	}
+}
+
+func TestSocksServerBindCancelledOnDisconnect(t *testing.T) {
+	assert := assert.On(t)
+
+	port := startSocksServerWithConfig(assert, &ServerConfig{
+		AuthType: AuthType_NO_AUTH,
+		Address:  v2net.NewAddressPB(v2net.LocalHostIP),
+		Timeout:  1,
+	})
+
+	conn := requestBind(assert, port, v2net.TCPDestination(v2net.DomainAddress("localhost"), 0))
+	response, err := protocol.ReadResponse(conn)
+	assert.Error(err).IsNil()
+	assert.Byte(response.Error).Equals(protocol.ErrorSuccess)
+	boundAddr := &net.TCPAddr{
+		IP:   response.Destination().Address.IP(),
+		Port: int(response.Destination().Port),
+	}
+
+	// An idle client keeps waiting beyond the timeout.
+	time.Sleep(time.Millisecond * 1500)
+	peer, err := net.DialTCP("tcp", nil, boundAddr)
+	assert.Error(err).IsNil()
+	response, err = protocol.ReadResponse(conn)
+	assert.Error(err).IsNil()
+	assert.Byte(response.Error).Equals(protocol.ErrorSuccess)
+	peer.Close()
+	conn.Close()
+
+	conn = requestBind(assert, port, v2net.TCPDestination(v2net.DomainAddress("localhost"), 0))
+	response, err = protocol.ReadResponse(conn)
+	assert.Error(err).IsNil()
+	assert.Byte(response.Error).Equals(protocol.ErrorSuccess)
+	boundAddr = &net.TCPAddr{
+		IP:   response.Destination().Address.IP(),
+		Port: int(response.Destination().Port),
+	}
+
+	// Once the client is gone, nothing is listening for the destination any more.
+	conn.Close()
+	time.Sleep(time.Millisecond * 500)
+	_, err = net.DialTCP("tcp", nil, boundAddr)
+	assert.Error(err).IsNotNil()
+}
+
+func TestSocksServerBindNotSupported(t *testing.T) {
+	assert := assert.On(t)
+
+	port := startSocksServerWithOutbound(assert, &ServerConfig{
+		AuthType: AuthType_NO_AUTH,
+		Address:  v2net.NewAddressPB(v2net.LocalHostIP),
+		Timeout:  30,
+	}, func(space app.Space) proxy.OutboundHandler {
+		handler, err := blackhole.NewBlackHole(space, &blackhole.Config{}, &proxy.OutboundHandlerMeta{})
+		assert.Error(err).IsNil()
+		return handler
+	})
+
+	conn := requestBind(assert, port, v2net.TCPDestination(v2net.LocalHostIP, 0))
+	defer conn.Close()
+
+	response, err := protocol.ReadResponse(conn)
+	assert.Error(err).IsNil()
+	assert.Byte(response.Error).Equals(protocol.ErrorCommandNotSupported)
+}
+
+// sessionRecorder is a dispatcher that records the sessions, and replies to traffic with "Processed: " prefixed.
+type sessionRecorder struct {
+	sessions chan *proxy.SessionInfo
+}
+
+func (this *sessionRecorder) Release() {}
+
+func (this *sessionRecorder) DispatchToOutbound(meta *proxy.InboundHandlerMeta, session *proxy.SessionInfo) ray.InboundRay {
+	this.sessions <- session
+	traffic := ray.NewRay()
+	go func() {
+		for {
+			payload, err := traffic.OutboundInput().Read()
+			if err != nil {
+				break
+			}
+			traffic.OutboundOutput().Write(payload.Prepend([]byte("Processed: ")))
+		}
+		traffic.OutboundOutput().Close()
+	}()
+	return traffic
+}
+
+// startRecordingServer starts a SOCKS server with the config, which sends traffic to a sessionRecorder.
+func startRecordingServer(assert *assert.Assert, config *ServerConfig) (v2net.Port, *sessionRecorder, *Server) {
+	recorder := &sessionRecorder{
+		sessions: make(chan *proxy.SessionInfo, 4),
+	}
+	space := app.NewSpace()
+	space.BindApp(dispatcher.APP_ID, recorder)
+
+	port := v2net.Port(dice.Roll(20000) + 10000)
+	server := NewServer(config, space, &proxy.InboundHandlerMeta{
+		Address: v2net.LocalHostIP,
+		Port:    port,
+		StreamSettings: &internet.StreamConfig{
+			Network: v2net.Network_RawTCP,
+		},
+	})
+	assert.Error(space.Initialize()).IsNil()
+	assert.Error(server.Start()).IsNil()
+	return port, recorder, server
+}
+
+func TestSocksServerUserInSession(t *testing.T) {
+	assert := assert.On(t)
+
+	account, err := (&Account{Username: "v2ray", Password: "pass"}).AsAny()
+	assert.Error(err).IsNil()
+	port, recorder, server := startRecordingServer(assert, &ServerConfig{
+		AuthType: AuthType_PASSWORD,
+		User: []*v2protocol.User{{
+			Level:   1,
+			Email:   "love@v2ray.com",
+			Account: account,
+		}},
+		Address:    v2net.NewAddressPB(v2net.LocalHostIP),
+		UdpEnabled: true,
+		Timeout:    30,
+	})
+	defer server.Close()
+
+	for _, dest := range []v2net.Destination{
+		v2net.TCPDestination(v2net.LocalHostIP, 80),
+		v2net.UDPDestination(v2net.LocalHostIP, 53),
+	} {
+		traffic := ray.NewRay()
+		payload := alloc.NewLocalBuffer(2048).Clear().Append([]byte("Data to be sent to remote"))
+		go newTestClient(assert, port, "pass").Dispatch(dest, payload, traffic)
+
+		response, err := traffic.InboundOutput().Read()
+		assert.Error(err).IsNil()
+		assert.Bytes(response.Value).Equals([]byte("Processed: Data to be sent to remote"))
+		traffic.InboundInput().Close()
+
+		session := <-recorder.sessions
+		assert.Destination(session.Destination).EqualsString(dest.String())
+		assert.Pointer(session.User).IsNotNil()
+		assert.Uint32(session.User.Level).Equals(1)
+		assert.String(session.User.Email).Equals("love@v2ray.com")
+	}
+}
+
+func TestSocksServerTimeoutOfUsers(t *testing.T) {
+	assert := assert.On(t)
+
+	account, err := (&Account{Username: "v2ray", Password: "pass"}).AsAny()
+	assert.Error(err).IsNil()
+
+	// The configured timeout applies to users of any level, as well as users in the deprecated accounts map.
+	for _, config := range []*ServerConfig{
+		{
+			AuthType: AuthType_PASSWORD,
+			User: []*v2protocol.User{{
+				Level:   1,
+				Account: account,
+			}},
+			Timeout: 1,
+		},
+		{
+			AuthType: AuthType_PASSWORD,
+			Accounts: map[string]string{"v2ray": "pass"},
+			Timeout:  1,
+		},
+	} {
+		port, _, server := startRecordingServer(assert, config)
+
+		traffic := ray.NewRay()
+		payload := alloc.NewLocalBuffer(2048).Clear().Append([]byte("Data to be sent to remote"))
+		go newTestClient(assert, port, "pass").Dispatch(v2net.TCPDestination(v2net.LocalHostIP, 80), payload, traffic)
+
+		response, err := traffic.InboundOutput().Read()
+		assert.Error(err).IsNil()
+		assert.Bytes(response.Value).Equals([]byte("Processed: Data to be sent to remote"))
+
+		// The client stays idle, so the connection is closed by the server.
+		closed := make(chan bool, 1)
+		go func() {
+			_, err := traffic.InboundOutput().Read()
+			closed <- err != nil
+		}()
+		select {
+		case result := <-closed:
+			assert.Bool(result).IsTrue()
+		case <-time.After(time.Second * 3):
+			t.Error("Connection is not closed after timeout.")
+		}
+		server.Close()
+	}
+}
@@ -10,6 +10,7 @@ import (
 type Account struct {
 	ID       *protocol.ID
 	AlterIDs []*protocol.ID
+	Security protocol.SecurityType
 }
 
 func NewAccount() protocol.AsAccount {
@@ -42,5 +43,6 @@ func (this *AccountPB) AsAccount() (protocol.Account, error) {
 	return &Account{
 		ID:       protoId,
 		AlterIDs: protocol.NewAlterIDs(protoId, uint16(this.AlterId)),
+		Security: protocol.SecurityType(this.Security),
 	}, nil
 }
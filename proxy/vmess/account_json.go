@@ -4,19 +4,45 @@ package vmess
 
 import (
 	"encoding/json"
+	"strings"
+
+	"v2ray.com/core/common"
+	"v2ray.com/core/common/log"
 )
 
+func parseSecurityType(security string) (SecurityType, error) {
+	switch strings.ToLower(security) {
+	case "", "legacy", "aes-128-cfb":
+		return SecurityType_LEGACY, nil
+	case "aes-128-gcm":
+		return SecurityType_AES128_GCM, nil
+	case "chacha20-poly1305":
+		return SecurityType_CHACHA20_POLY1305, nil
+	case "none":
+		return SecurityType_NONE, nil
+	default:
+		log.Error("VMess: Unknown security type: ", security)
+		return SecurityType_LEGACY, common.ErrBadConfiguration
+	}
+}
+
 func (u *AccountPB) UnmarshalJSON(data []byte) error {
 	type JsonConfig struct {
 		ID       string `json:"id"`
 		AlterIds uint16 `json:"alterId"`
+		Security string `json:"security"`
 	}
 	var rawConfig JsonConfig
 	if err := json.Unmarshal(data, &rawConfig); err != nil {
 		return err
 	}
+	security, err := parseSecurityType(rawConfig.Security)
+	if err != nil {
+		return err
+	}
 	u.Id = rawConfig.ID
 	u.AlterId = uint32(rawConfig.AlterIds)
+	u.Security = security
 
 	return nil
 }
@@ -1,7 +1,15 @@
 package encoding
 
 import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/md5"
 	"hash/fnv"
+
+	"v2ray.com/core/common/protocol"
+	vmessio "v2ray.com/core/proxy/vmess/io"
+
+	"golang.org/x/crypto/chacha20poly1305"
 )
 
 func Authenticate(b []byte) uint32 {
@@ -9,3 +17,29 @@ func Authenticate(b []byte) uint32 {
 	fnv1hash.Write(b)
 	return fnv1hash.Sum32()
 }
+
+// chacha20Key expands a 16-byte body key into a 32-byte ChaCha20-Poly1305 key.
+func chacha20Key(key []byte) []byte {
+	chachaKey := make([]byte, 0, 32)
+	first := md5.Sum(key)
+	chachaKey = append(chachaKey, first[:]...)
+	second := md5.Sum(first[:])
+	return append(chachaKey, second[:]...)
+}
+
+// newBodyAEAD returns the AEAD for the body of the security type, or nil if the body is encrypted in the legacy way.
+func newBodyAEAD(security protocol.SecurityType, key []byte) cipher.AEAD {
+	switch security {
+	case protocol.SecurityTypeAES128GCM:
+		block, _ := aes.NewCipher(key)
+		aead, _ := cipher.NewGCM(block)
+		return aead
+	case protocol.SecurityTypeChacha20Poly1305:
+		aead, _ := chacha20poly1305.New(chacha20Key(key))
+		return aead
+	case protocol.SecurityTypeNone:
+		return vmessio.NoOpAuthenticator{}
+	default:
+		return nil
+	}
+}
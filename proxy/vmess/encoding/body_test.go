@@ -0,0 +1,85 @@
+package encoding_test
+
+import (
+	"io"
+	"testing"
+
+	"v2ray.com/core/common/alloc"
+	v2net "v2ray.com/core/common/net"
+	"v2ray.com/core/common/protocol"
+	"v2ray.com/core/common/uuid"
+	"v2ray.com/core/proxy/vmess"
+	. "v2ray.com/core/proxy/vmess/encoding"
+	"v2ray.com/core/testing/assert"
+
+	"github.com/golang/protobuf/ptypes"
+)
+
+func testBodyEncryption(assert *assert.Assert, security vmess.SecurityType) {
+	account, err := ptypes.MarshalAny(&vmess.AccountPB{
+		Id:       uuid.New().String(),
+		Security: security,
+	})
+	assert.Error(err).IsNil()
+	user := &protocol.User{
+		Email:   "test@v2ray.com",
+		Account: account,
+	}
+
+	request := &protocol.RequestHeader{
+		Version:  Version,
+		User:     user,
+		Command:  protocol.RequestCommandTCP,
+		Option:   protocol.RequestOptionChunkStream,
+		Security: protocol.SecurityType(security),
+		Address:  v2net.DomainAddress("www.v2ray.com"),
+		Port:     v2net.Port(443),
+	}
+
+	requestBuffer := alloc.NewBuffer().Clear()
+	client := NewClientSession(protocol.DefaultIDHash)
+	client.EncodeRequestHeader(request, requestBuffer)
+	requestWriter := client.EncodeRequestBody(request, requestBuffer)
+	assert.Error(requestWriter.Write(alloc.NewBuffer().Clear().AppendString("request body"))).IsNil()
+	assert.Error(requestWriter.Write(alloc.NewBuffer().Clear())).IsNil()
+
+	userValidator := vmess.NewTimedUserValidator(protocol.DefaultIDHash)
+	userValidator.Add(user)
+	defer userValidator.Release()
+
+	server := NewServerSession(userValidator)
+	actualRequest, err := server.DecodeRequestHeader(requestBuffer)
+	assert.Error(err).IsNil()
+	assert.Byte(byte(actualRequest.Security)).Equals(byte(security))
+
+	requestReader := server.DecodeRequestBody(actualRequest, requestBuffer)
+	body, err := requestReader.Read()
+	assert.Error(err).IsNil()
+	assert.String(body.String()).Equals("request body")
+	_, err = requestReader.Read()
+	assert.Error(err).Equals(io.EOF)
+
+	responseBuffer := alloc.NewBuffer().Clear()
+	server.EncodeResponseHeader(&protocol.ResponseHeader{}, responseBuffer)
+	responseWriter := server.EncodeResponseBody(actualRequest, responseBuffer)
+	assert.Error(responseWriter.Write(alloc.NewBuffer().Clear().AppendString("response body"))).IsNil()
+	assert.Error(responseWriter.Write(alloc.NewBuffer().Clear())).IsNil()
+
+	_, err = client.DecodeResponseHeader(responseBuffer)
+	assert.Error(err).IsNil()
+	responseReader := client.DecodeResponseBody(request, responseBuffer)
+	body, err = responseReader.Read()
+	assert.Error(err).IsNil()
+	assert.String(body.String()).Equals("response body")
+	_, err = responseReader.Read()
+	assert.Error(err).Equals(io.EOF)
+}
+
+func TestBodyEncryption(t *testing.T) {
+	assert := assert.On(t)
+
+	testBodyEncryption(assert, vmess.SecurityType_LEGACY)
+	testBodyEncryption(assert, vmess.SecurityType_AES128_GCM)
+	testBodyEncryption(assert, vmess.SecurityType_CHACHA20_POLY1305)
+	testBodyEncryption(assert, vmess.SecurityType_NONE)
+}
@@ -7,10 +7,12 @@ import (
 	"io"
 
 	"v2ray.com/core/common/crypto"
+	v2io "v2ray.com/core/common/io"
 	"v2ray.com/core/common/log"
 	v2net "v2ray.com/core/common/net"
 	"v2ray.com/core/common/protocol"
 	"v2ray.com/core/proxy/vmess"
+	vmessio "v2ray.com/core/proxy/vmess/io"
 	"v2ray.com/core/transport"
 )
 
@@ -65,7 +67,7 @@ func (this *ClientSession) EncodeRequestHeader(header *protocol.RequestHeader, w
 	buffer = append(buffer, Version)
 	buffer = append(buffer, this.requestBodyIV...)
 	buffer = append(buffer, this.requestBodyKey...)
-	buffer = append(buffer, this.responseHeader, byte(header.Option), byte(0), byte(0), byte(header.Command))
+	buffer = append(buffer, this.responseHeader, byte(header.Option), byte(header.Security), byte(0), byte(header.Command))
 	buffer = header.Port.Bytes(buffer)
 
 	switch header.Address.Family() {
@@ -95,9 +97,17 @@ func (this *ClientSession) EncodeRequestHeader(header *protocol.RequestHeader, w
 	return
 }
 
-func (this *ClientSession) EncodeRequestBody(writer io.Writer) io.Writer {
+// EncodeRequestBody returns a writer that encrypts the body of the request, as required by its security type.
+func (this *ClientSession) EncodeRequestBody(request *protocol.RequestHeader, writer io.Writer) v2io.Writer {
+	if aead := newBodyAEAD(request.Security, this.requestBodyKey); aead != nil {
+		return vmessio.NewAEADChunkWriter(v2io.NewAdaptiveWriter(writer), aead, this.requestBodyIV)
+	}
 	aesStream := crypto.NewAesEncryptionStream(this.requestBodyKey, this.requestBodyIV)
-	return crypto.NewCryptionWriter(aesStream, writer)
+	var bodyWriter v2io.Writer = v2io.NewAdaptiveWriter(crypto.NewCryptionWriter(aesStream, writer))
+	if request.Option.Has(protocol.RequestOptionChunkStream) {
+		bodyWriter = vmessio.NewAuthChunkWriter(bodyWriter)
+	}
+	return bodyWriter
 }
 
 func (this *ClientSession) DecodeResponseHeader(reader io.Reader) (*protocol.ResponseHeader, error) {
@@ -139,6 +149,13 @@ func (this *ClientSession) DecodeResponseHeader(reader io.Reader) (*protocol.Res
 	return header, nil
 }
 
-func (this *ClientSession) DecodeResponseBody(reader io.Reader) io.Reader {
-	return this.responseReader
+// DecodeResponseBody returns a reader that decrypts the body of the response to the request.
+func (this *ClientSession) DecodeResponseBody(request *protocol.RequestHeader, reader io.Reader) v2io.Reader {
+	if aead := newBodyAEAD(request.Security, this.responseBodyKey); aead != nil {
+		return vmessio.NewAEADChunkReader(reader, aead, this.responseBodyIV)
+	}
+	if request.Option.Has(protocol.RequestOptionChunkStream) {
+		return vmessio.NewAuthChunkReader(this.responseReader)
+	}
+	return v2io.NewAdaptiveReader(this.responseReader)
 }
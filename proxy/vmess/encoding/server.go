@@ -2,18 +2,25 @@ package encoding
 
 import (
 	"crypto/md5"
+	"errors"
 	"hash/fnv"
 	"io"
 
 	"v2ray.com/core/common/crypto"
+	v2io "v2ray.com/core/common/io"
 	"v2ray.com/core/common/log"
 	v2net "v2ray.com/core/common/net"
 	"v2ray.com/core/common/protocol"
 	"v2ray.com/core/common/serial"
 	"v2ray.com/core/proxy/vmess"
+	vmessio "v2ray.com/core/proxy/vmess/io"
 	"v2ray.com/core/transport"
 )
 
+var (
+	ErrUnsupportedSecurity = errors.New("VMess: Unsupported security type.")
+)
+
 type ServerSession struct {
 	userValidator   protocol.UserValidator
 	requestBodyKey  []byte
@@ -87,7 +94,8 @@ func (this *ServerSession) DecodeRequestHeader(reader io.Reader) (*protocol.Requ
 	this.requestBodyIV = append([]byte(nil), buffer[1:17]...)   // 16 bytes
 	this.requestBodyKey = append([]byte(nil), buffer[17:33]...) // 16 bytes
 	this.responseHeader = buffer[33]                            // 1 byte
-	request.Option = protocol.RequestOption(buffer[34])         // 1 byte + 2 bytes reserved
+	request.Option = protocol.RequestOption(buffer[34])         // 1 byte
+	request.Security = protocol.SecurityType(buffer[35])        // 1 byte + 1 byte reserved
 	request.Command = protocol.RequestCommand(buffer[37])
 
 	request.Port = v2net.PortFromBytes(buffer[38:40])
@@ -143,12 +151,29 @@ func (this *ServerSession) DecodeRequestHeader(reader io.Reader) (*protocol.Requ
 		return nil, transport.ErrCorruptedPacket
 	}
 
+	if request.Security > protocol.SecurityTypeNone {
+		log.Info("VMess: Unsupported security type ", request.Security)
+		return nil, ErrUnsupportedSecurity
+	}
+	if request.Security != protocol.SecurityTypeLegacy {
+		// Bodies are always chunked with security types other than the legacy one.
+		request.Option.Set(protocol.RequestOptionChunkStream)
+	}
+
 	return request, nil
 }
 
-func (this *ServerSession) DecodeRequestBody(reader io.Reader) io.Reader {
+// DecodeRequestBody returns a reader that decrypts the body of the request, as required by its security type.
+func (this *ServerSession) DecodeRequestBody(request *protocol.RequestHeader, reader io.Reader) v2io.Reader {
+	if aead := newBodyAEAD(request.Security, this.requestBodyKey); aead != nil {
+		return vmessio.NewAEADChunkReader(reader, aead, this.requestBodyIV)
+	}
 	aesStream := crypto.NewAesDecryptionStream(this.requestBodyKey, this.requestBodyIV)
-	return crypto.NewCryptionReader(aesStream, reader)
+	decryptReader := crypto.NewCryptionReader(aesStream, reader)
+	if request.Option.Has(protocol.RequestOptionChunkStream) {
+		return vmessio.NewAuthChunkReader(decryptReader)
+	}
+	return v2io.NewAdaptiveReader(decryptReader)
 }
 
 func (this *ServerSession) EncodeResponseHeader(header *protocol.ResponseHeader, writer io.Writer) {
@@ -168,6 +193,14 @@ func (this *ServerSession) EncodeResponseHeader(header *protocol.ResponseHeader,
 	}
 }
 
-func (this *ServerSession) EncodeResponseBody(writer io.Writer) io.Writer {
-	return this.responseWriter
+// EncodeResponseBody returns a writer that encrypts the body of the response to the request.
+func (this *ServerSession) EncodeResponseBody(request *protocol.RequestHeader, writer io.Writer) v2io.Writer {
+	if aead := newBodyAEAD(request.Security, this.responseBodyKey); aead != nil {
+		return vmessio.NewAEADChunkWriter(v2io.NewAdaptiveWriter(writer), aead, this.responseBodyIV)
+	}
+	var bodyWriter v2io.Writer = v2io.NewAdaptiveWriter(this.responseWriter)
+	if request.Option.Has(protocol.RequestOptionChunkStream) {
+		bodyWriter = vmessio.NewAuthChunkWriter(bodyWriter)
+	}
+	return bodyWriter
 }
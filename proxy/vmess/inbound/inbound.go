@@ -18,8 +18,9 @@ import (
 	"v2ray.com/core/proxy/registry"
 	"v2ray.com/core/proxy/vmess"
 	"v2ray.com/core/proxy/vmess/encoding"
-	vmessio "v2ray.com/core/proxy/vmess/io"
+	"v2ray.com/core/proxy/vmess/mux"
 	"v2ray.com/core/transport/internet"
+	"v2ray.com/core/transport/ray"
 
 	"github.com/golang/protobuf/ptypes"
 )
@@ -70,6 +71,20 @@ func (this *userByEmail) Get(email string) (*protocol.User, bool) {
 	return user, found
 }
 
+// Put adds the user, or replaces the existing user with the same email.
+func (this *userByEmail) Put(user *protocol.User) {
+	this.Lock()
+	this.cache[user.Email] = user
+	this.Unlock()
+}
+
+// Delete removes the user of the email.
+func (this *userByEmail) Delete(email string) {
+	this.Lock()
+	delete(this.cache, email)
+	this.Unlock()
+}
+
 // Inbound connection handler that handles messages in VMess format.
 type VMessInboundHandler struct {
 	sync.RWMutex
@@ -114,6 +129,53 @@ func (this *VMessInboundHandler) GetUser(email string) *protocol.User {
 	return user
 }
 
+// AddUser implements proxy.UserManager. It adds the user, or replaces the existing user with the same email. The user
+// must have an email, so that it can be updated or removed later.
+func (this *VMessInboundHandler) AddUser(user *protocol.User) error {
+	if user == nil {
+		return protocol.ErrUserMissing
+	}
+	if len(user.Email) == 0 {
+		return protocol.ErrEmailMissing
+	}
+	// Validate the account before the existing user is removed.
+	if _, err := user.GetTypedAccount(&vmess.AccountPB{}); err != nil {
+		return err
+	}
+
+	this.Lock()
+	defer this.Unlock()
+
+	if this.clients == nil {
+		return proxy.ErrHandlerClosed
+	}
+	this.clients.Remove(user.Email)
+	if err := this.clients.Add(user); err != nil {
+		return err
+	}
+	this.usersByEmail.Put(user)
+	return nil
+}
+
+// RemoveUser implements proxy.UserManager. Connections already established by the user are not affected.
+func (this *VMessInboundHandler) RemoveUser(email string) error {
+	if len(email) == 0 {
+		return protocol.ErrEmailMissing
+	}
+
+	this.Lock()
+	defer this.Unlock()
+
+	if this.clients == nil {
+		return proxy.ErrHandlerClosed
+	}
+	this.usersByEmail.Delete(email)
+	if !this.clients.Remove(email) {
+		return protocol.ErrUserMissing
+	}
+	return nil
+}
+
 func (this *VMessInboundHandler) Start() error {
 	if this.accepting {
 		return nil
@@ -163,6 +225,12 @@ func (this *VMessInboundHandler) HandleConnection(connection internet.Connection
 		connection.SetReusable(false)
 		return
 	}
+
+	if request.Command == protocol.RequestCommandMux {
+		this.handleMux(connection, connReader, reader, session, request)
+		return
+	}
+
 	log.Access(connection.RemoteAddr(), request.Destination(), log.AccessAccepted, "")
 	log.Info("VMessIn: Received request for ", request.Destination())
 
@@ -185,13 +253,7 @@ func (this *VMessInboundHandler) HandleConnection(connection internet.Connection
 	reader.SetCached(false)
 
 	go func() {
-		bodyReader := session.DecodeRequestBody(reader)
-		var requestReader v2io.Reader
-		if request.Option.Has(protocol.RequestOptionChunkStream) {
-			requestReader = vmessio.NewAuthChunkReader(bodyReader)
-		} else {
-			requestReader = v2io.NewAdaptiveReader(bodyReader)
-		}
+		requestReader := session.DecodeRequestBody(request, reader)
 		err := v2io.Pipe(requestReader, input)
 		if err != io.EOF {
 			connection.SetReusable(false)
@@ -215,11 +277,7 @@ func (this *VMessInboundHandler) HandleConnection(connection internet.Connection
 
 	session.EncodeResponseHeader(response, writer)
 
-	bodyWriter := session.EncodeResponseBody(writer)
-	var v2writer v2io.Writer = v2io.NewAdaptiveWriter(bodyWriter)
-	if request.Option.Has(protocol.RequestOptionChunkStream) {
-		v2writer = vmessio.NewAuthChunkWriter(v2writer)
-	}
+	v2writer := session.EncodeResponseBody(request, writer)
 
 	// Optimize for small response packet
 	if data, err := output.Read(); err == nil {
@@ -247,6 +305,42 @@ func (this *VMessInboundHandler) HandleConnection(connection internet.Connection
 	readFinish.Lock()
 }
 
+// handleMux serves a connection that carries multiple streams. Each stream is dispatched separately.
+func (this *VMessInboundHandler) handleMux(connection internet.Connection, connReader *v2net.TimeOutReader, reader *v2io.BufferedReader, session *encoding.ServerSession, request *protocol.RequestHeader) {
+	log.Info("VMessIn: Received multiplexed connection from ", connection.RemoteAddr())
+	connection.SetReusable(false)
+
+	userSettings := request.User.GetSettings()
+	connReader.SetTimeOut(userSettings.PayloadReadTimeout)
+	reader.SetCached(false)
+
+	writer := v2io.NewBufferedWriter(connection)
+	defer writer.Release()
+
+	session.EncodeResponseHeader(&protocol.ResponseHeader{}, writer)
+	bodyWriter := session.EncodeResponseBody(request, writer)
+	if err := writer.Flush(); err != nil {
+		log.Warning("VMessIn: Failed to write response to ", connection.RemoteAddr(), ": ", err)
+		bodyWriter.Release()
+		return
+	}
+	writer.SetCached(false)
+
+	source := v2net.DestinationFromAddr(connection.RemoteAddr())
+	bodyReader := session.DecodeRequestBody(request, reader)
+	mux.Serve(bodyReader, bodyWriter, func(destination v2net.Destination) ray.InboundRay {
+		log.Access(connection.RemoteAddr(), destination, log.AccessAccepted, "")
+		return this.packetDispatcher.DispatchToOutbound(this.meta, &proxy.SessionInfo{
+			Source:      source,
+			Destination: destination,
+		})
+	})
+	bodyReader.Release()
+
+	bodyWriter.Write(alloc.NewLocalBuffer(32).Clear())
+	bodyWriter.Release()
+}
+
 type Factory struct{}
 
 func (this *Factory) StreamCapability() v2net.NetworkList {
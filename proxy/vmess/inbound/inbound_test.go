@@ -0,0 +1,187 @@
+package inbound_test
+
+import (
+	"testing"
+
+	"v2ray.com/core/app"
+	"v2ray.com/core/app/dispatcher"
+	dispatchers "v2ray.com/core/app/dispatcher/impl"
+	"v2ray.com/core/app/proxyman"
+	"v2ray.com/core/common/alloc"
+	"v2ray.com/core/common/dice"
+	v2net "v2ray.com/core/common/net"
+	"v2ray.com/core/common/protocol"
+	"v2ray.com/core/common/uuid"
+	"v2ray.com/core/proxy"
+	"v2ray.com/core/proxy/freedom"
+	"v2ray.com/core/proxy/vmess"
+	. "v2ray.com/core/proxy/vmess/inbound"
+	"v2ray.com/core/proxy/vmess/outbound"
+	"v2ray.com/core/testing/assert"
+	"v2ray.com/core/testing/servers/tcp"
+	"v2ray.com/core/transport/internet"
+	"v2ray.com/core/transport/ray"
+
+	"github.com/golang/protobuf/ptypes"
+)
+
+func newUser(assert *assert.Assert, email string) *protocol.User {
+	account, err := ptypes.MarshalAny(&vmess.AccountPB{
+		Id: uuid.New().String(),
+	})
+	assert.Error(err).IsNil()
+	return &protocol.User{
+		Email:   email,
+		Account: account,
+	}
+}
+
+// startVMessServer starts a VMess inbound with the users, which sends traffic out directly.
+func startVMessServer(assert *assert.Assert, users ...*protocol.User) (v2net.Port, proxy.InboundHandler) {
+	space := app.NewSpace()
+	space.BindApp(dispatcher.APP_ID, dispatchers.NewDefaultDispatcher(space))
+	ohm := proxyman.NewDefaultOutboundHandlerManager()
+	ohm.SetDefaultHandler(
+		freedom.NewFreedomConnection(
+			&freedom.Config{},
+			space,
+			&proxy.OutboundHandlerMeta{
+				Address: v2net.AnyIP,
+				StreamSettings: &internet.StreamConfig{
+					Network: v2net.Network_RawTCP,
+				},
+			}))
+	space.BindApp(proxyman.APP_ID_OUTBOUND_MANAGER, ohm)
+
+	port := v2net.Port(dice.Roll(20000) + 10000)
+	server, err := new(Factory).Create(space, &Config{
+		User:    users,
+		Default: &DefaultConfig{},
+	}, &proxy.InboundHandlerMeta{
+		Address: v2net.LocalHostIP,
+		Port:    port,
+		StreamSettings: &internet.StreamConfig{
+			Network: v2net.Network_TCP,
+		},
+	})
+	assert.Error(err).IsNil()
+	assert.Error(space.Initialize()).IsNil()
+	assert.Error(server.Start()).IsNil()
+	return port, server
+}
+
+// sendThrough sends the data to the destination through the VMess inbound on the port, and returns the response.
+func sendThrough(assert *assert.Assert, port v2net.Port, user *protocol.User, dest v2net.Destination, data string) (*alloc.Buffer, error) {
+	client, err := new(outbound.Factory).Create(app.NewSpace(), &outbound.Config{
+		Receiver: []*protocol.ServerSpecPB{{
+			Address: v2net.NewAddressPB(v2net.LocalHostIP),
+			Port:    uint32(port),
+			User:    []*protocol.User{user},
+		}},
+	}, &proxy.OutboundHandlerMeta{
+		Address: v2net.AnyIP,
+		StreamSettings: &internet.StreamConfig{
+			Network: v2net.Network_TCP,
+		},
+	})
+	assert.Error(err).IsNil()
+
+	traffic := ray.NewRay()
+	go client.Dispatch(dest, alloc.NewLocalBuffer(2048).Clear().AppendString(data), traffic)
+	traffic.InboundInput().Close()
+	return traffic.InboundOutput().Read()
+}
+
+func TestVMessAddRemoveUser(t *testing.T) {
+	assert := assert.On(t)
+
+	tcpServer := &tcp.Server{
+		MsgProcessor: func(data []byte) []byte {
+			return append([]byte("Processed: "), data...)
+		},
+	}
+	dest, err := tcpServer.Start()
+	assert.Error(err).IsNil()
+	defer tcpServer.Close()
+
+	port, server := startVMessServer(assert, newUser(assert, "love@v2ray.com"))
+	userManager := server.(proxy.UserManager)
+
+	user := newUser(assert, "live@v2ray.com")
+	assert.Error(userManager.AddUser(user)).IsNil()
+	response, err := sendThrough(assert, port, user, dest, "Data from a user added at runtime")
+	assert.Error(err).IsNil()
+	assert.String(response.String()).Equals("Processed: Data from a user added at runtime")
+
+	// Adding a user with the same email replaces the previous one.
+	updatedUser := newUser(assert, "live@v2ray.com")
+	assert.Error(userManager.AddUser(updatedUser)).IsNil()
+	_, err = sendThrough(assert, port, user, dest, "Data from a replaced user")
+	assert.Error(err).IsNotNil()
+	response, err = sendThrough(assert, port, updatedUser, dest, "Data from an updated user")
+	assert.Error(err).IsNil()
+	assert.String(response.String()).Equals("Processed: Data from an updated user")
+
+	assert.Error(userManager.RemoveUser(user.Email)).IsNil()
+	assert.Error(userManager.RemoveUser(user.Email)).Equals(protocol.ErrUserMissing)
+	_, err = sendThrough(assert, port, updatedUser, dest, "Data from a removed user")
+	assert.Error(err).IsNotNil()
+}
+
+func TestVMessDuplicateEmailInConfig(t *testing.T) {
+	assert := assert.On(t)
+
+	tcpServer := &tcp.Server{
+		MsgProcessor: func(data []byte) []byte {
+			return append([]byte("Processed: "), data...)
+		},
+	}
+	dest, err := tcpServer.Start()
+	assert.Error(err).IsNil()
+	defer tcpServer.Close()
+
+	user1 := newUser(assert, "love@v2ray.com")
+	user2 := newUser(assert, "love@v2ray.com")
+	port, _ := startVMessServer(assert, user1, user2)
+
+	for _, user := range []*protocol.User{user1, user2} {
+		response, err := sendThrough(assert, port, user, dest, "Data from a configured user")
+		assert.Error(err).IsNil()
+		assert.String(response.String()).Equals("Processed: Data from a configured user")
+	}
+}
+
+func TestVMessAddUserAfterClose(t *testing.T) {
+	assert := assert.On(t)
+
+	_, server := startVMessServer(assert, newUser(assert, "love@v2ray.com"))
+	server.Close()
+
+	assert.Error(server.(proxy.UserManager).AddUser(newUser(assert, "live@v2ray.com"))).Equals(proxy.ErrHandlerClosed)
+}
+
+func TestVMessUserWithoutEmail(t *testing.T) {
+	assert := assert.On(t)
+
+	tcpServer := &tcp.Server{
+		MsgProcessor: func(data []byte) []byte {
+			return append([]byte("Processed: "), data...)
+		},
+	}
+	dest, err := tcpServer.Start()
+	assert.Error(err).IsNil()
+	defer tcpServer.Close()
+
+	// A configured user without email is never removed.
+	user := newUser(assert, "")
+	port, server := startVMessServer(assert, user)
+	defer server.Close()
+	userManager := server.(proxy.UserManager)
+
+	assert.Error(userManager.AddUser(newUser(assert, ""))).Equals(protocol.ErrEmailMissing)
+	assert.Error(userManager.RemoveUser("")).Equals(protocol.ErrEmailMissing)
+
+	response, err := sendThrough(assert, port, user, dest, "Data from a user without email")
+	assert.Error(err).IsNil()
+	assert.String(response.String()).Equals("Processed: Data from a user without email")
+}
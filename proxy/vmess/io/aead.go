@@ -0,0 +1,146 @@
+package io
+
+import (
+	"crypto/cipher"
+	"io"
+
+	"v2ray.com/core/common/alloc"
+	v2io "v2ray.com/core/common/io"
+	"v2ray.com/core/common/serial"
+	"v2ray.com/core/transport"
+)
+
+const (
+	// maxChunkSize is the maximum size of content in one chunk.
+	maxChunkSize = 16 * 1024
+)
+
+// chunkNonce generates the nonce of each chunk. The first 2 bytes are the count of the chunk, and the rest comes from
+// the IV.
+type chunkNonce struct {
+	nonce []byte
+	count uint16
+}
+
+func newChunkNonce(size int, iv []byte) *chunkNonce {
+	nonce := make([]byte, size)
+	copy(nonce[2:], iv[2:])
+	return &chunkNonce{
+		nonce: nonce,
+	}
+}
+
+func (this *chunkNonce) Next() []byte {
+	serial.Uint16ToBytes(this.count, this.nonce[:0])
+	this.count++
+	return this.nonce
+}
+
+// NoOpAuthenticator is an AEAD that neither encrypts nor authenticates.
+type NoOpAuthenticator struct{}
+
+func (NoOpAuthenticator) NonceSize() int { return 12 }
+func (NoOpAuthenticator) Overhead() int  { return 0 }
+
+func (NoOpAuthenticator) Seal(dst, nonce, plaintext, additionalData []byte) []byte {
+	return append(dst, plaintext...)
+}
+
+func (NoOpAuthenticator) Open(dst, nonce, ciphertext, additionalData []byte) ([]byte, error) {
+	return append(dst, ciphertext...), nil
+}
+
+// AEADChunkWriter writes each buffer as a chunk, which consists of a 2-byte length and the sealed content. An empty
+// buffer marks the end of the stream.
+type AEADChunkWriter struct {
+	writer v2io.Writer
+	aead   cipher.AEAD
+	nonce  *chunkNonce
+}
+
+func NewAEADChunkWriter(writer v2io.Writer, aead cipher.AEAD, iv []byte) *AEADChunkWriter {
+	return &AEADChunkWriter{
+		writer: writer,
+		aead:   aead,
+		nonce:  newChunkNonce(aead.NonceSize(), iv),
+	}
+}
+
+func (this *AEADChunkWriter) Write(buffer *alloc.Buffer) error {
+	defer buffer.Release()
+
+	content := buffer.Value
+	for {
+		size := len(content)
+		if size > maxChunkSize {
+			size = maxChunkSize
+		}
+		if err := this.writeChunk(content[:size]); err != nil {
+			return err
+		}
+		content = content[size:]
+		if len(content) == 0 {
+			return nil
+		}
+	}
+}
+
+func (this *AEADChunkWriter) writeChunk(content []byte) error {
+	chunk := alloc.NewBufferWithSize(2 + len(content) + this.aead.Overhead()).Clear()
+	sealed := this.aead.Seal(chunk.Value[2:2], this.nonce.Next(), content, nil)
+	chunk.AppendUint16(uint16(len(sealed)))
+	chunk.Value = chunk.Value[:2+len(sealed)]
+	return this.writer.Write(chunk)
+}
+
+func (this *AEADChunkWriter) Release() {
+	this.writer.Release()
+	this.writer = nil
+}
+
+// AEADChunkReader reads chunks written by AEADChunkWriter.
+type AEADChunkReader struct {
+	reader io.Reader
+	aead   cipher.AEAD
+	nonce  *chunkNonce
+}
+
+func NewAEADChunkReader(reader io.Reader, aead cipher.AEAD, iv []byte) *AEADChunkReader {
+	return &AEADChunkReader{
+		reader: reader,
+		aead:   aead,
+		nonce:  newChunkNonce(aead.NonceSize(), iv),
+	}
+}
+
+func (this *AEADChunkReader) Read() (*alloc.Buffer, error) {
+	var sizeBytes [2]byte
+	if _, err := io.ReadFull(this.reader, sizeBytes[:]); err != nil {
+		return nil, io.ErrUnexpectedEOF
+	}
+	size := int(serial.BytesToUint16(sizeBytes[:]))
+	if size < this.aead.Overhead() || size > maxChunkSize+this.aead.Overhead() {
+		return nil, transport.ErrCorruptedPacket
+	}
+
+	buffer := alloc.NewBufferWithSize(size)
+	if _, err := io.ReadFull(this.reader, buffer.Value[:size]); err != nil {
+		buffer.Release()
+		return nil, io.ErrUnexpectedEOF
+	}
+	content, err := this.aead.Open(buffer.Value[:0], this.nonce.Next(), buffer.Value[:size], nil)
+	if err != nil {
+		buffer.Release()
+		return nil, transport.ErrCorruptedPacket
+	}
+	if len(content) == 0 {
+		buffer.Release()
+		return nil, io.EOF
+	}
+	buffer.Value = content
+	return buffer, nil
+}
+
+func (this *AEADChunkReader) Release() {
+	this.reader = nil
+}
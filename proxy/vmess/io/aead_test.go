@@ -0,0 +1,79 @@
+package io_test
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"io"
+	"testing"
+
+	"v2ray.com/core/common/alloc"
+	v2io "v2ray.com/core/common/io"
+	. "v2ray.com/core/proxy/vmess/io"
+	"v2ray.com/core/testing/assert"
+	"v2ray.com/core/transport"
+)
+
+func newTestAEAD(assert *assert.Assert) cipher.AEAD {
+	key := make([]byte, 16)
+	rand.Read(key)
+	block, err := aes.NewCipher(key)
+	assert.Error(err).IsNil()
+	aead, err := cipher.NewGCM(block)
+	assert.Error(err).IsNil()
+	return aead
+}
+
+func testAEADChunkIO(assert *assert.Assert, aead cipher.AEAD) {
+	iv := make([]byte, 16)
+	rand.Read(iv)
+
+	content := make([]byte, 100*1024)
+	rand.Read(content)
+
+	chunkContent := bytes.NewBuffer(make([]byte, 0, len(content)*2))
+	writer := NewAEADChunkWriter(v2io.NewAdaptiveWriter(chunkContent), aead, iv)
+	assert.Error(writer.Write(alloc.NewBuffer().Clear().Append(content[:1024]))).IsNil()
+	assert.Error(writer.Write(alloc.NewLargeBuffer().Clear().Append(content[1024 : 1024+60*1024]))).IsNil()
+	assert.Error(writer.Write(alloc.NewLargeBuffer().Clear().Append(content[1024+60*1024:]))).IsNil()
+	assert.Error(writer.Write(alloc.NewBuffer().Clear())).IsNil()
+	writer.Release()
+
+	actualContent := make([]byte, 0, len(content))
+	reader := NewAEADChunkReader(chunkContent, aead, iv)
+	for {
+		buffer, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		assert.Error(err).IsNil()
+		actualContent = append(actualContent, buffer.Value...)
+		buffer.Release()
+	}
+	assert.Bytes(actualContent).Equals(content)
+}
+
+func TestAEADChunkIO(t *testing.T) {
+	assert := assert.On(t)
+
+	testAEADChunkIO(assert, newTestAEAD(assert))
+	testAEADChunkIO(assert, NoOpAuthenticator{})
+}
+
+func TestAEADChunkTampered(t *testing.T) {
+	assert := assert.On(t)
+
+	aead := newTestAEAD(assert)
+	iv := make([]byte, 16)
+
+	chunkContent := bytes.NewBuffer(make([]byte, 0, 1024))
+	writer := NewAEADChunkWriter(v2io.NewAdaptiveWriter(chunkContent), aead, iv)
+	assert.Error(writer.Write(alloc.NewBuffer().Clear().AppendString("abcd"))).IsNil()
+
+	chunk := chunkContent.Bytes()
+	chunk[3] ^= 1
+
+	_, err := NewAEADChunkReader(chunkContent, aead, iv).Read()
+	assert.Error(err).Equals(transport.ErrCorruptedPacket)
+}
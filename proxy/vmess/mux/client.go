@@ -0,0 +1,189 @@
+package mux
+
+import (
+	"sync"
+	"time"
+
+	"v2ray.com/core/common/alloc"
+	v2io "v2ray.com/core/common/io"
+	"v2ray.com/core/common/log"
+	v2net "v2ray.com/core/common/net"
+	"v2ray.com/core/transport/ray"
+)
+
+const (
+	// DefaultConcurrency is the default number of streams in one connection.
+	DefaultConcurrency = 8
+	// MaxConcurrency is the maximum number of streams in one connection.
+	MaxConcurrency = 128
+
+	// idleTimeout is how long a client connection is kept open without any stream.
+	idleTimeout = 16 * time.Second
+)
+
+// Client opens streams over one connection to a multiplexing server.
+type Client struct {
+	worker      *worker
+	concurrency int
+	onClose     func()
+	closeOnce   sync.Once
+
+	access     sync.Mutex
+	nextID     uint16
+	lastActive time.Time
+}
+
+// NewClient creates a Client that writes to the connection through the writer. onClose is called once when the
+// Client is closed, in order to close the connection.
+func NewClient(writer v2io.Writer, concurrency int, onClose func()) *Client {
+	if concurrency <= 0 {
+		concurrency = DefaultConcurrency
+	}
+	if concurrency > MaxConcurrency {
+		concurrency = MaxConcurrency
+	}
+	client := &Client{
+		worker:      newWorker(writer),
+		concurrency: concurrency,
+		onClose:     onClose,
+		lastActive:  time.Now(),
+	}
+	client.worker.onRemove = client.onStreamRemoved
+	return client
+}
+
+// Run reads responses from the connection until it ends. The Client is closed afterwards.
+func (this *Client) Run(reader v2io.Reader) {
+	chanReader := v2io.NewChanReader(reader)
+	this.worker.run(chanReader)
+	chanReader.Release()
+	this.Close()
+}
+
+// Closed returns true if the Client can't open any more streams.
+func (this *Client) Closed() bool {
+	return this.worker.isClosed()
+}
+
+// Full returns true if the Client has reached its limit of streams.
+func (this *Client) Full() bool {
+	return this.worker.size() >= this.concurrency
+}
+
+// Dispatch opens a stream to the destination, and relays the traffic of the ray over it until the stream ends. It
+// returns false without touching the ray if the Client is closed or full.
+func (this *Client) Dispatch(destination v2net.Destination, payload *alloc.Buffer, ray ray.OutboundRay) bool {
+	this.access.Lock()
+	var s *stream
+	for i := 0; i < MaxConcurrency+1; i++ {
+		this.nextID++
+		if this.worker.get(this.nextID) == nil {
+			s = newStream(this.nextID, destination, this.worker, ray.OutboundInput(), ray.OutboundOutput())
+			break
+		}
+	}
+	if s == nil || !this.worker.add(s, this.concurrency) {
+		this.access.Unlock()
+		return false
+	}
+	this.access.Unlock()
+
+	log.Info("Mux|Client: Opening stream ", s.id, " to ", destination)
+	s.start(&frameMetadata{
+		streamID:    s.id,
+		status:      statusNew,
+		destination: destination,
+	}, payload)
+	<-s.done
+	return true
+}
+
+func (this *Client) onStreamRemoved() {
+	if this.worker.size() > 0 {
+		return
+	}
+	this.access.Lock()
+	this.lastActive = time.Now()
+	this.access.Unlock()
+
+	time.AfterFunc(idleTimeout, func() {
+		// Holding the lock, so that no stream is added while closing.
+		this.access.Lock()
+		defer this.access.Unlock()
+		if time.Since(this.lastActive) >= idleTimeout && this.worker.size() == 0 {
+			log.Info("Mux|Client: Closing idle connection.")
+			this.Close()
+		}
+	})
+}
+
+// Close closes all streams and the connection.
+func (this *Client) Close() {
+	this.closeOnce.Do(func() {
+		this.worker.close()
+		if this.onClose != nil {
+			this.onClose()
+		}
+	})
+}
+
+// ClientManager keeps a set of Clients, and opens new connections when all of them are full.
+type ClientManager struct {
+	access  sync.Mutex
+	clients []*Client
+	dial    func() (*Client, error)
+}
+
+// NewClientManager creates a ClientManager that opens new connections with dial.
+func NewClientManager(dial func() (*Client, error)) *ClientManager {
+	return &ClientManager{
+		dial: dial,
+	}
+}
+
+func (this *ClientManager) pick() *Client {
+	this.access.Lock()
+	defer this.access.Unlock()
+
+	clients := this.clients[:0]
+	var picked *Client
+	for _, client := range this.clients {
+		if client.Closed() {
+			continue
+		}
+		clients = append(clients, client)
+		if picked == nil && !client.Full() {
+			picked = client
+		}
+	}
+	for i := len(clients); i < len(this.clients); i++ {
+		this.clients[i] = nil
+	}
+	this.clients = clients
+	return picked
+}
+
+// Dispatch relays the traffic of the ray over a stream of any available connection.
+func (this *ClientManager) Dispatch(destination v2net.Destination, payload *alloc.Buffer, ray ray.OutboundRay) error {
+	for {
+		client := this.pick()
+		if client == nil {
+			break
+		}
+		if client.Dispatch(destination, payload, ray) {
+			return nil
+		}
+	}
+
+	client, err := this.dial()
+	if err != nil {
+		return err
+	}
+	this.access.Lock()
+	this.clients = append(this.clients, client)
+	this.access.Unlock()
+	if !client.Dispatch(destination, payload, ray) {
+		return ErrClosed
+	}
+	return nil
+}
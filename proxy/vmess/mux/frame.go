@@ -0,0 +1,192 @@
+package mux
+
+import (
+	"io"
+
+	"v2ray.com/core/common/alloc"
+	v2io "v2ray.com/core/common/io"
+	v2net "v2ray.com/core/common/net"
+	"v2ray.com/core/common/serial"
+	"v2ray.com/core/transport"
+)
+
+const (
+	statusNew    = byte(0x01)
+	statusKeep   = byte(0x02)
+	statusEnd    = byte(0x03)
+	statusWindow = byte(0x04)
+
+	optionData = byte(0x01)
+
+	networkTCP = byte(0x01)
+	networkUDP = byte(0x02)
+
+	addrTypeIPv4   = byte(0x01)
+	addrTypeDomain = byte(0x02)
+	addrTypeIPv6   = byte(0x03)
+
+	maxMetadataSize = 512
+)
+
+// frameMetadata is the header of a frame. A frame consists of a 2-byte length of the metadata, the metadata, and
+// optionally a 2-byte length of data and the data.
+//
+// The metadata consists of a 2-byte stream ID, a 1-byte status and a 1-byte option. A new stream carries its
+// destination afterwards, and a window update carries the 4-byte number of bytes consumed.
+type frameMetadata struct {
+	streamID    uint16
+	status      byte
+	option      byte
+	destination v2net.Destination
+	window      uint32
+}
+
+func (this *frameMetadata) bytes() []byte {
+	b := make([]byte, 2, 64)
+	b = serial.Uint16ToBytes(this.streamID, b)
+	b = append(b, this.status, this.option)
+
+	switch this.status {
+	case statusNew:
+		if this.destination.Network == v2net.Network_UDP {
+			b = append(b, networkUDP)
+		} else {
+			b = append(b, networkTCP)
+		}
+		b = this.destination.Port.Bytes(b)
+		address := this.destination.Address
+		switch address.Family() {
+		case v2net.AddressFamilyIPv4:
+			b = append(b, addrTypeIPv4)
+			b = append(b, address.IP()...)
+		case v2net.AddressFamilyIPv6:
+			b = append(b, addrTypeIPv6)
+			b = append(b, address.IP()...)
+		case v2net.AddressFamilyDomain:
+			b = append(b, addrTypeDomain, byte(len(address.Domain())))
+			b = append(b, address.Domain()...)
+		}
+	case statusWindow:
+		b = serial.Uint32ToBytes(this.window, b)
+	}
+
+	serial.Uint16ToBytes(uint16(len(b)-2), b[:0])
+	return b
+}
+
+func parseMetadata(b []byte) (*frameMetadata, error) {
+	if len(b) < 4 {
+		return nil, transport.ErrCorruptedPacket
+	}
+	meta := &frameMetadata{
+		streamID: serial.BytesToUint16(b[:2]),
+		status:   b[2],
+		option:   b[3],
+	}
+	b = b[4:]
+
+	switch meta.status {
+	case statusNew:
+		if len(b) < 4 {
+			return nil, transport.ErrCorruptedPacket
+		}
+		network := b[0]
+		port := v2net.PortFromBytes(b[1:3])
+		addrType := b[3]
+		b = b[4:]
+
+		var address v2net.Address
+		switch addrType {
+		case addrTypeIPv4:
+			if len(b) < 4 {
+				return nil, transport.ErrCorruptedPacket
+			}
+			address = v2net.IPAddress(b[:4])
+		case addrTypeIPv6:
+			if len(b) < 16 {
+				return nil, transport.ErrCorruptedPacket
+			}
+			address = v2net.IPAddress(b[:16])
+		case addrTypeDomain:
+			if len(b) < 1 || len(b) < 1+int(b[0]) {
+				return nil, transport.ErrCorruptedPacket
+			}
+			address = v2net.DomainAddress(string(b[1 : 1+int(b[0])]))
+		default:
+			return nil, transport.ErrCorruptedPacket
+		}
+
+		if network == networkUDP {
+			meta.destination = v2net.UDPDestination(address, port)
+		} else {
+			meta.destination = v2net.TCPDestination(address, port)
+		}
+	case statusWindow:
+		if len(b) < 4 {
+			return nil, transport.ErrCorruptedPacket
+		}
+		meta.window = serial.BytesToUint32(b[:4])
+	}
+	return meta, nil
+}
+
+// writeFrame writes the metadata and the data, if not nil, as one frame. It takes ownership of the data.
+func writeFrame(writer v2io.Writer, meta *frameMetadata, data *alloc.Buffer) error {
+	if data == nil {
+		return writer.Write(alloc.NewSmallBuffer().Clear().Append(meta.bytes()))
+	}
+
+	meta.option |= optionData
+	header := serial.Uint16ToBytes(uint16(data.Len()), meta.bytes())
+	if len(header)+data.Len() > alloc.LargeBufferSize {
+		if err := writer.Write(alloc.NewSmallBuffer().Clear().Append(header)); err != nil {
+			data.Release()
+			return err
+		}
+		return writer.Write(data)
+	}
+
+	frame := alloc.NewBufferWithSize(len(header) + data.Len()).Clear()
+	frame.Append(header).Append(data.Value)
+	data.Release()
+	return writer.Write(frame)
+}
+
+// readFrame reads a frame. The data is nil if the frame carries no data.
+func readFrame(reader io.Reader) (*frameMetadata, *alloc.Buffer, error) {
+	var sizeBytes [2]byte
+	if _, err := io.ReadFull(reader, sizeBytes[:]); err != nil {
+		return nil, nil, err
+	}
+	size := int(serial.BytesToUint16(sizeBytes[:]))
+	if size > maxMetadataSize {
+		return nil, nil, transport.ErrCorruptedPacket
+	}
+
+	metaBytes := make([]byte, size)
+	if _, err := io.ReadFull(reader, metaBytes); err != nil {
+		return nil, nil, err
+	}
+	meta, err := parseMetadata(metaBytes)
+	if err != nil {
+		return nil, nil, err
+	}
+	if meta.option&optionData == 0 {
+		return meta, nil, nil
+	}
+
+	if _, err := io.ReadFull(reader, sizeBytes[:]); err != nil {
+		return nil, nil, err
+	}
+	size = int(serial.BytesToUint16(sizeBytes[:]))
+	if size > alloc.LargeBufferSize {
+		return nil, nil, transport.ErrCorruptedPacket
+	}
+	data := alloc.NewBufferWithSize(size)
+	if _, err := io.ReadFull(reader, data.Value[:size]); err != nil {
+		data.Release()
+		return nil, nil, err
+	}
+	data.Value = data.Value[:size]
+	return meta, data, nil
+}
@@ -0,0 +1,56 @@
+package mux
+
+import (
+	"bytes"
+	"testing"
+
+	"v2ray.com/core/common/alloc"
+	v2io "v2ray.com/core/common/io"
+	v2net "v2ray.com/core/common/net"
+	"v2ray.com/core/testing/assert"
+)
+
+func TestFrameMetadata(t *testing.T) {
+	assert := assert.On(t)
+
+	for _, meta := range []*frameMetadata{
+		{streamID: 1, status: statusNew, destination: v2net.TCPDestination(v2net.DomainAddress("www.v2ray.com"), 443)},
+		{streamID: 2, status: statusNew, destination: v2net.UDPDestination(v2net.IPAddress([]byte{8, 8, 8, 8}), 53)},
+		{streamID: 3, status: statusNew, destination: v2net.TCPDestination(v2net.IPAddress(make([]byte, 16)), 80)},
+		{streamID: 4, status: statusKeep},
+		{streamID: 5, status: statusEnd},
+		{streamID: 6, status: statusWindow, window: 65536},
+	} {
+		b := meta.bytes()
+		actual, err := parseMetadata(b[2:])
+		assert.Error(err).IsNil()
+		assert.Uint16(actual.streamID).Equals(meta.streamID)
+		assert.Byte(actual.status).Equals(meta.status)
+		assert.Uint32(actual.window).Equals(meta.window)
+		if meta.status == statusNew {
+			assert.Destination(actual.destination).EqualsString(meta.destination.String())
+		}
+	}
+}
+
+func TestFrameData(t *testing.T) {
+	assert := assert.On(t)
+
+	content := bytes.NewBuffer(nil)
+	writer := v2io.NewAdaptiveWriter(content)
+
+	newStream := &frameMetadata{streamID: 1, status: statusNew, destination: v2net.TCPDestination(v2net.LocalHostIP, 80)}
+	assert.Error(writeFrame(writer, newStream, alloc.NewBuffer().Clear().AppendString("abcd"))).IsNil()
+	assert.Error(writeFrame(writer, &frameMetadata{streamID: 1, status: statusEnd}, nil)).IsNil()
+
+	meta, data, err := readFrame(content)
+	assert.Error(err).IsNil()
+	assert.Byte(meta.status).Equals(statusNew)
+	assert.Destination(meta.destination).EqualsString("tcp:127.0.0.1:80")
+	assert.String(data.String()).Equals("abcd")
+
+	meta, data, err = readFrame(content)
+	assert.Error(err).IsNil()
+	assert.Byte(meta.status).Equals(statusEnd)
+	assert.Bool(data == nil).IsTrue()
+}
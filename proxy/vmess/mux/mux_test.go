@@ -0,0 +1,154 @@
+package mux_test
+
+import (
+	"crypto/rand"
+	"sync"
+	"testing"
+
+	"v2ray.com/core/common/alloc"
+	v2net "v2ray.com/core/common/net"
+	. "v2ray.com/core/proxy/vmess/mux"
+	"v2ray.com/core/testing/assert"
+	"v2ray.com/core/transport/ray"
+)
+
+func echo(destination v2net.Destination) ray.InboundRay {
+	link := ray.NewRay()
+	go func() {
+		for {
+			payload, err := link.OutboundInput().Read()
+			if err != nil {
+				break
+			}
+			if err := link.OutboundOutput().Write(payload); err != nil {
+				break
+			}
+		}
+		link.OutboundOutput().Close()
+	}()
+	return link
+}
+
+// newTestClient creates a Client connected to a server that echos every stream.
+func newTestClient(concurrency int) *Client {
+	request := ray.NewStream()
+	response := ray.NewStream()
+	go Serve(request, response, echo)
+
+	client := NewClient(request, concurrency, func() {
+		request.Close()
+	})
+	go client.Run(response)
+	return client
+}
+
+func testStream(assert *assert.Assert, dispatch func(v2net.Destination, *alloc.Buffer, ray.OutboundRay), content []byte) {
+	link := ray.NewRay()
+	go dispatch(v2net.TCPDestination(v2net.LocalHostIP, 80), alloc.NewLocalBuffer(32).Clear(), link)
+
+	go func() {
+		for sent := 0; sent < len(content); {
+			size := len(content) - sent
+			if size > 8*1024 {
+				size = 8 * 1024
+			}
+			link.InboundInput().Write(alloc.NewBuffer().Clear().Append(content[sent : sent+size]))
+			sent += size
+		}
+		link.InboundInput().Close()
+	}()
+
+	received := make([]byte, 0, len(content))
+	for {
+		payload, err := link.InboundOutput().Read()
+		if err != nil {
+			break
+		}
+		received = append(received, payload.Value...)
+		payload.Release()
+	}
+	assert.Bytes(received).Equals(content)
+}
+
+func TestConcurrentStreams(t *testing.T) {
+	assert := assert.On(t)
+
+	client := newTestClient(8)
+	defer client.Close()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			// Larger than the window, so that window updates are needed.
+			content := make([]byte, 1024*1024)
+			rand.Read(content)
+			testStream(assert, func(dest v2net.Destination, payload *alloc.Buffer, link ray.OutboundRay) {
+				assert.Bool(client.Dispatch(dest, payload, link)).IsTrue()
+			}, content)
+		}()
+	}
+	wg.Wait()
+}
+
+func TestClientConcurrency(t *testing.T) {
+	assert := assert.On(t)
+
+	client := newTestClient(1)
+	defer client.Close()
+
+	link := ray.NewRay()
+	go client.Dispatch(v2net.TCPDestination(v2net.LocalHostIP, 80), alloc.NewLocalBuffer(32).Clear(), link)
+	link.InboundInput().Write(alloc.NewBuffer().Clear().AppendString("abcd"))
+	payload, err := link.InboundOutput().Read()
+	assert.Error(err).IsNil()
+	assert.String(payload.String()).Equals("abcd")
+
+	assert.Bool(client.Full()).IsTrue()
+	assert.Bool(client.Dispatch(v2net.TCPDestination(v2net.LocalHostIP, 80), alloc.NewLocalBuffer(32).Clear(), ray.NewRay())).IsFalse()
+
+	link.InboundInput().Close()
+	_, err = link.InboundOutput().Read()
+	assert.Error(err).IsNotNil()
+}
+
+func TestClientManager(t *testing.T) {
+	assert := assert.On(t)
+
+	var access sync.Mutex
+	var clients []*Client
+	manager := NewClientManager(func() (*Client, error) {
+		access.Lock()
+		defer access.Unlock()
+		client := newTestClient(2)
+		clients = append(clients, client)
+		return client, nil
+	})
+
+	links := make([]ray.Ray, 3)
+	for i := range links {
+		link := ray.NewRay()
+		links[i] = link
+		go manager.Dispatch(v2net.TCPDestination(v2net.LocalHostIP, 80), alloc.NewLocalBuffer(32).Clear(), link)
+
+		link.InboundInput().Write(alloc.NewBuffer().Clear().AppendString("abcd"))
+		payload, err := link.InboundOutput().Read()
+		assert.Error(err).IsNil()
+		assert.String(payload.String()).Equals("abcd")
+	}
+
+	access.Lock()
+	assert.Int(len(clients)).Equals(2)
+	access.Unlock()
+
+	for _, link := range links {
+		link.InboundInput().Close()
+		_, err := link.InboundOutput().Read()
+		assert.Error(err).IsNotNil()
+	}
+	for _, client := range clients {
+		client.Close()
+	}
+}
@@ -0,0 +1,32 @@
+package mux
+
+import (
+	v2io "v2ray.com/core/common/io"
+	"v2ray.com/core/common/log"
+	v2net "v2ray.com/core/common/net"
+	"v2ray.com/core/transport/ray"
+)
+
+// Serve reads streams from the reader, and dispatches each of them as a separate connection to its destination.
+// Responses are written through the writer. It returns after the reader ends and no more frames will be written.
+func Serve(reader v2io.Reader, writer v2io.Writer, dispatch func(destination v2net.Destination) ray.InboundRay) {
+	worker := newWorker(writer)
+	worker.onNewStream = func(meta *frameMetadata) *stream {
+		log.Info("Mux|Server: Received stream ", meta.streamID, " to ", meta.destination)
+		// The stream takes its place before dispatching, so that no connection is made for a refused stream.
+		s := newStream(meta.streamID, meta.destination, worker, nil, nil)
+		if !worker.add(s, MaxConcurrency) {
+			log.Warning("Mux|Server: Too many streams. Refusing stream ", meta.streamID)
+			return nil
+		}
+		link := dispatch(meta.destination)
+		s.input = link.InboundOutput()
+		s.output = link.InboundInput()
+		s.start(nil, nil)
+		return s
+	}
+
+	chanReader := v2io.NewChanReader(reader)
+	worker.run(chanReader)
+	chanReader.Release()
+}
@@ -0,0 +1,158 @@
+package mux
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"v2ray.com/core/common/alloc"
+	v2io "v2ray.com/core/common/io"
+	v2net "v2ray.com/core/common/net"
+	"v2ray.com/core/testing/assert"
+	"v2ray.com/core/transport/ray"
+)
+
+// discard returns a link that drops all data, and ends once its input ends.
+func discard(destination v2net.Destination) ray.InboundRay {
+	link := ray.NewRay()
+	go func() {
+		for {
+			payload, err := link.OutboundInput().Read()
+			if err != nil {
+				break
+			}
+			payload.Release()
+		}
+		link.OutboundOutput().Close()
+	}()
+	return link
+}
+
+// waitForEnd reads frames from the reader until the stream of the id is ended, and returns false on timeout.
+func waitForEnd(reader v2io.Reader, id uint16) bool {
+	ended := make(chan bool, 1)
+	go func() {
+		chanReader := v2io.NewChanReader(reader)
+		for {
+			meta, data, err := readFrame(chanReader)
+			if err != nil {
+				ended <- false
+				return
+			}
+			data.Release()
+			if meta.streamID == id && meta.status == statusEnd {
+				ended <- true
+				return
+			}
+		}
+	}()
+
+	select {
+	case result := <-ended:
+		return result
+	case <-time.After(5 * time.Second):
+		return false
+	}
+}
+
+func TestServerRefusesStreamsOverConcurrency(t *testing.T) {
+	assert := assert.On(t)
+
+	request := ray.NewStream()
+	response := ray.NewStream()
+	defer request.Close()
+
+	var dispatched int32
+	go Serve(request, response, func(destination v2net.Destination) ray.InboundRay {
+		atomic.AddInt32(&dispatched, 1)
+		return discard(destination)
+	})
+
+	for i := 1; i <= MaxConcurrency+1; i++ {
+		assert.Error(writeFrame(request, &frameMetadata{
+			streamID:    uint16(i),
+			status:      statusNew,
+			destination: v2net.TCPDestination(v2net.LocalHostIP, 80),
+		}, nil)).IsNil()
+	}
+
+	assert.Bool(waitForEnd(response, MaxConcurrency+1)).IsTrue()
+	assert.Int(int(atomic.LoadInt32(&dispatched))).Equals(MaxConcurrency)
+}
+
+func TestServerResetsStreamOverWindow(t *testing.T) {
+	assert := assert.On(t)
+
+	request := ray.NewStream()
+	response := ray.NewStream()
+	defer request.Close()
+
+	links := make(chan ray.Ray, 1)
+	go Serve(request, response, func(destination v2net.Destination) ray.InboundRay {
+		// Nothing is read from the link, so that data from the peer piles up.
+		link := ray.NewRay()
+		links <- link
+		return link
+	})
+
+	assert.Error(writeFrame(request, &frameMetadata{
+		streamID:    1,
+		status:      statusNew,
+		destination: v2net.TCPDestination(v2net.LocalHostIP, 80),
+	}, nil)).IsNil()
+	go func() {
+		// Far more than the window and what the link buffers, regardless of window updates.
+		for i := 0; i < 512; i++ {
+			if writeFrame(request, &frameMetadata{
+				streamID: 1,
+				status:   statusKeep,
+			}, alloc.NewBuffer().Clear().Append(make([]byte, alloc.BufferSize))) != nil {
+				return
+			}
+		}
+	}()
+
+	ended := waitForEnd(response, 1)
+	assert.Bool(ended).IsTrue()
+	if !ended {
+		return
+	}
+
+	link := <-links
+	received := 0
+	for {
+		payload, err := link.OutboundInput().Read()
+		if err != nil {
+			break
+		}
+		received += payload.Len()
+		payload.Release()
+	}
+	assert.Bool(received < 512*alloc.BufferSize).IsTrue()
+}
+
+func TestServerResetsDuplicateStream(t *testing.T) {
+	assert := assert.On(t)
+
+	request := ray.NewStream()
+	response := ray.NewStream()
+	defer request.Close()
+
+	var dispatched int32
+	go Serve(request, response, func(destination v2net.Destination) ray.InboundRay {
+		atomic.AddInt32(&dispatched, 1)
+		// The link never ends on its own, so the stream stays open until it is reset.
+		return ray.NewRay()
+	})
+
+	for i := 0; i < 2; i++ {
+		assert.Error(writeFrame(request, &frameMetadata{
+			streamID:    1,
+			status:      statusNew,
+			destination: v2net.TCPDestination(v2net.LocalHostIP, 80),
+		}, nil)).IsNil()
+	}
+
+	assert.Bool(waitForEnd(response, 1)).IsTrue()
+	assert.Int(int(atomic.LoadInt32(&dispatched))).Equals(1)
+}
@@ -0,0 +1,233 @@
+package mux
+
+import (
+	"sync"
+
+	"v2ray.com/core/common/alloc"
+	v2io "v2ray.com/core/common/io"
+	"v2ray.com/core/common/log"
+	v2net "v2ray.com/core/common/net"
+	"v2ray.com/core/transport/ray"
+)
+
+const (
+	// initialWindow is the number of bytes that may be sent on a stream before the peer consumes any of them.
+	initialWindow = 256 * 1024
+)
+
+// stream is a logical connection inside a multiplexed connection. Data read from input is sent to the peer, and data
+// from the peer is written into output.
+type stream struct {
+	id          uint16
+	destination v2net.Destination
+	worker      *worker
+	input       v2io.Reader
+	output      ray.OutputStream
+
+	// credit is the number of bytes allowed to be sent before the next window update.
+	creditCond *sync.Cond
+	credit     int
+	closed     bool
+
+	queueCond *sync.Cond
+	queue     []*alloc.Buffer
+	// received is the number of bytes from the peer that are not yet granted back by window updates.
+	received     int
+	remoteEnded  bool
+	outputFailed bool
+
+	done      chan bool
+	doneCount sync.WaitGroup
+}
+
+func newStream(id uint16, destination v2net.Destination, worker *worker, input v2io.Reader, output ray.OutputStream) *stream {
+	return &stream{
+		id:          id,
+		destination: destination,
+		worker:      worker,
+		input:       input,
+		output:      output,
+		creditCond:  sync.NewCond(new(sync.Mutex)),
+		credit:      initialWindow,
+		queueCond:   sync.NewCond(new(sync.Mutex)),
+		done:        make(chan bool),
+	}
+}
+
+// start starts sending and receiving. If first is not nil, it is sent as the first frame, along with the payload.
+func (this *stream) start(first *frameMetadata, payload *alloc.Buffer) {
+	this.doneCount.Add(2)
+	go this.pump(first, payload)
+	go this.deliver()
+	go func() {
+		this.doneCount.Wait()
+		this.worker.remove(this)
+		close(this.done)
+	}()
+}
+
+// waitForCredit blocks until there is credit to send, and returns false if the stream is closed.
+func (this *stream) waitForCredit() bool {
+	this.creditCond.L.Lock()
+	defer this.creditCond.L.Unlock()
+	for this.credit <= 0 && !this.closed {
+		this.creditCond.Wait()
+	}
+	return !this.closed
+}
+
+func (this *stream) consumeCredit(size int) {
+	this.creditCond.L.Lock()
+	this.credit -= size
+	this.creditCond.L.Unlock()
+}
+
+func (this *stream) addCredit(size int) {
+	this.creditCond.L.Lock()
+	this.credit += size
+	this.creditCond.L.Unlock()
+	this.creditCond.Broadcast()
+}
+
+// pump sends the input to the peer. A buffer is sent as a whole as long as there is any credit left, so that UDP
+// packets are never split.
+func (this *stream) pump(first *frameMetadata, payload *alloc.Buffer) {
+	defer this.doneCount.Done()
+	defer this.input.Release()
+
+	if first != nil {
+		if payload != nil && payload.IsEmpty() {
+			payload.Release()
+			payload = nil
+		}
+		if payload != nil {
+			this.consumeCredit(payload.Len())
+		}
+		if err := this.worker.writeFrame(first, payload); err != nil {
+			log.Info("Mux: Failed to open stream ", this.id, " to ", this.destination, ": ", err)
+			return
+		}
+	}
+
+	for {
+		data, err := this.input.Read()
+		if err != nil {
+			break
+		}
+		if !this.waitForCredit() {
+			data.Release()
+			return
+		}
+		this.consumeCredit(data.Len())
+		meta := &frameMetadata{
+			streamID: this.id,
+			status:   statusKeep,
+		}
+		if err := this.worker.writeFrame(meta, data); err != nil {
+			log.Info("Mux: Failed to write stream ", this.id, " to ", this.destination, ": ", err)
+			return
+		}
+	}
+
+	this.worker.writeFrame(&frameMetadata{
+		streamID: this.id,
+		status:   statusEnd,
+	}, nil)
+}
+
+// push queues data from the peer. It never blocks. A peer that keeps sending after using up its window gets the stream
+// reset, so that the queue is bounded by the window, plus one buffer.
+func (this *stream) push(data *alloc.Buffer) {
+	this.queueCond.L.Lock()
+	if this.remoteEnded {
+		this.queueCond.L.Unlock()
+		data.Release()
+		return
+	}
+	if this.received >= initialWindow {
+		this.queueCond.L.Unlock()
+		data.Release()
+		log.Warning("Mux: Stream ", this.id, " to ", this.destination, " exceeds the window. Resetting.")
+		this.reset()
+		return
+	}
+	this.received += data.Len()
+	this.queue = append(this.queue, data)
+	this.queueCond.L.Unlock()
+	this.queueCond.Signal()
+}
+
+// endRemote marks the end of data from the peer.
+func (this *stream) endRemote() {
+	this.queueCond.L.Lock()
+	this.remoteEnded = true
+	this.queueCond.L.Unlock()
+	this.queueCond.Signal()
+}
+
+// close stops the stream without waiting for the peer, e.g. when the connection is broken.
+func (this *stream) close() {
+	this.creditCond.L.Lock()
+	this.closed = true
+	this.creditCond.L.Unlock()
+	this.creditCond.Broadcast()
+	this.endRemote()
+}
+
+// reset drops the data queued from the peer, stops the stream and tells the peer to end it.
+func (this *stream) reset() {
+	this.queueCond.L.Lock()
+	for _, data := range this.queue {
+		data.Release()
+	}
+	this.queue = nil
+	this.queueCond.L.Unlock()
+
+	this.close()
+	this.worker.writeFrame(&frameMetadata{
+		streamID: this.id,
+		status:   statusEnd,
+	}, nil)
+}
+
+// deliver writes data from the peer into output, and grants the peer more window as the data is consumed.
+func (this *stream) deliver() {
+	defer this.doneCount.Done()
+	defer this.output.Close()
+
+	consumed := 0
+	for {
+		this.queueCond.L.Lock()
+		for len(this.queue) == 0 && !this.remoteEnded {
+			this.queueCond.Wait()
+		}
+		if len(this.queue) == 0 {
+			this.queueCond.L.Unlock()
+			return
+		}
+		data := this.queue[0]
+		this.queue[0] = nil
+		this.queue = this.queue[1:]
+		this.queueCond.L.Unlock()
+
+		size := data.Len()
+		if this.outputFailed {
+			data.Release()
+		} else if err := this.output.Write(data); err != nil {
+			this.outputFailed = true
+		}
+
+		consumed += size
+		if consumed >= initialWindow/4 {
+			this.queueCond.L.Lock()
+			this.received -= consumed
+			this.queueCond.L.Unlock()
+			this.worker.writeFrame(&frameMetadata{
+				streamID: this.id,
+				status:   statusWindow,
+				window:   uint32(consumed),
+			}, nil)
+			consumed = 0
+		}
+	}
+}
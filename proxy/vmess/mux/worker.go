@@ -0,0 +1,180 @@
+package mux
+
+import (
+	"errors"
+	"io"
+	"sync"
+
+	"v2ray.com/core/common/alloc"
+	v2io "v2ray.com/core/common/io"
+	"v2ray.com/core/common/log"
+)
+
+var (
+	ErrClosed = errors.New("Mux: Connection closed.")
+)
+
+// worker carries the streams of one multiplexed connection.
+type worker struct {
+	sync.Mutex
+	streams map[uint16]*stream
+	closed  bool
+
+	writeLock sync.Mutex
+	writer    v2io.Writer
+	// writerClosed is set once no more frames may be written.
+	writerClosed bool
+
+	// onNewStream is called when the peer opens a new stream. It returns nil if the stream is refused.
+	onNewStream func(meta *frameMetadata) *stream
+	// onRemove is called after a stream is removed.
+	onRemove func()
+}
+
+func newWorker(writer v2io.Writer) *worker {
+	return &worker{
+		streams: make(map[uint16]*stream),
+		writer:  writer,
+	}
+}
+
+func (this *worker) writeFrame(meta *frameMetadata, data *alloc.Buffer) error {
+	this.writeLock.Lock()
+	defer this.writeLock.Unlock()
+
+	if this.writerClosed {
+		data.Release()
+		return ErrClosed
+	}
+	return writeFrame(this.writer, meta, data)
+}
+
+func (this *worker) get(id uint16) *stream {
+	this.Lock()
+	defer this.Unlock()
+	return this.streams[id]
+}
+
+func (this *worker) remove(s *stream) {
+	this.Lock()
+	if this.streams[s.id] == s {
+		delete(this.streams, s.id)
+	}
+	this.Unlock()
+
+	if this.onRemove != nil {
+		this.onRemove()
+	}
+}
+
+// run reads frames from the peer until the reader ends or fails. The worker is closed afterwards.
+func (this *worker) run(reader io.Reader) {
+	defer this.close()
+
+	for {
+		meta, data, err := readFrame(reader)
+		if err != nil {
+			if err != io.EOF {
+				log.Info("Mux: Failed to read frame: ", err)
+			}
+			return
+		}
+
+		switch meta.status {
+		case statusNew:
+			if this.onNewStream == nil {
+				log.Warning("Mux: Unexpected new stream ", meta.streamID)
+				data.Release()
+				continue
+			}
+			if s := this.get(meta.streamID); s != nil {
+				// The peer no longer knows about the stream. Both ends drop it.
+				log.Warning("Mux: Stream ", meta.streamID, " is opened again. Resetting.")
+				data.Release()
+				s.reset()
+				continue
+			}
+			s := this.onNewStream(meta)
+			if s == nil {
+				data.Release()
+				this.writeFrame(&frameMetadata{
+					streamID: meta.streamID,
+					status:   statusEnd,
+				}, nil)
+				continue
+			}
+			if data != nil {
+				s.push(data)
+			}
+		case statusKeep:
+			if data == nil {
+				continue
+			}
+			if s := this.get(meta.streamID); s != nil {
+				s.push(data)
+			} else {
+				data.Release()
+			}
+		case statusEnd:
+			data.Release()
+			if s := this.get(meta.streamID); s != nil {
+				s.endRemote()
+			}
+		case statusWindow:
+			data.Release()
+			if s := this.get(meta.streamID); s != nil {
+				s.addCredit(int(meta.window))
+			}
+		default:
+			data.Release()
+			log.Info("Mux: Unknown frame status ", meta.status)
+		}
+	}
+}
+
+// add adds a new stream, unless the worker is closed or has reached the limit of streams.
+func (this *worker) add(s *stream, limit int) bool {
+	this.Lock()
+	defer this.Unlock()
+
+	if this.closed || (limit > 0 && len(this.streams) >= limit) {
+		return false
+	}
+	this.streams[s.id] = s
+	return true
+}
+
+// close closes all streams. No more frames are written afterwards.
+func (this *worker) close() {
+	this.Lock()
+	if this.closed {
+		this.Unlock()
+		return
+	}
+	this.closed = true
+	streams := make([]*stream, 0, len(this.streams))
+	for _, s := range this.streams {
+		streams = append(streams, s)
+	}
+	this.Unlock()
+
+	this.writeLock.Lock()
+	this.writerClosed = true
+	this.writeLock.Unlock()
+
+	for _, s := range streams {
+		s.close()
+	}
+}
+
+func (this *worker) isClosed() bool {
+	this.Lock()
+	defer this.Unlock()
+	return this.closed
+}
+
+func (this *worker) size() int {
+	this.Lock()
+	defer this.Unlock()
+	return len(this.streams)
+}
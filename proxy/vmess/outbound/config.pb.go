@@ -9,6 +9,7 @@ It is generated from these files:
 	v2ray.com/core/proxy/vmess/outbound/config.proto
 
 It has these top-level messages:
+	MuxConfig
 	Config
 */
 package outbound
@@ -29,14 +30,26 @@ var _ = math.Inf
 // proto package needs to be updated.
 const _ = proto.ProtoPackageIsVersion2 // please upgrade the proto package
 
+type MuxConfig struct {
+	Enabled bool `protobuf:"varint,1,opt,name=enabled" json:"enabled,omitempty"`
+	// Maximum number of streams in one connection.
+	Concurrency uint32 `protobuf:"varint,2,opt,name=concurrency" json:"concurrency,omitempty"`
+}
+
+func (m *MuxConfig) Reset()                    { *m = MuxConfig{} }
+func (m *MuxConfig) String() string            { return proto.CompactTextString(m) }
+func (*MuxConfig) ProtoMessage()               {}
+func (*MuxConfig) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{0} }
+
 type Config struct {
 	Receiver []*v2ray_core_common_protocol1.ServerSpecPB `protobuf:"bytes,1,rep,name=Receiver,json=receiver" json:"Receiver,omitempty"`
+	Mux      *MuxConfig                                  `protobuf:"bytes,2,opt,name=mux" json:"mux,omitempty"`
 }
 
 func (m *Config) Reset()                    { *m = Config{} }
 func (m *Config) String() string            { return proto.CompactTextString(m) }
 func (*Config) ProtoMessage()               {}
-func (*Config) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{0} }
+func (*Config) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{1} }
 
 func (m *Config) GetReceiver() []*v2ray_core_common_protocol1.ServerSpecPB {
 	if m != nil {
@@ -45,25 +58,36 @@ func (m *Config) GetReceiver() []*v2ray_core_common_protocol1.ServerSpecPB {
 	return nil
 }
 
+func (m *Config) GetMux() *MuxConfig {
+	if m != nil {
+		return m.Mux
+	}
+	return nil
+}
+
 func init() {
+	proto.RegisterType((*MuxConfig)(nil), "v2ray.core.proxy.vmess.outbound.MuxConfig")
 	proto.RegisterType((*Config)(nil), "v2ray.core.proxy.vmess.outbound.Config")
 }
 
 func init() { proto.RegisterFile("v2ray.com/core/proxy/vmess/outbound/config.proto", fileDescriptor0) }
 
 var fileDescriptor0 = []byte{
-	// 193 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x09, 0x6e, 0x88, 0x02, 0xff, 0x84, 0xce, 0x31, 0x6b, 0xc3, 0x30,
-	0x10, 0x05, 0x60, 0x4a, 0xc1, 0x18, 0x79, 0xf3, 0x54, 0xba, 0xb4, 0xb4, 0x8b, 0xa7, 0x53, 0x71,
-	0xd7, 0x4e, 0x6e, 0xe6, 0x60, 0xec, 0x2d, 0x4b, 0x88, 0x2f, 0x97, 0x60, 0x88, 0x7c, 0xe2, 0x64,
-	0x8b, 0xf8, 0xdf, 0x87, 0xc8, 0x11, 0x84, 0x2c, 0x59, 0x8f, 0xf7, 0xbe, 0x77, 0xea, 0xc7, 0x97,
-	0xb2, 0x9b, 0x01, 0xd9, 0x68, 0x64, 0x21, 0x6d, 0x85, 0xcf, 0xb3, 0xf6, 0x86, 0x9c, 0xd3, 0x3c,
-	0x8d, 0x1d, 0x4f, 0xc3, 0x5e, 0x23, 0x0f, 0x87, 0xfe, 0x08, 0x56, 0x78, 0xe4, 0xfc, 0x23, 0x36,
-	0x84, 0x20, 0xa4, 0x21, 0xa4, 0x21, 0xa6, 0xdf, 0x1f, 0x49, 0x64, 0x63, 0x78, 0xd0, 0xa1, 0x8d,
-	0x7c, 0xd2, 0x8e, 0xc4, 0x93, 0x6c, 0x9d, 0x25, 0x5c, 0xc8, 0xaf, 0xb5, 0x4a, 0xfe, 0xc3, 0x44,
-	0xbe, 0x52, 0x69, 0x43, 0x48, 0xbd, 0x27, 0x79, 0x7b, 0xf9, 0x7c, 0x2d, 0xb2, 0xb2, 0x80, 0xbb,
-	0xbd, 0x85, 0x82, 0x48, 0x41, 0x1b, 0xa8, 0xd6, 0x12, 0xd6, 0x55, 0x93, 0xca, 0xad, 0x59, 0xfd,
-	0xa9, 0x6f, 0x64, 0x03, 0x4f, 0x1e, 0xad, 0xb2, 0x65, 0xb4, 0xbe, 0x6a, 0x9b, 0x34, 0x9e, 0xbb,
-	0x24, 0xf0, 0xbf, 0x97, 0x00, 0x00, 0x00, 0xff, 0xff, 0x5a, 0x86, 0x34, 0xb5, 0x1b, 0x01, 0x00,
-	0x00,
+	// 253 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x09, 0x6e, 0x88, 0x02, 0xff, 0x84, 0x8f, 0xc1, 0x4a, 0xc3, 0x40,
+	0x10, 0x86, 0x89, 0x85, 0x1a, 0x37, 0x78, 0xc9, 0x29, 0x78, 0x31, 0xd4, 0x4b, 0xf0, 0x30, 0x2b,
+	0xf1, 0xda, 0x53, 0x14, 0x3c, 0x09, 0x25, 0xbd, 0x79, 0x91, 0x66, 0x3a, 0x4a, 0xa1, 0xbb, 0x13,
+	0x26, 0xdd, 0x90, 0xbc, 0x83, 0x0f, 0x2d, 0xdd, 0x74, 0xa5, 0x78, 0xe9, 0x71, 0x86, 0xff, 0xfb,
+	0xe7, 0x1b, 0xf5, 0xd4, 0x97, 0xb2, 0x19, 0x01, 0xd9, 0x68, 0x64, 0x21, 0xdd, 0x0a, 0x0f, 0xa3,
+	0xee, 0x0d, 0x75, 0x9d, 0x66, 0x77, 0x68, 0xd8, 0xd9, 0xad, 0x46, 0xb6, 0x5f, 0xbb, 0x6f, 0x68,
+	0x85, 0x0f, 0x9c, 0xde, 0x07, 0x42, 0x08, 0x7c, 0x1a, 0x7c, 0x1a, 0x42, 0xfa, 0xee, 0x7f, 0x25,
+	0xb2, 0x31, 0x6c, 0xb5, 0xa7, 0x91, 0xf7, 0xba, 0x23, 0xe9, 0x49, 0x3e, 0xbb, 0x96, 0x70, 0xaa,
+	0x5c, 0xbc, 0xa9, 0x9b, 0x77, 0x37, 0xbc, 0xf8, 0x2b, 0x69, 0xa6, 0xae, 0xc9, 0x6e, 0x9a, 0x3d,
+	0x6d, 0xb3, 0x28, 0x8f, 0x8a, 0xb8, 0x0e, 0x63, 0x9a, 0xab, 0x04, 0xd9, 0xa2, 0x13, 0x21, 0x8b,
+	0x63, 0x76, 0x95, 0x47, 0xc5, 0x6d, 0x7d, 0xbe, 0x5a, 0xfc, 0x44, 0x6a, 0x7e, 0xaa, 0x79, 0x55,
+	0x71, 0x4d, 0x48, 0xbb, 0x9e, 0x24, 0x8b, 0xf2, 0x59, 0x91, 0x94, 0x05, 0x9c, 0x99, 0x4f, 0x52,
+	0x10, 0xa4, 0x60, 0xed, 0xa5, 0xd6, 0x2d, 0xe1, 0xaa, 0xaa, 0x63, 0x39, 0x91, 0xe9, 0x52, 0xcd,
+	0x8c, 0x1b, 0xfc, 0xa9, 0xa4, 0x7c, 0x84, 0x0b, 0xaf, 0xc3, 0xdf, 0x17, 0xf5, 0x11, 0xab, 0x96,
+	0xea, 0x01, 0xd9, 0x5c, 0xa2, 0xaa, 0x64, 0x62, 0x56, 0x47, 0x97, 0x8f, 0x38, 0xac, 0x9b, 0xb9,
+	0x97, 0x7b, 0xfe, 0x1d, 0x00, 0xcf, 0xe7, 0x98, 0xf9, 0xa3, 0x01, 0x00, 0x00,
 }
@@ -25,6 +25,7 @@ func (this *Config) UnmarshalJSON(data []byte) error {
 	}
 	type RawOutbound struct {
 		Receivers []*RawConfigTarget `json:"vnext"`
+		Mux       *MuxConfig         `json:"mux"`
 	}
 	rawOutbound := &RawOutbound{}
 	err := json.Unmarshal(data, rawOutbound)
@@ -76,6 +77,7 @@ func (this *Config) UnmarshalJSON(data []byte) error {
 		serverSpecs[idx] = spec
 	}
 	this.Receiver = serverSpecs
+	this.Mux = rawOutbound.Mux
 	return nil
 }
 
@@ -36,3 +36,54 @@ func TestConfigTargetParsing(t *testing.T) {
 	spec := protocol.NewServerSpecFromPB(vmess.NewAccount, *specPB)
 	assert.Destination(spec.Destination()).EqualsString("tcp:127.0.0.1:80")
 }
+
+func TestConfigSecurityParsing(t *testing.T) {
+	assert := assert.On(t)
+
+	rawJson := `{
+    "vnext": [{
+      "address": "127.0.0.1",
+      "port": 80,
+      "users": [
+        {
+          "id": "e641f5ad-9397-41e3-bf1a-e8740dfed019",
+          "security": "aes-128-gcm"
+        }
+      ]
+    }]
+  }`
+
+	config := new(Config)
+	err := json.Unmarshal([]byte(rawJson), &config)
+	assert.Error(err).IsNil()
+	spec := protocol.NewServerSpecFromPB(vmess.NewAccount, *config.Receiver[0])
+	account, err := spec.PickUser().GetTypedAccount(&vmess.AccountPB{})
+	assert.Error(err).IsNil()
+	assert.Byte(byte(account.(*vmess.Account).Security)).Equals(byte(protocol.SecurityTypeAES128GCM))
+}
+
+func TestConfigMuxParsing(t *testing.T) {
+	assert := assert.On(t)
+
+	rawJson := `{
+    "vnext": [{
+      "address": "127.0.0.1",
+      "port": 80,
+      "users": [
+        {
+          "id": "e641f5ad-9397-41e3-bf1a-e8740dfed019"
+        }
+      ]
+    }],
+    "mux": {
+      "enabled": true,
+      "concurrency": 16
+    }
+  }`
+
+	config := new(Config)
+	err := json.Unmarshal([]byte(rawJson), &config)
+	assert.Error(err).IsNil()
+	assert.Bool(config.Mux.Enabled).IsTrue()
+	assert.Uint32(config.Mux.Concurrency).Equals(16)
+}
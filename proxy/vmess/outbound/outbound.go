@@ -15,7 +15,7 @@ import (
 	"v2ray.com/core/proxy/registry"
 	"v2ray.com/core/proxy/vmess"
 	"v2ray.com/core/proxy/vmess/encoding"
-	vmessio "v2ray.com/core/proxy/vmess/io"
+	"v2ray.com/core/proxy/vmess/mux"
 	"v2ray.com/core/transport/internet"
 	"v2ray.com/core/transport/ray"
 )
@@ -24,12 +24,11 @@ type VMessOutboundHandler struct {
 	serverList   *protocol.ServerList
 	serverPicker protocol.ServerPicker
 	meta         *proxy.OutboundHandlerMeta
+	mux          *mux.ClientManager
+	concurrency  int
 }
 
-func (this *VMessOutboundHandler) Dispatch(target v2net.Destination, payload *alloc.Buffer, ray ray.OutboundRay) error {
-	defer ray.OutboundInput().Release()
-	defer ray.OutboundOutput().Close()
-
+func (this *VMessOutboundHandler) openConnection() (*protocol.ServerSpec, internet.Connection, error) {
 	var rec *protocol.ServerSpec
 	var conn internet.Connection
 
@@ -45,22 +44,47 @@ func (this *VMessOutboundHandler) Dispatch(target v2net.Destination, payload *al
 	})
 	if err != nil {
 		log.Error("VMess|Outbound: Failed to find an available destination:", err)
-		return err
+		return nil, nil, err
 	}
-	log.Info("VMess|Outbound: Tunneling request to ", target, " via ", rec.Destination())
+	return rec, conn, nil
+}
 
-	command := protocol.RequestCommandTCP
-	if target.Network == v2net.Network_UDP {
-		command = protocol.RequestCommandUDP
-	}
+// newRequest creates a request to the target for a user of the server.
+func newRequest(user *protocol.User, command protocol.RequestCommand, target v2net.Destination) *protocol.RequestHeader {
 	request := &protocol.RequestHeader{
 		Version: encoding.Version,
-		User:    rec.PickUser(),
+		User:    user,
 		Command: command,
 		Address: target.Address,
 		Port:    target.Port,
 		Option:  protocol.RequestOptionChunkStream,
 	}
+	if account, err := user.GetTypedAccount(&vmess.AccountPB{}); err == nil {
+		request.Security = account.(*vmess.Account).Security
+	}
+	return request
+}
+
+func (this *VMessOutboundHandler) Dispatch(target v2net.Destination, payload *alloc.Buffer, ray ray.OutboundRay) error {
+	defer ray.OutboundInput().Release()
+	defer ray.OutboundOutput().Close()
+
+	if this.mux != nil {
+		log.Info("VMess|Outbound: Tunneling request to ", target, " in a multiplexed connection")
+		return this.mux.Dispatch(target, payload, ray)
+	}
+
+	rec, conn, err := this.openConnection()
+	if err != nil {
+		return err
+	}
+	log.Info("VMess|Outbound: Tunneling request to ", target, " via ", rec.Destination())
+
+	command := protocol.RequestCommandTCP
+	if target.Network == v2net.Network_UDP {
+		command = protocol.RequestCommandUDP
+	}
+	request := newRequest(rec.PickUser(), command, target)
 
 	defer conn.Close()
 
@@ -93,11 +117,7 @@ func (this *VMessOutboundHandler) handleRequest(session *encoding.ClientSession,
 	defer writer.Release()
 	session.EncodeRequestHeader(request, writer)
 
-	bodyWriter := session.EncodeRequestBody(writer)
-	var streamWriter v2io.Writer = v2io.NewAdaptiveWriter(bodyWriter)
-	if request.Option.Has(protocol.RequestOptionChunkStream) {
-		streamWriter = vmessio.NewAuthChunkWriter(streamWriter)
-	}
+	streamWriter := session.EncodeRequestBody(request, writer)
 	if !payload.IsEmpty() {
 		if err := streamWriter.Write(payload); err != nil {
 			conn.SetReusable(false)
@@ -139,15 +159,7 @@ func (this *VMessOutboundHandler) handleResponse(session *encoding.ClientSession
 	}
 
 	reader.SetCached(false)
-	decryptReader := session.DecodeResponseBody(reader)
-
-	var bodyReader v2io.Reader
-	if request.Option.Has(protocol.RequestOptionChunkStream) {
-		bodyReader = vmessio.NewAuthChunkReader(decryptReader)
-	} else {
-		bodyReader = v2io.NewAdaptiveReader(decryptReader)
-	}
-
+	bodyReader := session.DecodeResponseBody(request, reader)
 	err = v2io.Pipe(bodyReader, output)
 	if err != io.EOF {
 		conn.SetReusable(false)
@@ -158,6 +170,55 @@ func (this *VMessOutboundHandler) handleResponse(session *encoding.ClientSession
 	return
 }
 
+// dialMux opens a connection that carries multiple streams.
+func (this *VMessOutboundHandler) dialMux() (*mux.Client, error) {
+	rec, conn, err := this.openConnection()
+	if err != nil {
+		return nil, err
+	}
+	log.Info("VMess|Outbound: Opening multiplexed connection to ", rec.Destination())
+
+	conn.SetReusable(false)
+	request := newRequest(rec.PickUser(), protocol.RequestCommandMux, v2net.TCPDestination(v2net.AnyIP, 0))
+	session := encoding.NewClientSession(protocol.DefaultIDHash)
+
+	writer := v2io.NewBufferedWriter(conn)
+	session.EncodeRequestHeader(request, writer)
+	bodyWriter := session.EncodeRequestBody(request, writer)
+	if err := writer.Flush(); err != nil {
+		log.Warning("VMess|Outbound: Failed to write request to ", rec.Destination(), ": ", err)
+		bodyWriter.Release()
+		writer.Release()
+		conn.Close()
+		return nil, err
+	}
+	writer.SetCached(false)
+
+	client := mux.NewClient(bodyWriter, this.concurrency, func() {
+		bodyWriter.Write(alloc.NewLocalBuffer(32).Clear())
+		bodyWriter.Release()
+		writer.Release()
+		conn.Close()
+	})
+
+	go func() {
+		reader := v2io.NewBufferedReader(conn)
+		defer reader.Release()
+
+		if _, err := session.DecodeResponseHeader(reader); err != nil {
+			log.Warning("VMess|Outbound: Failed to read response from ", rec.Destination(), ": ", err)
+			client.Close()
+			return
+		}
+		reader.SetCached(false)
+		bodyReader := session.DecodeResponseBody(request, reader)
+		client.Run(bodyReader)
+		bodyReader.Release()
+	}()
+
+	return client, nil
+}
+
 type Factory struct{}
 
 func (this *Factory) StreamCapability() v2net.NetworkList {
@@ -178,6 +239,10 @@ func (this *Factory) Create(space app.Space, rawConfig interface{}, meta *proxy.
 		serverPicker: protocol.NewRoundRobinServerPicker(serverList),
 		meta:         meta,
 	}
+	if vOutConfig.Mux != nil && vOutConfig.Mux.Enabled {
+		handler.concurrency = int(vOutConfig.Mux.Concurrency)
+		handler.mux = mux.NewClientManager(handler.dialMux)
+	}
 
 	return handler, nil
 }
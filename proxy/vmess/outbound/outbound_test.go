@@ -0,0 +1,159 @@
+package outbound_test
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"v2ray.com/core/app"
+	"v2ray.com/core/app/dispatcher"
+	dispatchers "v2ray.com/core/app/dispatcher/impl"
+	"v2ray.com/core/app/proxyman"
+	"v2ray.com/core/common/alloc"
+	"v2ray.com/core/common/dice"
+	v2net "v2ray.com/core/common/net"
+	"v2ray.com/core/common/protocol"
+	"v2ray.com/core/common/uuid"
+	"v2ray.com/core/proxy"
+	"v2ray.com/core/proxy/freedom"
+	"v2ray.com/core/proxy/vmess"
+	"v2ray.com/core/proxy/vmess/inbound"
+	. "v2ray.com/core/proxy/vmess/outbound"
+	"v2ray.com/core/testing/assert"
+	"v2ray.com/core/testing/servers/tcp"
+	"v2ray.com/core/transport/internet"
+	"v2ray.com/core/transport/ray"
+
+	"github.com/golang/protobuf/ptypes"
+)
+
+func newUser(assert *assert.Assert, id string, security vmess.SecurityType) *protocol.User {
+	account, err := ptypes.MarshalAny(&vmess.AccountPB{
+		Id:       id,
+		Security: security,
+	})
+	assert.Error(err).IsNil()
+	return &protocol.User{
+		Email:   "love@v2ray.com",
+		Account: account,
+	}
+}
+
+// startVMessServer starts a VMess inbound that sends traffic out directly.
+func startVMessServer(assert *assert.Assert, id string) v2net.Port {
+	space := app.NewSpace()
+	space.BindApp(dispatcher.APP_ID, dispatchers.NewDefaultDispatcher(space))
+	ohm := proxyman.NewDefaultOutboundHandlerManager()
+	ohm.SetDefaultHandler(
+		freedom.NewFreedomConnection(
+			&freedom.Config{},
+			space,
+			&proxy.OutboundHandlerMeta{
+				Address: v2net.AnyIP,
+				StreamSettings: &internet.StreamConfig{
+					Network: v2net.Network_RawTCP,
+				},
+			}))
+	space.BindApp(proxyman.APP_ID_OUTBOUND_MANAGER, ohm)
+
+	port := v2net.Port(dice.Roll(20000) + 10000)
+	server, err := new(inbound.Factory).Create(space, &inbound.Config{
+		User:    []*protocol.User{newUser(assert, id, vmess.SecurityType_LEGACY)},
+		Default: &inbound.DefaultConfig{},
+	}, &proxy.InboundHandlerMeta{
+		Address: v2net.LocalHostIP,
+		Port:    port,
+		StreamSettings: &internet.StreamConfig{
+			Network: v2net.Network_TCP,
+		},
+	})
+	assert.Error(err).IsNil()
+	assert.Error(space.Initialize()).IsNil()
+	assert.Error(server.Start()).IsNil()
+	return port
+}
+
+func newTestClient(assert *assert.Assert, port v2net.Port, user *protocol.User, mux *MuxConfig) proxy.OutboundHandler {
+	client, err := new(Factory).Create(app.NewSpace(), &Config{
+		Receiver: []*protocol.ServerSpecPB{{
+			Address: v2net.NewAddressPB(v2net.LocalHostIP),
+			Port:    uint32(port),
+			User:    []*protocol.User{user},
+		}},
+		Mux: mux,
+	}, &proxy.OutboundHandlerMeta{
+		Address: v2net.AnyIP,
+		StreamSettings: &internet.StreamConfig{
+			Network: v2net.Network_TCP,
+		},
+	})
+	assert.Error(err).IsNil()
+	return client
+}
+
+func testTraffic(assert *assert.Assert, client proxy.OutboundHandler, dest v2net.Destination, data string) {
+	traffic := ray.NewRay()
+	payload := alloc.NewLocalBuffer(2048).Clear().Append([]byte(data))
+	go client.Dispatch(dest, payload, traffic)
+	traffic.InboundInput().Close()
+
+	response, err := traffic.InboundOutput().Read()
+	assert.Error(err).IsNil()
+	assert.String(response.String()).Equals("Processed: " + data)
+}
+
+func TestVMessSecurity(t *testing.T) {
+	assert := assert.On(t)
+
+	tcpServer := &tcp.Server{
+		MsgProcessor: func(data []byte) []byte {
+			return append([]byte("Processed: "), data...)
+		},
+	}
+	dest, err := tcpServer.Start()
+	assert.Error(err).IsNil()
+	defer tcpServer.Close()
+
+	id := uuid.New().String()
+	port := startVMessServer(assert, id)
+
+	for _, security := range []vmess.SecurityType{
+		vmess.SecurityType_LEGACY,
+		vmess.SecurityType_AES128_GCM,
+		vmess.SecurityType_CHACHA20_POLY1305,
+		vmess.SecurityType_NONE,
+	} {
+		client := newTestClient(assert, port, newUser(assert, id, security), nil)
+		testTraffic(assert, client, dest, "Data to be sent to remote with "+security.String())
+	}
+}
+
+func TestVMessMux(t *testing.T) {
+	assert := assert.On(t)
+
+	tcpServer := &tcp.Server{
+		MsgProcessor: func(data []byte) []byte {
+			return append([]byte("Processed: "), data...)
+		},
+	}
+	dest, err := tcpServer.Start()
+	assert.Error(err).IsNil()
+	defer tcpServer.Close()
+
+	id := uuid.New().String()
+	port := startVMessServer(assert, id)
+	client := newTestClient(assert, port, newUser(assert, id, vmess.SecurityType_AES128_GCM), &MuxConfig{
+		Enabled:     true,
+		Concurrency: 4,
+	})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			testTraffic(assert, client, dest, fmt.Sprint("Data of stream ", i))
+		}(i)
+	}
+	wg.Wait()
+}
@@ -20,7 +20,7 @@ const (
 
 type idEntry struct {
 	id             *protocol.ID
-	userIdx        int
+	user           *protocol.User
 	lastSec        protocol.Timestamp
 	lastSecRemoval protocol.Timestamp
 }
@@ -29,21 +29,21 @@ type TimedUserValidator struct {
 	sync.RWMutex
 	running    bool
 	validUsers []*protocol.User
-	userHash   map[[16]byte]*indexTimePair
+	userHash   map[[16]byte]*userTimePair
 	ids        []*idEntry
 	hasher     protocol.IDHash
 	cancel     *signal.CancelSignal
 }
 
-type indexTimePair struct {
-	index   int
+type userTimePair struct {
+	user    *protocol.User
 	timeSec protocol.Timestamp
 }
 
 func NewTimedUserValidator(hasher protocol.IDHash) protocol.UserValidator {
 	tus := &TimedUserValidator{
 		validUsers: make([]*protocol.User, 0, 16),
-		userHash:   make(map[[16]byte]*indexTimePair, 512),
+		userHash:   make(map[[16]byte]*userTimePair, 512),
 		ids:        make([]*idEntry, 0, 512),
 		hasher:     hasher,
 		running:    true,
@@ -76,7 +76,8 @@ func (this *TimedUserValidator) Release() {
 	this.cancel = nil
 }
 
-func (this *TimedUserValidator) generateNewHashes(nowSec protocol.Timestamp, idx int, entry *idEntry) {
+// generateNewHashes must be called with the lock held.
+func (this *TimedUserValidator) generateNewHashes(nowSec protocol.Timestamp, entry *idEntry) {
 	var hashValue [16]byte
 	var hashValueRemoval [16]byte
 	idHash := this.hasher(entry.id.Bytes())
@@ -89,10 +90,8 @@ func (this *TimedUserValidator) generateNewHashes(nowSec protocol.Timestamp, idx
 		idHash.Sum(hashValueRemoval[:0])
 		idHash.Reset()
 
-		this.Lock()
-		this.userHash[hashValue] = &indexTimePair{idx, entry.lastSec}
+		this.userHash[hashValue] = &userTimePair{entry.user, entry.lastSec}
 		delete(this.userHash, hashValueRemoval)
-		this.Unlock()
 
 		entry.lastSec++
 		entry.lastSecRemoval++
@@ -105,9 +104,11 @@ L:
 		select {
 		case now := <-time.After(interval):
 			nowSec := protocol.Timestamp(now.Unix() + cacheDurationSec)
+			this.Lock()
 			for _, entry := range this.ids {
-				this.generateNewHashes(nowSec, entry.userIdx, entry)
+				this.generateNewHashes(nowSec, entry)
 			}
+			this.Unlock()
 		case <-this.cancel.WaitForCancel():
 			break L
 		}
@@ -115,39 +116,91 @@ L:
 	this.cancel.Done()
 }
 
+// Add adds a user. Existing users are kept as is, even if they have the same email.
 func (this *TimedUserValidator) Add(user *protocol.User) error {
-	idx := len(this.validUsers)
-	this.validUsers = append(this.validUsers, user)
 	rawAccount, err := user.GetTypedAccount(&AccountPB{})
 	if err != nil {
 		return err
 	}
 	account := rawAccount.(*Account)
 
-	nowSec := time.Now().Unix()
+	this.Lock()
+	defer this.Unlock()
 
-	entry := &idEntry{
-		id:             account.ID,
-		userIdx:        idx,
-		lastSec:        protocol.Timestamp(nowSec - cacheDurationSec),
-		lastSecRemoval: protocol.Timestamp(nowSec - cacheDurationSec*3),
+	if !this.running {
+		return protocol.ErrValidatorReleased
 	}
-	this.generateNewHashes(protocol.Timestamp(nowSec+cacheDurationSec), idx, entry)
-	this.ids = append(this.ids, entry)
-	for _, alterid := range account.AlterIDs {
+	this.validUsers = append(this.validUsers, user)
+
+	nowSec := time.Now().Unix()
+
+	ids := make([]*protocol.ID, 0, 1+len(account.AlterIDs))
+	ids = append(ids, account.ID)
+	ids = append(ids, account.AlterIDs...)
+	for _, id := range ids {
 		entry := &idEntry{
-			id:             alterid,
-			userIdx:        idx,
+			id:             id,
+			user:           user,
 			lastSec:        protocol.Timestamp(nowSec - cacheDurationSec),
 			lastSecRemoval: protocol.Timestamp(nowSec - cacheDurationSec*3),
 		}
-		this.generateNewHashes(protocol.Timestamp(nowSec+cacheDurationSec), idx, entry)
+		this.generateNewHashes(protocol.Timestamp(nowSec+cacheDurationSec), entry)
 		this.ids = append(this.ids, entry)
 	}
 
 	return nil
 }
 
+// Remove removes all users of the email, along with their IDs. It returns false if there is no such user. Users
+// without email are never removed.
+func (this *TimedUserValidator) Remove(email string) bool {
+	if len(email) == 0 {
+		return false
+	}
+
+	this.Lock()
+	defer this.Unlock()
+
+	if !this.running {
+		return false
+	}
+
+	removed := make(map[*protocol.User]bool)
+	users := this.validUsers[:0]
+	for _, user := range this.validUsers {
+		if user.Email == email {
+			removed[user] = true
+			continue
+		}
+		users = append(users, user)
+	}
+	if len(removed) == 0 {
+		return false
+	}
+	for i := len(users); i < len(this.validUsers); i++ {
+		this.validUsers[i] = nil
+	}
+	this.validUsers = users
+
+	ids := this.ids[:0]
+	for _, entry := range this.ids {
+		if !removed[entry.user] {
+			ids = append(ids, entry)
+		}
+	}
+	for i := len(ids); i < len(this.ids); i++ {
+		this.ids[i] = nil
+	}
+	this.ids = ids
+
+	for hash, pair := range this.userHash {
+		if removed[pair.user] {
+			delete(this.userHash, hash)
+		}
+	}
+	return true
+}
+
 func (this *TimedUserValidator) Get(userHash []byte) (*protocol.User, protocol.Timestamp, bool) {
 	defer this.RUnlock()
 	this.RLock()
@@ -159,7 +212,7 @@ func (this *TimedUserValidator) Get(userHash []byte) (*protocol.User, protocol.T
 	copy(fixedSizeHash[:], userHash)
 	pair, found := this.userHash[fixedSizeHash]
 	if found {
-		return this.validUsers[pair.index], pair.timeSec, true
+		return pair.user, pair.timeSec, true
 	}
 	return nil, 0, false
 }
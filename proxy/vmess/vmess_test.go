@@ -0,0 +1,160 @@
+package vmess_test
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"v2ray.com/core/common/protocol"
+	"v2ray.com/core/common/uuid"
+	. "v2ray.com/core/proxy/vmess"
+	"v2ray.com/core/testing/assert"
+
+	"github.com/golang/protobuf/ptypes"
+)
+
+func newUser(assert *assert.Assert, email string, alterID uint32) *protocol.User {
+	account, err := ptypes.MarshalAny(&AccountPB{
+		Id:      uuid.New().String(),
+		AlterId: alterID,
+	})
+	assert.Error(err).IsNil()
+	return &protocol.User{
+		Email:   email,
+		Account: account,
+	}
+}
+
+func hashesOf(assert *assert.Assert, user *protocol.User) [][]byte {
+	rawAccount, err := user.GetTypedAccount(&AccountPB{})
+	assert.Error(err).IsNil()
+	account := rawAccount.(*Account)
+
+	timestamp := protocol.Timestamp(time.Now().Unix())
+	hashes := make([][]byte, 0, 1+len(account.AlterIDs))
+	for _, id := range append([]*protocol.ID{account.ID}, account.AlterIDs...) {
+		idHash := protocol.DefaultIDHash(id.Bytes())
+		idHash.Write(timestamp.Bytes(nil))
+		hashes = append(hashes, idHash.Sum(nil))
+	}
+	return hashes
+}
+
+func TestUserValidatorRemove(t *testing.T) {
+	assert := assert.On(t)
+
+	validator := NewTimedUserValidator(protocol.DefaultIDHash)
+	defer validator.Release()
+
+	user1 := newUser(assert, "user1@v2ray.com", 2)
+	user2 := newUser(assert, "user2@v2ray.com", 0)
+	assert.Error(validator.Add(user1)).IsNil()
+	assert.Error(validator.Add(user2)).IsNil()
+
+	for _, hash := range hashesOf(assert, user1) {
+		user, _, found := validator.Get(hash)
+		assert.Bool(found).IsTrue()
+		assert.String(user.Email).Equals(user1.Email)
+	}
+
+	assert.Bool(validator.Remove(user1.Email)).IsTrue()
+	assert.Bool(validator.Remove(user1.Email)).IsFalse()
+
+	for _, hash := range hashesOf(assert, user1) {
+		_, _, found := validator.Get(hash)
+		assert.Bool(found).IsFalse()
+	}
+	user, _, found := validator.Get(hashesOf(assert, user2)[0])
+	assert.Bool(found).IsTrue()
+	assert.String(user.Email).Equals(user2.Email)
+}
+
+func TestUserValidatorDuplicateEmail(t *testing.T) {
+	assert := assert.On(t)
+
+	validator := NewTimedUserValidator(protocol.DefaultIDHash)
+	defer validator.Release()
+
+	user1 := newUser(assert, "user@v2ray.com", 1)
+	user2 := newUser(assert, "user@v2ray.com", 1)
+	assert.Error(validator.Add(user1)).IsNil()
+	assert.Error(validator.Add(user2)).IsNil()
+
+	for _, expected := range []*protocol.User{user1, user2} {
+		for _, hash := range hashesOf(assert, expected) {
+			user, _, found := validator.Get(hash)
+			assert.Bool(found).IsTrue()
+			assert.Bool(user == expected).IsTrue()
+		}
+	}
+
+	assert.Bool(validator.Remove("user@v2ray.com")).IsTrue()
+	for _, removed := range []*protocol.User{user1, user2} {
+		for _, hash := range hashesOf(assert, removed) {
+			_, _, found := validator.Get(hash)
+			assert.Bool(found).IsFalse()
+		}
+	}
+}
+
+func TestUserValidatorRemoveEmptyEmail(t *testing.T) {
+	assert := assert.On(t)
+
+	validator := NewTimedUserValidator(protocol.DefaultIDHash)
+	defer validator.Release()
+
+	user := newUser(assert, "", 0)
+	assert.Error(validator.Add(user)).IsNil()
+	assert.Bool(validator.Remove("")).IsFalse()
+
+	_, _, found := validator.Get(hashesOf(assert, user)[0])
+	assert.Bool(found).IsTrue()
+}
+
+func TestUserValidatorAddAfterRelease(t *testing.T) {
+	assert := assert.On(t)
+
+	validator := NewTimedUserValidator(protocol.DefaultIDHash)
+	validator.Release()
+
+	assert.Error(validator.Add(newUser(assert, "user@v2ray.com", 0))).Equals(protocol.ErrValidatorReleased)
+}
+
+func TestUserValidatorConcurrentAccess(t *testing.T) {
+	assert := assert.On(t)
+
+	validator := NewTimedUserValidator(protocol.DefaultIDHash)
+	defer validator.Release()
+
+	stable := newUser(assert, "stable@v2ray.com", 0)
+	assert.Error(validator.Add(stable)).IsNil()
+	stableHash := hashesOf(assert, stable)[0]
+
+	var wg sync.WaitGroup
+	done := make(chan bool)
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				_, _, found := validator.Get(stableHash)
+				assert.Bool(found).IsTrue()
+			}
+		}()
+	}
+
+	for i := 0; i < 50; i++ {
+		user := newUser(assert, "changing@v2ray.com", 4)
+		assert.Error(validator.Add(user)).IsNil()
+		if i%2 == 0 {
+			assert.Bool(validator.Remove(user.Email)).IsTrue()
+		}
+	}
+	close(done)
+	wg.Wait()
+}
@@ -171,6 +171,9 @@ func (this *Point) Close() {
 	for _, idh := range this.idh {
 		idh.Close()
 	}
+	if this.space.HasApp(dns.APP_ID) {
+		this.space.GetApp(dns.APP_ID).Release()
+	}
 }
 
 // Start starts the Point server, and return any error during the process.